@@ -49,8 +49,24 @@ func start(_ *cobra.Command, _ []string) {
 	apartmentRepo := repositories.NewApartmentRepository(cfg.Postgres.AutoCreate, db)
 	userApartmentRepo := repositories.NewUserApartmentRepository(cfg.Postgres.AutoCreate, db)
 	inviteLinkRepo := repositories.NewInvitationLinkRepository(redisClient, "invite_salt")
+	idempotencyRepo := repositories.NewIdempotencyRepository(redisClient, cfg.Idempotency.TTL, cfg.Idempotency.LockTimeout)
 	billRepo := repositories.NewBillRepository(cfg.Postgres.AutoCreate, db)
+	approvalRepo := repositories.NewApprovalRepository(cfg.Postgres.AutoCreate, db)
+	reserveFundRepo := repositories.NewReserveFundRepository(cfg.Postgres.AutoCreate, db)
+	budgetRepo := repositories.NewBudgetRepository(cfg.Postgres.AutoCreate, db)
+	statementRepo := repositories.NewStatementRepository(cfg.Postgres.AutoCreate, db)
+	statsRepo := repositories.NewStatsRepository(db)
 	paymentRepo := repositories.NewPaymentRepository(cfg.Postgres.AutoCreate, db)
+	splitPolicyRepo := repositories.NewSplitPolicyRepository(cfg.Postgres.AutoCreate, db)
+	categoryRepo := repositories.NewBillCategoryRepository(cfg.Postgres.AutoCreate, db)
+	meterRepo := repositories.NewMeterRepository(cfg.Postgres.AutoCreate, db)
+	recurringBillRepo := repositories.NewRecurringBillRepository(cfg.Postgres.AutoCreate, db)
+	lateFeePolicyRepo := repositories.NewLateFeePolicyRepository(cfg.Postgres.AutoCreate, db)
+	installmentRepo := repositories.NewInstallmentRepository(cfg.Postgres.AutoCreate, db)
+	paymentTransactionRepo := repositories.NewPaymentTransactionRepository(cfg.Postgres.AutoCreate, db)
+	walletRepo := repositories.NewWalletRepository(cfg.Postgres.AutoCreate, db)
+	ledgerRepo := repositories.NewLedgerRepository(cfg.Postgres.AutoCreate, db)
+	disputeRepo := repositories.NewDisputeRepository(cfg.Postgres.AutoCreate, db)
 
 	notificationService := notification.NewNotification(
 		cfg.TelegramConfig,
@@ -58,7 +74,10 @@ func start(_ *cobra.Command, _ []string) {
 	)
 
 	imageService := image.NewImage(cfg.Minio.Endpoint, cfg.Minio.AccessKey, cfg.Minio.SecretKey, cfg.Minio.Bucket)
-	paymentService := payment.NewPayment(redisClient)
+	if cfg.Payment.Gateway != payment.SimulatorName {
+		log.Fatalf("unsupported payment gateway: %q", cfg.Payment.Gateway)
+	}
+	paymentGateway := payment.NewSimulator(cfg.Payment.PublicURL, cfg.Payment.Secret, cfg.Payment.CheckoutTimeout)
 	httpService := myhttp.NewApartmantService(
 		cfg,
 		db,
@@ -70,9 +89,25 @@ func start(_ *cobra.Command, _ []string) {
 		inviteLinkRepo,
 		notificationService,
 		billRepo,
+		approvalRepo,
 		imageService,
 		paymentRepo,
-		paymentService,
+		paymentGateway,
+		splitPolicyRepo,
+		categoryRepo,
+		meterRepo,
+		recurringBillRepo,
+		lateFeePolicyRepo,
+		installmentRepo,
+		paymentTransactionRepo,
+		walletRepo,
+		ledgerRepo,
+		disputeRepo,
+		reserveFundRepo,
+		budgetRepo,
+		statementRepo,
+		statsRepo,
+		idempotencyRepo,
 	)
 
 	if err := httpService.Start("Apartment Service"); err != nil {
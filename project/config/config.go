@@ -13,6 +13,8 @@ type Config struct {
 	Minio          Minio          `yaml:"minio"`
 	Redis          Redis          `yaml:"redis"`
 	TelegramConfig TelegramConfig `yaml:"telegram_config"`
+	Payment        Payment        `yaml:"payment"`
+	Idempotency    Idempotency    `yaml:"idempotency"`
 }
 
 type Server struct {
@@ -48,6 +50,18 @@ type TelegramConfig struct {
 	BotAddress string        `yaml:"bot_address"`
 }
 
+type Payment struct {
+	Gateway         string        `yaml:"gateway"`
+	PublicURL       string        `yaml:"public_url"` // where residents and the gateway reach this service
+	Secret          string        `yaml:"secret"`     // shared with the gateway to sign callbacks
+	CheckoutTimeout time.Duration `yaml:"checkout_timeout"`
+}
+
+type Idempotency struct {
+	TTL         time.Duration `yaml:"ttl"`          // how long responses are replayed for retries
+	LockTimeout time.Duration `yaml:"lock_timeout"` // how long a key stays claimed by a request that never finishes
+}
+
 func InitConfig(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -0,0 +1,13 @@
+package dto
+
+import "github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+
+type ResidentSharesRequest struct {
+	UnitArea        float64 `json:"unit_area"`
+	OccupantsCount  int     `json:"occupants_count"`
+	SharePercentage float64 `json:"share_percentage"`
+}
+
+type ShareVisibilityRequest struct {
+	ShareVisibility models.ShareVisibility `json:"share_visibility"`
+}
@@ -1,15 +1,144 @@
 package dto
 
-import "github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+import (
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
 
 type CreateBillRequest struct {
-	BillType        models.BillType `json:"bill_type"`
-	TotalAmount     float64         `json:"total_amount"`
-	DueDate         string          `json:"due_date"`
-	BillingDeadline string          `json:"billing_deadline"`
-	Description     string          `json:"description"`
+	BillType        models.BillType   `json:"bill_type"`
+	TotalAmount     money.Amount      `json:"total_amount"`
+	Currency        money.Currency    `json:"currency"`
+	DueDate         string            `json:"due_date"`
+	BillingDeadline string            `json:"billing_deadline"`
+	PeriodStart     string            `json:"period_start"` // YYYY-MM-DD, optional billing period used for pro-rating
+	PeriodEnd       string            `json:"period_end"`
+	Description     string            `json:"description"`
+	LineItems       []LineItemRequest `json:"line_items"` // optional, the total amount is their sum
+}
+
+// one component of an itemized bill, the split strategy defaults to the bill's split policy
+type LineItemRequest struct {
+	Name          string                  `json:"name"`
+	Category      models.LineItemCategory `json:"category"`
+	Amount        money.Amount            `json:"amount"`
+	SplitStrategy models.SplitStrategy    `json:"split_strategy"`
 }
 
 type PayBillsRequest struct {
 	BillIDs []int `json:"bill_ids"`
 }
+
+type SplitPolicyRequest struct {
+	BillType         models.BillType      `json:"bill_type"` // empty sets the apartment-wide default
+	Strategy         models.SplitStrategy `json:"strategy"`
+	EqualWeight      float64              `json:"equal_weight"`
+	AreaWeight       float64              `json:"area_weight"`
+	OccupantsWeight  float64              `json:"occupants_weight"`
+	PercentageWeight float64              `json:"percentage_weight"`
+}
+
+// an empty split strategy leaves the bill type to the split policies, a zero due-day offset
+// keeps the due date required when creating bills
+type BillCategoryRequest struct {
+	Name                 models.BillType      `json:"name"`
+	Icon                 string               `json:"icon"`
+	DefaultSplitStrategy models.SplitStrategy `json:"default_split_strategy"`
+	DueAfterDays         int                  `json:"due_after_days"`
+}
+
+// an annual budget for a bill category. the annual amount is spread evenly over the months unless
+// monthly gives all twelve, January first
+type BudgetRequest struct {
+	Category     models.BillType `json:"category"`
+	Year         int             `json:"year"`
+	Currency     money.Currency  `json:"currency"` // defaults to IRR
+	AnnualAmount money.Amount    `json:"annual_amount"`
+	Monthly      []money.Amount  `json:"monthly"`
+}
+
+// both dates are YYYY-MM-DD and inclusive. to defaults to today and from to the first of its month
+type StatementPeriod struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// bills at or above the threshold need a second approver, the currency defaults to IRR
+type ApprovalPolicyRequest struct {
+	Threshold        money.Amount   `json:"threshold"`
+	Currency         money.Currency `json:"currency"`
+	RepresentativeID *int           `json:"representative_id"`
+}
+
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment"` // required to reject
+}
+
+type RecurringBillRequest struct {
+	BillType       models.BillType `json:"bill_type"`
+	Amount         money.Amount    `json:"amount"`
+	Currency       money.Currency  `json:"currency"`
+	Description    string          `json:"description"`
+	DayOfMonth     int             `json:"day_of_month"`
+	IntervalMonths int             `json:"interval_months"` // defaults to monthly
+	DueAfterDays   int             `json:"due_after_days"`
+	StartMonth     string          `json:"start_month"` // YYYY-MM, defaults to the current month
+	AutoDivide     bool            `json:"auto_divide"`
+	Active         *bool           `json:"active"` // defaults to true
+}
+
+type LateFeePolicyRequest struct {
+	FeeType    models.LateFeeType `json:"fee_type"`
+	FlatAmount money.Amount       `json:"flat_amount"`
+	Percentage float64            `json:"percentage"`
+	DailyRate  float64            `json:"daily_rate"` // percent of the share per overdue day
+	Cap        money.Amount       `json:"cap"`        // zero means no cap
+	GraceDays  int                `json:"grace_days"`
+}
+
+type PartialPaymentRequest struct {
+	Amount money.Amount `json:"amount"`
+}
+
+type InstallmentPlanRequest struct {
+	DueDates []string       `json:"due_dates"` // YYYY-MM-DD, one per installment
+	Amounts  []money.Amount `json:"amounts"`   // optional, the outstanding amount is split evenly when empty
+}
+
+type WalletTopUpRequest struct {
+	Amount money.Amount `json:"amount"`
+}
+
+type WalletAutoPayRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// a manager correction, positive credits the resident and negative debits them
+type WalletAdjustmentRequest struct {
+	Amount money.Amount `json:"amount"`
+	Note   string       `json:"note"`
+}
+
+type WriteOffRequest struct {
+	Note string `json:"note"`
+}
+
+// the amount defaults to everything still refundable on the payment
+type OpenDisputeRequest struct {
+	Amount money.Amount `json:"amount"`
+	Reason string       `json:"reason"`
+}
+
+// refund_to is "gateway" or "wallet", left empty the refund goes back the way the resident paid
+type ResolveDisputeRequest struct {
+	RefundTo models.RefundMethod `json:"refund_to"`
+	Note     string              `json:"note"`
+}
+
+// sent as a multipart form along with the receipt
+type CapitalExpenseRequest struct {
+	Amount      money.Amount   `json:"amount"`
+	Currency    money.Currency `json:"currency"` // defaults to IRR
+	Description string         `json:"description"`
+	SpentAt     string         `json:"spent_at"` // YYYY-MM-DD, defaults to now
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+
+type RegisterMeterRequest struct {
+	UserID       int             `json:"user_id"`
+	MeterType    models.BillType `json:"meter_type"`
+	SerialNumber string          `json:"serial_number"`
+}
+
+type SubmitReadingRequest struct {
+	Value  float64 `json:"value"`
+	ReadAt string  `json:"read_at"` // YYYY-MM-DD, defaults to now
+}
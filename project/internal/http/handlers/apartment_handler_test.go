@@ -433,7 +433,7 @@ func TestLeaveApartment(t *testing.T) {
 			queryParam: "apartment_id=1",
 			userID:     "1",
 			mockSetup: func(userAptRepo *repositories.MockUserApartmentRepository) {
-				userAptRepo.On("DeleteUserApartment", 1, 1).Return(nil)
+				userAptRepo.On("LeaveApartment", mock.Anything, 1, 1).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 		},
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+)
+
+type ApprovalHandler struct {
+	approvalService services.ApprovalService
+}
+
+func NewApprovalHandler(approvalService services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: approvalService,
+	}
+}
+
+func (h *ApprovalHandler) SetApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.ApprovalPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	policy, err := h.approvalService.SetApprovalPolicy(r.Context(), userID, apartmentID, req)
+	if err != nil {
+		http.Error(w, "Failed to set approval policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *ApprovalHandler) GetApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	policy, err := h.approvalService.GetApprovalPolicy(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get approval policy: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *ApprovalHandler) DeleteApprovalPolicy(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	if err := h.approvalService.DeleteApprovalPolicy(r.Context(), userID, apartmentID); err != nil {
+		http.Error(w, "Failed to delete approval policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ApprovalHandler) GetPendingApprovals(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	pending, err := h.approvalService.GetPendingApprovals(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get pending approvals: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pending)
+}
+
+func (h *ApprovalHandler) GetBillApprovals(w http.ResponseWriter, r *http.Request) {
+	billID, err := strconv.Atoi(r.PathValue("bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	approvals, err := h.approvalService.GetBillApprovals(r.Context(), userID, billID)
+	if err != nil {
+		http.Error(w, "Failed to get bill approvals: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+func (h *ApprovalHandler) ApproveBill(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, models.BillApproved)
+}
+
+func (h *ApprovalHandler) RejectBill(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, models.BillRejected)
+}
+
+// the comment is optional when approving, so an empty body is accepted
+func (h *ApprovalHandler) decide(w http.ResponseWriter, r *http.Request, decision models.ApprovalDecision) {
+	billID, err := strconv.Atoi(r.PathValue("bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.ApprovalDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	var status models.BillStatus
+	if decision == models.BillApproved {
+		status, err = h.approvalService.ApproveBill(r.Context(), userID, billID, req.Comment)
+	} else {
+		status, err = h.approvalService.RejectBill(r.Context(), userID, billID, req.Comment)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, repositories.ErrBillNotPendingApproval) || errors.Is(err, repositories.ErrInvalidBillTransition) {
+			status = http.StatusConflict
+		}
+		http.Error(w, "Failed to decide on bill: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bill_id":  billID,
+		"decision": decision,
+		"status":   status,
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
 	"github.com/sirupsen/logrus"
 )
@@ -47,9 +50,25 @@ func (h *BillHandler) CreateBill(w http.ResponseWriter, r *http.Request) {
 
 	var req dto.CreateBillRequest
 	req.BillType = models.BillType(r.FormValue("bill_type"))
-	req.TotalAmount, _ = strconv.ParseFloat(r.FormValue("total_amount"), 64)
+	// line items come as a json array, an itemized bill can leave out the total
+	if lineItems := r.FormValue("line_items"); lineItems != "" {
+		if err := json.Unmarshal([]byte(lineItems), &req.LineItems); err != nil {
+			http.Error(w, "Invalid line items", http.StatusBadRequest)
+			return
+		}
+	}
+	if totalAmount := r.FormValue("total_amount"); totalAmount != "" || len(req.LineItems) == 0 {
+		req.TotalAmount, err = money.Parse(totalAmount)
+		if err != nil {
+			http.Error(w, "Invalid total amount", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Currency = money.Currency(r.FormValue("currency"))
 	req.DueDate = r.FormValue("due_date")
 	req.BillingDeadline = r.FormValue("billing_deadline")
+	req.PeriodStart = r.FormValue("period_start")
+	req.PeriodEnd = r.FormValue("period_end")
 	req.Description = r.FormValue("description")
 
 	file, handler, _ := r.FormFile("bill_image")
@@ -82,21 +101,16 @@ func (h *BillHandler) DivideBillByType(w http.ResponseWriter, r *http.Request) {
 	}
 	userID, _ := strconv.Atoi(userIDString)
 
-	validBillTypes := map[string]models.BillType{
-		"water":       models.WaterBill,
-		"electricity": models.ElectricityBill,
-		"gas":         models.GasBill,
-		"maintenance": models.MaintenanceBill,
-		"other":       models.OtherBill,
-	}
-
-	billType, valid := validBillTypes[billTypeStr]
-	if !valid {
-		http.Error(w, "Invalid bill type. Valid types: water, electricity, gas, maintenance, other", http.StatusBadRequest)
+	// apartments define their own categories, so any type is accepted and simply matches no bills
+	billType := models.BillType(billTypeStr)
+	if billType == "" {
+		http.Error(w, "Invalid bill type", http.StatusBadRequest)
 		return
 	}
 
-	response, err := h.billService.DivideBillByType(r.Context(), userID, apartmentID, billType)
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	response, err := h.billService.DivideBillByType(r.Context(), userID, apartmentID, billType, dryRun)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -122,7 +136,9 @@ func (h *BillHandler) DivideAllBills(w http.ResponseWriter, r *http.Request) {
 	}
 	userID, _ := strconv.Atoi(userIDString)
 
-	response, err := h.billService.DivideAllBills(r.Context(), userID, apartmentID)
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	response, err := h.billService.DivideAllBills(r.Context(), userID, apartmentID, dryRun)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -171,21 +187,29 @@ func (h *BillHandler) GetBillsByApartment(w http.ResponseWriter, r *http.Request
 
 func (h *BillHandler) UpdateBill(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID              int     `json:"id"`
-		ApartmentID     int     `json:"apartment_id"`
-		BillType        string  `json:"bill_type"`
-		TotalAmount     float64 `json:"total_amount"`
-		DueDate         string  `json:"due_date"`
-		BillingDeadline string  `json:"billing_deadline"`
-		Description     string  `json:"description"`
+		ID              int                   `json:"id"`
+		ApartmentID     int                   `json:"apartment_id"`
+		BillType        string                `json:"bill_type"`
+		TotalAmount     money.Amount          `json:"total_amount"`
+		DueDate         string                `json:"due_date"`
+		BillingDeadline string                `json:"billing_deadline"`
+		Description     string                `json:"description"`
+		LineItems       []dto.LineItemRequest `json:"line_items"` // replaces the bill's line items when set
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.billService.UpdateBill(r.Context(), req.ID, req.ApartmentID, req.BillType, req.TotalAmount, req.DueDate, req.BillingDeadline, req.Description); err != nil {
-		http.Error(w, "Failed to update bill", http.StatusInternalServerError)
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	if err := h.billService.UpdateBill(r.Context(), userID, req.ID, req.ApartmentID, req.BillType, req.TotalAmount, req.DueDate, req.BillingDeadline, req.Description, req.LineItems); err != nil {
+		http.Error(w, "Failed to update bill: "+err.Error(), billErrorStatus(err))
 		return
 	}
 
@@ -200,9 +224,16 @@ func (h *BillHandler) DeleteBill(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.billService.DeleteBill(r.Context(), id); err != nil {
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	if err := h.billService.DeleteBill(r.Context(), userID, id); err != nil {
 		logrus.Error("Failed to delete bill:", err)
-		http.Error(w, "Failed to delete bill", http.StatusInternalServerError)
+		http.Error(w, "Failed to delete bill: "+err.Error(), billErrorStatus(err))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -232,30 +263,83 @@ func (h *BillHandler) PayBill(w http.ResponseWriter, r *http.Request) {
 
 	payments := []int{paymentID}
 
-	if err := h.billService.PayBills(r.Context(), userID, payments, r.Context().Value(middleware.IdempotentKey).(string)); err != nil {
-		http.Error(w, "Payment failed: "+err.Error(), http.StatusInternalServerError)
+	// ?source=wallet pays from the resident's wallet instead of starting a gateway checkout
+	fromWallet := r.URL.Query().Get("source") == "wallet"
+	transaction, err := h.billService.PayBills(r.Context(), userID, payments, fromWallet, r.Context().Value(middleware.IdempotentKey).(string))
+	if err != nil {
+		http.Error(w, "Payment failed: "+err.Error(), paymentErrorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "payment successful"})
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func (h *BillHandler) PayPartial(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	paymentID, err := strconv.Atoi(r.PathValue("payment_id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.PartialPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	transaction, err := h.billService.PayPartial(r.Context(), userID, paymentID, req.Amount, r.Context().Value(middleware.IdempotentKey).(string))
+	if err != nil {
+		http.Error(w, "Payment failed: "+err.Error(), paymentErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(transaction)
 }
 
 func (h *BillHandler) PayBatchBills(w http.ResponseWriter, r *http.Request) {
 	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
 
-	response, err := h.billService.PayBatchBills(r.Context(), userID, r.Context().Value(middleware.IdempotentKey).(string))
+	fromWallet := r.URL.Query().Get("source") == "wallet"
+	response, err := h.billService.PayBatchBills(r.Context(), userID, fromWallet, r.Context().Value(middleware.IdempotentKey).(string))
 	if err != nil {
-		http.Error(w, "Batch payment failed: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Batch payment failed: "+err.Error(), paymentErrorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 }
 
+// changes the bill's status doesn't allow are conflicts
+func billErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrInvalidBillTransition),
+		errors.Is(err, repositories.ErrBillNotEditable),
+		errors.Is(err, repositories.ErrBillNotDeletable),
+		errors.Is(err, repositories.ErrBillHasPayments),
+		errors.Is(err, repositories.ErrLineItemsLocked),
+		errors.Is(err, repositories.ErrBillAwaitingApproval),
+		errors.Is(err, repositories.ErrBillNotApproved):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// a payment another request is already paying is a conflict, the other request wins
+func paymentErrorStatus(err error, fallback int) int {
+	if errors.Is(err, repositories.ErrPaymentNotPayable) {
+		return http.StatusConflict
+	}
+	return fallback
+}
+
 func (h *BillHandler) GetUnpaidBills(w http.ResponseWriter, r *http.Request) {
 	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
 
@@ -281,3 +365,265 @@ func (h *BillHandler) GetUserPaymentHistory(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(history)
 }
+
+// every status change of the payment with who made it and why
+func (h *BillHandler) GetPaymentEvents(w http.ResponseWriter, r *http.Request) {
+	paymentID, err := strconv.Atoi(r.PathValue("payment_id"))
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	events, err := h.billService.GetPaymentEvents(r.Context(), userID, paymentID)
+	if err != nil {
+		http.Error(w, "Failed to get payment history: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func (h *BillHandler) SetSplitPolicy(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SplitPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	policy, err := h.billService.SetSplitPolicy(r.Context(), userID, apartmentID, req)
+	if err != nil {
+		http.Error(w, "Failed to set split policy: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *BillHandler) GetSplitPolicies(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	policies, err := h.billService.GetSplitPolicies(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get split policies: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+func (h *BillHandler) SetBillCategory(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.BillCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	category, err := h.billService.SetBillCategory(r.Context(), userID, apartmentID, req)
+	if err != nil {
+		http.Error(w, "Failed to set bill category: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *BillHandler) GetBillCategories(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	categories, err := h.billService.GetBillCategories(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get bill categories: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *BillHandler) DeleteBillCategory(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	if err := h.billService.DeleteBillCategory(r.Context(), userID, apartmentID, models.BillType(r.PathValue("name"))); err != nil {
+		http.Error(w, "Failed to delete bill category: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *BillHandler) RedivideBill(w http.ResponseWriter, r *http.Request) {
+	billID, err := strconv.Atoi(r.PathValue("bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	response, err := h.billService.RedivideBill(r.Context(), userID, billID)
+	if err != nil {
+		http.Error(w, err.Error(), billErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *BillHandler) PublishBill(w http.ResponseWriter, r *http.Request) {
+	billID, err := strconv.Atoi(r.PathValue("bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	status, err := h.billService.PublishBill(r.Context(), userID, billID)
+	if err != nil {
+		http.Error(w, err.Error(), billErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bill_id": billID,
+		"status":  status,
+	})
+}
+
+// voids the bill and its unpaid shares, returns the journal entry that reversed them
+func (h *BillHandler) CancelBill(w http.ResponseWriter, r *http.Request) {
+	billID, err := strconv.Atoi(r.PathValue("bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	journal, err := h.billService.CancelBill(r.Context(), userID, billID)
+	if err != nil {
+		http.Error(w, err.Error(), billErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bill_id": billID,
+		"status":  models.BillCancelled,
+		"journal": journal,
+	})
+}
+
+// the published bills of an apartment the resident lives in
+func (h *BillHandler) GetResidentBills(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	bills, err := h.billService.GetResidentBills(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get bills: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bills)
+}
+
+func (h *BillHandler) GetBillDetail(w http.ResponseWriter, r *http.Request) {
+	billID, err := strconv.Atoi(r.PathValue("bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid bill ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	detail, err := h.billService.GetBillDetail(r.Context(), userID, billID)
+	if err != nil {
+		http.Error(w, "Failed to get bill: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
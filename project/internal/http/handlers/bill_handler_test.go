@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// many requests for the same payment with different idempotency keys: the first checkout locks the
+// payment and every other request is turned away with a conflict
+func TestPayBill_ConcurrentRequests(t *testing.T) {
+	const requests = 20
+
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockCheckoutService := new(services.MockCheckoutService)
+
+	mockPaymentRepo.On("GetPaymentByID", 5).Return(&models.Payment{
+		BaseModel:     models.BaseModel{ID: 5},
+		UserID:        2,
+		Amount:        7000,
+		Currency:      money.IRR,
+		PaymentStatus: models.Pending,
+	}, nil)
+	mockCheckoutService.On("StartCheckout", mock.Anything, 2, money.IRR, mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 4}, Amount: 7000}, nil).Once()
+	mockCheckoutService.On("StartCheckout", mock.Anything, 2, money.IRR, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("failed to start checkout: %w", repositories.ErrPaymentNotPayable))
+
+	service := services.NewBillService(nil, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, mockCheckoutService, nil, nil, nil)
+	handler := middleware.IdempotentKeyMiddleware(http.HandlerFunc(NewBillHandler(service).PayBill))
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/bills/pay/5", nil)
+			req.Header.Set("X-Idempotent-Key", fmt.Sprintf("key-%d", i))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "2"))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			statuses <- w.Code
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, 1, counts[http.StatusAccepted])
+	assert.Equal(t, requests-1, counts[http.StatusConflict])
+	mockCheckoutService.AssertNumberOfCalls(t, "StartCheckout", requests)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+)
+
+type CheckoutHandler struct {
+	checkoutService services.CheckoutService
+}
+
+func NewCheckoutHandler(checkoutService services.CheckoutService) *CheckoutHandler {
+	return &CheckoutHandler{
+		checkoutService: checkoutService,
+	}
+}
+
+// the gateway calls this once the resident finished the checkout, it is not authenticated
+// since the callback is verified by its signature instead
+func (h *CheckoutHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid callback", http.StatusBadRequest)
+		return
+	}
+
+	transaction, err := h.checkoutService.HandleCallback(r.Context(), r.Form)
+	if err != nil {
+		http.Error(w, "Callback rejected: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func (h *CheckoutHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	transactionID, err := strconv.Atoi(r.PathValue("transaction_id"))
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	transaction, err := h.checkoutService.GetTransaction(r.Context(), userID, transactionID)
+	if err != nil {
+		http.Error(w, "Failed to get transaction: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+)
+
+type MeterHandler struct {
+	meterService services.MeterService
+}
+
+func NewMeterHandler(meterService services.MeterService) *MeterHandler {
+	return &MeterHandler{
+		meterService: meterService,
+	}
+}
+
+func (h *MeterHandler) RegisterMeter(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.RegisterMeterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	meter, err := h.meterService.RegisterMeter(r.Context(), userID, apartmentID, req)
+	if err != nil {
+		http.Error(w, "Failed to register meter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(meter)
+}
+
+func (h *MeterHandler) GetMeters(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	meters, err := h.meterService.GetMeters(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get meters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meters)
+}
+
+func (h *MeterHandler) SubmitReading(w http.ResponseWriter, r *http.Request) {
+	meterID, err := strconv.Atoi(r.PathValue("meter_id"))
+	if err != nil {
+		http.Error(w, "Invalid meter ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	err = r.ParseMultipartForm(32 << 20) // 32 MB max
+	if err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SubmitReadingRequest
+	req.Value, err = strconv.ParseFloat(r.FormValue("value"), 64)
+	if err != nil {
+		http.Error(w, "Invalid reading value", http.StatusBadRequest)
+		return
+	}
+	req.ReadAt = r.FormValue("read_at")
+
+	file, handler, _ := r.FormFile("meter_image")
+
+	reading, err := h.meterService.SubmitReading(r.Context(), userID, meterID, req, file, handler)
+	if err != nil {
+		http.Error(w, "Failed to submit reading: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reading)
+}
+
+func (h *MeterHandler) GetReadings(w http.ResponseWriter, r *http.Request) {
+	meterID, err := strconv.Atoi(r.PathValue("meter_id"))
+	if err != nil {
+		http.Error(w, "Invalid meter ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	readings, err := h.meterService.GetReadings(r.Context(), userID, meterID)
+	if err != nil {
+		http.Error(w, "Failed to get readings: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(readings)
+}
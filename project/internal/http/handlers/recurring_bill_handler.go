@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+)
+
+type RecurringBillHandler struct {
+	recurringBillService services.RecurringBillService
+}
+
+func NewRecurringBillHandler(recurringBillService services.RecurringBillService) *RecurringBillHandler {
+	return &RecurringBillHandler{
+		recurringBillService: recurringBillService,
+	}
+}
+
+func (h *RecurringBillHandler) CreateRecurringBill(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.RecurringBillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	template, err := h.recurringBillService.CreateRecurringBill(r.Context(), userID, apartmentID, req)
+	if err != nil {
+		http.Error(w, "Failed to create recurring bill: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+func (h *RecurringBillHandler) GetRecurringBills(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	templates, err := h.recurringBillService.GetRecurringBills(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get recurring bills: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+func (h *RecurringBillHandler) UpdateRecurringBill(w http.ResponseWriter, r *http.Request) {
+	recurringBillID, err := strconv.Atoi(r.PathValue("recurring_bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid recurring bill ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.RecurringBillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	template, err := h.recurringBillService.UpdateRecurringBill(r.Context(), userID, recurringBillID, req)
+	if err != nil {
+		http.Error(w, "Failed to update recurring bill: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+func (h *RecurringBillHandler) DeleteRecurringBill(w http.ResponseWriter, r *http.Request) {
+	recurringBillID, err := strconv.Atoi(r.PathValue("recurring_bill_id"))
+	if err != nil {
+		http.Error(w, "Invalid recurring bill ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	if err := h.recurringBillService.DeleteRecurringBill(r.Context(), userID, recurringBillID); err != nil {
+		http.Error(w, "Failed to delete recurring bill: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
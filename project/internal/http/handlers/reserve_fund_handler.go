@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+)
+
+type ReserveFundHandler struct {
+	reserveFundService services.ReserveFundService
+}
+
+func NewReserveFundHandler(reserveFundService services.ReserveFundService) *ReserveFundHandler {
+	return &ReserveFundHandler{
+		reserveFundService: reserveFundService,
+	}
+}
+
+func (h *ReserveFundHandler) GetReserveFund(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	fund, err := h.reserveFundService.GetReserveFund(r.Context(), userID, apartmentID)
+	if err != nil {
+		http.Error(w, "Failed to get reserve fund: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fund)
+}
+
+func (h *ReserveFundHandler) RecordExpense(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userIDString, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Failed to get user ID from context", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := strconv.Atoi(userIDString)
+
+	err = r.ParseMultipartForm(32 << 20) // 32 MB max
+	if err != nil {
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CapitalExpenseRequest
+	req.Amount, err = money.Parse(r.FormValue("amount"))
+	if err != nil {
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+	req.Currency = money.Currency(r.FormValue("currency"))
+	req.Description = r.FormValue("description")
+	req.SpentAt = r.FormValue("spent_at")
+
+	file, handler, _ := r.FormFile("receipt")
+
+	expense, err := h.reserveFundService.RecordExpense(r.Context(), userID, apartmentID, req, file, handler)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, repositories.ErrInsufficientReserve) {
+			status = http.StatusConflict
+		}
+		http.Error(w, "Failed to record expense: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(expense)
+}
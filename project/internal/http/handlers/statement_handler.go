@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/middleware"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/services"
+)
+
+type StatementHandler struct {
+	statementService services.StatementService
+}
+
+func NewStatementHandler(statementService services.StatementService) *StatementHandler {
+	return &StatementHandler{
+		statementService: statementService,
+	}
+}
+
+// json by default, format=csv downloads it as a file
+func (h *StatementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+	period := dto.StatementPeriod{From: r.URL.Query().Get("from"), To: r.URL.Query().Get("to")}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		statement, err := h.statementService.GetStatement(r.Context(), userID, apartmentID, period)
+		if err != nil {
+			http.Error(w, "Failed to get statement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statement)
+	case "csv":
+		out, err := h.statementService.ExportCSV(r.Context(), userID, apartmentID, period)
+		if err != nil {
+			http.Error(w, "Failed to get statement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement_%d.csv"`, apartmentID))
+		w.Write(out)
+	default:
+		http.Error(w, "Invalid format (use json or csv)", http.StatusBadRequest)
+	}
+}
+
+func (h *StatementHandler) GeneratePDF(w http.ResponseWriter, r *http.Request) {
+	apartmentID, err := strconv.Atoi(r.PathValue("apartment_id"))
+	if err != nil {
+		http.Error(w, "Invalid apartment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+	period := dto.StatementPeriod{From: r.URL.Query().Get("from"), To: r.URL.Query().Get("to")}
+
+	document, err := h.statementService.GeneratePDF(r.Context(), userID, apartmentID, period)
+	if err != nil {
+		http.Error(w, "Failed to generate statement: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(document)
+}
+
+func (h *StatementHandler) GetStatementDocuments(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	documents, err := h.statementService.GetStatementDocuments(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to get statements: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(documents)
+}
+
+func (h *StatementHandler) GetStatementDocument(w http.ResponseWriter, r *http.Request) {
+	documentID, err := strconv.Atoi(r.PathValue("statement_id"))
+	if err != nil {
+		http.Error(w, "Invalid statement ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	document, err := h.statementService.GetStatementDocument(r.Context(), userID, documentID)
+	if err != nil {
+		http.Error(w, "Failed to get statement: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(document)
+}
+
+func (h *StatementHandler) SendStatementDocument(w http.ResponseWriter, r *http.Request) {
+	documentID, err := strconv.Atoi(r.PathValue("statement_id"))
+	if err != nil {
+		http.Error(w, "Invalid statement ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserIDKey).(string))
+
+	if err := h.statementService.SendStatementDocument(r.Context(), userID, documentID); err != nil {
+		http.Error(w, "Failed to send statement: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "sent"})
+}
@@ -248,7 +248,7 @@ func TestCorsMiddleware(t *testing.T) {
 			if tt.checkHeaders {
 				assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
 				assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
-				assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+				assert.Equal(t, "Content-Type, Authorization, X-Idempotent-Key", w.Header().Get("Access-Control-Allow-Headers"))
 			}
 		})
 	}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/http/utils"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+)
+
+const IdempotentReplayedHeader = "X-Idempotent-Replayed"
+
+// makes mutating requests that carry an X-Idempotent-Key safe to retry. the first request claims the
+// key with a fingerprint of its method, url and body, and its response is stored for the retries to
+// get back without running the handler again. reusing a key for a different request is a conflict
+// and a retry that arrives while the first request is still running is told to come back later.
+// requests without the key pass through, routes that need one also use IdempotentKeyMiddleware.
+// must run after JWTAuthMiddleware, keys are kept per user
+func IdempotencyMiddleware(repo repositories.IdempotencyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotentKey := r.Header.Get("X-Idempotent-Key")
+			if idempotentKey == "" || !isMutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				utils.WriteErrorResponse(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := fmt.Sprintf("%v:%s", r.Context().Value(UserIDKey), idempotentKey)
+			fingerprint := requestFingerprint(r, body)
+
+			token, err := claimToken()
+			if err != nil {
+				log.Printf("Failed to generate idempotency claim token: %v", err)
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "failed to check idempotency key")
+				return
+			}
+			existing, err := repo.Claim(r.Context(), key, fingerprint, token)
+			if err != nil {
+				log.Printf("Idempotency claim failed for key %s: %v", key, err)
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "failed to check idempotency key")
+				return
+			}
+			if existing != nil {
+				replayOrReject(w, existing, fingerprint)
+				return
+			}
+
+			// the stored response must not be lost to a client that hung up
+			storeCtx := context.WithoutCancel(r.Context())
+			recorder := &idempotencyRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					if err := repo.Release(storeCtx, key, token); err != nil {
+						log.Printf("Failed to release idempotency key %s: %v", key, err)
+					}
+				}
+			}()
+
+			ctx := context.WithValue(r.Context(), IdempotentKey, idempotentKey)
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			// server errors are not cached so a retry gets another go
+			if recorder.statusCode >= http.StatusInternalServerError {
+				return
+			}
+			err = repo.Complete(storeCtx, key, token, models.IdempotencyRecord{
+				Fingerprint: fingerprint,
+				StatusCode:  recorder.statusCode,
+				Header:      recorder.header,
+				Body:        recorder.body.Bytes(),
+			})
+			if err != nil {
+				log.Printf("Failed to store response for idempotency key %s: %v", key, err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func replayOrReject(w http.ResponseWriter, existing *models.IdempotencyRecord, fingerprint string) {
+	if existing.Fingerprint != fingerprint {
+		utils.WriteErrorResponse(w, http.StatusConflict, "idempotency key was already used for a different request")
+		return
+	}
+	if existing.Status != models.IdempotencyCompleted {
+		utils.WriteErrorResponse(w, http.StatusTooEarly, "a request with this idempotency key is still in progress")
+		return
+	}
+
+	for name, values := range existing.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Body)
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// tells this request's claim apart from a later one on the same key
+func claimToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// passes the response through while keeping a copy to replay
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	header      map[string][]string
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.statusCode = code
+	rec.header = rec.ResponseWriter.Header().Clone()
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newIdempotentRequest(method, body string) *http.Request {
+	req := httptest.NewRequest(method, "/bills/pay/1", strings.NewReader(body))
+	req.Header.Set("X-Idempotent-Key", "key-1")
+	return req.WithContext(context.WithValue(req.Context(), UserIDKey, "7"))
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	body := `{"amount":100}`
+	fingerprint := requestFingerprint(newIdempotentRequest("POST", body), []byte(body))
+
+	tests := []struct {
+		name           string
+		setupMock      func(repo *repositories.MockIdempotencyRepository)
+		method         string
+		withKey        bool
+		handlerStatus  int
+		expectedStatus int
+		expectedBody   string
+		handlerRuns    bool
+	}{
+		{
+			name: "first request runs and its response is stored",
+			setupMock: func(repo *repositories.MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).Return(nil, nil)
+				repo.On("Complete", mock.Anything, "7:key-1", mock.AnythingOfType("string"), mock.MatchedBy(func(record models.IdempotencyRecord) bool {
+					return record.Fingerprint == fingerprint && record.StatusCode == http.StatusAccepted &&
+						string(record.Body) == "handled" && record.Header["Content-Type"][0] == "text/plain"
+				})).Return(nil)
+			},
+			method:         "POST",
+			withKey:        true,
+			handlerStatus:  http.StatusAccepted,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "handled",
+			handlerRuns:    true,
+		},
+		{
+			name: "retry replays the stored response",
+			setupMock: func(repo *repositories.MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).Return(&models.IdempotencyRecord{
+					Fingerprint: fingerprint,
+					Status:      models.IdempotencyCompleted,
+					StatusCode:  http.StatusAccepted,
+					Header:      map[string][]string{"Content-Type": {"text/plain"}},
+					Body:        []byte("handled"),
+				}, nil)
+			},
+			method:         "POST",
+			withKey:        true,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "handled",
+		},
+		{
+			name: "key reused with a different body",
+			setupMock: func(repo *repositories.MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).Return(&models.IdempotencyRecord{
+					Fingerprint: "other",
+					Status:      models.IdempotencyCompleted,
+				}, nil)
+			},
+			method:         "POST",
+			withKey:        true,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "original request still in flight",
+			setupMock: func(repo *repositories.MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).Return(&models.IdempotencyRecord{
+					Fingerprint: fingerprint,
+					Status:      models.IdempotencyInFlight,
+				}, nil)
+			},
+			method:         "POST",
+			withKey:        true,
+			expectedStatus: http.StatusTooEarly,
+		},
+		{
+			name: "server error releases the key",
+			setupMock: func(repo *repositories.MockIdempotencyRepository) {
+				var claimed string
+				repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).
+					Run(func(args mock.Arguments) { claimed = args.String(3) }).
+					Return(nil, nil)
+				// only the claim this request made may be released
+				repo.On("Release", mock.Anything, "7:key-1", mock.MatchedBy(func(token string) bool {
+					return token != "" && token == claimed
+				})).Return(nil)
+			},
+			method:         "POST",
+			withKey:        true,
+			handlerStatus:  http.StatusInternalServerError,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "handled",
+			handlerRuns:    true,
+		},
+		{
+			name: "store failure",
+			setupMock: func(repo *repositories.MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).Return(nil, errors.New("redis down"))
+			},
+			method:         "POST",
+			withKey:        true,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "request without a key passes through",
+			setupMock:      func(repo *repositories.MockIdempotencyRepository) {},
+			method:         "POST",
+			handlerStatus:  http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "handled",
+			handlerRuns:    true,
+		},
+		{
+			name:           "reads are not tracked",
+			setupMock:      func(repo *repositories.MockIdempotencyRepository) {},
+			method:         "GET",
+			withKey:        true,
+			handlerStatus:  http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "handled",
+			handlerRuns:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(repositories.MockIdempotencyRepository)
+			tt.setupMock(repo)
+
+			handlerRan := false
+			handler := IdempotencyMiddleware(repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerRan = true
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.handlerStatus)
+				w.Write([]byte("handled"))
+			}))
+
+			req := newIdempotentRequest(tt.method, body)
+			if !tt.withKey {
+				req.Header.Del("X-Idempotent-Key")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.handlerRuns, handlerRan)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_ReplayIsMarked(t *testing.T) {
+	body := `{}`
+	fingerprint := requestFingerprint(newIdempotentRequest("POST", body), []byte(body))
+
+	repo := new(repositories.MockIdempotencyRepository)
+	repo.On("Claim", mock.Anything, "7:key-1", fingerprint, mock.AnythingOfType("string")).Return(&models.IdempotencyRecord{
+		Fingerprint: fingerprint,
+		Status:      models.IdempotencyCompleted,
+		StatusCode:  http.StatusOK,
+	}, nil)
+
+	handler := IdempotencyMiddleware(repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not run for a replay")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newIdempotentRequest("POST", body))
+
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+}
@@ -18,10 +18,14 @@ func (s *ApartmantService) SetupRoutes(mux *http.ServeMux) {
 	v1.HandleFunc("/user/login", utils.MethodHandler(map[string]http.HandlerFunc{
 		"POST": s.userHandler.Login,
 	}))
+	v1.HandleFunc("/payments/callback", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET":  s.checkoutHandler.Callback,
+		"POST": s.checkoutHandler.Callback,
+	}))
 
 	// manager routes
 	managerRoutes := http.NewServeMux()
-	v1.Handle("/manager/", http.StripPrefix("/manager", middleware.JWTAuthMiddleware(models.Manager)(managerRoutes)))
+	v1.Handle("/manager/", http.StripPrefix("/manager", ChainMiddleware(managerRoutes, middleware.IdempotencyMiddleware(s.idempotencyRepo), middleware.JWTAuthMiddleware(models.Manager))))
 
 	managerRoutes.HandleFunc("/user/get-all", utils.MethodHandler(map[string]http.HandlerFunc{
 		"GET": s.userHandler.GetAllUsers,
@@ -46,10 +50,130 @@ func (s *ApartmantService) SetupRoutes(mux *http.ServeMux) {
 	managerRoutes.HandleFunc("/apartment/{apartment_id}/invite/resident/{telegram_username}", s.methodHandler(map[string]http.HandlerFunc{
 		"POST": s.apartmentHandler.InviteUserToApartment,
 	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/residents/{user_id}/shares", s.methodHandler(map[string]http.HandlerFunc{
+		"PUT": s.apartmentHandler.UpdateResidentShares,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/share-visibility", s.methodHandler(map[string]http.HandlerFunc{
+		"PUT": s.apartmentHandler.SetShareVisibility,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/residents/{user_id}/wallet/adjustments", s.methodHandler(map[string]http.HandlerFunc{
+		"POST": s.walletHandler.AdjustBalance,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/ledger/entries", s.methodHandler(map[string]http.HandlerFunc{
+		"GET": s.ledgerHandler.GetEntries,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/ledger/balances", s.methodHandler(map[string]http.HandlerFunc{
+		"GET": s.ledgerHandler.GetBalances,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/ledger/trial-balance", s.methodHandler(map[string]http.HandlerFunc{
+		"GET": s.ledgerHandler.GetTrialBalance,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/ledger/accounts/{account}", s.methodHandler(map[string]http.HandlerFunc{
+		"GET": s.ledgerHandler.GetAccountStatement,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/split-policies", s.methodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetSplitPolicies,
+		"PUT": s.billHandler.SetSplitPolicy,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/bill-categories", s.methodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetBillCategories,
+		"PUT": s.billHandler.SetBillCategory,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/bill-categories/{name}", s.methodHandler(map[string]http.HandlerFunc{
+		"DELETE": s.billHandler.DeleteBillCategory,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/meters", s.methodHandler(map[string]http.HandlerFunc{
+		"GET":  s.meterHandler.GetMeters,
+		"POST": s.meterHandler.RegisterMeter,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/recurring-bills", s.methodHandler(map[string]http.HandlerFunc{
+		"GET":  s.recurringBillHandler.GetRecurringBills,
+		"POST": s.recurringBillHandler.CreateRecurringBill,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/late-fee-policy", s.methodHandler(map[string]http.HandlerFunc{
+		"GET":    s.lateFeeHandler.GetLateFeePolicy,
+		"PUT":    s.lateFeeHandler.SetLateFeePolicy,
+		"DELETE": s.lateFeeHandler.DeleteLateFeePolicy,
+	}))
+	managerRoutes.HandleFunc("/recurring-bills/{recurring_bill_id}", s.methodHandler(map[string]http.HandlerFunc{
+		"PUT":    s.recurringBillHandler.UpdateRecurringBill,
+		"DELETE": s.recurringBillHandler.DeleteRecurringBill,
+	}))
 	managerRoutes.HandleFunc("/bill/{apartment_id}/create", utils.MethodHandler(map[string]http.HandlerFunc{
 		"POST": s.billHandler.CreateBill,
 	}))
 
+	managerRoutes.HandleFunc("/bill/{bill_id}/redivide", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.billHandler.RedivideBill,
+	}))
+	managerRoutes.HandleFunc("/bill/{bill_id}/publish", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.billHandler.PublishBill,
+	}))
+	managerRoutes.HandleFunc("/bill/{bill_id}/cancel", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.billHandler.CancelBill,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/reserve-fund", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.reserveFundHandler.GetReserveFund,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/reserve-fund/expenses", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.reserveFundHandler.RecordExpense,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/stats", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.statsHandler.GetApartmentStats,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/budgets", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.budgetHandler.GetBudgets,
+		"PUT": s.budgetHandler.SetBudget,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/budgets/{category}", utils.MethodHandler(map[string]http.HandlerFunc{
+		"DELETE": s.budgetHandler.DeleteBudget,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/budget-report", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.budgetHandler.GetBudgetReport,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/approval-policy", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.approvalHandler.GetApprovalPolicy,
+		"PUT":    s.approvalHandler.SetApprovalPolicy,
+		"DELETE": s.approvalHandler.DeleteApprovalPolicy,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/approvals", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.approvalHandler.GetPendingApprovals,
+	}))
+	managerRoutes.HandleFunc("/bill/{bill_id}/approvals", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.approvalHandler.GetBillApprovals,
+	}))
+	managerRoutes.HandleFunc("/bill/{bill_id}/approve", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.approvalHandler.ApproveBill,
+	}))
+	managerRoutes.HandleFunc("/bill/{bill_id}/reject", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.approvalHandler.RejectBill,
+	}))
+
+	managerRoutes.HandleFunc("/payments/{payment_id}/installment-plan", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.installmentHandler.GetPlan,
+		"POST":   s.installmentHandler.CreatePlan,
+		"DELETE": s.installmentHandler.DeletePlan,
+	}))
+
+	managerRoutes.HandleFunc("/payments/{payment_id}/write-off", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.ledgerHandler.WriteOff,
+	}))
+	managerRoutes.HandleFunc("/payments/{payment_id}/events", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetPaymentEvents,
+	}))
+	managerRoutes.HandleFunc("/apartment/{apartment_id}/disputes", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.disputeHandler.GetApartmentDisputes,
+	}))
+	managerRoutes.HandleFunc("/disputes/{dispute_id}", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.disputeHandler.GetDispute,
+	}))
+	managerRoutes.HandleFunc("/disputes/{dispute_id}/approve", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.disputeHandler.ApproveDispute,
+	}))
+	managerRoutes.HandleFunc("/disputes/{dispute_id}/reject", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.disputeHandler.RejectDispute,
+	}))
+
 	managerRoutes.HandleFunc("/bills/{apartment_id}/divide/{bill_type}", utils.MethodHandler(map[string]http.HandlerFunc{
 		"POST": s.billHandler.DivideBillByType,
 	}))
@@ -68,7 +192,7 @@ func (s *ApartmantService) SetupRoutes(mux *http.ServeMux) {
 	}))
 	// resident routes
 	residentRoutes := http.NewServeMux()
-	v1.Handle("/resident/", http.StripPrefix("/resident", middleware.JWTAuthMiddleware(models.Resident, models.Manager)(residentRoutes)))
+	v1.Handle("/resident/", http.StripPrefix("/resident", ChainMiddleware(residentRoutes, middleware.IdempotencyMiddleware(s.idempotencyRepo), middleware.JWTAuthMiddleware(models.Resident, models.Manager))))
 
 	residentRoutes.HandleFunc("/profile", utils.MethodHandler(map[string]http.HandlerFunc{
 		"GET": s.userHandler.GetProfile,
@@ -97,6 +221,116 @@ func (s *ApartmantService) SetupRoutes(mux *http.ServeMux) {
 		).ServeHTTP,
 	)
 
+	residentRoutes.HandleFunc("/bills/pay/{payment_id}/partial",
+		middleware.IdempotentKeyMiddleware(
+			utils.MethodHandler(map[string]http.HandlerFunc{
+				"POST": s.billHandler.PayPartial,
+			}),
+		).ServeHTTP,
+	)
+
+	residentRoutes.HandleFunc("/installments/{installment_id}/pay",
+		middleware.IdempotentKeyMiddleware(
+			utils.MethodHandler(map[string]http.HandlerFunc{
+				"POST": s.installmentHandler.PayInstallment,
+			}),
+		).ServeHTTP,
+	)
+	residentRoutes.HandleFunc("/wallets", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.walletHandler.GetWallets,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/wallet", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.walletHandler.GetWallet,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/wallet/transactions", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.walletHandler.GetTransactions,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/wallet/auto-pay", utils.MethodHandler(map[string]http.HandlerFunc{
+		"PUT": s.walletHandler.SetAutoPay,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/wallet/top-up",
+		middleware.IdempotentKeyMiddleware(
+			utils.MethodHandler(map[string]http.HandlerFunc{
+				"POST": s.walletHandler.TopUp,
+			}),
+		).ServeHTTP,
+	)
+	residentRoutes.HandleFunc("/transactions/{transaction_id}", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.checkoutHandler.GetTransaction,
+	}))
+	residentRoutes.HandleFunc("/installment-plans", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.installmentHandler.GetUserPlans,
+	}))
+	residentRoutes.HandleFunc("/payments/{payment_id}/installment-plan", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.installmentHandler.GetPlan,
+	}))
+	residentRoutes.HandleFunc("/payments/{payment_id}/events", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetPaymentEvents,
+	}))
+	residentRoutes.HandleFunc("/payments/{payment_id}/disputes", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.disputeHandler.OpenDispute,
+	}))
+	residentRoutes.HandleFunc("/disputes", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.disputeHandler.GetMyDisputes,
+	}))
+	residentRoutes.HandleFunc("/disputes/{dispute_id}", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.disputeHandler.GetDispute,
+	}))
+
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/meters", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.meterHandler.GetMeters,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/bill-categories", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetBillCategories,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/late-fee-policy", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.lateFeeHandler.GetLateFeePolicy,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/reserve-fund", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.reserveFundHandler.GetReserveFund,
+	}))
+	// the apartment's representative approves large bills from the resident side
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/approval-policy", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.approvalHandler.GetApprovalPolicy,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/approvals", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.approvalHandler.GetPendingApprovals,
+	}))
+	residentRoutes.HandleFunc("/bill/{bill_id}/approvals", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.approvalHandler.GetBillApprovals,
+	}))
+	residentRoutes.HandleFunc("/bill/{bill_id}/approve", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.approvalHandler.ApproveBill,
+	}))
+	residentRoutes.HandleFunc("/bill/{bill_id}/reject", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.approvalHandler.RejectBill,
+	}))
+	residentRoutes.HandleFunc("/meters/{meter_id}/readings", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET":  s.meterHandler.GetReadings,
+		"POST": s.meterHandler.SubmitReading,
+	}))
+
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/bills", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetResidentBills,
+	}))
+	residentRoutes.HandleFunc("/bills/{bill_id}", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.billHandler.GetBillDetail,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/statement", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.statementHandler.GetStatement,
+	}))
+	residentRoutes.HandleFunc("/apartment/{apartment_id}/statement/pdf", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.statementHandler.GeneratePDF,
+	}))
+	residentRoutes.HandleFunc("/statements", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.statementHandler.GetStatementDocuments,
+	}))
+	residentRoutes.HandleFunc("/statements/{statement_id}", utils.MethodHandler(map[string]http.HandlerFunc{
+		"GET": s.statementHandler.GetStatementDocument,
+	}))
+	residentRoutes.HandleFunc("/statements/{statement_id}/send", utils.MethodHandler(map[string]http.HandlerFunc{
+		"POST": s.statementHandler.SendStatementDocument,
+	}))
 	residentRoutes.HandleFunc("/bills/get-unpaid", utils.MethodHandler(map[string]http.HandlerFunc{
 		"GET": s.billHandler.GetUnpaidBills,
 	}))
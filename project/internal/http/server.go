@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -24,24 +25,57 @@ import (
 	goredis "github.com/redis/go-redis/v9"
 )
 
+const (
+	recurringBillsInterval = time.Hour
+	lateFeesInterval       = time.Hour
+	checkoutExpiryInterval = time.Minute
+)
+
 type ApartmantService struct {
-	server              *http.Server
-	cfg                 *config.Config
-	shutdownWG          sync.WaitGroup
-	shutdownCtx         context.Context
-	cancelFunc          context.CancelFunc
-	db                  *sqlx.DB
-	minioClient         *minio.Client
-	redisClient         *goredis.Client
-	userHandler         *handlers.UserHandler
-	apartmentHandler    *handlers.ApartmentHandler
-	billHandler         *handlers.BillHandler
-	userService         services.UserService
-	apartmentService    services.ApartmentService
-	billService         services.BillService
-	notificationService notification.Notification
-	imageService        image.Image
-	paymentService      payment.Payment
+	server               *http.Server
+	cfg                  *config.Config
+	shutdownWG           sync.WaitGroup
+	shutdownCtx          context.Context
+	cancelFunc           context.CancelFunc
+	db                   *sqlx.DB
+	minioClient          *minio.Client
+	redisClient          *goredis.Client
+	userHandler          *handlers.UserHandler
+	apartmentHandler     *handlers.ApartmentHandler
+	billHandler          *handlers.BillHandler
+	meterHandler         *handlers.MeterHandler
+	recurringBillHandler *handlers.RecurringBillHandler
+	lateFeeHandler       *handlers.LateFeeHandler
+	installmentHandler   *handlers.InstallmentHandler
+	checkoutHandler      *handlers.CheckoutHandler
+	walletHandler        *handlers.WalletHandler
+	ledgerHandler        *handlers.LedgerHandler
+	disputeHandler       *handlers.DisputeHandler
+	approvalHandler      *handlers.ApprovalHandler
+	reserveFundHandler   *handlers.ReserveFundHandler
+	budgetHandler        *handlers.BudgetHandler
+	statementHandler     *handlers.StatementHandler
+	statsHandler         *handlers.StatsHandler
+	userService          services.UserService
+	apartmentService     services.ApartmentService
+	billService          services.BillService
+	meterService         services.MeterService
+	recurringBillService services.RecurringBillService
+	lateFeeService       services.LateFeeService
+	installmentService   services.InstallmentService
+	checkoutService      services.CheckoutService
+	walletService        services.WalletService
+	ledgerService        services.LedgerService
+	disputeService       services.DisputeService
+	approvalService      services.ApprovalService
+	reserveFundService   services.ReserveFundService
+	budgetService        services.BudgetService
+	statementService     services.StatementService
+	statsService         services.StatsService
+	notificationService  notification.Notification
+	imageService         image.Image
+	paymentGateway       payment.Gateway
+	idempotencyRepo      repositories.IdempotencyRepository
 }
 
 func NewApartmantService(
@@ -55,9 +89,25 @@ func NewApartmantService(
 	inviteLinkRepo repositories.InviteLinkRepo,
 	notificationService notification.Notification,
 	billRepo repositories.BillRepository,
+	approvalRepo repositories.ApprovalRepository,
 	imageService image.Image,
 	paymentRepo repositories.PaymentRepository,
-	paymentService payment.Payment,
+	paymentGateway payment.Gateway,
+	splitPolicyRepo repositories.SplitPolicyRepository,
+	categoryRepo repositories.BillCategoryRepository,
+	meterRepo repositories.MeterRepository,
+	recurringBillRepo repositories.RecurringBillRepository,
+	lateFeePolicyRepo repositories.LateFeePolicyRepository,
+	installmentRepo repositories.InstallmentRepository,
+	paymentTransactionRepo repositories.PaymentTransactionRepository,
+	walletRepo repositories.WalletRepository,
+	ledgerRepo repositories.LedgerRepository,
+	disputeRepo repositories.DisputeRepository,
+	reserveFundRepo repositories.ReserveFundRepository,
+	budgetRepo repositories.BudgetRepository,
+	statementRepo repositories.StatementRepository,
+	statsRepo repositories.StatsRepository,
+	idempotencyRepo repositories.IdempotencyRepository,
 ) *ApartmantService {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -69,37 +119,117 @@ func NewApartmantService(
 		inviteLinkRepo,
 		notificationService,
 	)
+	checkoutService := services.NewCheckoutService(
+		paymentTransactionRepo,
+		paymentGateway,
+		strings.TrimRight(cfg.Payment.PublicURL, "/")+"/api/v1/payments/callback",
+	)
+	ledgerService := services.NewLedgerService(ledgerRepo, paymentRepo, billRepo, userApartmentRepo)
+	walletService := services.NewWalletService(walletRepo, paymentTransactionRepo, userApartmentRepo, checkoutService, notificationService)
+	approvalService := services.NewApprovalService(approvalRepo, billRepo, userApartmentRepo, notificationService)
 	billService := services.NewBillService(
 		billRepo,
 		userRepo,
 		apartmentRepo,
 		userApartmentRepo,
 		paymentRepo,
+		splitPolicyRepo,
+		categoryRepo,
+		meterRepo,
 		imageService,
-		paymentService,
+		checkoutService,
+		walletService,
+		approvalService,
 		notificationService,
 	)
+	approvalService.UseBillDivider(billService)
 
 	userHandler := handlers.NewUserHandler(userService, cfg.TelegramConfig.BotAddress)
 	apartmentHandler := handlers.NewApartmentHandler(apartmentService)
+	meterService := services.NewMeterService(meterRepo, userApartmentRepo, imageService)
+	recurringBillService := services.NewRecurringBillService(recurringBillRepo, categoryRepo, userApartmentRepo, billService, approvalService)
+	lateFeeService := services.NewLateFeeService(lateFeePolicyRepo, billRepo, paymentRepo, userApartmentRepo, notificationService)
+	installmentService := services.NewInstallmentService(installmentRepo, paymentRepo, billRepo, userApartmentRepo, checkoutService)
+	disputeService := services.NewDisputeService(
+		disputeRepo,
+		paymentRepo,
+		billRepo,
+		paymentTransactionRepo,
+		walletRepo,
+		userApartmentRepo,
+		paymentGateway,
+		notificationService,
+	)
+	reserveFundService := services.NewReserveFundService(reserveFundRepo, userApartmentRepo, imageService)
+	budgetService := services.NewBudgetService(budgetRepo, billRepo, categoryRepo, userApartmentRepo)
+	statementService := services.NewStatementService(
+		statementRepo,
+		ledgerRepo,
+		apartmentRepo,
+		userRepo,
+		userApartmentRepo,
+		imageService,
+		notificationService,
+	)
+	statsService := services.NewStatsService(statsRepo, userApartmentRepo)
 	billHandler := handlers.NewBillHandler(billService)
+	meterHandler := handlers.NewMeterHandler(meterService)
+	recurringBillHandler := handlers.NewRecurringBillHandler(recurringBillService)
+	lateFeeHandler := handlers.NewLateFeeHandler(lateFeeService)
+	installmentHandler := handlers.NewInstallmentHandler(installmentService)
+	checkoutHandler := handlers.NewCheckoutHandler(checkoutService)
+	walletHandler := handlers.NewWalletHandler(walletService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	disputeHandler := handlers.NewDisputeHandler(disputeService)
+	approvalHandler := handlers.NewApprovalHandler(approvalService)
+	reserveFundHandler := handlers.NewReserveFundHandler(reserveFundService)
+	budgetHandler := handlers.NewBudgetHandler(budgetService)
+	statementHandler := handlers.NewStatementHandler(statementService)
+	statsHandler := handlers.NewStatsHandler(statsService)
 
 	return &ApartmantService{
-		cfg:                 cfg,
-		shutdownCtx:         ctx,
-		cancelFunc:          cancel,
-		db:                  db,
-		minioClient:         minioClient,
-		redisClient:         redisClient,
-		userHandler:         userHandler,
-		apartmentHandler:    apartmentHandler,
-		billHandler:         billHandler,
-		userService:         userService,
-		apartmentService:    apartmentService,
-		billService:         billService,
-		notificationService: notificationService,
-		imageService:        imageService,
-		paymentService:      paymentService,
+		cfg:                  cfg,
+		shutdownCtx:          ctx,
+		cancelFunc:           cancel,
+		db:                   db,
+		minioClient:          minioClient,
+		redisClient:          redisClient,
+		userHandler:          userHandler,
+		apartmentHandler:     apartmentHandler,
+		billHandler:          billHandler,
+		meterHandler:         meterHandler,
+		recurringBillHandler: recurringBillHandler,
+		lateFeeHandler:       lateFeeHandler,
+		installmentHandler:   installmentHandler,
+		checkoutHandler:      checkoutHandler,
+		walletHandler:        walletHandler,
+		ledgerHandler:        ledgerHandler,
+		disputeHandler:       disputeHandler,
+		approvalHandler:      approvalHandler,
+		reserveFundHandler:   reserveFundHandler,
+		budgetHandler:        budgetHandler,
+		statementHandler:     statementHandler,
+		statsHandler:         statsHandler,
+		userService:          userService,
+		apartmentService:     apartmentService,
+		billService:          billService,
+		meterService:         meterService,
+		recurringBillService: recurringBillService,
+		lateFeeService:       lateFeeService,
+		installmentService:   installmentService,
+		checkoutService:      checkoutService,
+		walletService:        walletService,
+		ledgerService:        ledgerService,
+		disputeService:       disputeService,
+		approvalService:      approvalService,
+		reserveFundService:   reserveFundService,
+		budgetService:        budgetService,
+		statementService:     statementService,
+		statsService:         statsService,
+		notificationService:  notificationService,
+		imageService:         imageService,
+		paymentGateway:       paymentGateway,
+		idempotencyRepo:      idempotencyRepo,
 	}
 }
 
@@ -119,6 +249,10 @@ func (s *ApartmantService) Start(serviceName string) error {
 	s.setupSignalHandling()
 	go s.notificationService.ListenForUpdates(context.Background())
 
+	s.runPeriodically(recurringBillsInterval, "generate recurring bills", s.recurringBillService.GenerateDueBills)
+	s.runPeriodically(lateFeesInterval, "apply late fees", s.lateFeeService.ApplyLateFees)
+	s.runPeriodically(checkoutExpiryInterval, "expire stale checkouts", s.checkoutService.ExpireStaleCheckouts)
+
 	s.shutdownWG.Add(1)
 	go func() {
 		defer s.shutdownWG.Done()
@@ -132,6 +266,33 @@ func (s *ApartmantService) Start(serviceName string) error {
 	return nil
 }
 
+// runs a background job on start and then on every tick until shutdown.
+// the job reports how many records it changed, which is only logged
+func (s *ApartmantService) runPeriodically(interval time.Duration, name string, job func(context.Context, time.Time) (int, error)) {
+	s.shutdownWG.Add(1)
+	go func() {
+		defer s.shutdownWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			changed, err := job(s.shutdownCtx, time.Now())
+			if err != nil && s.shutdownCtx.Err() == nil {
+				log.Printf("failed to %s: %v", name, err)
+			} else if changed > 0 {
+				log.Printf("%s: %d changed", name, changed)
+			}
+
+			select {
+			case <-s.shutdownCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
 func (s *ApartmantService) methodHandler(methods map[string]http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		handler, exists := methods[r.Method]
@@ -166,6 +327,11 @@ func (s *ApartmantService) addCommonRoutes(mux *http.ServeMux, serviceName strin
 	mux.HandleFunc("/health", utils.MethodHandler(map[string]http.HandlerFunc{
 		"GET": utils.HealthCheck(serviceName),
 	}))
+
+	// gateways that host their own checkout page, like the simulator, serve it from here
+	if page, ok := s.paymentGateway.(http.Handler); ok {
+		mux.Handle("/simulator/", page)
+	}
 }
 
 func (s *ApartmantService) Stop() error {
@@ -177,6 +343,7 @@ func (s *ApartmantService) Stop() error {
 		log.Printf("server forced to shutdown: %v", err)
 		return err
 	}
+	s.cancelFunc()
 	s.shutdownWG.Wait()
 
 	return nil
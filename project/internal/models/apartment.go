@@ -2,8 +2,21 @@ package models
 
 type Apartment struct {
 	BaseModel
-	ApartmentName string `json:"apartment_name" db:"apartment_name"`
-	Address       string `json:"address" db:"address"`
-	UnitsCount    int    `json:"units_count" db:"units_count"`
-	ManagerID     int    `json:"manager_id" db:"manager_id"`
+	ApartmentName   string          `json:"apartment_name" db:"apartment_name"`
+	Address         string          `json:"address" db:"address"`
+	UnitsCount      int             `json:"units_count" db:"units_count"`
+	ManagerID       int             `json:"manager_id" db:"manager_id"`
+	ShareVisibility ShareVisibility `json:"share_visibility" db:"share_visibility"`
+}
+
+// how residents see each other's shares of a bill
+type ShareVisibility string
+
+const (
+	AnonymousShares ShareVisibility = "anonymous" // amounts and statuses only, the default
+	NamedShares     ShareVisibility = "named"
+)
+
+func (v ShareVisibility) Valid() bool {
+	return v == AnonymousShares || v == NamedShares
 }
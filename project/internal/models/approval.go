@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// bills at or above the threshold need sign-off from a second manager or the representative before
+// they can be divided
+type ApprovalPolicy struct {
+	BaseModel
+	ApartmentID      int            `json:"apartment_id" db:"apartment_id"`
+	Threshold        money.Amount   `json:"threshold" db:"threshold"`
+	Currency         money.Currency `json:"currency" db:"currency"`
+	RepresentativeID *int           `json:"representative_id,omitempty" db:"representative_id"` // elected resident who can approve too
+}
+
+// bills in another currency can't be compared with the threshold, so they always need approval
+func (p ApprovalPolicy) Requires(bill Bill) bool {
+	return bill.Currency != p.Currency || bill.TotalAmount >= p.Threshold
+}
+
+// one step of a bill's approval, the request itself or a decision on it
+type BillApproval struct {
+	ID        int              `json:"id" db:"id"`
+	BillID    int              `json:"bill_id" db:"bill_id"`
+	UserID    int              `json:"user_id" db:"user_id"`
+	Decision  ApprovalDecision `json:"decision" db:"decision"`
+	Comment   string           `json:"comment" db:"comment"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+}
+
+type ApprovalDecision string
+
+const (
+	ApprovalRequested ApprovalDecision = "requested"
+	BillApproved      ApprovalDecision = "approved"
+	BillRejected      ApprovalDecision = "rejected" // the bill goes back to draft to be fixed
+)
+
+// the status the bill moves to with the decision. a bill that already has shares goes back to divided
+// either way, a rejected one is not re-divided until an approval comes
+func (d ApprovalDecision) BillStatus(divided bool) BillStatus {
+	switch {
+	case d == ApprovalRequested:
+		return BillPendingApproval
+	case divided:
+		return BillDivided
+	case d == BillApproved:
+		return BillPublished
+	default:
+		return BillDraft
+	}
+}
@@ -1,14 +1,63 @@
 package models
 
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
 type Bill struct {
 	BaseModel
-	ApartmentID     int      `json:"apartment_id" db:"apartment_id"`
-	BillType        BillType `json:"bill_type" db:"bill_type"`
-	TotalAmount     float64  `json:"total_amount" db:"total_amount"`
-	DueDate         string   `json:"due_date" db:"due_date"`
-	BillingDeadline string   `json:"billing_deadline" db:"billing_deadline"`
-	Description     string   `json:"description" db:"description"`
-	ImageURL        string   `json:"image_url" db:"image_url"`
+	ApartmentID     int            `json:"apartment_id" db:"apartment_id"`
+	BillType        BillType       `json:"bill_type" db:"bill_type"`
+	TotalAmount     money.Amount   `json:"total_amount" db:"total_amount"`
+	Currency        money.Currency `json:"currency" db:"currency"`
+	DueDate         string         `json:"due_date" db:"due_date"`
+	BillingDeadline string         `json:"billing_deadline" db:"billing_deadline"`
+	PeriodStart     *time.Time     `json:"period_start,omitempty" db:"period_start"` // billing period, both ends inclusive
+	PeriodEnd       *time.Time     `json:"period_end,omitempty" db:"period_end"`
+	Description     string         `json:"description" db:"description"`
+	ImageURL        string         `json:"image_url" db:"image_url"`
+	Status          BillStatus     `json:"status" db:"status"`
+	AutoDivide      bool           `json:"auto_divide,omitempty" db:"auto_divide"` // generated by a template that divides its bills, divided once approved
+	LineItems       []BillLineItem `json:"line_items,omitempty" db:"-"`            // when set, the total is their sum
+	Icon            string         `json:"icon,omitempty" db:"-"`                  // from the bill's category, for notifications
+}
+
+// one component of an itemized bill, like its base charge or the consumption part
+type BillLineItem struct {
+	ID            int              `json:"id" db:"id"`
+	BillID        int              `json:"bill_id" db:"bill_id"`
+	Name          string           `json:"name" db:"name"`
+	Category      LineItemCategory `json:"category" db:"category"`
+	Amount        money.Amount     `json:"amount" db:"amount"`
+	SplitStrategy SplitStrategy    `json:"split_strategy,omitempty" db:"split_strategy"` // empty follows the bill's split policy
+}
+
+type LineItemCategory string
+
+const (
+	BaseCharge        LineItemCategory = "base"
+	ConsumptionCharge LineItemCategory = "consumption"
+	TaxCharge         LineItemCategory = "tax"
+	ServiceFee        LineItemCategory = "service_fee"
+	OtherCharge       LineItemCategory = "other"
+)
+
+var LineItemCategories = map[LineItemCategory]bool{
+	BaseCharge:        true,
+	ConsumptionCharge: true,
+	TaxCharge:         true,
+	ServiceFee:        true,
+	OtherCharge:       true,
+}
+
+func LineItemsTotal(items []BillLineItem) money.Amount {
+	var total money.Amount
+	for _, item := range items {
+		total += item.Amount
+	}
+	return total
 }
 
 type BillType string
@@ -19,4 +68,48 @@ const (
 	GasBill         BillType = "gas"
 	MaintenanceBill BillType = "maintenance"
 	OtherBill       BillType = "other"
+	ReserveFundBill BillType = "reserve_fund" // contributions to the apartment's reserve fund
 )
+
+type BillStatus string
+
+const (
+	BillDraft           BillStatus = "draft"            // still being prepared, only managers see it
+	BillPendingApproval BillStatus = "pending_approval" // above the approval threshold, waiting for sign-off
+	BillPublished       BillStatus = "published"        // visible to residents, waiting to be divided
+	BillDivided         BillStatus = "divided"          // residents have their shares
+	BillSettled         BillStatus = "settled"          // nothing is left to pay on any of its payments
+	BillCancelled       BillStatus = "cancelled"        // voided along with its unpaid payments
+)
+
+// the statuses a bill can move to from each status. a rejected bill goes back to draft, and a
+// published or divided one is sent back for approval when an edit raises it above the threshold.
+// one that already has shares goes back to divided with the decision. a settled bill goes back to
+// divided when it gets something to pay again, like an extra charge after an edit. cancelled bills
+// are final
+var billTransitions = map[BillStatus][]BillStatus{
+	BillDraft:           {BillPendingApproval, BillPublished, BillCancelled},
+	BillPendingApproval: {BillPublished, BillDivided, BillDraft, BillCancelled},
+	BillPublished:       {BillPendingApproval, BillDivided, BillCancelled},
+	BillDivided:         {BillPendingApproval, BillSettled, BillCancelled},
+	BillSettled:         {BillPendingApproval, BillDivided},
+}
+
+// drafts and bills waiting for approval stay with the managers
+func (s BillStatus) VisibleToResidents() bool {
+	return s != BillDraft && s != BillPendingApproval
+}
+
+func (s BillStatus) CanTransitionTo(next BillStatus) bool {
+	for _, allowed := range billTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// divided and settled bills already have their shares
+func (s BillStatus) Divided() bool {
+	return s == BillDivided || s == BillSettled
+}
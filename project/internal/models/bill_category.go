@@ -0,0 +1,32 @@
+package models
+
+// a kind of bill an apartment is charged for. the built-in types are available to every apartment,
+// managers can add their own categories or change the defaults of a built-in one
+type BillCategory struct {
+	BaseModel
+	ApartmentID     int           `json:"apartment_id" db:"apartment_id"`
+	Name            BillType      `json:"name" db:"name"`
+	Icon            string        `json:"icon" db:"icon"`                                     // shown in telegram messages
+	DefaultStrategy SplitStrategy `json:"default_split_strategy" db:"default_split_strategy"` // used when no split policy is set for the type
+	DueAfterDays    int           `json:"due_after_days" db:"due_after_days"`                 // due date of bills created without one, 0 keeps it required
+	BuiltIn         bool          `json:"built_in" db:"-"`
+}
+
+// the categories every apartment starts with
+var BuiltInBillCategories = []BillCategory{
+	{Name: WaterBill, Icon: "💧", BuiltIn: true},
+	{Name: ElectricityBill, Icon: "⚡", BuiltIn: true},
+	{Name: GasBill, Icon: "🔥", BuiltIn: true},
+	{Name: MaintenanceBill, Icon: "🛠", BuiltIn: true},
+	{Name: OtherBill, Icon: "🧾", BuiltIn: true},
+	{Name: ReserveFundBill, Icon: "🏦", BuiltIn: true},
+}
+
+func BuiltInBillCategory(name BillType) (BillCategory, bool) {
+	for _, category := range BuiltInBillCategories {
+		if category.Name == name {
+			return category, true
+		}
+	}
+	return BillCategory{}, false
+}
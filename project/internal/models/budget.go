@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// what an apartment plans to spend on a bill category in one month
+type Budget struct {
+	BaseModel
+	ApartmentID int            `json:"apartment_id" db:"apartment_id"`
+	Category    BillType       `json:"category" db:"category"`
+	Year        int            `json:"year" db:"year"`
+	Month       int            `json:"month" db:"month"` // 1 to 12
+	Amount      money.Amount   `json:"amount" db:"amount"`
+	Currency    money.Currency `json:"currency" db:"currency"`
+}
+
+// variance is planned minus actual, so a negative variance is overspending
+type BudgetMonth struct {
+	Month    int          `json:"month"`
+	Planned  money.Amount `json:"planned"`
+	Actual   money.Amount `json:"actual"`
+	Variance money.Amount `json:"variance"`
+}
+
+type BudgetCategoryReport struct {
+	Category         BillType      `json:"category"`
+	Months           []BudgetMonth `json:"months"`
+	Planned          money.Amount  `json:"planned"`
+	Actual           money.Amount  `json:"actual"`
+	Variance         money.Amount  `json:"variance"`
+	Forecast         money.Amount  `json:"forecast"`          // expected total by the end of the year
+	ForecastVariance money.Amount  `json:"forecast_variance"` // planned minus forecast
+}
+
+type BudgetReport struct {
+	ApartmentID      int                    `json:"apartment_id"`
+	Year             int                    `json:"year"`
+	Currency         money.Currency         `json:"currency"`
+	Categories       []BudgetCategoryReport `json:"categories"`
+	Planned          money.Amount           `json:"planned"`
+	Actual           money.Amount           `json:"actual"`
+	Variance         money.Amount           `json:"variance"`
+	Forecast         money.Amount           `json:"forecast"`
+	ForecastVariance money.Amount           `json:"forecast_variance"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+type DisputeStatus string
+
+const (
+	DisputeOpen      DisputeStatus = "open"
+	DisputeRefunding DisputeStatus = "refunding" // approved, the money is on its way back
+	DisputeRejected  DisputeStatus = "rejected"
+	DisputeRefunded  DisputeStatus = "refunded"
+)
+
+// where an approved refund goes
+type RefundMethod string
+
+const (
+	RefundToGateway RefundMethod = "gateway" // back through the provider the resident paid with
+	RefundToWallet  RefundMethod = "wallet"
+)
+
+// a resident's request to get (part of) a payment back
+type PaymentDispute struct {
+	BaseModel
+	PaymentID      int            `json:"payment_id" db:"payment_id"`
+	UserID         int            `json:"user_id" db:"user_id"`
+	Amount         money.Amount   `json:"amount" db:"amount"`
+	Currency       money.Currency `json:"currency" db:"currency"`
+	Reason         string         `json:"reason" db:"reason"`
+	Status         DisputeStatus  `json:"status" db:"status"`
+	RefundMethod   *RefundMethod  `json:"refund_method,omitempty" db:"refund_method"`
+	RefundRef      string         `json:"refund_ref,omitempty" db:"refund_ref"` // the provider's refund ids
+	ResolvedBy     *int           `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolutionNote string         `json:"resolution_note,omitempty" db:"resolution_note"`
+	ResolvedAt     *time.Time     `json:"resolved_at,omitempty" db:"resolved_at"`
+	Events         []PaymentEvent `json:"events,omitempty" db:"-"`
+}
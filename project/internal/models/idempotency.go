@@ -0,0 +1,19 @@
+package models
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyInFlight  IdempotencyStatus = "in_flight" // the first request with the key is still running
+	IdempotencyCompleted IdempotencyStatus = "completed"
+)
+
+// what is kept under an idempotency key: a fingerprint of the request that claimed it and, once that
+// request finished, its full response to replay for retries
+type IdempotencyRecord struct {
+	Fingerprint string              `json:"fingerprint"`
+	Token       string              `json:"token,omitempty"` // set while in flight, only the claim holding it may release the key
+	Status      IdempotencyStatus   `json:"status"`
+	StatusCode  int                 `json:"status_code,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// spreads what is left of one resident's share over several scheduled payments
+type InstallmentPlan struct {
+	BaseModel
+	PaymentID    int           `json:"payment_id" db:"payment_id"`
+	UserID       int           `json:"user_id" db:"user_id"`
+	CreatedBy    int           `json:"created_by" db:"created_by"`
+	Installments []Installment `json:"installments" db:"-"`
+}
+
+type Installment struct {
+	BaseModel
+	PlanID   int           `json:"plan_id" db:"plan_id"`
+	Sequence int           `json:"sequence" db:"sequence"`
+	Amount   money.Amount  `json:"amount" db:"amount"`
+	DueDate  time.Time     `json:"due_date" db:"due_date"`
+	Status   PaymentStatus `json:"status" db:"status"` // pending or paid
+	PaidAt   *time.Time    `json:"paid_at,omitempty" db:"paid_at"`
+}
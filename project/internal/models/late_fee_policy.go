@@ -0,0 +1,23 @@
+package models
+
+import "github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+
+// how an apartment charges residents whose share is still pending after the bill's deadline
+type LateFeePolicy struct {
+	BaseModel
+	ApartmentID int          `json:"apartment_id" db:"apartment_id"`
+	FeeType     LateFeeType  `json:"fee_type" db:"fee_type"`
+	FlatAmount  money.Amount `json:"flat_amount" db:"flat_amount"`
+	Percentage  float64      `json:"percentage" db:"percentage"` // of the share, for percentage fees
+	DailyRate   float64      `json:"daily_rate" db:"daily_rate"` // percent of the share per overdue day
+	Cap         money.Amount `json:"cap" db:"cap"`               // zero means no cap
+	GraceDays   int          `json:"grace_days" db:"grace_days"`
+}
+
+type LateFeeType string
+
+const (
+	FlatLateFee       LateFeeType = "flat"
+	PercentageLateFee LateFeeType = "percentage"
+	DailyInterestFee  LateFeeType = "daily_interest"
+)
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// the accounts of an apartment's books. receivable and wallet accounts are kept per resident
+type LedgerAccount string
+
+const (
+	ResidentReceivable LedgerAccount = "resident_receivable" // what a resident still owes
+	ResidentWallet     LedgerAccount = "resident_wallet"     // prepaid credit the apartment holds for a resident
+	ApartmentFund      LedgerAccount = "apartment_fund"      // money collected
+	UtilityPayable     LedgerAccount = "utility_payable"     // what the apartment owes for its bills
+	BillsToDivide      LedgerAccount = "bills_to_divide"     // bill amounts not charged to residents yet
+	PenaltyIncome      LedgerAccount = "penalty_income"
+	BadDebt            LedgerAccount = "bad_debt"
+	WalletAdjustments  LedgerAccount = "wallet_adjustments" // manager corrections of wallet balances
+	ReserveFund        LedgerAccount = "reserve_fund"       // set aside for repairs, billed like a bill and spent on capital expenses
+)
+
+type JournalEntryType string
+
+const (
+	BillEntry             JournalEntryType = "bill"
+	BillChangeEntry       JournalEntryType = "bill_change"
+	DivisionEntry         JournalEntryType = "division"
+	RedivisionEntry       JournalEntryType = "redivision"
+	PenaltyEntry          JournalEntryType = "penalty"
+	PaymentEntry          JournalEntryType = "payment"
+	TopUpEntry            JournalEntryType = "top_up"
+	WalletAdjustmentEntry JournalEntryType = "wallet_adjustment"
+	RefundEntry           JournalEntryType = "refund"
+	WriteOffEntry         JournalEntryType = "write_off"
+	CancellationEntry     JournalEntryType = "cancellation"
+	CapitalExpenseEntry   JournalEntryType = "capital_expense"
+)
+
+// one balanced posting, the debits of its lines always equal the credits
+type JournalEntry struct {
+	ID          int              `json:"id" db:"id"`
+	ApartmentID int              `json:"apartment_id" db:"apartment_id"`
+	EntryType   JournalEntryType `json:"entry_type" db:"entry_type"`
+	Reference   string           `json:"reference" db:"reference"` // what caused it, like bill:11 or transaction:4
+	Description string           `json:"description" db:"description"`
+	Currency    money.Currency   `json:"currency" db:"currency"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	Lines       []JournalLine    `json:"lines" db:"-"`
+}
+
+type JournalLine struct {
+	ID      int           `json:"id" db:"id"`
+	EntryID int           `json:"entry_id" db:"entry_id"`
+	Account LedgerAccount `json:"account" db:"account"`
+	UserID  *int          `json:"user_id,omitempty" db:"user_id"`
+	Debit   money.Amount  `json:"debit" db:"debit"`
+	Credit  money.Amount  `json:"credit" db:"credit"`
+}
+
+func NewJournalEntry(apartmentID int, entryType JournalEntryType, reference, description string, currency money.Currency) *JournalEntry {
+	return &JournalEntry{
+		ApartmentID: apartmentID,
+		EntryType:   entryType,
+		Reference:   reference,
+		Description: description,
+		Currency:    currency,
+	}
+}
+
+// adds a debit line, a negative amount is booked as a credit so corrections read naturally
+func (e *JournalEntry) Debit(account LedgerAccount, userID *int, amount money.Amount) *JournalEntry {
+	switch {
+	case amount > 0:
+		e.Lines = append(e.Lines, JournalLine{Account: account, UserID: userID, Debit: amount})
+	case amount < 0:
+		e.Lines = append(e.Lines, JournalLine{Account: account, UserID: userID, Credit: -amount})
+	}
+	return e
+}
+
+func (e *JournalEntry) Credit(account LedgerAccount, userID *int, amount money.Amount) *JournalEntry {
+	return e.Debit(account, userID, -amount)
+}
+
+func (e *JournalEntry) Balanced() bool {
+	var debits, credits money.Amount
+	for _, line := range e.Lines {
+		debits += line.Debit
+		credits += line.Credit
+	}
+	return len(e.Lines) > 0 && debits == credits
+}
+
+// debits minus credits of one account
+type AccountBalance struct {
+	Account  LedgerAccount  `json:"account" db:"account"`
+	UserID   *int           `json:"user_id,omitempty" db:"user_id"`
+	Currency money.Currency `json:"currency" db:"currency"`
+	Debit    money.Amount   `json:"debit" db:"debit"`
+	Credit   money.Amount   `json:"credit" db:"credit"`
+	Balance  money.Amount   `json:"balance" db:"balance"`
+}
+
+// a line of an account statement with the balance after it
+type AccountLine struct {
+	EntryID        int              `json:"entry_id" db:"entry_id"`
+	EntryType      JournalEntryType `json:"entry_type" db:"entry_type"`
+	Reference      string           `json:"reference" db:"reference"`
+	Description    string           `json:"description" db:"description"`
+	Currency       money.Currency   `json:"currency" db:"currency"`
+	Debit          money.Amount     `json:"debit" db:"debit"`
+	Credit         money.Amount     `json:"credit" db:"credit"`
+	RunningBalance money.Amount     `json:"running_balance" db:"running_balance"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+}
+
+func (a LedgerAccount) Valid() bool {
+	switch a {
+	case ResidentReceivable, ResidentWallet, ApartmentFund, UtilityPayable, BillsToDivide, PenaltyIncome, BadDebt, WalletAdjustments, ReserveFund:
+		return true
+	}
+	return false
+}
+
+// the account a bill's amount is owed to. reserve contributions stay with the apartment instead of a utility
+func (t BillType) PayableAccount() LedgerAccount {
+	if t == ReserveFundBill {
+		return ReserveFund
+	}
+	return UtilityPayable
+}
+
+// the net balance of every account on its debit or credit side, per currency
+type TrialBalance struct {
+	Currency    money.Currency     `json:"currency"`
+	Accounts    []TrialBalanceLine `json:"accounts"`
+	TotalDebit  money.Amount       `json:"total_debit"`
+	TotalCredit money.Amount       `json:"total_credit"`
+	Balanced    bool               `json:"balanced"`
+}
+
+type TrialBalanceLine struct {
+	Account LedgerAccount `json:"account"`
+	Debit   money.Amount  `json:"debit"`
+	Credit  money.Amount  `json:"credit"`
+}
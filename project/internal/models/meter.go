@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// a sub-meter installed for one unit (membership) of an apartment
+type Meter struct {
+	BaseModel
+	ApartmentID  int      `json:"apartment_id" db:"apartment_id"`
+	UserID       int      `json:"user_id" db:"user_id"`
+	MeterType    BillType `json:"meter_type" db:"meter_type"` // water, electricity or gas
+	SerialNumber string   `json:"serial_number" db:"serial_number"`
+}
+
+type MeterReading struct {
+	BaseModel
+	MeterID     int       `json:"meter_id" db:"meter_id"`
+	Value       float64   `json:"value" db:"reading_value"`
+	ReadAt      time.Time `json:"read_at" db:"read_at"`
+	ImageKey    string    `json:"image_key,omitempty" db:"image_key"`
+	SubmittedBy int       `json:"submitted_by" db:"submitted_by"`
+}
+
+// meter types that can be billed by consumption
+var MeteredBillTypes = map[BillType]bool{
+	WaterBill:       true,
+	ElectricityBill: true,
+	GasBill:         true,
+}
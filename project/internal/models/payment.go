@@ -1,20 +1,96 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
 
 type Payment struct {
 	BaseModel
-	BillID        int           `json:"bill_id" db:"bill_id"`
-	UserID        int           `json:"user_id" db:"user_id"`
-	Amount        string        `json:"amount" db:"amount"` // using string to handle decimal values
-	PaidAt        time.Time     `json:"paid_at" db:"paid_at"`
-	PaymentStatus PaymentStatus `json:"payment_status" db:"payment_status"`
+	BillID          int             `json:"bill_id" db:"bill_id"`
+	UserID          int             `json:"user_id" db:"user_id"`
+	Amount          money.Amount    `json:"amount" db:"amount"`
+	AmountPaid      money.Amount    `json:"amount_paid" db:"amount_paid"`
+	AmountRefunded  money.Amount    `json:"amount_refunded" db:"amount_refunded"`
+	Currency        money.Currency  `json:"currency" db:"currency"`
+	PaidAt          time.Time       `json:"paid_at" db:"paid_at"`
+	PaymentStatus   PaymentStatus   `json:"payment_status" db:"payment_status"`
+	SplitStrategy   SplitStrategy   `json:"split_strategy" db:"split_strategy"`
+	Kind            PaymentKind     `json:"kind" db:"kind"`
+	ParentPaymentID *int            `json:"parent_payment_id,omitempty" db:"parent_payment_id"` // the share an adjustment or penalty belongs to
+	Breakdown       []ShareLineItem `json:"breakdown,omitempty" db:"-"`                         // what the payment covers of each line item of an itemized bill
+	OnInstallments  bool            `json:"on_installments,omitempty" db:"on_installments"`     // paid through an installment plan, only filled in for unpaid payments
 }
 
+// the part of a payment that covers one line item of its bill
+type ShareLineItem struct {
+	PaymentID  int              `json:"-" db:"payment_id"`
+	LineItemID int              `json:"line_item_id" db:"line_item_id"`
+	Name       string           `json:"name" db:"name"`
+	Category   LineItemCategory `json:"category" db:"category"`
+	Amount     money.Amount     `json:"amount" db:"amount"`
+}
+
+type PaymentKind string
+
+const (
+	ShareKind      PaymentKind = "share"      // a resident's part of a bill
+	AdjustmentKind PaymentKind = "adjustment" // extra charge (positive) or credit (negative) after a bill changed
+	PenaltyKind    PaymentKind = "penalty"    // late fee on an overdue share
+)
+
 type PaymentStatus string
 
 const (
-	Pending PaymentStatus = "pending"
-	Paid    PaymentStatus = "paid"
-	Failed  PaymentStatus = "failed"
+	Pending       PaymentStatus = "pending"
+	PartiallyPaid PaymentStatus = "partially_paid"
+	Processing    PaymentStatus = "processing" // a gateway checkout is open for it
+	Paid          PaymentStatus = "paid"
+	Failed        PaymentStatus = "failed"
+	WrittenOff    PaymentStatus = "written_off" // the manager gave up collecting what was left
+	Disputed      PaymentStatus = "disputed"    // the resident asked for money back, the manager hasn't decided yet
+	Refunded      PaymentStatus = "refunded"    // everything paid was given back
+	Cancelled     PaymentStatus = "cancelled"   // voided with its bill before anything was paid
 )
+
+// the statuses a payment can move to from each status. written off, refunded and cancelled payments
+// are final
+var paymentTransitions = map[PaymentStatus][]PaymentStatus{
+	Pending:       {PartiallyPaid, Processing, Paid, Failed, WrittenOff, Cancelled},
+	PartiallyPaid: {Processing, Paid, WrittenOff, Disputed},
+	Processing:    {Pending, PartiallyPaid, Paid, Failed},
+	Paid:          {Disputed, Refunded},
+	Failed:        {Pending, Processing, Cancelled},
+	Disputed:      {PartiallyPaid, Paid, Refunded},
+}
+
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range paymentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// what is still left to pay
+func (p Payment) Outstanding() money.Amount {
+	return p.Amount - p.AmountPaid
+}
+
+// what was paid and not given back yet
+func (p Payment) Refundable() money.Amount {
+	return p.AmountPaid - p.AmountRefunded
+}
+
+// one status change of a payment, the first event of a payment has no from status
+type PaymentEvent struct {
+	ID         int           `json:"id" db:"id"`
+	PaymentID  int           `json:"payment_id" db:"payment_id"`
+	FromStatus PaymentStatus `json:"from_status" db:"from_status"`
+	ToStatus   PaymentStatus `json:"to_status" db:"to_status"`
+	ActorID    *int          `json:"actor_id,omitempty" db:"actor_id"` // nil for changes made by the system
+	Reason     string        `json:"reason" db:"reason"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+}
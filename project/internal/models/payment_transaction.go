@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// one checkout at the payment gateway, covering one or more payments of a resident or a wallet top-up
+type PaymentTransaction struct {
+	BaseModel
+	UserID         int               `json:"user_id" db:"user_id"`
+	Gateway        string            `json:"gateway" db:"gateway"`
+	SessionID      *string           `json:"session_id,omitempty" db:"session_id"`
+	RedirectURL    string            `json:"redirect_url" db:"redirect_url"`
+	Amount         money.Amount      `json:"amount" db:"amount"`
+	Currency       money.Currency    `json:"currency" db:"currency"`
+	Status         TransactionStatus `json:"status" db:"status"`
+	IdempotencyKey string            `json:"-" db:"idempotency_key"`
+	GatewayRef     string            `json:"gateway_ref,omitempty" db:"gateway_ref"` // the gateway's id of the captured payment
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty" db:"expires_at"`
+	WalletID       *int              `json:"wallet_id,omitempty" db:"wallet_id"` // set on top-ups, the wallet the payment is credited to
+	Items          []TransactionItem `json:"items" db:"-"`
+}
+
+// the part of a transaction that goes to one payment, and to one installment of it when paying a plan
+type TransactionItem struct {
+	ID            int          `json:"id" db:"id"`
+	TransactionID int          `json:"transaction_id" db:"transaction_id"`
+	PaymentID     int          `json:"payment_id" db:"payment_id"`
+	InstallmentID *int         `json:"installment_id,omitempty" db:"installment_id"`
+	Amount        money.Amount `json:"amount" db:"amount"`
+}
+
+type TransactionStatus string
+
+// payments settled from a wallet balance are recorded under this gateway name
+const WalletGateway = "wallet"
+
+const (
+	TransactionProcessing TransactionStatus = "processing"
+	TransactionSucceeded  TransactionStatus = "succeeded"
+	TransactionFailed     TransactionStatus = "failed"
+	TransactionExpired    TransactionStatus = "expired"
+)
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// a bill that is generated automatically every IntervalMonths months on DayOfMonth
+type RecurringBill struct {
+	BaseModel
+	ApartmentID    int            `json:"apartment_id" db:"apartment_id"`
+	CreatedBy      int            `json:"created_by" db:"created_by"`
+	BillType       BillType       `json:"bill_type" db:"bill_type"`
+	Amount         money.Amount   `json:"amount" db:"amount"`
+	Currency       money.Currency `json:"currency" db:"currency"`
+	Description    string         `json:"description" db:"description"`
+	DayOfMonth     int            `json:"day_of_month" db:"day_of_month"` // 1-28 so every month has it
+	IntervalMonths int            `json:"interval_months" db:"interval_months"`
+	DueAfterDays   int            `json:"due_after_days" db:"due_after_days"`
+	StartDate      time.Time      `json:"start_date" db:"start_date"` // first month that is billed
+	AutoDivide     bool           `json:"auto_divide" db:"auto_divide"`
+	Active         bool           `json:"active" db:"active"`
+}
+
+// format of the period a recurring bill was generated for, e.g. 2025-03
+const RecurringPeriodLayout = "2006-01"
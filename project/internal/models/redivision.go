@@ -0,0 +1,27 @@
+package models
+
+// the changes that bring a divided bill's charges in line with a new split. it is planned from the
+// payments in Seen and only applied while they are still as they were
+type Redivision struct {
+	BillID      int
+	ApartmentID int
+	Seen        []Payment
+	Deleted     []int     // pending shares that fell to nothing
+	Updated     []Payment // pending shares changed in place, with their new amount and breakdown
+	Created     []Payment // new shares and adjustments, negative adjustments are paid out to the resident's wallet
+	Journal     *JournalEntry
+}
+
+// the existing payments the plan changes or charges against
+func (r Redivision) Touched() []int {
+	ids := append([]int{}, r.Deleted...)
+	for _, share := range r.Updated {
+		ids = append(ids, share.ID)
+	}
+	for _, payment := range r.Created {
+		if payment.ParentPaymentID != nil {
+			ids = append(ids, *payment.ParentPaymentID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// a repair or purchase paid from the apartment's reserve fund
+type CapitalExpense struct {
+	BaseModel
+	ApartmentID int            `json:"apartment_id" db:"apartment_id"`
+	Amount      money.Amount   `json:"amount" db:"amount"`
+	Currency    money.Currency `json:"currency" db:"currency"`
+	Description string         `json:"description" db:"description"`
+	ReceiptKey  string         `json:"-" db:"receipt_key"`
+	ReceiptURL  string         `json:"receipt_url,omitempty" db:"-"`
+	SpentAt     time.Time      `json:"spent_at" db:"spent_at"`
+	CreatedBy   int            `json:"created_by" db:"created_by"`
+}
+
+type ReserveTransactionType string
+
+const (
+	ReserveContribution ReserveTransactionType = "contribution" // what a resident paid of a reserve fund bill
+	ReserveExpense      ReserveTransactionType = "expense"
+)
+
+// money in or out of the reserve fund, the amount is always positive
+type ReserveTransaction struct {
+	Type        ReserveTransactionType `json:"type" db:"type"`
+	Amount      money.Amount           `json:"amount" db:"amount"`
+	Currency    money.Currency         `json:"currency" db:"currency"`
+	BillID      *int                   `json:"bill_id,omitempty" db:"bill_id"`
+	UserID      *int                   `json:"user_id,omitempty" db:"user_id"`
+	ExpenseID   *int                   `json:"expense_id,omitempty" db:"expense_id"`
+	Description string                 `json:"description" db:"description"`
+	ReceiptKey  string                 `json:"-" db:"receipt_key"`
+	ReceiptURL  string                 `json:"receipt_url,omitempty" db:"-"`
+	OccurredAt  time.Time              `json:"occurred_at" db:"occurred_at"`
+}
+
+type ReserveBalance struct {
+	Currency      money.Currency `json:"currency" db:"currency"`
+	Contributions money.Amount   `json:"contributions" db:"contributions"`
+	Expenses      money.Amount   `json:"expenses" db:"expenses"`
+	Balance       money.Amount   `json:"balance" db:"balance"`
+}
+
+type ReserveFundSummary struct {
+	ApartmentID  int                  `json:"apartment_id"`
+	Balances     []ReserveBalance     `json:"balances"` // one per currency
+	Transactions []ReserveTransaction `json:"transactions"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// decides how a bill is divided between the members of an apartment
+type SplitPolicy struct {
+	BaseModel
+	ApartmentID      int           `json:"apartment_id" db:"apartment_id"`
+	BillType         BillType      `json:"bill_type" db:"bill_type"` // empty means apartment-wide default
+	Strategy         SplitStrategy `json:"strategy" db:"strategy"`
+	EqualWeight      float64       `json:"equal_weight" db:"equal_weight"` // weights below are only used by mixed splits
+	AreaWeight       float64       `json:"area_weight" db:"area_weight"`
+	OccupantsWeight  float64       `json:"occupants_weight" db:"occupants_weight"`
+	PercentageWeight float64       `json:"percentage_weight" db:"percentage_weight"`
+}
+
+type SplitStrategy string
+
+const (
+	SplitEqual        SplitStrategy = "equal"
+	SplitByArea       SplitStrategy = "area"
+	SplitByOccupants  SplitStrategy = "occupants"
+	SplitByPercentage SplitStrategy = "percentage"
+	SplitMixed        SplitStrategy = "mixed"
+	SplitByMeter      SplitStrategy = "consumption" // only for metered bill types
+)
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// what a resident was charged and paid in an apartment over a period, from their receivable account.
+// a positive balance is what they still owe
+type Statement struct {
+	ApartmentID   int                `json:"apartment_id"`
+	ApartmentName string             `json:"apartment_name"`
+	UserID        int                `json:"user_id"`
+	ResidentName  string             `json:"resident_name"`
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"` // inclusive
+	Sections      []StatementSection `json:"sections"`
+}
+
+// the statement in one currency
+type StatementSection struct {
+	Currency       money.Currency  `json:"currency"`
+	OpeningBalance money.Amount    `json:"opening_balance"`
+	Charges        money.Amount    `json:"charges"`
+	Payments       money.Amount    `json:"payments"`
+	ClosingBalance money.Amount    `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+}
+
+// payments also cover the other credits, like write-offs and cancelled shares
+type StatementLine struct {
+	Date        time.Time        `json:"date"`
+	EntryType   JournalEntryType `json:"entry_type"`
+	Reference   string           `json:"reference"`
+	Description string           `json:"description"`
+	Charge      money.Amount     `json:"charge"`
+	Payment     money.Amount     `json:"payment"`
+	Balance     money.Amount     `json:"balance"`
+}
+
+// a generated PDF statement kept in storage so it can be downloaded again
+type StatementDocument struct {
+	BaseModel
+	ApartmentID int       `json:"apartment_id" db:"apartment_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	PeriodFrom  time.Time `json:"period_from" db:"period_from"`
+	PeriodTo    time.Time `json:"period_to" db:"period_to"`
+	DocumentKey string    `json:"-" db:"document_key"`
+	DocumentURL string    `json:"document_url,omitempty" db:"-"`
+}
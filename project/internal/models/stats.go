@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// the manager dashboard of an apartment. penalties are left out of billed and collected amounts so the
+// collection rate only measures the bills themselves
+type ApartmentStats struct {
+	ApartmentID       int                 `json:"apartment_id"`
+	From              time.Time           `json:"from"`
+	Collection        []CollectionSummary `json:"collection"` // one per currency
+	BilledVsCollected []MonthlyTotals     `json:"billed_vs_collected"`
+	DaysToPay         DaysToPay           `json:"days_to_pay"`
+	Categories        []CategoryTotals    `json:"categories"`
+	TopDebtors        []Debtor            `json:"top_debtors"`
+}
+
+// charged and collected cover the shares of bills in the period, outstanding and overdue are what is
+// open right now
+type CollectionSummary struct {
+	Currency        money.Currency `json:"currency" db:"currency"`
+	Charged         money.Amount   `json:"charged" db:"charged"`
+	Collected       money.Amount   `json:"collected" db:"collected"`
+	CollectionRate  float64        `json:"collection_rate" db:"-"` // percent of charged that was collected
+	Outstanding     money.Amount   `json:"outstanding" db:"outstanding"`
+	Overdue         money.Amount   `json:"overdue" db:"overdue"`
+	OverduePayments int            `json:"overdue_payments" db:"overdue_payments"`
+}
+
+// billed by the month of the bill's period (or due date), collected by the month it was paid
+type MonthlyTotals struct {
+	Month     time.Time      `json:"month" db:"month"`
+	Currency  money.Currency `json:"currency" db:"currency"`
+	Billed    money.Amount   `json:"billed" db:"billed"`
+	Collected money.Amount   `json:"collected" db:"collected"`
+}
+
+// how long residents took to pay their shares in full, from the day the share was charged
+type DaysToPay struct {
+	Payments     int               `json:"payments"`
+	AverageDays  float64           `json:"average_days"`
+	PaidLate     int               `json:"paid_late"` // after the bill's deadline
+	Distribution []DaysToPayBucket `json:"distribution"`
+}
+
+type DaysToPayBucket struct {
+	Bucket    int    `json:"-" db:"bucket"`
+	Label     string `json:"label" db:"-"`
+	Payments  int    `json:"payments" db:"payments"`
+	TotalDays int    `json:"-" db:"total_days"`
+	PaidLate  int    `json:"paid_late" db:"paid_late"`
+}
+
+type CategoryTotals struct {
+	Category  BillType       `json:"category" db:"category"`
+	Currency  money.Currency `json:"currency" db:"currency"`
+	Bills     int            `json:"bills" db:"bills"`
+	Billed    money.Amount   `json:"billed" db:"billed"`
+	Collected money.Amount   `json:"collected" db:"collected"`
+}
+
+// a resident with open payments, penalties included
+type Debtor struct {
+	UserID       int            `json:"user_id" db:"user_id"`
+	Username     string         `json:"username" db:"username"`
+	FullName     string         `json:"full_name" db:"full_name"`
+	Currency     money.Currency `json:"currency" db:"currency"`
+	Outstanding  money.Amount   `json:"outstanding" db:"outstanding"`
+	Overdue      money.Amount   `json:"overdue" db:"overdue"`
+	OpenPayments int            `json:"open_payments" db:"open_payments"`
+}
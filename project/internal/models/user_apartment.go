@@ -1,8 +1,15 @@
 package models
 
+import "time"
+
 type User_apartment struct {
 	BaseModel
-	UserID      int  `json:"user_id" db:"user_id"`
-	ApartmentID int  `json:"apartment_id" db:"apartment_id"`
-	IsManager   bool `json:"is_manager" db:"is_manager"`
+	UserID          int        `json:"user_id" db:"user_id"`
+	ApartmentID     int        `json:"apartment_id" db:"apartment_id"`
+	IsManager       bool       `json:"is_manager" db:"is_manager"`
+	UnitArea        float64    `json:"unit_area" db:"unit_area"`               // floor area in square meters
+	OccupantsCount  int        `json:"occupants_count" db:"occupants_count"`   // people living in the unit
+	SharePercentage float64    `json:"share_percentage" db:"share_percentage"` // fixed percentage for percentage splits
+	JoinedAt        time.Time  `json:"joined_at" db:"joined_at"`
+	LeftAt          *time.Time `json:"left_at,omitempty" db:"left_at"` // nil while the user still lives there
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// prepaid credit of a resident in one apartment
+type Wallet struct {
+	BaseModel
+	UserID      int            `json:"user_id" db:"user_id"`
+	ApartmentID int            `json:"apartment_id" db:"apartment_id"`
+	Currency    money.Currency `json:"currency" db:"currency"`
+	Balance     money.Amount   `json:"balance" db:"balance"`
+	AutoPay     bool           `json:"auto_pay" db:"auto_pay"` // settle new shares from the balance as soon as they are created
+}
+
+// one entry of a wallet's append-only ledger. credits are positive and debits negative
+type WalletTransaction struct {
+	ID                   int                   `json:"id" db:"id"`
+	WalletID             int                   `json:"wallet_id" db:"wallet_id"`
+	Type                 WalletTransactionType `json:"type" db:"type"`
+	Amount               money.Amount          `json:"amount" db:"amount"`
+	BalanceAfter         money.Amount          `json:"balance_after" db:"balance_after"`
+	PaymentID            *int                  `json:"payment_id,omitempty" db:"payment_id"`
+	PaymentTransactionID *int                  `json:"payment_transaction_id,omitempty" db:"payment_transaction_id"`
+	Note                 string                `json:"note,omitempty" db:"note"`
+	CreatedAt            time.Time             `json:"created_at" db:"created_at"`
+}
+
+type WalletTransactionType string
+
+const (
+	WalletTopUp      WalletTransactionType = "top_up"
+	WalletDeduction  WalletTransactionType = "deduction"
+	WalletRefund     WalletTransactionType = "refund"
+	WalletAdjustment WalletTransactionType = "adjustment"
+)
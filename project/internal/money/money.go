@@ -0,0 +1,260 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// number of minor units in one major unit; amounts are stored as DECIMAL(x,2)
+const minorUnits = 100
+
+type Currency string
+
+const (
+	IRR Currency = "IRR"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+
+	DefaultCurrency = IRR
+)
+
+// a valid currency is a three letter uppercase ISO 4217 code
+func (c Currency) Valid() bool {
+	if len(c) != 3 {
+		return false
+	}
+	for _, r := range c {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Amount is a monetary value counted in minor units, so 12.34 is stored as 1234
+type Amount int64
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// Parse reads a decimal string such as "12", "12.3" or "-12.34".
+// more than two fractional digits is rejected instead of silently rounded.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" && (!hasFrac || frac == "") {
+		return 0, ErrInvalidAmount
+	}
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("%w: %q has more than two decimals", ErrInvalidAmount, s)
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || major < 0 {
+		return 0, ErrInvalidAmount
+	}
+	minor, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || minor < 0 {
+		return 0, ErrInvalidAmount
+	}
+
+	value := major*minorUnits + minor
+	if negative {
+		value = -value
+	}
+	return Amount(value), nil
+}
+
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// only meant for display and logging, never for arithmetic
+func (a Amount) Float64() float64 {
+	return float64(a) / minorUnits
+}
+
+func (a Amount) String() string {
+	value := int64(a)
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/minorUnits, value%minorUnits)
+}
+
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Percent returns percent% of the amount, rounded half away from zero to the nearest minor unit
+func (a Amount) Percent(percent float64) Amount {
+	return Amount(math.Round(float64(a) * percent / 100))
+}
+
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// Allocate splits the amount proportionally to the weights so the shares always add up
+// to the amount. every share is first rounded towards zero, then the leftover minor units
+// are handed out one by one to the shares with the largest rounding remainder. ties go to
+// the earlier position, so callers that pass members in a stable order (e.g. by user id)
+// always get the same result.
+func (a Amount) Allocate(weights []float64) ([]Amount, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("no weights to allocate by")
+	}
+
+	rats := make([]*big.Rat, len(weights))
+	sum := new(big.Rat)
+	for i, weight := range weights {
+		if weight < 0 {
+			return nil, errors.New("weights cannot be negative")
+		}
+		rats[i] = new(big.Rat).SetFloat64(weight)
+		if rats[i] == nil {
+			return nil, errors.New("weights must be finite")
+		}
+		sum.Add(sum, rats[i])
+	}
+	if sum.Sign() == 0 {
+		return nil, errors.New("weights add up to zero")
+	}
+
+	total := big.NewRat(int64(a.Abs()), 1)
+	shares := make([]Amount, len(weights))
+	remainders := make([]*big.Rat, len(weights))
+	allocated := int64(0)
+
+	for i, weight := range rats {
+		exact := new(big.Rat).Mul(total, weight)
+		exact.Quo(exact, sum)
+
+		floor := new(big.Int).Quo(exact.Num(), exact.Denom())
+		shares[i] = Amount(floor.Int64())
+		allocated += floor.Int64()
+		remainders[i] = exact.Sub(exact, new(big.Rat).SetInt(floor))
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		return remainders[order[x]].Cmp(remainders[order[y]]) > 0
+	})
+
+	leftover := int64(a.Abs()) - allocated
+	for i := int64(0); i < leftover; i++ {
+		shares[order[i%int64(len(order))]]++
+	}
+
+	if a < 0 {
+		for i := range shares {
+			shares[i] = -shares[i]
+		}
+	}
+	return shares, nil
+}
+
+// stored as a decimal string so postgres keeps the exact value
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = Amount(v * minorUnits)
+		return nil
+	case float64:
+		return a.scanString(strconv.FormatFloat(v, 'f', 2, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	// postgres may return trailing zeros beyond the column scale, e.g. "12.3400"
+	if whole, frac, ok := strings.Cut(s, "."); ok && len(frac) > 2 {
+		frac = strings.TrimRight(frac, "0")
+		s = whole
+		if frac != "" {
+			s += "." + frac
+		}
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// encoded as a decimal string to avoid float rounding in clients
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// accepts both "12.34" and 12.34
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return ErrInvalidAmount
+		}
+		s = n.String()
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
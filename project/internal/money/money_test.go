@@ -0,0 +1,155 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+		wantErr  bool
+	}{
+		{input: "100", expected: 10000},
+		{input: "100.5", expected: 10050},
+		{input: "100.05", expected: 10005},
+		{input: "-0.01", expected: -1},
+		{input: ".5", expected: 50},
+		{input: " 12.34 ", expected: 1234},
+		{input: "1.234", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "1.-5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			amount, err := Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, amount)
+		})
+	}
+}
+
+func TestAmount_String(t *testing.T) {
+	assert.Equal(t, "0.00", Amount(0).String())
+	assert.Equal(t, "33.34", Amount(3334).String())
+	assert.Equal(t, "-0.05", Amount(-5).String())
+	assert.Equal(t, "1200.00", Amount(120000).String())
+}
+
+func TestAmount_Percent(t *testing.T) {
+	assert.Equal(t, Amount(500), Amount(10000).Percent(5))
+	assert.Equal(t, Amount(17), Amount(333).Percent(5))
+	assert.Equal(t, Amount(3), Amount(1000).Percent(0.25))
+	assert.Equal(t, Amount(-17), Amount(-333).Percent(5))
+}
+
+func TestAmount_Allocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Amount
+		weights  []float64
+		expected []Amount
+		wantErr  bool
+	}{
+		{
+			name:     "remainder goes to the first share on ties",
+			amount:   10000,
+			weights:  []float64{1, 1, 1},
+			expected: []Amount{3334, 3333, 3333},
+		},
+		{
+			name:     "remainder goes to the largest fraction",
+			amount:   100,
+			weights:  []float64{1, 2, 4},
+			expected: []Amount{14, 29, 57},
+		},
+		{
+			name:     "zero weight gets nothing",
+			amount:   1001,
+			weights:  []float64{0, 1, 1},
+			expected: []Amount{0, 501, 500},
+		},
+		{
+			name:     "negative amounts mirror positive ones",
+			amount:   -10000,
+			weights:  []float64{1, 1, 1},
+			expected: []Amount{-3334, -3333, -3333},
+		},
+		{
+			name:    "no weights",
+			amount:  100,
+			wantErr: true,
+		},
+		{
+			name:    "all weights zero",
+			amount:  100,
+			weights: []float64{0, 0},
+			wantErr: true,
+		},
+		{
+			name:    "negative weight",
+			amount:  100,
+			weights: []float64{1, -1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := tt.amount.Allocate(tt.weights)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, shares)
+			assert.Equal(t, tt.amount, Sum(shares...))
+		})
+	}
+}
+
+func TestAmount_JSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Total Amount `json:"total"`
+	}{Total: 1050})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":"10.50"}`, string(data))
+
+	var fromString, fromNumber Amount
+	assert.NoError(t, json.Unmarshal([]byte(`"10.50"`), &fromString))
+	assert.NoError(t, json.Unmarshal([]byte(`10.5`), &fromNumber))
+	assert.Equal(t, Amount(1050), fromString)
+	assert.Equal(t, Amount(1050), fromNumber)
+	assert.Error(t, json.Unmarshal([]byte(`"10.505"`), &fromString))
+}
+
+func TestAmount_Scan(t *testing.T) {
+	var amount Amount
+	assert.NoError(t, amount.Scan([]byte("12.3400")))
+	assert.Equal(t, Amount(1234), amount)
+	assert.NoError(t, amount.Scan(float64(75.25)))
+	assert.Equal(t, Amount(7525), amount)
+	assert.NoError(t, amount.Scan(int64(3)))
+	assert.Equal(t, Amount(300), amount)
+	assert.Error(t, amount.Scan(true))
+
+	value, err := Amount(-250).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "-2.50", value)
+}
+
+func TestCurrency_Valid(t *testing.T) {
+	assert.True(t, IRR.Valid())
+	assert.True(t, Currency("GBP").Valid())
+	assert.False(t, Currency("usd").Valid())
+	assert.False(t, Currency("EURO").Valid())
+}
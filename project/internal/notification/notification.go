@@ -10,13 +10,14 @@ import (
 
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/config"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
 )
 
 type Notification interface {
 	SendNotification(ctx context.Context, userID int, message string) error
 	SendInvitation(ctx context.Context, inviteURL string, apartmentID int, receiverUsername string) error
-	SendBillNotification(ctx context.Context, userID int, bill models.Bill, amount float64) error
+	SendBillNotification(ctx context.Context, userID int, bill models.Bill, amount money.Amount) error
 	ListenForUpdates(ctx context.Context)
 }
 
@@ -85,7 +86,7 @@ func (n *notificationImpl) SendInvitation(ctx context.Context, inviteURL string,
 	return n.sendMessage(receiver.TelegramChatID, message)
 }
 
-func (n *notificationImpl) SendBillNotification(ctx context.Context, userID int, bill models.Bill, amount float64) error {
+func (n *notificationImpl) SendBillNotification(ctx context.Context, userID int, bill models.Bill, amount money.Amount) error {
 	user, err := n.userRepo.GetUserByID(userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
@@ -95,13 +96,18 @@ func (n *notificationImpl) SendBillNotification(ctx context.Context, userID int,
 		return fmt.Errorf("user hasn't started the bot yet")
 	}
 
+	billType := string(bill.BillType)
+	if bill.Icon != "" {
+		billType = bill.Icon + " " + billType
+	}
+
 	message := fmt.Sprintf(
 		"*New Bill Notification*\n\n"+
 			"Type: %s\n"+
-			"Your Share: %.2f\n"+
+			"Your Share: %s %s\n"+
 			"Due Date: %s\n"+
 			"Description: %s\n",
-		bill.BillType, amount, bill.DueDate, bill.Description)
+		billType, amount, bill.Currency, bill.DueDate, bill.Description)
 
 	return n.sendMessage(user.TelegramChatID, message)
 }
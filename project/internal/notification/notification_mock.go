@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -21,7 +22,7 @@ func (m *MockNotification) SendInvitation(ctx context.Context, inviteURL string,
 	return args.Error(0)
 }
 
-func (m *MockNotification) SendBillNotification(ctx context.Context, userID int, bill models.Bill, amount float64) error {
+func (m *MockNotification) SendBillNotification(ctx context.Context, userID int, bill models.Bill, amount money.Amount) error {
 	args := m.Called(ctx, userID, bill, amount)
 	return args.Error(0)
 }
@@ -42,7 +43,7 @@ func (m *MockNotification) ExpectSendInvitation(ctx context.Context, inviteURL s
 	return m.On("SendInvitation", ctx, inviteURL, apartmentID, receiverUsername).Return(returnError)
 }
 
-func (m *MockNotification) ExpectSendBillNotification(ctx context.Context, userID int, bill models.Bill, amount float64, returnError error) *mock.Call {
+func (m *MockNotification) ExpectSendBillNotification(ctx context.Context, userID int, bill models.Bill, amount money.Amount, returnError error) *mock.Call {
 	return m.On("SendBillNotification", ctx, userID, bill, amount).Return(returnError)
 }
 
@@ -58,7 +59,7 @@ func (m *MockNotification) ExpectSendInvitationTimes(times int, ctx context.Cont
 	return m.On("SendInvitation", ctx, inviteURL, apartmentID, receiverUsername).Return(returnError).Times(times)
 }
 
-func (m *MockNotification) ExpectSendBillNotificationTimes(times int, ctx context.Context, userID int, bill models.Bill, amount float64, returnError error) *mock.Call {
+func (m *MockNotification) ExpectSendBillNotificationTimes(times int, ctx context.Context, userID int, bill models.Bill, amount money.Amount, returnError error) *mock.Call {
 	return m.On("SendBillNotification", ctx, userID, bill, amount).Return(returnError).Times(times)
 }
 
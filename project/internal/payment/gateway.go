@@ -0,0 +1,65 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// a payment provider. money only moves on the provider's side, the application starts a checkout,
+// sends the resident to the redirect url and trusts the outcome only after verifying the callback
+type Gateway interface {
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	VerifyCallback(ctx context.Context, params url.Values) (*CallbackResult, error)
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+}
+
+type CheckoutRequest struct {
+	Reference   string // our transaction reference, echoed back in the callback
+	Amount      money.Amount
+	Currency    money.Currency
+	Description string
+	CallbackURL string
+}
+
+type Checkout struct {
+	SessionID   string
+	RedirectURL string
+	ExpiresAt   time.Time
+}
+
+type CallbackStatus string
+
+const (
+	CallbackSucceeded CallbackStatus = "succeeded"
+	CallbackFailed    CallbackStatus = "failed"
+)
+
+type CallbackResult struct {
+	SessionID     string
+	Reference     string
+	Status        CallbackStatus
+	Amount        money.Amount
+	TransactionID string // the provider's id of the captured payment, needed for refunds
+}
+
+type RefundRequest struct {
+	SessionID     string
+	TransactionID string
+	Amount        money.Amount
+	Reason        string
+}
+
+type RefundResult struct {
+	RefundID string
+	Amount   money.Amount
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid callback signature")
+	ErrUnknownSession   = errors.New("unknown checkout session")
+)
@@ -0,0 +1,44 @@
+package payment
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGateway struct {
+	mock.Mock
+}
+
+func NewMockGateway() *MockGateway {
+	return &MockGateway{}
+}
+
+func (m *MockGateway) Name() string {
+	return "mock"
+}
+
+func (m *MockGateway) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	args := m.Called(ctx, req)
+	if checkout, ok := args.Get(0).(*Checkout); ok {
+		return checkout, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockGateway) VerifyCallback(ctx context.Context, params url.Values) (*CallbackResult, error) {
+	args := m.Called(ctx, params)
+	if result, ok := args.Get(0).(*CallbackResult); ok {
+		return result, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockGateway) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	args := m.Called(ctx, req)
+	if result, ok := args.Get(0).(*RefundResult); ok {
+		return result, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
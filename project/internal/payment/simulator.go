@@ -0,0 +1,233 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+const SimulatorName = "simulator"
+
+// what the resident does on the simulated checkout page
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	OutcomeTimeout Outcome = "timeout" // the resident walks away, no callback is ever sent
+)
+
+// an in-process gateway for local runs and tests. sessions live in memory and callbacks are signed
+// with a shared secret the same way a real provider would sign them
+type Simulator struct {
+	mu       sync.Mutex
+	baseURL  string
+	secret   []byte
+	ttl      time.Duration
+	sessions map[string]*simulatedSession
+	now      func() time.Time
+}
+
+type simulatedSession struct {
+	request       CheckoutRequest
+	expiresAt     time.Time
+	callback      url.Values // set once the checkout was completed or failed
+	timedOut      bool
+	transactionID string
+	refunded      money.Amount
+}
+
+func NewSimulator(baseURL, secret string, ttl time.Duration) *Simulator {
+	return &Simulator{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		secret:   []byte(secret),
+		ttl:      ttl,
+		sessions: make(map[string]*simulatedSession),
+		now:      time.Now,
+	}
+}
+
+func (s *Simulator) Name() string {
+	return SimulatorName
+}
+
+func (s *Simulator) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("checkout amount must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionID := randomID("cs")
+	session := &simulatedSession{request: req, expiresAt: s.now().Add(s.ttl)}
+	s.sessions[sessionID] = session
+
+	return &Checkout{
+		SessionID:   sessionID,
+		RedirectURL: s.baseURL + "/simulator/checkout/" + sessionID,
+		ExpiresAt:   session.expiresAt,
+	}, nil
+}
+
+// finishes a checkout and returns the signed callback parameters, or nil for a timeout.
+// completing a session again returns the very same callback, which is how providers retry webhooks
+func (s *Simulator) Complete(sessionID string, outcome Outcome) (url.Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, ErrUnknownSession
+	}
+	if session.callback != nil {
+		return session.callback, nil
+	}
+	if session.timedOut || s.now().After(session.expiresAt) {
+		session.timedOut = true
+		return nil, errors.New("checkout session expired")
+	}
+
+	var status CallbackStatus
+	switch outcome {
+	case OutcomeSuccess:
+		status = CallbackSucceeded
+		session.transactionID = randomID("tx")
+	case OutcomeFailure:
+		status = CallbackFailed
+	case OutcomeTimeout:
+		session.timedOut = true
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown outcome %q", outcome)
+	}
+
+	params := url.Values{}
+	params.Set("session_id", sessionID)
+	params.Set("reference", session.request.Reference)
+	params.Set("status", string(status))
+	params.Set("amount", session.request.Amount.String())
+	params.Set("transaction_id", session.transactionID)
+	params.Set("signature", s.sign(params))
+	session.callback = params
+	return params, nil
+}
+
+func (s *Simulator) VerifyCallback(ctx context.Context, params url.Values) (*CallbackResult, error) {
+	if !hmac.Equal([]byte(params.Get("signature")), []byte(s.sign(params))) {
+		return nil, ErrInvalidSignature
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[params.Get("session_id")]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownSession
+	}
+
+	amount, err := money.Parse(params.Get("amount"))
+	if err != nil || amount != session.request.Amount {
+		return nil, fmt.Errorf("callback amount does not match the checkout")
+	}
+
+	status := CallbackStatus(params.Get("status"))
+	if status != CallbackSucceeded && status != CallbackFailed {
+		return nil, fmt.Errorf("unknown callback status %q", status)
+	}
+
+	return &CallbackResult{
+		SessionID:     params.Get("session_id"),
+		Reference:     params.Get("reference"),
+		Status:        status,
+		Amount:        amount,
+		TransactionID: params.Get("transaction_id"),
+	}, nil
+}
+
+func (s *Simulator) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[req.SessionID]
+	if !ok {
+		return nil, ErrUnknownSession
+	}
+	if session.transactionID == "" || session.transactionID != req.TransactionID {
+		return nil, fmt.Errorf("no captured payment to refund")
+	}
+	if req.Amount <= 0 || session.refunded+req.Amount > session.request.Amount {
+		return nil, fmt.Errorf("refund exceeds the captured amount")
+	}
+
+	session.refunded += req.Amount
+	return &RefundResult{RefundID: randomID("re"), Amount: req.Amount}, nil
+}
+
+// serves the checkout page the resident is redirected to. picking an outcome sends the browser
+// back to the callback url, the same round trip a hosted payment page does
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := strings.TrimPrefix(r.URL.Path, "/simulator/checkout/")
+
+	s.mu.Lock()
+	session, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown checkout session", http.StatusNotFound)
+		return
+	}
+
+	outcome := Outcome(r.URL.Query().Get("outcome"))
+	if outcome == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<h1>Simulated checkout</h1><p>%s %s</p>
+<a href="?outcome=success">Pay</a> | <a href="?outcome=failure">Decline</a> | <a href="?outcome=timeout">Abandon</a>`,
+			session.request.Amount, session.request.Currency)
+		return
+	}
+
+	params, err := s.Complete(sessionID, outcome)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params == nil {
+		fmt.Fprint(w, "Checkout abandoned")
+		return
+	}
+
+	callbackURL, err := url.Parse(session.request.CallbackURL)
+	if err != nil {
+		http.Error(w, "Invalid callback URL", http.StatusInternalServerError)
+		return
+	}
+	callbackURL.RawQuery = params.Encode()
+	http.Redirect(w, r, callbackURL.String(), http.StatusFound)
+}
+
+func (s *Simulator) sign(params url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	for _, key := range []string{"session_id", "reference", "status", "amount", "transaction_id"} {
+		mac.Write([]byte(params.Get(key)))
+		mac.Write([]byte{'|'})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomID(prefix string) string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("failed to generate id: %v", err))
+	}
+	return prefix + "_" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,130 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCheckout(t *testing.T, sim *Simulator) *Checkout {
+	checkout, err := sim.CreateCheckout(context.Background(), CheckoutRequest{
+		Reference:   "txn-7",
+		Amount:      25000,
+		Currency:    "IRR",
+		CallbackURL: "http://localhost:8080/api/v1/payments/callback",
+	})
+	require.NoError(t, err)
+	return checkout
+}
+
+func TestSimulator_Success(t *testing.T) {
+	sim := NewSimulator("http://localhost:8080/", "secret", time.Minute)
+	checkout := newTestCheckout(t, sim)
+	assert.Equal(t, "http://localhost:8080/simulator/checkout/"+checkout.SessionID, checkout.RedirectURL)
+
+	params, err := sim.Complete(checkout.SessionID, OutcomeSuccess)
+	require.NoError(t, err)
+
+	result, err := sim.VerifyCallback(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, CallbackSucceeded, result.Status)
+	assert.Equal(t, "txn-7", result.Reference)
+	assert.NotEmpty(t, result.TransactionID)
+
+	refund, err := sim.Refund(context.Background(), RefundRequest{SessionID: checkout.SessionID, TransactionID: result.TransactionID, Amount: 20000})
+	require.NoError(t, err)
+	assert.NotEmpty(t, refund.RefundID)
+
+	_, err = sim.Refund(context.Background(), RefundRequest{SessionID: checkout.SessionID, TransactionID: result.TransactionID, Amount: 10000})
+	assert.ErrorContains(t, err, "exceeds the captured amount")
+}
+
+func TestSimulator_Failure(t *testing.T) {
+	sim := NewSimulator("http://localhost:8080", "secret", time.Minute)
+	checkout := newTestCheckout(t, sim)
+
+	params, err := sim.Complete(checkout.SessionID, OutcomeFailure)
+	require.NoError(t, err)
+
+	result, err := sim.VerifyCallback(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, CallbackFailed, result.Status)
+
+	_, err = sim.Refund(context.Background(), RefundRequest{SessionID: checkout.SessionID, Amount: 100})
+	assert.Error(t, err, "nothing was captured")
+}
+
+func TestSimulator_Timeout(t *testing.T) {
+	sim := NewSimulator("http://localhost:8080", "secret", time.Minute)
+	checkout := newTestCheckout(t, sim)
+
+	params, err := sim.Complete(checkout.SessionID, OutcomeTimeout)
+	assert.NoError(t, err)
+	assert.Nil(t, params)
+
+	_, err = sim.Complete(checkout.SessionID, OutcomeSuccess)
+	assert.ErrorContains(t, err, "expired")
+
+	expiring := newTestCheckout(t, sim)
+	sim.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err = sim.Complete(expiring.SessionID, OutcomeSuccess)
+	assert.ErrorContains(t, err, "expired")
+}
+
+func TestSimulator_DuplicateCallback(t *testing.T) {
+	sim := NewSimulator("http://localhost:8080", "secret", time.Minute)
+	checkout := newTestCheckout(t, sim)
+
+	first, err := sim.Complete(checkout.SessionID, OutcomeSuccess)
+	require.NoError(t, err)
+	second, err := sim.Complete(checkout.SessionID, OutcomeFailure)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second, "a completed session keeps its outcome")
+	_, err = sim.VerifyCallback(context.Background(), second)
+	assert.NoError(t, err)
+}
+
+func TestSimulator_RejectsTamperedCallback(t *testing.T) {
+	sim := NewSimulator("http://localhost:8080", "secret", time.Minute)
+	checkout := newTestCheckout(t, sim)
+
+	params, err := sim.Complete(checkout.SessionID, OutcomeFailure)
+	require.NoError(t, err)
+
+	tampered := url.Values{}
+	for key, values := range params {
+		tampered[key] = values
+	}
+	tampered.Set("status", string(CallbackSucceeded))
+
+	_, err = sim.VerifyCallback(context.Background(), tampered)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	other := NewSimulator("http://localhost:8080", "another-secret", time.Minute)
+	_, err = other.VerifyCallback(context.Background(), params)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestSimulator_CheckoutPageRedirectsToCallback(t *testing.T) {
+	sim := NewSimulator("http://localhost:8080", "secret", time.Minute)
+	checkout := newTestCheckout(t, sim)
+
+	req := httptest.NewRequest(http.MethodGet, "/simulator/checkout/"+checkout.SessionID+"?outcome=success", nil)
+	rec := httptest.NewRecorder()
+	sim.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/payments/callback", location.Path)
+
+	_, err = sim.VerifyCallback(context.Background(), location.Query())
+	assert.NoError(t, err)
+}
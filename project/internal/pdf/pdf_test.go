@@ -0,0 +1,42 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocument(t *testing.T) {
+	doc := New()
+	doc.Line("Statement for %s", "ali (unit 3)")
+	doc.Line("سلام")
+	for i := 0; i < linesPerPage; i++ {
+		doc.Line("line %d", i)
+	}
+	out := doc.Bytes()
+
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	assert.Contains(t, string(out), "/Count 2", "the lines overflow onto a second page")
+	assert.Contains(t, string(out), `(Statement for ali \(unit 3\)) Tj`)
+	assert.Contains(t, string(out), "(????) Tj")
+
+	// every xref offset points at the start of its object
+	xref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	start, _ := strconv.Atoi(string(xref[1]))
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out[start:], -1)
+	assert.Len(t, offsets, 7)
+	for i, offset := range offsets {
+		at, _ := strconv.Atoi(string(offset[1]))
+		assert.True(t, bytes.HasPrefix(out[at:], []byte(fmt.Sprintf("%d 0 obj", i+1))))
+	}
+}
+
+func TestDocument_Empty(t *testing.T) {
+	out := New().Bytes()
+	assert.Contains(t, string(out), "/Count 1", "an empty document still has a page")
+}
@@ -16,6 +16,7 @@ const (
 		address TEXT NOT NULL,
 		units_count INTEGER NOT NULL,
 		manager_id INTEGER REFERENCES users(id),
+		share_visibility VARCHAR(20) NOT NULL DEFAULT 'anonymous',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
@@ -25,6 +26,7 @@ type ApartmentRepository interface {
 	CreateApartment(ctx context.Context, apartment models.Apartment) (int, error)
 	GetApartmentByID(id int) (*models.Apartment, error)
 	UpdateApartment(ctx context.Context, apartment models.Apartment) error
+	SetShareVisibility(ctx context.Context, id int, visibility models.ShareVisibility) error
 	DeleteApartment(id int) error
 }
 
@@ -59,7 +61,7 @@ func (r *apartmentRepositoryImpl) CreateApartment(ctx context.Context, apartment
 
 func (r *apartmentRepositoryImpl) GetApartmentByID(id int) (*models.Apartment, error) {
 	var apartment models.Apartment
-	query := `SELECT id, apartment_name, address, units_count, manager_id, created_at, updated_at
+	query := `SELECT id, apartment_name, address, units_count, manager_id, share_visibility, created_at, updated_at
 		FROM apartments WHERE id = $1`
 	err := r.db.Get(&apartment, query, id)
 	if err != nil {
@@ -81,6 +83,12 @@ func (r *apartmentRepositoryImpl) UpdateApartment(ctx context.Context, apartment
 	return err
 }
 
+func (r *apartmentRepositoryImpl) SetShareVisibility(ctx context.Context, id int, visibility models.ShareVisibility) error {
+	query := `UPDATE apartments SET share_visibility = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, visibility, id)
+	return err
+}
+
 func (r *apartmentRepositoryImpl) DeleteApartment(id int) error {
 	query := `DELETE FROM apartments WHERE id = $1`
 	result, err := r.db.Exec(query, id)
@@ -26,6 +26,11 @@ func (m *MockApartmentRepo) UpdateApartment(ctx context.Context, apartment model
 	return args.Error(0)
 }
 
+func (m *MockApartmentRepo) SetShareVisibility(ctx context.Context, id int, visibility models.ShareVisibility) error {
+	args := m.Called(ctx, id, visibility)
+	return args.Error(0)
+}
+
 func (m *MockApartmentRepo) DeleteApartment(id int) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -60,10 +60,10 @@ func TestApartmentRepository_GetApartmentByID(t *testing.T) {
 	now := time.Now()
 
 	t.Run("success", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "apartment_name", "address", "units_count", "manager_id", "created_at", "updated_at"}).
-			AddRow(1, "Erfan Apartments", "123 Enghelab St", 10, 1, now, now)
+		rows := sqlmock.NewRows([]string{"id", "apartment_name", "address", "units_count", "manager_id", "share_visibility", "created_at", "updated_at"}).
+			AddRow(1, "Erfan Apartments", "123 Enghelab St", 10, 1, models.AnonymousShares, now, now)
 
-		mock.ExpectQuery(`SELECT id, apartment_name, address, units_count, manager_id, created_at, updated_at FROM apartments WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, apartment_name, address, units_count, manager_id, share_visibility, created_at, updated_at FROM apartments WHERE id = \$1`).
 			WithArgs(1).
 			WillReturnRows(rows)
 
@@ -73,7 +73,7 @@ func TestApartmentRepository_GetApartmentByID(t *testing.T) {
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT id, apartment_name, address, units_count, manager_id, created_at, updated_at FROM apartments WHERE id = \$1`).
+		mock.ExpectQuery(`SELECT id, apartment_name, address, units_count, manager_id, share_visibility, created_at, updated_at FROM apartments WHERE id = \$1`).
 			WithArgs(2).
 			WillReturnError(sql.ErrNoRows)
 
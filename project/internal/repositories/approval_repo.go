@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_APPROVAL_POLICIES_TABLE = `CREATE TABLE IF NOT EXISTS approval_policies(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL UNIQUE REFERENCES apartments(id) ON DELETE CASCADE,
+		threshold DECIMAL(12,2) NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		representative_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+	CREATE_BILL_APPROVALS_TABLE = `CREATE TABLE IF NOT EXISTS bill_approvals(
+		id SERIAL PRIMARY KEY,
+		bill_id INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		decision VARCHAR(20) NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+)
+
+var (
+	ErrBillNotPendingApproval = errors.New("bill is not waiting for approval")
+	ErrBillAwaitingApproval   = errors.New("bills waiting for approval can't be edited, reject them first")
+	ErrBillNotApproved        = errors.New("the bill's amount needs approval before it can be re-divided")
+)
+
+type ApprovalRepository interface {
+	UpsertApprovalPolicy(ctx context.Context, policy models.ApprovalPolicy) (int, error)
+	GetApprovalPolicy(apartmentID int) (*models.ApprovalPolicy, error)
+	DeleteApprovalPolicy(apartmentID int) error
+	RecordApproval(ctx context.Context, approval models.BillApproval) (models.BillStatus, error)
+	GetApprovalsByBill(billID int) ([]models.BillApproval, error)
+}
+
+type approvalRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewApprovalRepository(autoCreate bool, db *sqlx.DB) ApprovalRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_APPROVAL_POLICIES_TABLE); err != nil {
+			log.Fatalf("failed to create approval_policies table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_BILL_APPROVALS_TABLE); err != nil {
+			log.Fatalf("failed to create bill_approvals table: %v", err)
+		}
+	}
+	return &approvalRepositoryImpl{db: db}
+}
+
+func (r *approvalRepositoryImpl) UpsertApprovalPolicy(ctx context.Context, policy models.ApprovalPolicy) (int, error) {
+	query := `INSERT INTO approval_policies (apartment_id, threshold, currency, representative_id)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (apartment_id) DO UPDATE SET
+			  threshold = EXCLUDED.threshold,
+			  currency = EXCLUDED.currency,
+			  representative_id = EXCLUDED.representative_id,
+			  updated_at = CURRENT_TIMESTAMP
+			  RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		policy.ApartmentID,
+		policy.Threshold,
+		policy.Currency,
+		policy.RepresentativeID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *approvalRepositoryImpl) GetApprovalPolicy(apartmentID int) (*models.ApprovalPolicy, error) {
+	var policy models.ApprovalPolicy
+	query := `SELECT id, apartment_id, threshold, currency, representative_id, created_at, updated_at
+			  FROM approval_policies WHERE apartment_id = $1`
+	err := r.db.Get(&policy, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *approvalRepositoryImpl) DeleteApprovalPolicy(apartmentID int) error {
+	query := `DELETE FROM approval_policies WHERE apartment_id = $1`
+	_, err := r.db.Exec(query, apartmentID)
+	return err
+}
+
+// moves the bill to the status of the decision and stores the step in one transaction, and returns
+// that status. decisions are only taken on bills waiting for approval, so of two approvers deciding
+// at once the second gets ErrBillNotPendingApproval
+func (r *approvalRepositoryImpl) RecordApproval(ctx context.Context, approval models.BillApproval) (to models.BillStatus, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	from, err := lockBillStatus(ctx, tx, approval.BillID)
+	if err != nil {
+		return "", err
+	}
+	if approval.Decision != models.ApprovalRequested && from != models.BillPendingApproval {
+		return "", fmt.Errorf("%w: bill %d is %s", ErrBillNotPendingApproval, approval.BillID, from)
+	}
+	var shares []int
+	if err = tx.SelectContext(ctx, &shares, `SELECT id FROM payments WHERE bill_id = $1`, approval.BillID); err != nil {
+		return "", err
+	}
+	to = approval.Decision.BillStatus(len(shares) > 0)
+	if !from.CanTransitionTo(to) {
+		return "", fmt.Errorf("%w: bill %d from %s to %s", ErrInvalidBillTransition, approval.BillID, from, to)
+	}
+	if err = setBillStatus(ctx, tx, approval.BillID, to); err != nil {
+		return "", err
+	}
+	//its shares may have been paid off while it waited
+	if to == models.BillDivided {
+		if err = syncBillSettlement(ctx, tx, shares...); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err = insertBillApproval(ctx, tx, approval); err != nil {
+		return "", err
+	}
+	return to, nil
+}
+
+func (r *approvalRepositoryImpl) GetApprovalsByBill(billID int) ([]models.BillApproval, error) {
+	var approvals []models.BillApproval
+	query := `SELECT id, bill_id, user_id, decision, comment, created_at
+			  FROM bill_approvals WHERE bill_id = $1 ORDER BY id`
+	if err := r.db.Select(&approvals, query, billID); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+func insertBillApproval(ctx context.Context, tx *sqlx.Tx, approval models.BillApproval) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, `INSERT INTO bill_approvals (bill_id, user_id, decision, comment)
+			  VALUES ($1, $2, $3, $4) RETURNING id`,
+		approval.BillID, approval.UserID, approval.Decision, approval.Comment).Scan(&id)
+	return id, err
+}
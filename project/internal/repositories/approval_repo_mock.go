@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockApprovalRepository struct {
+	mock.Mock
+}
+
+func (m *MockApprovalRepository) UpsertApprovalPolicy(ctx context.Context, policy models.ApprovalPolicy) (int, error) {
+	args := m.Called(ctx, policy)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockApprovalRepository) GetApprovalPolicy(apartmentID int) (*models.ApprovalPolicy, error) {
+	args := m.Called(apartmentID)
+	if policy, ok := args.Get(0).(*models.ApprovalPolicy); ok {
+		return policy, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockApprovalRepository) DeleteApprovalPolicy(apartmentID int) error {
+	args := m.Called(apartmentID)
+	return args.Error(0)
+}
+
+func (m *MockApprovalRepository) RecordApproval(ctx context.Context, approval models.BillApproval) (models.BillStatus, error) {
+	args := m.Called(ctx, approval)
+	return args.Get(0).(models.BillStatus), args.Error(1)
+}
+
+func (m *MockApprovalRepository) GetApprovalsByBill(billID int) ([]models.BillApproval, error) {
+	args := m.Called(billID)
+	if approvals, ok := args.Get(0).([]models.BillApproval); ok {
+		return approvals, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
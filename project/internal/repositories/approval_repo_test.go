@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalRepository_UpsertApprovalPolicy(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &approvalRepositoryImpl{db: db}
+	representative := 3
+	policy := models.ApprovalPolicy{
+		ApartmentID:      7,
+		Threshold:        money.Amount(10000000),
+		Currency:         money.IRR,
+		RepresentativeID: &representative,
+	}
+
+	mock.ExpectQuery("INSERT INTO approval_policies .* ON CONFLICT \\(apartment_id\\) DO UPDATE").
+		WithArgs(7, policy.Threshold, money.IRR, &representative).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	id, err := repo.UpsertApprovalPolicy(context.Background(), policy)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApprovalRepository_GetApprovalPolicy(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &approvalRepositoryImpl{db: db}
+
+	rows := sqlmock.NewRows([]string{"id", "apartment_id", "threshold", "currency", "representative_id"}).
+		AddRow(2, 7, "100000.00", money.IRR, nil)
+	mock.ExpectQuery("SELECT (.+) FROM approval_policies WHERE apartment_id = \\$1").
+		WithArgs(7).
+		WillReturnRows(rows)
+
+	policy, err := repo.GetApprovalPolicy(7)
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(10000000), policy.Threshold)
+	assert.Nil(t, policy.RepresentativeID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApprovalRepository_RecordApproval(t *testing.T) {
+	t.Run("request holds a draft", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &approvalRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status FROM bills WHERE id = \\$1 FOR UPDATE").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillDraft))
+		mock.ExpectQuery("SELECT id FROM payments WHERE bill_id = \\$1").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("UPDATE bills SET status = \\$1").
+			WithArgs(models.BillPendingApproval, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO bill_approvals").
+			WithArgs(11, 1, models.ApprovalRequested, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectCommit()
+
+		status, err := repo.RecordApproval(context.Background(), models.BillApproval{BillID: 11, UserID: 1, Decision: models.ApprovalRequested})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BillPendingApproval, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejection sends the bill back to draft", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &approvalRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status FROM bills WHERE id = \\$1 FOR UPDATE").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillPendingApproval))
+		mock.ExpectQuery("SELECT id FROM payments WHERE bill_id = \\$1").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("UPDATE bills SET status = \\$1").
+			WithArgs(models.BillDraft, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO bill_approvals").
+			WithArgs(11, 2, models.BillRejected, "the meter reading is wrong").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectCommit()
+
+		status, err := repo.RecordApproval(context.Background(), models.BillApproval{
+			BillID: 11, UserID: 2, Decision: models.BillRejected, Comment: "the meter reading is wrong",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BillDraft, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("request holds a divided bill", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &approvalRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status FROM bills WHERE id = \\$1 FOR UPDATE").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillDivided))
+		mock.ExpectQuery("SELECT id FROM payments WHERE bill_id = \\$1").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21).AddRow(22))
+		mock.ExpectExec("UPDATE bills SET status = \\$1").
+			WithArgs(models.BillPendingApproval, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO bill_approvals").
+			WithArgs(11, 1, models.ApprovalRequested, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectCommit()
+
+		status, err := repo.RecordApproval(context.Background(), models.BillApproval{BillID: 11, UserID: 1, Decision: models.ApprovalRequested})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BillPendingApproval, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejecting a bill with shares takes it back to divided", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &approvalRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status FROM bills WHERE id = \\$1 FOR UPDATE").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillPendingApproval))
+		mock.ExpectQuery("SELECT id FROM payments WHERE bill_id = \\$1").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21).AddRow(22))
+		mock.ExpectExec("UPDATE bills SET status = \\$1").
+			WithArgs(models.BillDivided, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		//both shares were paid while the bill waited
+		expectBillSettlement(mock, 11, models.BillDivided, false)
+		mock.ExpectQuery("INSERT INTO bill_approvals").
+			WithArgs(11, 2, models.BillRejected, "too much").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectCommit()
+
+		status, err := repo.RecordApproval(context.Background(), models.BillApproval{
+			BillID: 11, UserID: 2, Decision: models.BillRejected, Comment: "too much",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BillDivided, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already decided", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &approvalRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status FROM bills WHERE id = \\$1 FOR UPDATE").
+			WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillPublished))
+		mock.ExpectRollback()
+
+		_, err := repo.RecordApproval(context.Background(), models.BillApproval{BillID: 11, UserID: 2, Decision: models.BillApproved})
+
+		assert.ErrorIs(t, err, ErrBillNotPendingApproval)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_BILL_CATEGORIES_TABLE = `CREATE TABLE IF NOT EXISTS bill_categories(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		name VARCHAR(50) NOT NULL,
+		icon VARCHAR(16) NOT NULL DEFAULT '',
+		default_split_strategy VARCHAR(20) NOT NULL DEFAULT '',
+		due_after_days INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (apartment_id, name)
+	);`
+)
+
+// only stores the categories an apartment defined or changed, the built-in ones live in models
+type BillCategoryRepository interface {
+	UpsertBillCategory(ctx context.Context, category models.BillCategory) (int, error)
+	GetBillCategory(apartmentID int, name models.BillType) (*models.BillCategory, error)
+	GetBillCategoriesByApartment(apartmentID int) ([]models.BillCategory, error)
+	DeleteBillCategory(apartmentID int, name models.BillType) error
+}
+
+type billCategoryRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewBillCategoryRepository(autoCreate bool, db *sqlx.DB) BillCategoryRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_BILL_CATEGORIES_TABLE); err != nil {
+			log.Fatalf("failed to create bill_categories table: %v", err)
+		}
+	}
+	return &billCategoryRepositoryImpl{db: db}
+}
+
+func (r *billCategoryRepositoryImpl) UpsertBillCategory(ctx context.Context, category models.BillCategory) (int, error) {
+	query := `INSERT INTO bill_categories (apartment_id, name, icon, default_split_strategy, due_after_days)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (apartment_id, name) DO UPDATE SET
+			  icon = EXCLUDED.icon,
+			  default_split_strategy = EXCLUDED.default_split_strategy,
+			  due_after_days = EXCLUDED.due_after_days,
+			  updated_at = CURRENT_TIMESTAMP
+			  RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		category.ApartmentID,
+		category.Name,
+		category.Icon,
+		category.DefaultStrategy,
+		category.DueAfterDays).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *billCategoryRepositoryImpl) GetBillCategory(apartmentID int, name models.BillType) (*models.BillCategory, error) {
+	var category models.BillCategory
+	query := `SELECT id, apartment_id, name, icon, default_split_strategy, due_after_days, created_at, updated_at
+			  FROM bill_categories WHERE apartment_id = $1 AND name = $2`
+	err := r.db.Get(&category, query, apartmentID, name)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *billCategoryRepositoryImpl) GetBillCategoriesByApartment(apartmentID int) ([]models.BillCategory, error) {
+	var categories []models.BillCategory
+	query := `SELECT id, apartment_id, name, icon, default_split_strategy, due_after_days, created_at, updated_at
+			  FROM bill_categories WHERE apartment_id = $1 ORDER BY name ASC`
+	err := r.db.Select(&categories, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *billCategoryRepositoryImpl) DeleteBillCategory(apartmentID int, name models.BillType) error {
+	query := `DELETE FROM bill_categories WHERE apartment_id = $1 AND name = $2`
+	_, err := r.db.Exec(query, apartmentID, name)
+	return err
+}
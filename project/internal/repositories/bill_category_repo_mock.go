@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBillCategoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockBillCategoryRepository) UpsertBillCategory(ctx context.Context, category models.BillCategory) (int, error) {
+	args := m.Called(ctx, category)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockBillCategoryRepository) GetBillCategory(apartmentID int, name models.BillType) (*models.BillCategory, error) {
+	args := m.Called(apartmentID, name)
+	if category, ok := args.Get(0).(*models.BillCategory); ok {
+		return category, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillCategoryRepository) GetBillCategoriesByApartment(apartmentID int) ([]models.BillCategory, error) {
+	args := m.Called(apartmentID)
+	if categories, ok := args.Get(0).([]models.BillCategory); ok {
+		return categories, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillCategoryRepository) DeleteBillCategory(apartmentID int, name models.BillType) error {
+	args := m.Called(apartmentID, name)
+	return args.Error(0)
+}
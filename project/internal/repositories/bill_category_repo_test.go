@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBillCategoryRepository_UpsertBillCategory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &billCategoryRepositoryImpl{db: db}
+	category := models.BillCategory{
+		ApartmentID:     1,
+		Name:            "elevator",
+		Icon:            "🛗",
+		DefaultStrategy: models.SplitByArea,
+		DueAfterDays:    10,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO bill_categories").
+			WithArgs(category.ApartmentID, category.Name, category.Icon, category.DefaultStrategy, category.DueAfterDays).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+		id, err := repo.UpsertBillCategory(context.Background(), category)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO bill_categories").
+			WillReturnError(sql.ErrConnDone)
+
+		id, err := repo.UpsertBillCategory(context.Background(), category)
+		assert.Error(t, err)
+		assert.Equal(t, 0, id)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBillCategoryRepository_GetBillCategory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &billCategoryRepositoryImpl{db: db}
+	now := time.Now()
+	columns := []string{"id", "apartment_id", "name", "icon", "default_split_strategy", "due_after_days", "created_at", "updated_at"}
+
+	t.Run("found", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM bill_categories WHERE apartment_id = \$1 AND name = \$2`).
+			WithArgs(1, models.BillType("parking")).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "parking", "🅿", "", 7, now, now))
+
+		category, err := repo.GetBillCategory(1, "parking")
+		assert.NoError(t, err)
+		assert.Equal(t, models.BillType("parking"), category.Name)
+		assert.Equal(t, 7, category.DueAfterDays)
+		assert.False(t, category.BuiltIn)
+	})
+
+	t.Run("not defined", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM bill_categories`).
+			WithArgs(1, models.WaterBill).
+			WillReturnError(sql.ErrNoRows)
+
+		category, err := repo.GetBillCategory(1, models.WaterBill)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, category)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,9 +2,13 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
 )
 
@@ -13,25 +17,55 @@ const (
 		id SERIAL PRIMARY KEY,
         apartment_id INTEGER NOT NULL REFERENCES apartments(id),
         bill_type VARCHAR(50) NOT NULL,
-        total_amount DECIMAL(10,2) NOT NULL,
+        total_amount DECIMAL(12,2) NOT NULL,
+        currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
         due_date DATE NOT NULL,
         billing_deadline DATE,
+        period_start DATE,
+        period_end DATE,
         description TEXT,
         image_url VARCHAR(2000),
+        status VARCHAR(20) NOT NULL DEFAULT 'draft',
+        auto_divide BOOLEAN NOT NULL DEFAULT FALSE,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
+	CREATE_BILL_LINE_ITEMS_TABLE = `CREATE TABLE IF NOT EXISTS bill_line_items(
+		id SERIAL PRIMARY KEY,
+		bill_id INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		category VARCHAR(50) NOT NULL,
+		amount DECIMAL(12,2) NOT NULL,
+		split_strategy VARCHAR(20) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+)
+
+var (
+	ErrBillAlreadyDivided    = errors.New("bill is already divided")
+	ErrBillNotDivisible      = errors.New("only published bills can be divided")
+	ErrInvalidBillTransition = errors.New("bill status change not allowed")
+	ErrBillNotEditable       = errors.New("cancelled bills can't be edited")
+	ErrBillNotDeletable      = errors.New("only bills that were never divided can be deleted")
+	ErrBillHasPayments       = errors.New("bill has payments that are paid, written off or being paid")
+	ErrLineItemsLocked       = errors.New("line items can't change once the bill is divided")
+	ErrBillPaymentsChanged   = errors.New("the bill's payments changed while it was being re-divided, try again")
+	ErrSharesOnInstallments  = errors.New("the bill has shares on an installment plan, their plans have to be removed before it is re-divided")
 )
 
 type BillRepository interface {
-	CreateBill(ctx context.Context, bill models.Bill) (int, error)
+	CreateBill(ctx context.Context, bill models.Bill, journal *models.JournalEntry) (int, error)
 	GetBillByID(id int) (*models.Bill, error)
 	GetBillsByApartmentID(apartmentID int) ([]models.Bill, error)
-	UpdateBill(ctx context.Context, bill models.Bill) error
-	DeleteBill(id int) error
+	UpdateBill(ctx context.Context, bill models.Bill, journal *models.JournalEntry, approval *models.BillApproval) error
+	DeleteBill(ctx context.Context, id int) error
 	GetPaymentByBillAndUser(billID, userID int) (*models.Payment, error)
 	GetUndividedBillsByTypeAndApartment(apartmentID int, billType models.BillType) ([]models.Bill, error)
 	GetUndividedBillsByApartment(apartmentID int) ([]models.Bill, error)
+	DivideBill(ctx context.Context, billID int, shares []models.Payment, journal *models.JournalEntry) ([]models.Payment, error)
+	UpdateBillStatus(ctx context.Context, id int, status models.BillStatus) error
+	CancelBill(ctx context.Context, id, managerID int) (*models.JournalEntry, error)
+	RedivideBill(ctx context.Context, plan models.Redivision) error
 }
 
 type billRepositoryImpl struct {
@@ -43,78 +77,207 @@ func NewBillRepository(autoCreate bool, db *sqlx.DB) BillRepository {
 		if _, err := db.Exec(CREATE_BILLS_TABLE); err != nil {
 			log.Fatalf("failed to create bills table: %v", err)
 		}
+		if _, err := db.Exec(CREATE_BILL_LINE_ITEMS_TABLE); err != nil {
+			log.Fatalf("failed to create bill_line_items table: %v", err)
+		}
 	}
 	return &billRepositoryImpl{db: db}
 }
 
-func (r *billRepositoryImpl) CreateBill(ctx context.Context, bill models.Bill) (int, error) {
-	query := `INSERT INTO bills (apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url)
- 				VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
-	var id int
-	err := r.db.QueryRowContext(ctx, query,
+// stores the bill together with its line items and books it. the journal's reference is set to the new bill
+func (r *billRepositoryImpl) CreateBill(ctx context.Context, bill models.Bill, journal *models.JournalEntry) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	query := `INSERT INTO bills (apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status)
+ 				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+	err = tx.QueryRowContext(ctx, query,
 		bill.ApartmentID,
 		bill.BillType,
 		bill.TotalAmount,
+		bill.Currency,
 		bill.DueDate,
 		bill.BillingDeadline,
+		bill.PeriodStart,
+		bill.PeriodEnd,
 		bill.Description,
-		bill.ImageURL).Scan(&id)
+		bill.ImageURL,
+		bill.Status).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
+	if err = insertLineItems(ctx, tx, id, bill.LineItems); err != nil {
+		return 0, err
+	}
+	if journal != nil && len(journal.Lines) > 0 {
+		journal.Reference = fmt.Sprintf("bill:%d", id)
+		if err = postJournalEntry(ctx, tx, journal); err != nil {
+			return 0, err
+		}
+	}
 	return id, nil
 }
 
 func (r *billRepositoryImpl) GetBillByID(id int) (*models.Bill, error) {
 	var bill models.Bill
-	query := `SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at 
+	query := `SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at 
 			  FROM bills WHERE id = $1`
 	err := r.db.Get(&bill, query, id)
 	if err != nil {
 		return nil, err
 	}
-	return &bill, nil
+	bills := []models.Bill{bill}
+	if err := r.attachLineItems(bills); err != nil {
+		return nil, err
+	}
+	return &bills[0], nil
 }
 
 func (r *billRepositoryImpl) GetBillsByApartmentID(apartmentID int) ([]models.Bill, error) {
 	var bills []models.Bill
-	query := `SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at 
+	query := `SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at 
 			  FROM bills WHERE apartment_id = $1`
 	err := r.db.Select(&bills, query, apartmentID)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.attachLineItems(bills); err != nil {
+		return nil, err
+	}
 	return bills, nil
 }
 
-func (r *billRepositoryImpl) UpdateBill(ctx context.Context, bill models.Bill) error {
+// line items are replaced when the bill carries them (nil keeps the stored ones), which is only
+// allowed until the bill is divided since the shares are made of them. the change is booked with
+// the journal in the same transaction, and so is the hold when an approval request comes with it
+func (r *billRepositoryImpl) UpdateBill(ctx context.Context, bill models.Bill, journal *models.JournalEntry, approval *models.BillApproval) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
 	query := `UPDATE bills
 				SET apartment_id = $1, bill_type = $2, total_amount = $3,
 				due_date = $4, billing_deadline = $5, description = $6,
 				updated_at = CURRENT_TIMESTAMP
-				WHERE id = $7`
-	_, err := r.db.ExecContext(ctx, query,
+				WHERE id = $7 AND status <> 'cancelled' RETURNING status`
+	var status models.BillStatus
+	err = tx.QueryRowContext(ctx, query,
 		bill.ApartmentID,
 		bill.BillType,
 		bill.TotalAmount,
 		bill.DueDate,
 		bill.BillingDeadline,
 		bill.Description,
-		bill.ImageURL,
-		bill.ID)
-	return err
+		bill.ID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrBillNotEditable
+	}
+	if err != nil {
+		return err
+	}
+
+	if bill.LineItems != nil {
+		if status.Divided() {
+			return ErrLineItemsLocked
+		}
+		if _, err = tx.ExecContext(ctx, `DELETE FROM bill_line_items WHERE bill_id = $1`, bill.ID); err != nil {
+			return err
+		}
+		if err = insertLineItems(ctx, tx, bill.ID, bill.LineItems); err != nil {
+			return err
+		}
+	}
+
+	if approval != nil {
+		if !status.CanTransitionTo(models.BillPendingApproval) {
+			return fmt.Errorf("%w: bill %d from %s to %s", ErrInvalidBillTransition, bill.ID, status, models.BillPendingApproval)
+		}
+		if err = setBillStatus(ctx, tx, bill.ID, models.BillPendingApproval); err != nil {
+			return err
+		}
+		approval.BillID = bill.ID
+		if approval.ID, err = insertBillApproval(ctx, tx, *approval); err != nil {
+			return err
+		}
+	}
+
+	if journal != nil && len(journal.Lines) > 0 {
+		return postJournalEntry(ctx, tx, journal)
+	}
+	return nil
 }
 
-func (r *billRepositoryImpl) DeleteBill(id int) error {
-	query := `DELETE FROM bills WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+// a bill that was divided keeps its payment history, it can only be cancelled. what the bill put on
+// the books is reversed in the same transaction, a cancelled bill was reversed when it was cancelled
+func (r *billRepositoryImpl) DeleteBill(ctx context.Context, id int) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var bill models.Bill
+	err = tx.GetContext(ctx, &bill, `SELECT id, apartment_id, bill_type, total_amount, currency, status
+			  FROM bills WHERE id = $1 FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrBillNotDeletable
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM bills WHERE id = $1 AND status IN ('draft', 'pending_approval', 'published', 'cancelled')
+			  AND NOT EXISTS (SELECT 1 FROM payments WHERE bill_id = $1)`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrBillNotDeletable
+	}
+	if bill.Status == models.BillCancelled {
+		return nil
+	}
+
+	journal := models.NewJournalEntry(bill.ApartmentID, models.CancellationEntry, fmt.Sprintf("bill:%d", id), fmt.Sprintf("Deleted %s bill", bill.BillType), bill.Currency).
+		Debit(bill.BillType.PayableAccount(), nil, bill.TotalAmount).
+		Credit(models.BillsToDivide, nil, bill.TotalAmount)
+	if len(journal.Lines) == 0 {
+		return nil
+	}
+	return postJournalEntry(ctx, tx, journal)
 }
 
 func (r *billRepositoryImpl) GetPaymentByBillAndUser(billID, userID int) (*models.Payment, error) {
 	var payment models.Payment
-	query := `SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at 
-              FROM payments WHERE bill_id = $1 AND user_id = $2`
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at 
+              FROM payments WHERE bill_id = $1 AND user_id = $2 AND kind = 'share'`
 	err := r.db.Get(&payment, query, billID, userID)
 	if err != nil {
 		return nil, err
@@ -124,14 +287,12 @@ func (r *billRepositoryImpl) GetPaymentByBillAndUser(billID, userID int) (*model
 
 func (r *billRepositoryImpl) GetUndividedBillsByTypeAndApartment(apartmentID int, billType models.BillType) ([]models.Bill, error) {
 	query := `
-    SELECT b.id, b.apartment_id, b.bill_type, b.total_amount, b.due_date,
-           b.billing_deadline, b.description, b.image_url, b.created_at, b.updated_at
+    SELECT b.id, b.apartment_id, b.bill_type, b.total_amount, b.currency, b.due_date,
+           b.billing_deadline, b.period_start, b.period_end, b.description, b.image_url, b.status, b.auto_divide, b.created_at, b.updated_at
     FROM bills b
     WHERE b.apartment_id = $1 
       AND b.bill_type = $2
-      AND NOT EXISTS (
-          SELECT 1 FROM payments p WHERE p.bill_id = b.id
-      )
+      AND b.status = 'published'
     ORDER BY b.created_at ASC`
 
 	rows, err := r.db.Query(query, apartmentID, billType)
@@ -144,28 +305,29 @@ func (r *billRepositoryImpl) GetUndividedBillsByTypeAndApartment(apartmentID int
 	for rows.Next() {
 		var bill models.Bill
 		err := rows.Scan(
-			&bill.ID, &bill.ApartmentID, &bill.BillType, &bill.TotalAmount,
-			&bill.DueDate, &bill.BillingDeadline, &bill.Description,
-			&bill.ImageURL, &bill.CreatedAt, &bill.UpdatedAt,
+			&bill.ID, &bill.ApartmentID, &bill.BillType, &bill.TotalAmount, &bill.Currency,
+			&bill.DueDate, &bill.BillingDeadline, &bill.PeriodStart, &bill.PeriodEnd, &bill.Description,
+			&bill.ImageURL, &bill.Status, &bill.AutoDivide, &bill.CreatedAt, &bill.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		bills = append(bills, bill)
 	}
+	if err := r.attachLineItems(bills); err != nil {
+		return nil, err
+	}
 	return bills, nil
 }
 
-// gets all bills that don't have payment records yet
+// gets all published bills, the ones waiting to be divided
 func (r *billRepositoryImpl) GetUndividedBillsByApartment(apartmentID int) ([]models.Bill, error) {
 	query := `
-    SELECT b.id, b.apartment_id, b.bill_type, b.total_amount, b.due_date,
-           b.billing_deadline, b.description, b.image_url, b.created_at, b.updated_at
+    SELECT b.id, b.apartment_id, b.bill_type, b.total_amount, b.currency, b.due_date,
+           b.billing_deadline, b.period_start, b.period_end, b.description, b.image_url, b.status, b.auto_divide, b.created_at, b.updated_at
     FROM bills b
     WHERE b.apartment_id = $1
-      AND NOT EXISTS (
-          SELECT 1 FROM payments p WHERE p.bill_id = b.id
-      )
+      AND b.status = 'published'
     ORDER BY b.created_at ASC`
 
 	rows, err := r.db.Query(query, apartmentID)
@@ -178,14 +340,367 @@ func (r *billRepositoryImpl) GetUndividedBillsByApartment(apartmentID int) ([]mo
 	for rows.Next() {
 		var bill models.Bill
 		err := rows.Scan(
-			&bill.ID, &bill.ApartmentID, &bill.BillType, &bill.TotalAmount,
-			&bill.DueDate, &bill.BillingDeadline, &bill.Description,
-			&bill.ImageURL, &bill.CreatedAt, &bill.UpdatedAt,
+			&bill.ID, &bill.ApartmentID, &bill.BillType, &bill.TotalAmount, &bill.Currency,
+			&bill.DueDate, &bill.BillingDeadline, &bill.PeriodStart, &bill.PeriodEnd, &bill.Description,
+			&bill.ImageURL, &bill.Status, &bill.AutoDivide, &bill.CreatedAt, &bill.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		bills = append(bills, bill)
 	}
+	if err := r.attachLineItems(bills); err != nil {
+		return nil, err
+	}
 	return bills, nil
 }
+
+// creates every share of the bill, marks it divided and books the division in one transaction, a
+// failure leaves the bill undivided. the bill stays locked until the end, so a concurrent division
+// waits and then gets ErrBillAlreadyDivided
+func (r *billRepositoryImpl) DivideBill(ctx context.Context, billID int, shares []models.Payment, journal *models.JournalEntry) (created []models.Payment, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	status, err := lockBillStatus(ctx, tx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if status.Divided() {
+		return nil, ErrBillAlreadyDivided
+	}
+	if !status.CanTransitionTo(models.BillDivided) {
+		return nil, fmt.Errorf("%w: bill %d is %s", ErrBillNotDivisible, billID, status)
+	}
+
+	created = make([]models.Payment, 0, len(shares))
+	for _, share := range shares {
+		if share.ID, err = insertPayment(ctx, tx, share); err != nil {
+			return nil, err
+		}
+		created = append(created, share)
+	}
+	if err = setBillStatus(ctx, tx, billID, models.BillDivided); err != nil {
+		return nil, err
+	}
+
+	if journal != nil && len(journal.Lines) > 0 {
+		if err = postJournalEntry(ctx, tx, journal); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+// moves the bill along its lifecycle, see models.BillStatus for the allowed steps
+func (r *billRepositoryImpl) UpdateBillStatus(ctx context.Context, id int, status models.BillStatus) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	from, err := lockBillStatus(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !from.CanTransitionTo(status) {
+		return fmt.Errorf("%w: bill %d from %s to %s", ErrInvalidBillTransition, id, from, status)
+	}
+	return setBillStatus(ctx, tx, id, status)
+}
+
+// voids the bill with its unpaid payments and reverses what it put on the books. money that was paid
+// has to be refunded first, so a bill with paid, written off or in-flight payments is refused
+func (r *billRepositoryImpl) CancelBill(ctx context.Context, id, managerID int) (journal *models.JournalEntry, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var bill models.Bill
+	if err = tx.GetContext(ctx, &bill, `SELECT id, apartment_id, bill_type, total_amount, currency, status
+			  FROM bills WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return nil, err
+	}
+	if !bill.Status.CanTransitionTo(models.BillCancelled) {
+		return nil, fmt.Errorf("%w: bill %d from %s to %s", ErrInvalidBillTransition, id, bill.Status, models.BillCancelled)
+	}
+
+	var payments []models.Payment
+	if err = tx.SelectContext(ctx, &payments, `SELECT id, user_id, amount, amount_paid, kind, payment_status
+			  FROM payments WHERE bill_id = $1 ORDER BY id FOR UPDATE`, id); err != nil {
+		return nil, err
+	}
+	for _, payment := range payments {
+		switch payment.PaymentStatus {
+		case models.Pending, models.Failed, models.Refunded, models.Cancelled:
+		default:
+			return nil, fmt.Errorf("%w: payment %d is %s", ErrBillHasPayments, payment.ID, payment.PaymentStatus)
+		}
+	}
+
+	reference := fmt.Sprintf("bill:%d", id)
+	journal = models.NewJournalEntry(bill.ApartmentID, models.CancellationEntry, reference, fmt.Sprintf("Cancelled %s bill", bill.BillType), bill.Currency)
+	for _, payment := range payments {
+		if !payment.PaymentStatus.CanTransitionTo(models.Cancelled) {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE payments SET payment_status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+				  WHERE id = $1`, payment.ID); err != nil {
+			return nil, err
+		}
+		if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+			PaymentID:  payment.ID,
+			FromStatus: payment.PaymentStatus,
+			ToStatus:   models.Cancelled,
+			ActorID:    &managerID,
+			Reason:     "bill cancelled",
+		}); err != nil {
+			return nil, err
+		}
+
+		// penalties were income, everything else was charged from the bill
+		charge := models.BillsToDivide
+		if payment.Kind == models.PenaltyKind {
+			charge = models.PenaltyIncome
+		}
+		userID := payment.UserID
+		journal.Debit(charge, nil, payment.Outstanding()).Credit(models.ResidentReceivable, &userID, payment.Outstanding())
+	}
+	journal.Debit(bill.BillType.PayableAccount(), nil, bill.TotalAmount).Credit(models.BillsToDivide, nil, bill.TotalAmount)
+
+	if err = setBillStatus(ctx, tx, id, models.BillCancelled); err != nil {
+		return nil, err
+	}
+	if err = postJournalEntry(ctx, tx, journal); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// applies a re-division and books it in one transaction, credits go straight to the residents'
+// wallets. the bill and its payments are locked in id order first, and when any payment is no longer
+// as the plan saw it nothing is changed and ErrBillPaymentsChanged is returned
+func (r *billRepositoryImpl) RedivideBill(ctx context.Context, plan models.Redivision) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	status, err := lockBillStatus(ctx, tx, plan.BillID)
+	if err != nil {
+		return err
+	}
+	if !status.Divided() {
+		return fmt.Errorf("%w: bill %d is %s", ErrInvalidBillTransition, plan.BillID, status)
+	}
+
+	var current []models.Payment
+	if err = tx.SelectContext(ctx, &current, `SELECT id, amount, amount_paid, payment_status
+			  FROM payments WHERE bill_id = $1 ORDER BY id FOR UPDATE`, plan.BillID); err != nil {
+		return err
+	}
+	if !samePayments(plan.Seen, current) {
+		return ErrBillPaymentsChanged
+	}
+	// an installment plan is sized to its share, the share can't change under it
+	if touched := plan.Touched(); len(touched) > 0 {
+		var planned bool
+		if planned, err = onInstallments(ctx, tx, touched...); err != nil {
+			return err
+		}
+		if planned {
+			return ErrSharesOnInstallments
+		}
+	}
+
+	var changed []int
+	for _, id := range plan.Deleted {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM payments WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	for _, share := range plan.Updated {
+		if _, err = tx.ExecContext(ctx, `UPDATE payments SET amount = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+			share.Amount, share.ID); err != nil {
+			return err
+		}
+		if share.Breakdown != nil {
+			if _, err = tx.ExecContext(ctx, `DELETE FROM payment_line_items WHERE payment_id = $1`, share.ID); err != nil {
+				return err
+			}
+			if err = insertBreakdown(ctx, tx, share.ID, share.Breakdown); err != nil {
+				return err
+			}
+		}
+		changed = append(changed, share.ID)
+	}
+	for _, payment := range plan.Created {
+		var id int
+		if id, err = insertPayment(ctx, tx, payment); err != nil {
+			return err
+		}
+		changed = append(changed, id)
+		if payment.Amount < 0 {
+			if err = creditWallet(ctx, tx, plan, payment, id); err != nil {
+				return err
+			}
+		}
+	}
+	if err = syncBillSettlement(ctx, tx, changed...); err != nil {
+		return err
+	}
+
+	if plan.Journal != nil && len(plan.Journal.Lines) > 0 {
+		if err = postJournalEntry(ctx, tx, plan.Journal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gives a resident whose share shrank after they paid the difference back in their wallet
+func creditWallet(ctx context.Context, tx *sqlx.Tx, plan models.Redivision, credit models.Payment, paymentID int) error {
+	wallet, err := walletOf(ctx, tx, credit.UserID, plan.ApartmentID, credit.Currency)
+	if err != nil {
+		return err
+	}
+	if wallet.Currency != credit.Currency {
+		return fmt.Errorf("%w: the wallet of user %d is in %s, the bill in %s", ErrWalletCurrency, credit.UserID, wallet.Currency, credit.Currency)
+	}
+	_, err = addWalletTransaction(ctx, tx, &models.WalletTransaction{
+		WalletID:  wallet.ID,
+		Type:      models.WalletRefund,
+		Amount:    -credit.Amount,
+		PaymentID: &paymentID,
+		Note:      fmt.Sprintf("Credit from re-divided bill #%d", plan.BillID),
+	})
+	return err
+}
+
+// whether the payments still have the amounts and statuses they had, both ordered by id
+func samePayments(seen, current []models.Payment) bool {
+	if len(seen) != len(current) {
+		return false
+	}
+	for i := range seen {
+		if seen[i].ID != current[i].ID || seen[i].Amount != current[i].Amount ||
+			seen[i].AmountPaid != current[i].AmountPaid || seen[i].PaymentStatus != current[i].PaymentStatus {
+			return false
+		}
+	}
+	return true
+}
+
+func insertLineItems(ctx context.Context, tx *sqlx.Tx, billID int, items []models.BillLineItem) error {
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO bill_line_items (bill_id, name, category, amount, split_strategy)
+				  VALUES ($1, $2, $3, $4, $5)`, billID, item.Name, item.Category, item.Amount, item.SplitStrategy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loads the line items of all the bills in one query
+func (r *billRepositoryImpl) attachLineItems(bills []models.Bill) error {
+	if len(bills) == 0 {
+		return nil
+	}
+	ids := make([]int, len(bills))
+	for i, bill := range bills {
+		ids[i] = bill.ID
+	}
+
+	var items []models.BillLineItem
+	if err := r.db.Select(&items, `SELECT id, bill_id, name, category, amount, split_strategy
+			  FROM bill_line_items WHERE bill_id = ANY($1) ORDER BY id`, pq.Array(ids)); err != nil {
+		return err
+	}
+	byBill := make(map[int][]models.BillLineItem)
+	for _, item := range items {
+		byBill[item.BillID] = append(byBill[item.BillID], item)
+	}
+	for i := range bills {
+		bills[i].LineItems = byBill[bills[i].ID]
+	}
+	return nil
+}
+
+// locks the bill until the transaction ends and returns its status
+func lockBillStatus(ctx context.Context, tx *sqlx.Tx, id int) (models.BillStatus, error) {
+	var status models.BillStatus
+	err := tx.QueryRowContext(ctx, `SELECT status FROM bills WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	return status, err
+}
+
+func setBillStatus(ctx context.Context, tx *sqlx.Tx, id int, status models.BillStatus) error {
+	_, err := tx.ExecContext(ctx, `UPDATE bills SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, status, id)
+	return err
+}
+
+// settles the divided bills of the given payments once nothing is left to pay on any of their
+// payments, and takes settled ones back to divided when one of their payments is open again. runs at
+// the end of every transaction that changes payments, after their locks are taken, and locks the
+// bills in id order so two such transactions can't deadlock
+func syncBillSettlement(ctx context.Context, tx *sqlx.Tx, paymentIDs ...int) error {
+	if len(paymentIDs) == 0 {
+		return nil
+	}
+
+	var bills []models.Bill
+	if err := tx.SelectContext(ctx, &bills, `SELECT id, status FROM bills
+			  WHERE id IN (SELECT bill_id FROM payments WHERE id = ANY($1)) AND status IN ('divided', 'settled')
+			  ORDER BY id FOR UPDATE`, pq.Array(paymentIDs)); err != nil {
+		return err
+	}
+
+	for _, bill := range bills {
+		var open bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM payments WHERE bill_id = $1
+				  AND payment_status NOT IN ('paid', 'written_off', 'refunded', 'cancelled'))`, bill.ID).Scan(&open); err != nil {
+			return err
+		}
+		status := models.BillSettled
+		if open {
+			status = models.BillDivided
+		}
+		if status == bill.Status {
+			continue
+		}
+		if err := setBillStatus(ctx, tx, bill.ID, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,31 +11,83 @@ type MockBillRepository struct {
 	mock.Mock
 }
 
-func (m *MockBillRepository) CreateBill(ctx context.Context, bill models.Bill) (int, error) {
-	args := m.Called(ctx, bill)
+func (m *MockBillRepository) CreateBill(ctx context.Context, bill models.Bill, journal *models.JournalEntry) (int, error) {
+	args := m.Called(ctx, bill, journal)
 	return args.Int(0), args.Error(1)
 }
 
 func (m *MockBillRepository) GetBillByID(id int) (*models.Bill, error) {
 	args := m.Called(id)
-	return args.Get(0).(*models.Bill), args.Error(1)
+	if bill, ok := args.Get(0).(*models.Bill); ok {
+		return bill, args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
 func (m *MockBillRepository) GetBillsByApartmentID(apartmentID int) ([]models.Bill, error) {
 	args := m.Called(apartmentID)
-	return args.Get(0).([]models.Bill), args.Error(1)
+	if bills, ok := args.Get(0).([]models.Bill); ok {
+		return bills, args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
-func (m *MockBillRepository) UpdateBill(ctx context.Context, bill models.Bill) error {
-	args := m.Called(ctx, bill)
+func (m *MockBillRepository) UpdateBill(ctx context.Context, bill models.Bill, journal *models.JournalEntry, approval *models.BillApproval) error {
+	args := m.Called(ctx, bill, journal, approval)
 	return args.Error(0)
 }
 
-func (m *MockBillRepository) DeleteBill(id int) {
-	m.Called(id)
+func (m *MockBillRepository) DeleteBill(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
 
 func (m *MockBillRepository) GetPaymentByBillAndUser(billID, userID int) (*models.Payment, error) {
 	args := m.Called(billID, userID)
-	return args.Get(0).(*models.Payment), args.Error(1)
+	if payment, ok := args.Get(0).(*models.Payment); ok {
+		return payment, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillRepository) GetUndividedBillsByTypeAndApartment(apartmentID int, billType models.BillType) ([]models.Bill, error) {
+	args := m.Called(apartmentID, billType)
+	if bills, ok := args.Get(0).([]models.Bill); ok {
+		return bills, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillRepository) GetUndividedBillsByApartment(apartmentID int) ([]models.Bill, error) {
+	args := m.Called(apartmentID)
+	if bills, ok := args.Get(0).([]models.Bill); ok {
+		return bills, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillRepository) DivideBill(ctx context.Context, billID int, shares []models.Payment, journal *models.JournalEntry) ([]models.Payment, error) {
+	args := m.Called(ctx, billID, shares, journal)
+	if created, ok := args.Get(0).([]models.Payment); ok {
+		return created, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillRepository) RedivideBill(ctx context.Context, plan models.Redivision) error {
+	args := m.Called(ctx, plan)
+	return args.Error(0)
+}
+
+func (m *MockBillRepository) UpdateBillStatus(ctx context.Context, id int, status models.BillStatus) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockBillRepository) CancelBill(ctx context.Context, id, managerID int) (*models.JournalEntry, error) {
+	args := m.Called(ctx, id, managerID)
+	if journal, ok := args.Get(0).(*models.JournalEntry); ok {
+		return journal, args.Error(1)
+	}
+	return nil, args.Error(1)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,6 +22,28 @@ func setupTestDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
 	return sqlxDB, mock
 }
 
+func expectBillLock(mock sqlmock.Sqlmock, billID int, status models.BillStatus) {
+	mock.ExpectQuery("SELECT status FROM bills WHERE id = \\$1 FOR UPDATE").WithArgs(billID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+}
+
+// expects syncBillSettlement to find the bill of the payments with the given status, and to move it
+// when open doesn't match that status any more
+func expectBillSettlement(mock sqlmock.Sqlmock, billID int, status models.BillStatus, open bool) {
+	mock.ExpectQuery("SELECT id, status FROM bills").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(billID, status))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(billID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(open))
+	next := models.BillSettled
+	if open {
+		next = models.BillDivided
+	}
+	if next != status {
+		mock.ExpectExec("UPDATE bills SET status").WithArgs(next, billID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
 func TestNewBillRepository(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -34,6 +57,8 @@ func TestNewBillRepository(t *testing.T) {
 			setupMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectExec("CREATE TABLE IF NOT EXISTS bills").
 					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS bill_line_items").
+					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			wantPanic: false,
 		},
@@ -73,27 +98,115 @@ func TestBillRepository_CreateBill_Basic(t *testing.T) {
 	bill := models.Bill{
 		ApartmentID:     1,
 		BillType:        models.WaterBill,
-		TotalAmount:     100.50,
+		TotalAmount:     money.Amount(10050),
 		DueDate:         "2024-01-15",
 		BillingDeadline: "2024-01-10",
 		Description:     "Water bill for January",
 		ImageURL:        "https://example.com/bill.jpg",
 	}
 
+	journal := models.NewJournalEntry(1, models.BillEntry, "", "water bill", money.IRR).
+		Debit(models.BillsToDivide, nil, 10050).
+		Credit(models.UtilityPayable, nil, 10050)
+
 	// for sqlx named queries, we can't easily predict the exact arguments
 	// so we ll just expect any INSERT query and return an id
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO bills").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expectJournalEntry(mock, 3, 1, models.BillEntry,
+		models.JournalLine{Account: models.BillsToDivide, Debit: 10050},
+		models.JournalLine{Account: models.UtilityPayable, Credit: 10050})
+	mock.ExpectCommit()
 
 	repo := &billRepositoryImpl{db: db}
 	ctx := context.Background()
 
-	id, err := repo.CreateBill(ctx, bill)
+	id, err := repo.CreateBill(ctx, bill, journal)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 1, id)
+	assert.Equal(t, "bill:1", journal.Reference)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestBillRepository_CreateBill_LineItems(t *testing.T) {
+	bill := models.Bill{
+		ApartmentID: 1,
+		BillType:    models.WaterBill,
+		TotalAmount: money.Amount(9000),
+		DueDate:     "2024-01-15",
+		LineItems: []models.BillLineItem{
+			{Name: "base charge", Category: models.BaseCharge, Amount: 3000, SplitStrategy: models.SplitEqual},
+			{Name: "consumption", Category: models.ConsumptionCharge, Amount: 6000},
+		},
+	}
+
+	t.Run("stores the bill with its line items", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO bills").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectExec("INSERT INTO bill_line_items").
+			WithArgs(11, "base charge", models.BaseCharge, money.Amount(3000), models.SplitEqual).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO bill_line_items").
+			WithArgs(11, "consumption", models.ConsumptionCharge, money.Amount(6000), models.SplitStrategy("")).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		id, err := repo.CreateBill(context.Background(), bill, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 11, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed line item leaves no bill behind", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO bills").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectExec("INSERT INTO bill_line_items").
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := repo.CreateBill(context.Background(), bill, nil)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed posting leaves no bill behind", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO bills").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectExec("INSERT INTO bill_line_items").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO bill_line_items").
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectQuery("INSERT INTO journal_entries").
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := repo.CreateBill(context.Background(), bill, models.NewJournalEntry(1, models.BillEntry, "", "water bill", money.IRR).
+			Debit(models.BillsToDivide, nil, 9000).
+			Credit(models.UtilityPayable, nil, 9000))
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
 func TestBillRepository_GetBillByID(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -114,9 +227,13 @@ func TestBillRepository_GetBillByID(t *testing.T) {
 					"2024-01-10", "Water bill", "https://example.com/bill.jpg",
 					time.Now(), time.Now(),
 				)
-				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at FROM bills WHERE id = \$1`).
+				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at FROM bills WHERE id = \$1`).
 					WithArgs(1).
 					WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT id, bill_id, name, category, amount, split_strategy FROM bill_line_items`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "bill_id", "name", "category", "amount", "split_strategy"}).
+						AddRow(4, 1, "base charge", models.BaseCharge, "30.00", models.SplitEqual).
+						AddRow(5, 1, "consumption", models.ConsumptionCharge, "70.50", ""))
 			},
 			wantBill: &models.Bill{
 				BaseModel: models.BaseModel{
@@ -124,11 +241,15 @@ func TestBillRepository_GetBillByID(t *testing.T) {
 				},
 				ApartmentID:     1,
 				BillType:        models.WaterBill,
-				TotalAmount:     100.50,
+				TotalAmount:     money.Amount(10050),
 				DueDate:         "2024-01-15",
 				BillingDeadline: "2024-01-10",
 				Description:     "Water bill",
 				ImageURL:        "https://example.com/bill.jpg",
+				LineItems: []models.BillLineItem{
+					{ID: 4, BillID: 1, Name: "base charge", Category: models.BaseCharge, Amount: 3000, SplitStrategy: models.SplitEqual},
+					{ID: 5, BillID: 1, Name: "consumption", Category: models.ConsumptionCharge, Amount: 7050},
+				},
 			},
 			wantErr: false,
 		},
@@ -136,7 +257,7 @@ func TestBillRepository_GetBillByID(t *testing.T) {
 			name: "Bill not found",
 			id:   999,
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at FROM bills WHERE id = \$1`).
+				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at FROM bills WHERE id = \$1`).
 					WithArgs(999).
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -166,6 +287,7 @@ func TestBillRepository_GetBillByID(t *testing.T) {
 				assert.Equal(t, tt.wantBill.ApartmentID, bill.ApartmentID)
 				assert.Equal(t, tt.wantBill.BillType, bill.BillType)
 				assert.Equal(t, tt.wantBill.TotalAmount, bill.TotalAmount)
+				assert.Equal(t, tt.wantBill.LineItems, bill.LineItems)
 			}
 
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -192,16 +314,18 @@ func TestBillRepository_GetBillsByApartmentID(t *testing.T) {
 					AddRow(1, 1, "water", 100.50, "2024-01-15", "2024-01-10", "Water bill", "url1", time.Now(), time.Now()).
 					AddRow(2, 1, "electricity", 75.25, "2024-01-20", "2024-01-15", "Electricity bill", "url2", time.Now(), time.Now())
 
-				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at FROM bills WHERE apartment_id = \$1`).
+				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at FROM bills WHERE apartment_id = \$1`).
 					WithArgs(1).
 					WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT id, bill_id, name, category, amount, split_strategy FROM bill_line_items`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "bill_id", "name", "category", "amount", "split_strategy"}))
 			},
 			wantBills: []models.Bill{
 				{
 					BaseModel:       models.BaseModel{ID: 1},
 					ApartmentID:     1,
 					BillType:        models.WaterBill,
-					TotalAmount:     100.50,
+					TotalAmount:     money.Amount(10050),
 					DueDate:         "2024-01-15",
 					BillingDeadline: "2024-01-10",
 					Description:     "Water bill",
@@ -211,7 +335,7 @@ func TestBillRepository_GetBillsByApartmentID(t *testing.T) {
 					BaseModel:       models.BaseModel{ID: 2},
 					ApartmentID:     1,
 					BillType:        models.ElectricityBill,
-					TotalAmount:     75.25,
+					TotalAmount:     money.Amount(7525),
 					DueDate:         "2024-01-20",
 					BillingDeadline: "2024-01-15",
 					Description:     "Electricity bill",
@@ -224,7 +348,7 @@ func TestBillRepository_GetBillsByApartmentID(t *testing.T) {
 			name:        "No bills found",
 			apartmentID: 999,
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at FROM bills WHERE apartment_id = \$1`).
+				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at FROM bills WHERE apartment_id = \$1`).
 					WithArgs(999).
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "apartment_id", "bill_type", "total_amount", "due_date",
@@ -238,7 +362,7 @@ func TestBillRepository_GetBillsByApartmentID(t *testing.T) {
 			name:        "Database error",
 			apartmentID: 1,
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, due_date, billing_deadline, description, image_url, created_at, updated_at FROM bills WHERE apartment_id = \$1`).
+				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide, created_at, updated_at FROM bills WHERE apartment_id = \$1`).
 					WithArgs(1).
 					WillReturnError(sql.ErrConnDone)
 			},
@@ -280,6 +404,8 @@ func TestBillRepository_UpdateBill(t *testing.T) {
 	tests := []struct {
 		name      string
 		bill      models.Bill
+		journal   *models.JournalEntry
+		approval  *models.BillApproval
 		setupMock func(sqlmock.Sqlmock)
 		wantErr   bool
 	}{
@@ -289,26 +415,143 @@ func TestBillRepository_UpdateBill(t *testing.T) {
 				BaseModel:       models.BaseModel{ID: 1},
 				ApartmentID:     1,
 				BillType:        models.WaterBill,
-				TotalAmount:     150.75,
+				TotalAmount:     money.Amount(15075),
 				DueDate:         "2024-01-20",
 				BillingDeadline: "2024-01-15",
 				Description:     "Updated water bill",
 				ImageURL:        "https://example.com/updated-bill.jpg",
 			},
+			journal: models.NewJournalEntry(1, models.BillChangeEntry, "bill:1", "water bill", money.IRR).
+				Debit(models.BillsToDivide, nil, 5025).
+				Credit(models.UtilityPayable, nil, 5025),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillDivided))
+				expectJournalEntry(mock, 4, 1, models.BillChangeEntry,
+					models.JournalLine{Account: models.BillsToDivide, Debit: 5025},
+					models.JournalLine{Account: models.UtilityPayable, Credit: 5025})
+				mock.ExpectCommit()
+			},
+			wantErr: false,
+		},
+		{
+			name: "Raise is held for approval with the update",
+			bill: models.Bill{
+				BaseModel:   models.BaseModel{ID: 1},
+				TotalAmount: money.Amount(90000000),
+			},
+			approval: &models.BillApproval{UserID: 3, Decision: models.ApprovalRequested},
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`UPDATE bills`).
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillDivided))
+				mock.ExpectExec("UPDATE bills SET status").WithArgs(models.BillPendingApproval, 1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO bill_approvals").
+					WithArgs(1, 3, models.ApprovalRequested, "").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
+		{
+			name: "Failed approval request keeps the old amount",
+			bill: models.Bill{
+				BaseModel:   models.BaseModel{ID: 1},
+				TotalAmount: money.Amount(90000000),
+			},
+			approval: &models.BillApproval{UserID: 3, Decision: models.ApprovalRequested},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillPublished))
+				mock.ExpectExec("UPDATE bills SET status").WithArgs(models.BillPendingApproval, 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO bill_approvals").
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Replaces the line items of an undivided bill",
+			bill: models.Bill{
+				BaseModel:   models.BaseModel{ID: 1},
+				ApartmentID: 1,
+				BillType:    models.WaterBill,
+				TotalAmount: money.Amount(5000),
+				LineItems:   []models.BillLineItem{{Name: "base charge", Category: models.BaseCharge, Amount: 5000}},
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillPublished))
+				mock.ExpectExec(`DELETE FROM bill_line_items WHERE bill_id = \$1`).WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(`INSERT INTO bill_line_items`).
+					WithArgs(1, "base charge", models.BaseCharge, money.Amount(5000), models.SplitStrategy("")).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectCommit()
+			},
+			wantErr: false,
+		},
+		{
+			name: "Line items of a divided bill are locked",
+			bill: models.Bill{
+				BaseModel: models.BaseModel{ID: 1},
+				LineItems: []models.BillLineItem{{Name: "base charge", Category: models.BaseCharge, Amount: 5000}},
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillSettled))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Failed posting keeps the old bill",
+			bill: models.Bill{
+				BaseModel:   models.BaseModel{ID: 1},
+				TotalAmount: money.Amount(15075),
+			},
+			journal: models.NewJournalEntry(1, models.BillChangeEntry, "bill:1", "water bill", money.IRR).
+				Debit(models.BillsToDivide, nil, 5025).
+				Credit(models.UtilityPayable, nil, 5025),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.BillDivided))
+				mock.ExpectQuery("INSERT INTO journal_entries").
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cancelled bill",
+			bill: models.Bill{
+				BaseModel: models.BaseModel{ID: 1},
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
 		{
 			name: "Database error",
 			bill: models.Bill{
 				BaseModel: models.BaseModel{ID: 1},
 			},
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectExec(`UPDATE bills`).
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE bills`).
 					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -324,7 +567,7 @@ func TestBillRepository_UpdateBill(t *testing.T) {
 			repo := &billRepositoryImpl{db: db}
 			ctx := context.Background()
 
-			err := repo.UpdateBill(ctx, tt.bill)
+			err := repo.UpdateBill(ctx, tt.bill, tt.journal, tt.approval)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -338,28 +581,79 @@ func TestBillRepository_UpdateBill(t *testing.T) {
 }
 
 func TestBillRepository_DeleteBill(t *testing.T) {
+	expectBill := func(mock sqlmock.Sqlmock, status models.BillStatus) {
+		mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, status\s+FROM bills WHERE id = \$1 FOR UPDATE`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "apartment_id", "bill_type", "total_amount", "currency", "status"}).
+				AddRow(1, 7, models.WaterBill, "90.00", money.IRR, status))
+	}
+
 	tests := []struct {
 		name      string
-		id        int
 		setupMock func(sqlmock.Sqlmock)
+		wantErr   error
 	}{
 		{
-			name: "Success",
-			id:   1,
+			name: "Reverses what the bill booked",
 			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectBill(mock, models.BillPublished)
 				mock.ExpectExec(`DELETE FROM bills WHERE id = \$1`).
 					WithArgs(1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectJournalEntry(mock, 5, 7, models.CancellationEntry,
+					models.JournalLine{Account: models.UtilityPayable, Debit: 9000},
+					models.JournalLine{Account: models.BillsToDivide, Credit: 9000})
+				mock.ExpectCommit()
 			},
 		},
 		{
-			name: "Database error (ignored)",
-			id:   1,
+			name: "Cancelled bill was already reversed",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectBill(mock, models.BillCancelled)
+				mock.ExpectExec(`DELETE FROM bills WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Divided bill",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectBill(mock, models.BillDivided)
+				mock.ExpectExec(`DELETE FROM bills WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrBillNotDeletable,
+		},
+		{
+			name: "Failed posting keeps the bill",
 			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectBill(mock, models.BillDraft)
 				mock.ExpectExec(`DELETE FROM bills WHERE id = \$1`).
 					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO journal_entries").
 					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			wantErr: sql.ErrConnDone,
+		},
+		{
+			name: "Missing bill",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT id, apartment_id, bill_type, total_amount, currency, status`).
+					WithArgs(1).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
 			},
+			wantErr: ErrBillNotDeletable,
 		},
 	}
 
@@ -372,9 +666,13 @@ func TestBillRepository_DeleteBill(t *testing.T) {
 
 			repo := &billRepositoryImpl{db: db}
 
-			// DeleteBill doesn't return an error, so we just call it
-			repo.DeleteBill(tt.id)
+			err := repo.DeleteBill(context.Background(), 1)
 
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
@@ -399,7 +697,7 @@ func TestBillRepository_GetPaymentByBillAndUser(t *testing.T) {
 				}).AddRow(
 					1, 1, 1, 100.50, time.Now(), "completed", time.Now(), time.Now(),
 				)
-				mock.ExpectQuery(`SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE bill_id = \$1 AND user_id = \$2`).
+				mock.ExpectQuery(`SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE bill_id = \$1 AND user_id = \$2`).
 					WithArgs(1, 1).
 					WillReturnRows(rows)
 			},
@@ -407,7 +705,7 @@ func TestBillRepository_GetPaymentByBillAndUser(t *testing.T) {
 				BaseModel: models.BaseModel{ID: 1},
 				BillID:    1,
 				UserID:    1,
-				Amount:    "100.50",
+				Amount:    money.Amount(10050),
 			},
 			wantErr: false,
 		},
@@ -416,7 +714,7 @@ func TestBillRepository_GetPaymentByBillAndUser(t *testing.T) {
 			billID: 1,
 			userID: 999,
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE bill_id = \$1 AND user_id = \$2`).
+				mock.ExpectQuery(`SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE bill_id = \$1 AND user_id = \$2`).
 					WithArgs(1, 999).
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -451,3 +749,424 @@ func TestBillRepository_GetPaymentByBillAndUser(t *testing.T) {
 		})
 	}
 }
+
+func TestBillRepository_DivideBill(t *testing.T) {
+	resident := 2
+	share := models.Payment{BillID: 11, UserID: resident, Amount: 3000, Currency: money.IRR, PaymentStatus: models.Pending, Kind: models.ShareKind}
+	journal := func() *models.JournalEntry {
+		return models.NewJournalEntry(7, models.DivisionEntry, "bill:11", "water bill", money.IRR).
+			Debit(models.ResidentReceivable, &resident, 3000).
+			Credit(models.BillsToDivide, nil, 3000)
+	}
+	expectShare := func(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+		return mock.ExpectQuery("INSERT INTO payments").
+			WithArgs(share.BillID, share.UserID, share.Amount, share.AmountPaid, share.Currency, share.PaidAt, share.PaymentStatus, share.SplitStrategy, share.Kind, share.ParentPaymentID)
+	}
+
+	t.Run("creates the shares and the ledger entry together", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillPublished)
+		expectShare(mock).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(21, models.PaymentStatus(""), models.Pending, nil, "created").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE bills SET status").WithArgs(models.BillDivided, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectJournalEntry(mock, 5, 7, models.DivisionEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: 3000},
+			models.JournalLine{Account: models.BillsToDivide, Credit: 3000})
+		mock.ExpectCommit()
+
+		created, err := repo.DivideBill(context.Background(), 11, []models.Payment{share}, journal())
+
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+		assert.Equal(t, 21, created[0].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("draft bill can't be divided", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillDraft)
+		mock.ExpectRollback()
+
+		created, err := repo.DivideBill(context.Background(), 11, []models.Payment{share}, journal())
+
+		assert.ErrorIs(t, err, ErrBillNotDivisible)
+		assert.Nil(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("bill already divided", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillSettled)
+		mock.ExpectRollback()
+
+		created, err := repo.DivideBill(context.Background(), 11, []models.Payment{share}, journal())
+
+		assert.ErrorIs(t, err, ErrBillAlreadyDivided)
+		assert.Nil(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed ledger entry rolls the shares back", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillPublished)
+		expectShare(mock).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE bills SET status").WithArgs(models.BillDivided, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO journal_entries").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		created, err := repo.DivideBill(context.Background(), 11, []models.Payment{share}, journal())
+
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.Nil(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBillRepository_UpdateBillStatus(t *testing.T) {
+	t.Run("publishes a draft", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillDraft)
+		mock.ExpectExec("UPDATE bills SET status").WithArgs(models.BillPublished, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.UpdateBillStatus(context.Background(), 11, models.BillPublished))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cancelled bill can't be published", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillCancelled)
+		mock.ExpectRollback()
+
+		err := repo.UpdateBillStatus(context.Background(), 11, models.BillPublished)
+		assert.ErrorIs(t, err, ErrInvalidBillTransition)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBillRepository_CancelBill(t *testing.T) {
+	resident, manager := 2, 1
+	expectBill := func(mock sqlmock.Sqlmock, status models.BillStatus) {
+		mock.ExpectQuery("SELECT id, apartment_id, bill_type, total_amount, currency, status FROM bills").WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "apartment_id", "bill_type", "total_amount", "currency", "status"}).
+				AddRow(11, 7, models.WaterBill, "50.00", money.IRR, status))
+	}
+	paymentColumns := []string{"id", "user_id", "amount", "amount_paid", "kind", "payment_status"}
+
+	t.Run("voids the unpaid shares and reverses the bill", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBill(mock, models.BillDivided)
+		mock.ExpectQuery("SELECT id, user_id, amount, amount_paid, kind, payment_status FROM payments").WithArgs(11).
+			WillReturnRows(sqlmock.NewRows(paymentColumns).
+				AddRow(21, resident, "30.00", "10.00", models.ShareKind, models.Pending).
+				AddRow(22, 3, "20.00", "0.00", models.ShareKind, models.Refunded))
+		mock.ExpectExec("UPDATE payments SET payment_status = 'cancelled'").WithArgs(21).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(21, models.Pending, models.Cancelled, manager, "bill cancelled").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE bills SET status").WithArgs(models.BillCancelled, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectJournalEntry(mock, 5, 7, models.CancellationEntry,
+			models.JournalLine{Account: models.BillsToDivide, Debit: 2000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Credit: 2000},
+			models.JournalLine{Account: models.UtilityPayable, Debit: 5000},
+			models.JournalLine{Account: models.BillsToDivide, Credit: 5000})
+		mock.ExpectCommit()
+
+		journal, err := repo.CancelBill(context.Background(), 11, manager)
+
+		require.NoError(t, err)
+		assert.Equal(t, models.CancellationEntry, journal.EntryType)
+		assert.Len(t, journal.Lines, 4)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("paid shares have to be refunded first", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBill(mock, models.BillDivided)
+		mock.ExpectQuery("SELECT id, user_id, amount, amount_paid, kind, payment_status FROM payments").WithArgs(11).
+			WillReturnRows(sqlmock.NewRows(paymentColumns).
+				AddRow(21, resident, "30.00", "30.00", models.ShareKind, models.Paid).
+				AddRow(22, 3, "20.00", "0.00", models.ShareKind, models.Pending))
+		mock.ExpectRollback()
+
+		journal, err := repo.CancelBill(context.Background(), 11, manager)
+
+		assert.ErrorIs(t, err, ErrBillHasPayments)
+		assert.Nil(t, journal)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cancelled bill can't be cancelled again", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBill(mock, models.BillCancelled)
+		mock.ExpectRollback()
+
+		_, err := repo.CancelBill(context.Background(), 11, manager)
+
+		assert.ErrorIs(t, err, ErrInvalidBillTransition)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBillRepository_RedivideBill(t *testing.T) {
+	resident, payer := 2, 3
+	seen := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 21}, Amount: 2000, PaymentStatus: models.Pending},
+		{BaseModel: models.BaseModel{ID: 22}, Amount: 2000, AmountPaid: 2000, PaymentStatus: models.Paid},
+	}
+	adjustment := models.Payment{BillID: 11, UserID: payer, Amount: 1000, Currency: money.IRR, PaymentStatus: models.Pending,
+		Kind: models.AdjustmentKind, ParentPaymentID: &seen[1].ID}
+	plan := func() models.Redivision {
+		return models.Redivision{
+			BillID:  11,
+			Seen:    seen,
+			Updated: []models.Payment{{BaseModel: models.BaseModel{ID: 21}, Amount: 3000, Breakdown: []models.ShareLineItem{{LineItemID: 4, Amount: 3000}}}},
+			Created: []models.Payment{adjustment},
+			Journal: models.NewJournalEntry(7, models.RedivisionEntry, "bill:11", "water bill", money.IRR).
+				Debit(models.ResidentReceivable, &resident, 1000).
+				Debit(models.ResidentReceivable, &payer, 1000).
+				Credit(models.BillsToDivide, nil, 2000),
+		}
+	}
+	expectPayments := func(mock sqlmock.Sqlmock, paid string) {
+		mock.ExpectQuery("SELECT id, amount, amount_paid, payment_status FROM payments WHERE bill_id = \\$1 ORDER BY id FOR UPDATE").WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "amount_paid", "payment_status"}).
+				AddRow(21, "20.00", "0.00", models.Pending).
+				AddRow(22, "20.00", paid, models.Paid))
+	}
+
+	t.Run("changes the shares and books them together", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillSettled)
+		expectPayments(mock, "20.00")
+		expectInstallmentPlans(mock, false)
+		mock.ExpectExec("UPDATE payments SET amount").WithArgs(money.Amount(3000), 21).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM payment_line_items WHERE payment_id = \\$1").WithArgs(21).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO payment_line_items").WithArgs(21, 4, money.Amount(3000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO payments").
+			WithArgs(adjustment.BillID, adjustment.UserID, adjustment.Amount, adjustment.AmountPaid, adjustment.Currency, adjustment.PaidAt, adjustment.PaymentStatus, adjustment.SplitStrategy, adjustment.Kind, adjustment.ParentPaymentID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(30, models.PaymentStatus(""), models.Pending, nil, "created").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectBillSettlement(mock, 11, models.BillSettled, true)
+		expectJournalEntry(mock, 5, 7, models.RedivisionEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: 1000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &payer, Debit: 1000},
+			models.JournalLine{Account: models.BillsToDivide, Credit: 2000})
+		mock.ExpectCommit()
+
+		err := repo.RedivideBill(context.Background(), plan())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("share that shrank after it was paid is credited to the wallet", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		credit := models.Payment{BillID: 11, UserID: payer, Amount: -500, AmountPaid: -500, Currency: money.IRR, PaymentStatus: models.Paid,
+			Kind: models.AdjustmentKind, ParentPaymentID: &seen[1].ID}
+		shrunk := models.Redivision{
+			BillID:      11,
+			ApartmentID: 7,
+			Seen:        seen,
+			Created:     []models.Payment{credit},
+			Journal: models.NewJournalEntry(7, models.RedivisionEntry, "bill:11", "water bill", money.IRR).
+				Debit(models.ResidentReceivable, &payer, -500).
+				Credit(models.BillsToDivide, nil, -500).
+				Credit(models.ResidentReceivable, &payer, -500).
+				Debit(models.ResidentWallet, &payer, -500),
+		}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillSettled)
+		expectPayments(mock, "20.00")
+		expectInstallmentPlans(mock, false)
+		mock.ExpectQuery("INSERT INTO payments").
+			WithArgs(credit.BillID, credit.UserID, credit.Amount, credit.AmountPaid, credit.Currency, credit.PaidAt, models.Paid, credit.SplitStrategy, credit.Kind, credit.ParentPaymentID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(31, models.PaymentStatus(""), models.Paid, nil, "created").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO wallets").WithArgs(payer, 7, money.IRR).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "apartment_id", "currency", "balance", "auto_pay", "created_at", "updated_at"}).
+				AddRow(4, payer, 7, money.IRR, "0.00", false, time.Now(), time.Now()))
+		mock.ExpectQuery("UPDATE wallets SET balance").WithArgs(money.Amount(500), 4).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "user_id", "apartment_id", "currency"}).AddRow("5.00", payer, 7, money.IRR))
+		mock.ExpectQuery("INSERT INTO wallet_transactions").
+			WithArgs(4, models.WalletRefund, money.Amount(500), money.Amount(500), sqlmock.AnyArg(), nil, "Credit from re-divided bill #11").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, time.Now()))
+		//the credit is paid, so the bill stays settled
+		expectBillSettlement(mock, 11, models.BillSettled, false)
+		expectJournalEntry(mock, 6, 7, models.RedivisionEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &payer, Credit: 500},
+			models.JournalLine{Account: models.BillsToDivide, Debit: 500},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &payer, Debit: 500},
+			models.JournalLine{Account: models.ResidentWallet, UserID: &payer, Credit: 500})
+		mock.ExpectCommit()
+
+		err := repo.RedivideBill(context.Background(), shrunk)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("payments that moved on leave everything as it was", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillDivided)
+		expectPayments(mock, "10.00")
+		mock.ExpectRollback()
+
+		err := repo.RedivideBill(context.Background(), plan())
+
+		assert.ErrorIs(t, err, ErrBillPaymentsChanged)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("share on an installment plan", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillDivided)
+		expectPayments(mock, "20.00")
+		expectInstallmentPlans(mock, true)
+		mock.ExpectRollback()
+
+		err := repo.RedivideBill(context.Background(), plan())
+
+		assert.ErrorIs(t, err, ErrSharesOnInstallments)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cancelled bill", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		expectBillLock(mock, 11, models.BillCancelled)
+		mock.ExpectRollback()
+
+		err := repo.RedivideBill(context.Background(), plan())
+
+		assert.ErrorIs(t, err, ErrInvalidBillTransition)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBillRepository_GetUndividedBills(t *testing.T) {
+	columns := []string{
+		"id", "apartment_id", "bill_type", "total_amount", "currency", "due_date", "billing_deadline",
+		"period_start", "period_end", "description", "image_url", "status", "auto_divide", "created_at", "updated_at",
+	}
+	row := func() *sqlmock.Rows {
+		return sqlmock.NewRows(columns).
+			AddRow(11, 7, "water", 100.50, "IRR", "2024-01-15", "2024-01-10", nil, nil, "Water bill", "", "published", true, time.Now(), time.Now())
+	}
+	lineItems := sqlmock.NewRows([]string{"id", "bill_id", "name", "category", "amount", "split_strategy"})
+
+	check := func(t *testing.T, bills []models.Bill) {
+		require.Len(t, bills, 1)
+		assert.Equal(t, 11, bills[0].ID)
+		assert.Equal(t, money.Amount(10050), bills[0].TotalAmount)
+		assert.Equal(t, models.BillPublished, bills[0].Status)
+		assert.True(t, bills[0].AutoDivide)
+	}
+
+	t.Run("by type", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectQuery(`SELECT (.+) FROM bills b WHERE b.apartment_id = \$1 AND b.bill_type = \$2 AND b.status = 'published'`).
+			WithArgs(7, models.WaterBill).
+			WillReturnRows(row())
+		mock.ExpectQuery(`SELECT id, bill_id, name, category, amount, split_strategy FROM bill_line_items`).
+			WillReturnRows(lineItems)
+
+		bills, err := repo.GetUndividedBillsByTypeAndApartment(7, models.WaterBill)
+
+		require.NoError(t, err)
+		check(t, bills)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("all types", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &billRepositoryImpl{db: db}
+
+		mock.ExpectQuery(`SELECT (.+) FROM bills b WHERE b.apartment_id = \$1 AND b.status = 'published'`).
+			WithArgs(7).
+			WillReturnRows(row())
+		mock.ExpectQuery(`SELECT id, bill_id, name, category, amount, split_strategy FROM bill_line_items`).
+			WillReturnRows(lineItems)
+
+		bills, err := repo.GetUndividedBillsByApartment(7)
+
+		require.NoError(t, err)
+		check(t, bills)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_BUDGETS_TABLE = `CREATE TABLE IF NOT EXISTS budgets(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		category VARCHAR(50) NOT NULL,
+		year INTEGER NOT NULL,
+		month INTEGER NOT NULL CHECK (month BETWEEN 1 AND 12),
+		amount DECIMAL(12,2) NOT NULL CHECK (amount >= 0),
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (apartment_id, category, year, month)
+	);`
+)
+
+type BudgetRepository interface {
+	SetBudgets(ctx context.Context, budgets []models.Budget) error
+	GetBudgets(apartmentID, year int) ([]models.Budget, error)
+	DeleteBudgets(apartmentID int, category models.BillType, year int) error
+}
+
+type budgetRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewBudgetRepository(autoCreate bool, db *sqlx.DB) BudgetRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_BUDGETS_TABLE); err != nil {
+			log.Fatalf("failed to create budgets table: %v", err)
+		}
+	}
+	return &budgetRepositoryImpl{db: db}
+}
+
+// stores the months of a category's budget together, replacing what was planned for them before
+func (r *budgetRepositoryImpl) SetBudgets(ctx context.Context, budgets []models.Budget) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	query := `INSERT INTO budgets (apartment_id, category, year, month, amount, currency)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  ON CONFLICT (apartment_id, category, year, month) DO UPDATE SET
+			  amount = EXCLUDED.amount,
+			  currency = EXCLUDED.currency,
+			  updated_at = CURRENT_TIMESTAMP`
+	for _, budget := range budgets {
+		if _, err = tx.ExecContext(ctx, query,
+			budget.ApartmentID,
+			budget.Category,
+			budget.Year,
+			budget.Month,
+			budget.Amount,
+			budget.Currency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *budgetRepositoryImpl) GetBudgets(apartmentID, year int) ([]models.Budget, error) {
+	var budgets []models.Budget
+	query := `SELECT id, apartment_id, category, year, month, amount, currency, created_at, updated_at
+			  FROM budgets WHERE apartment_id = $1 AND year = $2 ORDER BY category ASC, month ASC`
+	if err := r.db.Select(&budgets, query, apartmentID, year); err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+func (r *budgetRepositoryImpl) DeleteBudgets(apartmentID int, category models.BillType, year int) error {
+	query := `DELETE FROM budgets WHERE apartment_id = $1 AND category = $2 AND year = $3`
+	_, err := r.db.Exec(query, apartmentID, category, year)
+	return err
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBudgetRepository struct {
+	mock.Mock
+}
+
+func (m *MockBudgetRepository) SetBudgets(ctx context.Context, budgets []models.Budget) error {
+	args := m.Called(ctx, budgets)
+	return args.Error(0)
+}
+
+func (m *MockBudgetRepository) GetBudgets(apartmentID, year int) ([]models.Budget, error) {
+	args := m.Called(apartmentID, year)
+	if budgets, ok := args.Get(0).([]models.Budget); ok {
+		return budgets, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBudgetRepository) DeleteBudgets(apartmentID int, category models.BillType, year int) error {
+	args := m.Called(apartmentID, category, year)
+	return args.Error(0)
+}
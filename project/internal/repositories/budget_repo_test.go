@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetRepository_SetBudgets(t *testing.T) {
+	budgets := []models.Budget{
+		{ApartmentID: 7, Category: models.WaterBill, Year: 2025, Month: 1, Amount: 10000, Currency: money.IRR},
+		{ApartmentID: 7, Category: models.WaterBill, Year: 2025, Month: 2, Amount: 12000, Currency: money.IRR},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &budgetRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		for _, budget := range budgets {
+			mock.ExpectExec("INSERT INTO budgets").
+				WithArgs(7, models.WaterBill, 2025, budget.Month, budget.Amount, money.IRR).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.SetBudgets(context.Background(), budgets))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &budgetRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO budgets").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO budgets").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		assert.Error(t, repo.SetBudgets(context.Background(), budgets))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBudgetRepository_GetBudgets(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &budgetRepositoryImpl{db: db}
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM budgets WHERE apartment_id = \\$1 AND year = \\$2").
+		WithArgs(7, 2025).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "apartment_id", "category", "year", "month", "amount", "currency", "created_at", "updated_at"}).
+			AddRow(1, 7, "water", 2025, 1, "100.00", "IRR", now, now).
+			AddRow(2, 7, "water", 2025, 2, "120.00", "IRR", now, now))
+
+	budgets, err := repo.GetBudgets(7, 2025)
+
+	assert.NoError(t, err)
+	assert.Len(t, budgets, 2)
+	assert.Equal(t, money.Amount(12000), budgets[1].Amount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBudgetRepository_DeleteBudgets(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &budgetRepositoryImpl{db: db}
+
+	mock.ExpectExec("DELETE FROM budgets WHERE apartment_id = \\$1 AND category = \\$2 AND year = \\$3").
+		WithArgs(7, models.WaterBill, 2025).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	assert.NoError(t, repo.DeleteBudgets(7, models.WaterBill, 2025))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,322 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+const (
+	CREATE_PAYMENT_DISPUTES_TABLE = `CREATE TABLE IF NOT EXISTS payment_disputes(
+		id SERIAL PRIMARY KEY,
+		payment_id INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		reason TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		refund_method VARCHAR(20),
+		refund_ref TEXT NOT NULL DEFAULT '',
+		resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		resolution_note TEXT NOT NULL DEFAULT '',
+		resolved_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// a payment has at most one open dispute at a time
+	CREATE_PAYMENT_DISPUTES_OPEN_INDEX = `CREATE UNIQUE INDEX IF NOT EXISTS payment_disputes_one_open
+		ON payment_disputes(payment_id) WHERE status = 'open';`
+
+	SELECT_PAYMENT_DISPUTES = `SELECT d.id, d.payment_id, d.user_id, d.amount, d.currency, d.reason, d.status, d.refund_method, d.refund_ref,
+		d.resolved_by, d.resolution_note, d.resolved_at, d.created_at, d.updated_at FROM payment_disputes d`
+)
+
+var (
+	ErrPaymentNotDisputable = errors.New("payment has not been paid that much or is already disputed")
+	ErrDisputeClosed        = errors.New("dispute is already resolved")
+)
+
+type DisputeRepository interface {
+	OpenDispute(ctx context.Context, dispute models.PaymentDispute) (int, error)
+	GetDisputeByID(id int) (*models.PaymentDispute, error)
+	GetDisputesByUser(userID int) ([]models.PaymentDispute, error)
+	GetDisputesByApartment(apartmentID int) ([]models.PaymentDispute, error)
+	RejectDispute(ctx context.Context, dispute models.PaymentDispute) error
+	ClaimDispute(ctx context.Context, id int) error
+	ReleaseDispute(ctx context.Context, id int) error
+	RefundDispute(ctx context.Context, dispute models.PaymentDispute, walletID *int) error
+}
+
+type disputeRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewDisputeRepository(autoCreate bool, db *sqlx.DB) DisputeRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_PAYMENT_DISPUTES_TABLE); err != nil {
+			log.Fatalf("failed to create payment_disputes table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_PAYMENT_DISPUTES_OPEN_INDEX); err != nil {
+			log.Fatalf("failed to create payment_disputes open index: %v", err)
+		}
+	}
+	return &disputeRepositoryImpl{db: db}
+}
+
+// stores the dispute and holds the payment in the disputed status until a manager decides
+func (r *disputeRepositoryImpl) OpenDispute(ctx context.Context, dispute models.PaymentDispute) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var status models.PaymentStatus
+	err = tx.QueryRowContext(ctx, `SELECT payment_status FROM payments WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		dispute.PaymentID, dispute.UserID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPaymentNotDisputable
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE payments SET payment_status = 'disputed', updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1 AND payment_status IN ('paid', 'partially_paid') AND amount_paid - amount_refunded >= $2`,
+		dispute.PaymentID, dispute.Amount)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, ErrPaymentNotDisputable
+	}
+
+	if err = tx.QueryRowContext(ctx, `INSERT INTO payment_disputes (payment_id, user_id, amount, currency, reason, status)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		dispute.PaymentID,
+		dispute.UserID,
+		dispute.Amount,
+		dispute.Currency,
+		dispute.Reason,
+		models.DisputeOpen).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+		PaymentID:  dispute.PaymentID,
+		FromStatus: status,
+		ToStatus:   models.Disputed,
+		ActorID:    &dispute.UserID,
+		Reason:     dispute.Reason,
+	}); err != nil {
+		return 0, err
+	}
+	if err = syncBillSettlement(ctx, tx, dispute.PaymentID); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *disputeRepositoryImpl) GetDisputeByID(id int) (*models.PaymentDispute, error) {
+	var dispute models.PaymentDispute
+	if err := r.db.Get(&dispute, SELECT_PAYMENT_DISPUTES+` WHERE d.id = $1`, id); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+func (r *disputeRepositoryImpl) GetDisputesByUser(userID int) ([]models.PaymentDispute, error) {
+	var disputes []models.PaymentDispute
+	if err := r.db.Select(&disputes, SELECT_PAYMENT_DISPUTES+` WHERE d.user_id = $1 ORDER BY d.id DESC`, userID); err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// disputes on the payments of the apartment's bills, open ones first
+func (r *disputeRepositoryImpl) GetDisputesByApartment(apartmentID int) ([]models.PaymentDispute, error) {
+	var disputes []models.PaymentDispute
+	query := SELECT_PAYMENT_DISPUTES + ` JOIN payments p ON p.id = d.payment_id JOIN bills b ON b.id = p.bill_id
+			  WHERE b.apartment_id = $1 ORDER BY d.status = 'open' DESC, d.id DESC`
+	if err := r.db.Select(&disputes, query, apartmentID); err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// closes the dispute without a refund and puts the payment back to what it was paid
+func (r *disputeRepositoryImpl) RejectDispute(ctx context.Context, dispute models.PaymentDispute) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var paymentID int
+	err = tx.QueryRowContext(ctx, `UPDATE payment_disputes SET status = 'rejected', resolved_by = $1, resolution_note = $2,
+			  resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $3 AND status = 'open' RETURNING payment_id`,
+		dispute.ResolvedBy, dispute.ResolutionNote, dispute.ID).Scan(&paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDisputeClosed
+	}
+	if err != nil {
+		return err
+	}
+
+	var status models.PaymentStatus
+	if err = tx.QueryRowContext(ctx, `UPDATE payments SET
+			  payment_status = CASE WHEN amount_paid >= amount THEN 'paid' ELSE 'partially_paid' END,
+			  updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1 AND payment_status = 'disputed' RETURNING payment_status`, paymentID).Scan(&status); err != nil {
+		return err
+	}
+
+	if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+		PaymentID:  paymentID,
+		FromStatus: models.Disputed,
+		ToStatus:   status,
+		ActorID:    dispute.ResolvedBy,
+		Reason:     dispute.ResolutionNote,
+	}); err != nil {
+		return err
+	}
+	return syncBillSettlement(ctx, tx, paymentID)
+}
+
+// takes an open dispute for a refund before any money moves, so it can only be refunded once. a
+// dispute that is no longer open returns ErrDisputeClosed
+func (r *disputeRepositoryImpl) ClaimDispute(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE payment_disputes SET status = 'refunding', updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1 AND status = 'open'`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrDisputeClosed
+	}
+	return nil
+}
+
+// opens a claimed dispute again when its refund didn't go out
+func (r *disputeRepositoryImpl) ReleaseDispute(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE payment_disputes SET status = 'open', updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1 AND status = 'refunding'`, id)
+	return err
+}
+
+// books the refund of a claimed dispute after the money went back through the gateway, or credits the
+// resident's wallet when walletID is set. the charge is cancelled along with the payment, so the
+// resident owes nothing for the refunded part
+func (r *disputeRepositoryImpl) RefundDispute(ctx context.Context, dispute models.PaymentDispute, walletID *int) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var paymentID int
+	var amount money.Amount
+	err = tx.QueryRowContext(ctx, `UPDATE payment_disputes SET status = 'refunded', refund_method = $1, refund_ref = $2,
+			  resolved_by = $3, resolution_note = $4, resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $5 AND status = 'refunding' RETURNING payment_id, amount`,
+		dispute.RefundMethod, dispute.RefundRef, dispute.ResolvedBy, dispute.ResolutionNote, dispute.ID).Scan(&paymentID, &amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDisputeClosed
+	}
+	if err != nil {
+		return err
+	}
+
+	var status models.PaymentStatus
+	var kind models.PaymentKind
+	var userID, apartmentID int
+	var currency money.Currency
+	err = tx.QueryRowContext(ctx, `UPDATE payments SET
+			  amount_refunded = amount_refunded + $1,
+			  payment_status = CASE WHEN amount_refunded + $1 >= amount_paid THEN 'refunded'
+			                        WHEN amount_paid >= amount THEN 'paid' ELSE 'partially_paid' END,
+			  updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $2 AND payment_status = 'disputed' AND amount_refunded + $1 <= amount_paid
+			  RETURNING payment_status, kind, user_id, (SELECT apartment_id FROM bills WHERE bills.id = payments.bill_id), currency`,
+		amount, paymentID).Scan(&status, &kind, &userID, &apartmentID, &currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPaymentNotDisputable
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+		PaymentID:  paymentID,
+		FromStatus: models.Disputed,
+		ToStatus:   status,
+		ActorID:    dispute.ResolvedBy,
+		Reason:     dispute.ResolutionNote,
+	}); err != nil {
+		return err
+	}
+	if err = syncBillSettlement(ctx, tx, paymentID); err != nil {
+		return err
+	}
+
+	source, sourceUser := models.ApartmentFund, (*int)(nil)
+	if walletID != nil {
+		entry := models.WalletTransaction{
+			WalletID:  *walletID,
+			Type:      models.WalletRefund,
+			Amount:    amount,
+			PaymentID: &paymentID,
+			Note:      fmt.Sprintf("Refund of dispute #%d", dispute.ID),
+		}
+		if _, err = addWalletTransaction(ctx, tx, &entry); err != nil {
+			return err
+		}
+		source, sourceUser = models.ResidentWallet, &userID
+	}
+
+	// penalties were income, everything else was charged from a bill
+	charge := models.BillsToDivide
+	if kind == models.PenaltyKind {
+		charge = models.PenaltyIncome
+	}
+	journal := models.NewJournalEntry(apartmentID, models.RefundEntry, fmt.Sprintf("payment:%d", paymentID), fmt.Sprintf("Refund of dispute #%d", dispute.ID), currency).
+		Debit(models.ResidentReceivable, &userID, amount).
+		Credit(source, sourceUser, amount).
+		Debit(charge, nil, amount).
+		Credit(models.ResidentReceivable, &userID, amount)
+	return postJournalEntry(ctx, tx, journal)
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDisputeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDisputeRepository) OpenDispute(ctx context.Context, dispute models.PaymentDispute) (int, error) {
+	args := m.Called(ctx, dispute)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDisputeRepository) GetDisputeByID(id int) (*models.PaymentDispute, error) {
+	args := m.Called(id)
+	if dispute, ok := args.Get(0).(*models.PaymentDispute); ok {
+		return dispute, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDisputeRepository) GetDisputesByUser(userID int) ([]models.PaymentDispute, error) {
+	args := m.Called(userID)
+	if disputes, ok := args.Get(0).([]models.PaymentDispute); ok {
+		return disputes, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDisputeRepository) GetDisputesByApartment(apartmentID int) ([]models.PaymentDispute, error) {
+	args := m.Called(apartmentID)
+	if disputes, ok := args.Get(0).([]models.PaymentDispute); ok {
+		return disputes, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDisputeRepository) RejectDispute(ctx context.Context, dispute models.PaymentDispute) error {
+	args := m.Called(ctx, dispute)
+	return args.Error(0)
+}
+
+func (m *MockDisputeRepository) ClaimDispute(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockDisputeRepository) ReleaseDispute(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockDisputeRepository) RefundDispute(ctx context.Context, dispute models.PaymentDispute, walletID *int) error {
+	args := m.Called(ctx, dispute, walletID)
+	return args.Error(0)
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputeRepository_OpenDispute(t *testing.T) {
+	dispute := models.PaymentDispute{PaymentID: 4, UserID: 2, Amount: 3000, Currency: money.IRR, Reason: "charged twice"}
+
+	t.Run("holds the payment as disputed", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments WHERE id = \\$1 AND user_id = \\$2 FOR UPDATE").
+			WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Paid))
+		mock.ExpectExec("UPDATE payments SET payment_status = 'disputed'").
+			WithArgs(4, money.Amount(3000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO payment_disputes").
+			WithArgs(4, 2, money.Amount(3000), money.IRR, "charged twice", models.DisputeOpen).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(4, models.Paid, models.Disputed, 2, "charged twice").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// the disputed payment is open again, so is its bill
+		expectBillSettlement(mock, 11, models.BillSettled, true)
+		mock.ExpectCommit()
+
+		id, err := repo.OpenDispute(context.Background(), dispute)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 6, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("more than was paid", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.PartiallyPaid))
+		mock.ExpectExec("UPDATE payments SET payment_status = 'disputed'").
+			WithArgs(4, money.Amount(3000)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := repo.OpenDispute(context.Background(), dispute)
+
+		assert.ErrorIs(t, err, ErrPaymentNotDisputable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDisputeRepository_RejectDispute(t *testing.T) {
+	manager := 1
+
+	t.Run("restores the paid status", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_disputes SET status = 'rejected'").
+			WithArgs(&manager, "receipt checks out", 6).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_id"}).AddRow(4))
+		mock.ExpectQuery("UPDATE payments SET payment_status = CASE").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Paid))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(4, models.Disputed, models.Paid, &manager, "receipt checks out").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectBillSettlement(mock, 11, models.BillDivided, false)
+		mock.ExpectCommit()
+
+		err := repo.RejectDispute(context.Background(), models.PaymentDispute{
+			BaseModel: models.BaseModel{ID: 6}, ResolvedBy: &manager, ResolutionNote: "receipt checks out",
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already resolved", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_disputes SET status = 'rejected'").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.RejectDispute(context.Background(), models.PaymentDispute{BaseModel: models.BaseModel{ID: 6}, ResolvedBy: &manager})
+
+		assert.ErrorIs(t, err, ErrDisputeClosed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDisputeRepository_RefundDispute(t *testing.T) {
+	manager := 1
+	resident := 2
+
+	t.Run("refund through the gateway", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+		method := models.RefundToGateway
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_disputes SET status = 'refunded'").
+			WithArgs(&method, "re_1", &manager, "", 6).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_id", "amount"}).AddRow(4, "30.00"))
+		mock.ExpectQuery("UPDATE payments SET amount_refunded = amount_refunded \\+ \\$1").
+			WithArgs(money.Amount(3000), 4).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status", "kind", "user_id", "apartment_id", "currency"}).
+				AddRow(models.Refunded, models.ShareKind, 2, 7, money.IRR))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(4, models.Disputed, models.Refunded, &manager, "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectBillSettlement(mock, 11, models.BillDivided, false)
+		expectJournalEntry(mock, 3, 7, models.RefundEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: 3000},
+			models.JournalLine{Account: models.ApartmentFund, Credit: 3000},
+			models.JournalLine{Account: models.BillsToDivide, Debit: 3000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Credit: 3000})
+		mock.ExpectCommit()
+
+		err := repo.RefundDispute(context.Background(), models.PaymentDispute{
+			BaseModel: models.BaseModel{ID: 6}, RefundMethod: &method, RefundRef: "re_1", ResolvedBy: &manager,
+		}, nil)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("penalty refunded to the wallet", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+		method := models.RefundToWallet
+		walletID := 9
+		paymentID := 4
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_disputes SET status = 'refunded'").
+			WithArgs(&method, "", &manager, "waived", 6).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_id", "amount"}).AddRow(4, "10.00"))
+		mock.ExpectQuery("UPDATE payments SET amount_refunded").
+			WithArgs(money.Amount(1000), 4).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status", "kind", "user_id", "apartment_id", "currency"}).
+				AddRow(models.Paid, models.PenaltyKind, 2, 7, money.IRR))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(4, models.Disputed, models.Paid, &manager, "waived").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectBillSettlement(mock, 11, models.BillDivided, false)
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1").
+			WithArgs(money.Amount(1000), 9).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "user_id", "apartment_id", "currency"}).AddRow("10.00", 2, 7, money.IRR))
+		mock.ExpectQuery("INSERT INTO wallet_transactions").
+			WithArgs(9, models.WalletRefund, money.Amount(1000), money.Amount(1000), &paymentID, nil, "Refund of dispute #6").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(12, time.Now()))
+		expectJournalEntry(mock, 3, 7, models.RefundEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: 1000},
+			models.JournalLine{Account: models.ResidentWallet, UserID: &resident, Credit: 1000},
+			models.JournalLine{Account: models.PenaltyIncome, Debit: 1000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Credit: 1000})
+		mock.ExpectCommit()
+
+		err := repo.RefundDispute(context.Background(), models.PaymentDispute{
+			BaseModel: models.BaseModel{ID: 6}, RefundMethod: &method, ResolvedBy: &manager, ResolutionNote: "waived",
+		}, &walletID)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("dispute that wasn't claimed", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_disputes SET status = 'refunded',(.+)WHERE id = \\$5 AND status = 'refunding'").
+			WillReturnRows(sqlmock.NewRows([]string{"payment_id", "amount"}))
+		mock.ExpectRollback()
+
+		err := repo.RefundDispute(context.Background(), models.PaymentDispute{BaseModel: models.BaseModel{ID: 6}, ResolvedBy: &manager}, nil)
+
+		assert.ErrorIs(t, err, ErrDisputeClosed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDisputeRepository_ClaimDispute(t *testing.T) {
+	t.Run("open dispute", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectExec("UPDATE payment_disputes SET status = 'refunding',(.+)WHERE id = \\$1 AND status = 'open'").
+			WithArgs(6).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.ClaimDispute(context.Background(), 6))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("claimed by a concurrent approval", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &disputeRepositoryImpl{db: db}
+
+		mock.ExpectExec("UPDATE payment_disputes SET status = 'refunding'").
+			WithArgs(6).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.ClaimDispute(context.Background(), 6), ErrDisputeClosed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultIdempotencyTTL         = 24 * time.Hour
+	defaultIdempotencyLockTimeout = time.Minute
+)
+
+// deletes KEYS[1] only when the record in it carries the token in ARGV[1], checked and deleted in one
+// step so the key can't change hands in between
+const releaseScript = `
+local data = redis.call('GET', KEYS[1])
+if data and cjson.decode(data).token == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// writes the finished record in ARGV[2] to KEYS[1] for ARGV[3] milliseconds, only while the key still
+// holds the claim with the token in ARGV[1]
+const completeScript = `
+local data = redis.call('GET', KEYS[1])
+if data and cjson.decode(data).token == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`
+
+var ErrIdempotencyClaimLost = errors.New("idempotency claim expired before the response was stored")
+
+type IdempotencyRepository interface {
+	// claims the key for a request with a token unique to it. returns nil when the caller owns the
+	// key now, otherwise the record of the request that claimed it first
+	Claim(ctx context.Context, key, fingerprint, token string) (*models.IdempotencyRecord, error)
+	// stores the response while the caller's claim still holds the key, a claim that timed out gets
+	// ErrIdempotencyClaimLost
+	Complete(ctx context.Context, key, token string, record models.IdempotencyRecord) error
+	Release(ctx context.Context, key, token string) error
+}
+
+type idempotencyRepositoryImpl struct {
+	redisClient *goredis.Client
+	ttl         time.Duration // how long a finished response is replayed
+	lockTimeout time.Duration // how long a claim survives a request that never finishes
+}
+
+func NewIdempotencyRepository(redisClient *goredis.Client, ttl, lockTimeout time.Duration) IdempotencyRepository {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	if lockTimeout <= 0 {
+		lockTimeout = defaultIdempotencyLockTimeout
+	}
+	return &idempotencyRepositoryImpl{
+		redisClient: redisClient,
+		ttl:         ttl,
+		lockTimeout: lockTimeout,
+	}
+}
+
+func (r *idempotencyRepositoryImpl) Claim(ctx context.Context, key, fingerprint, token string) (*models.IdempotencyRecord, error) {
+	claim, err := json.Marshal(models.IdempotencyRecord{Fingerprint: fingerprint, Status: models.IdempotencyInFlight, Token: token})
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := r.redisClient.SetNX(ctx, r.redisKey(key), claim, r.lockTimeout).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	data, err := r.redisClient.Get(ctx, r.redisKey(key)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		// released between the two calls, the first request failed a moment ago
+		return &models.IdempotencyRecord{Fingerprint: fingerprint, Status: models.IdempotencyInFlight}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	var record models.IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepositoryImpl) Complete(ctx context.Context, key, token string, record models.IdempotencyRecord) error {
+	record.Status = models.IdempotencyCompleted
+	record.Token = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	stored, err := r.redisClient.Eval(ctx, completeScript, []string{r.redisKey(key)}, token, string(data), r.ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("failed to save idempotency record: %w", err)
+	}
+	if stored == 0 {
+		return ErrIdempotencyClaimLost
+	}
+	return nil
+}
+
+// frees the key so a retry runs the request again. the key is only deleted while it still holds the
+// caller's claim, once the claim timed out it may belong to another request or hold its response
+func (r *idempotencyRepositoryImpl) Release(ctx context.Context, key, token string) error {
+	if err := r.redisClient.Eval(ctx, releaseScript, []string{r.redisKey(key)}, token).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepositoryImpl) redisKey(key string) string {
+	return "idempotency:" + key
+}
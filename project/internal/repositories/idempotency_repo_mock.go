@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) Claim(ctx context.Context, key, fingerprint, token string) (*models.IdempotencyRecord, error) {
+	args := m.Called(ctx, key, fingerprint, token)
+	if record, ok := args.Get(0).(*models.IdempotencyRecord); ok {
+		return record, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) Complete(ctx context.Context, key, token string, record models.IdempotencyRecord) error {
+	args := m.Called(ctx, key, token, record)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) Release(ctx context.Context, key, token string) error {
+	args := m.Called(ctx, key, token)
+	return args.Error(0)
+}
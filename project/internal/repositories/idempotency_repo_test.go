@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyRepository_Claim(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db, time.Hour, time.Minute)
+	ctx := context.Background()
+
+	claim, err := json.Marshal(models.IdempotencyRecord{Fingerprint: "abc", Status: models.IdempotencyInFlight, Token: "tok"})
+	require.NoError(t, err)
+
+	t.Run("free key is claimed", func(t *testing.T) {
+		mock.ExpectSetNX("idempotency:1:key", claim, time.Minute).SetVal(true)
+
+		record, err := repo.Claim(ctx, "1:key", "abc", "tok")
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("taken key returns the stored record", func(t *testing.T) {
+		stored, err := json.Marshal(models.IdempotencyRecord{
+			Fingerprint: "abc",
+			Status:      models.IdempotencyCompleted,
+			StatusCode:  202,
+			Body:        []byte(`{"success":true}`),
+		})
+		require.NoError(t, err)
+		mock.ExpectSetNX("idempotency:1:key", claim, time.Minute).SetVal(false)
+		mock.ExpectGet("idempotency:1:key").SetVal(string(stored))
+
+		record, err := repo.Claim(ctx, "1:key", "abc", "tok")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, models.IdempotencyCompleted, record.Status)
+		assert.Equal(t, 202, record.StatusCode)
+		assert.Equal(t, `{"success":true}`, string(record.Body))
+	})
+
+	t.Run("key released after the claim failed is treated as in flight", func(t *testing.T) {
+		mock.ExpectSetNX("idempotency:1:key", claim, time.Minute).SetVal(false)
+		mock.ExpectGet("idempotency:1:key").RedisNil()
+
+		record, err := repo.Claim(ctx, "1:key", "abc", "tok")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, models.IdempotencyInFlight, record.Status)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		mock.ExpectSetNX("idempotency:1:key", claim, time.Minute).SetErr(errors.New("connection refused"))
+
+		record, err := repo.Claim(ctx, "1:key", "abc", "tok")
+		assert.Error(t, err)
+		assert.Nil(t, record)
+		assert.Contains(t, err.Error(), "failed to claim idempotency key")
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyRepository_CompleteAndRelease(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db, time.Hour, time.Minute)
+	ctx := context.Background()
+
+	record := models.IdempotencyRecord{Fingerprint: "abc", StatusCode: 200, Body: []byte("ok")}
+	stored := record
+	stored.Status = models.IdempotencyCompleted
+	data, err := json.Marshal(stored)
+	require.NoError(t, err)
+
+	mock.ExpectEval(completeScript, []string{"idempotency:1:key"}, "tok", string(data), int64(3600000)).SetVal(int64(1))
+	assert.NoError(t, repo.Complete(ctx, "1:key", "tok", record))
+
+	// another request holds the key now, its claim is left alone
+	mock.ExpectEval(completeScript, []string{"idempotency:1:key"}, "tok", string(data), int64(3600000)).SetVal(int64(0))
+	assert.ErrorIs(t, repo.Complete(ctx, "1:key", "tok", record), ErrIdempotencyClaimLost)
+
+	mock.ExpectEval(releaseScript, []string{"idempotency:1:key"}, "tok").SetVal(int64(1))
+	assert.NoError(t, repo.Release(ctx, "1:key", "tok"))
+
+	// the claim timed out and the key was taken by another request
+	mock.ExpectEval(releaseScript, []string{"idempotency:1:key"}, "tok").SetVal(int64(0))
+	assert.NoError(t, repo.Release(ctx, "1:key", "tok"))
+
+	mock.ExpectEval(releaseScript, []string{"idempotency:1:key"}, "tok").SetErr(errors.New("connection refused"))
+	err = repo.Release(ctx, "1:key", "tok")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to release idempotency key")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewIdempotencyRepository_Defaults(t *testing.T) {
+	db, _ := redismock.NewClientMock()
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db, 0, 0).(*idempotencyRepositoryImpl)
+	assert.Equal(t, defaultIdempotencyTTL, repo.ttl)
+	assert.Equal(t, defaultIdempotencyLockTimeout, repo.lockTimeout)
+}
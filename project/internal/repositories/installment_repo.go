@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+const (
+	CREATE_INSTALLMENT_PLANS_TABLE = `CREATE TABLE IF NOT EXISTS installment_plans(
+		id SERIAL PRIMARY KEY,
+		payment_id INTEGER NOT NULL UNIQUE REFERENCES payments(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_by INTEGER NOT NULL REFERENCES users(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	CREATE_INSTALLMENTS_TABLE = `CREATE TABLE IF NOT EXISTS installments(
+		id SERIAL PRIMARY KEY,
+		plan_id INTEGER NOT NULL REFERENCES installment_plans(id) ON DELETE CASCADE,
+		sequence INTEGER NOT NULL,
+		amount DECIMAL(12,2) NOT NULL,
+		due_date DATE NOT NULL,
+		status VARCHAR(50) NOT NULL DEFAULT 'pending',
+		paid_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (plan_id, sequence)
+	);`
+)
+
+var (
+	ErrPaymentOnInstallments = errors.New("payment is on an installment plan, pay its installments instead")
+	ErrPlanOutstanding       = errors.New("the payment's outstanding amount changed, plan its installments again")
+	ErrPlanPaidOn            = errors.New("installments were already paid or are being paid on this plan")
+)
+
+type InstallmentRepository interface {
+	CreatePlan(ctx context.Context, plan models.InstallmentPlan) (int, error)
+	GetPlanByID(id int) (*models.InstallmentPlan, error)
+	GetPlanByPayment(paymentID int) (*models.InstallmentPlan, error)
+	GetPlansByUser(userID int) ([]models.InstallmentPlan, error)
+	GetInstallmentByID(id int) (*models.Installment, error)
+	DeletePlan(ctx context.Context, paymentID int) error
+}
+
+type installmentRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewInstallmentRepository(autoCreate bool, db *sqlx.DB) InstallmentRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_INSTALLMENT_PLANS_TABLE); err != nil {
+			log.Fatalf("failed to create installment_plans table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_INSTALLMENTS_TABLE); err != nil {
+			log.Fatalf("failed to create installments table: %v", err)
+		}
+	}
+	return &installmentRepositoryImpl{db: db}
+}
+
+// the payment is locked while the plan is stored, so it can't be paid meanwhile and the installments
+// still add up to what is left of it
+func (r *installmentRepositoryImpl) CreatePlan(ctx context.Context, plan models.InstallmentPlan) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var status models.PaymentStatus
+	var outstanding money.Amount
+	err = tx.QueryRowContext(ctx, `SELECT payment_status, amount - amount_paid FROM payments WHERE id = $1 FOR UPDATE`,
+		plan.PaymentID).Scan(&status, &outstanding)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPaymentNotPayable
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status != models.Pending && status != models.PartiallyPaid {
+		return 0, ErrPaymentNotPayable
+	}
+	var total money.Amount
+	for _, installment := range plan.Installments {
+		total += installment.Amount
+	}
+	if total != outstanding {
+		return 0, ErrPlanOutstanding
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO installment_plans (payment_id, user_id, created_by)
+			  VALUES ($1, $2, $3) RETURNING id`,
+		plan.PaymentID, plan.UserID, plan.CreatedBy).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, installment := range plan.Installments {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO installments (plan_id, sequence, amount, due_date, status)
+				  VALUES ($1, $2, $3, $4, $5)`,
+			id, installment.Sequence, installment.Amount, installment.DueDate, models.Pending); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// whether any of the payments is being paid off in installments. the caller holds their locks
+func onInstallments(ctx context.Context, tx *sqlx.Tx, paymentIDs ...int) (bool, error) {
+	var planned bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM installment_plans WHERE payment_id = ANY($1))`,
+		pq.Array(paymentIDs)).Scan(&planned)
+	return planned, err
+}
+
+// a payment on an installment plan is only paid through its installments, paying it in full would leave
+// them pending
+func checkInstallmentItem(ctx context.Context, tx *sqlx.Tx, item models.TransactionItem) error {
+	if item.InstallmentID != nil {
+		return nil
+	}
+	planned, err := onInstallments(ctx, tx, item.PaymentID)
+	if err != nil {
+		return err
+	}
+	if planned {
+		return ErrPaymentOnInstallments
+	}
+	return nil
+}
+
+func (r *installmentRepositoryImpl) GetPlanByID(id int) (*models.InstallmentPlan, error) {
+	var plan models.InstallmentPlan
+	query := `SELECT id, payment_id, user_id, created_by, created_at, updated_at FROM installment_plans WHERE id = $1`
+	if err := r.db.Get(&plan, query, id); err != nil {
+		return nil, err
+	}
+	return r.withInstallments(&plan)
+}
+
+func (r *installmentRepositoryImpl) GetPlanByPayment(paymentID int) (*models.InstallmentPlan, error) {
+	var plan models.InstallmentPlan
+	query := `SELECT id, payment_id, user_id, created_by, created_at, updated_at FROM installment_plans WHERE payment_id = $1`
+	if err := r.db.Get(&plan, query, paymentID); err != nil {
+		return nil, err
+	}
+	return r.withInstallments(&plan)
+}
+
+func (r *installmentRepositoryImpl) GetPlansByUser(userID int) ([]models.InstallmentPlan, error) {
+	var plans []models.InstallmentPlan
+	query := `SELECT id, payment_id, user_id, created_by, created_at, updated_at FROM installment_plans WHERE user_id = $1 ORDER BY id`
+	if err := r.db.Select(&plans, query, userID); err != nil {
+		return nil, err
+	}
+	for i := range plans {
+		if _, err := r.withInstallments(&plans[i]); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+func (r *installmentRepositoryImpl) withInstallments(plan *models.InstallmentPlan) (*models.InstallmentPlan, error) {
+	query := `SELECT id, plan_id, sequence, amount, due_date, status, paid_at, created_at, updated_at
+			  FROM installments WHERE plan_id = $1 ORDER BY sequence`
+	if err := r.db.Select(&plan.Installments, query, plan.ID); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (r *installmentRepositoryImpl) GetInstallmentByID(id int) (*models.Installment, error) {
+	var installment models.Installment
+	query := `SELECT id, plan_id, sequence, amount, due_date, status, paid_at, created_at, updated_at
+			  FROM installments WHERE id = $1`
+	if err := r.db.Get(&installment, query, id); err != nil {
+		return nil, err
+	}
+	return &installment, nil
+}
+
+// the share and the plan's installments stay locked from the check to the delete, so an installment
+// can't be paid or checked out in between. a share in processing has an installment checkout in flight
+func (r *installmentRepositoryImpl) DeletePlan(ctx context.Context, paymentID int) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	status, err := lockPaymentStatus(ctx, tx, paymentID)
+	if err != nil {
+		return err
+	}
+	if status == models.Processing {
+		return ErrPlanPaidOn
+	}
+	var statuses []models.PaymentStatus
+	if err = tx.SelectContext(ctx, &statuses, `SELECT i.status FROM installments i
+			  JOIN installment_plans p ON p.id = i.plan_id WHERE p.payment_id = $1 FOR UPDATE OF i`, paymentID); err != nil {
+		return err
+	}
+	for _, installment := range statuses {
+		if installment == models.Paid || installment == models.Processing {
+			return ErrPlanPaidOn
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM installment_plans WHERE payment_id = $1`, paymentID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInstallmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockInstallmentRepository) CreatePlan(ctx context.Context, plan models.InstallmentPlan) (int, error) {
+	args := m.Called(ctx, plan)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockInstallmentRepository) GetPlanByID(id int) (*models.InstallmentPlan, error) {
+	args := m.Called(id)
+	if plan, ok := args.Get(0).(*models.InstallmentPlan); ok {
+		return plan, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInstallmentRepository) GetPlanByPayment(paymentID int) (*models.InstallmentPlan, error) {
+	args := m.Called(paymentID)
+	if plan, ok := args.Get(0).(*models.InstallmentPlan); ok {
+		return plan, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInstallmentRepository) GetPlansByUser(userID int) ([]models.InstallmentPlan, error) {
+	args := m.Called(userID)
+	if plans, ok := args.Get(0).([]models.InstallmentPlan); ok {
+		return plans, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInstallmentRepository) GetInstallmentByID(id int) (*models.Installment, error) {
+	args := m.Called(id)
+	if installment, ok := args.Get(0).(*models.Installment); ok {
+		return installment, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInstallmentRepository) DeletePlan(ctx context.Context, paymentID int) error {
+	args := m.Called(ctx, paymentID)
+	return args.Error(0)
+}
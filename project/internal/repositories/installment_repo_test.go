@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectInstallmentPlans(mock sqlmock.Sqlmock, planned bool) {
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM installment_plans WHERE payment_id = ANY\\(\\$1\\)\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(planned))
+}
+
+func TestInstallmentRepository_CreatePlan(t *testing.T) {
+	plan := models.InstallmentPlan{
+		PaymentID: 5,
+		UserID:    2,
+		CreatedBy: 1,
+		Installments: []models.Installment{
+			{Sequence: 1, Amount: 5000},
+			{Sequence: 2, Amount: 5000},
+		},
+	}
+
+	t.Run("stores the plan", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &installmentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status, amount - amount_paid FROM payments WHERE id = \\$1 FOR UPDATE").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status", "outstanding"}).AddRow(models.Pending, "100.00"))
+		mock.ExpectQuery("INSERT INTO installment_plans").
+			WithArgs(5, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		for _, installment := range plan.Installments {
+			mock.ExpectExec("INSERT INTO installments").
+				WithArgs(3, installment.Sequence, installment.Amount, installment.DueDate, models.Pending).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		id, err := repo.CreatePlan(context.Background(), plan)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("payment went into a checkout", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &installmentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status, amount - amount_paid FROM payments").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status", "outstanding"}).AddRow(models.Processing, "100.00"))
+		mock.ExpectRollback()
+
+		_, err := repo.CreatePlan(context.Background(), plan)
+
+		assert.ErrorIs(t, err, ErrPaymentNotPayable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("payment was partly paid meanwhile", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &installmentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status, amount - amount_paid FROM payments").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status", "outstanding"}).AddRow(models.PartiallyPaid, "60.00"))
+		mock.ExpectRollback()
+
+		_, err := repo.CreatePlan(context.Background(), plan)
+
+		assert.ErrorIs(t, err, ErrPlanOutstanding)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestInstallmentRepository_DeletePlan(t *testing.T) {
+	tests := []struct {
+		name          string
+		shareStatus   models.PaymentStatus
+		installments  []models.PaymentStatus
+		deleted       int64
+		expectedError error
+	}{
+		{
+			name:         "deletes a plan nothing was paid on",
+			shareStatus:  models.Pending,
+			installments: []models.PaymentStatus{models.Pending, models.Pending},
+			deleted:      1,
+		},
+		{
+			name:          "an installment checkout is in flight",
+			shareStatus:   models.Processing,
+			expectedError: ErrPlanPaidOn,
+		},
+		{
+			name:          "an installment was paid",
+			shareStatus:   models.PartiallyPaid,
+			installments:  []models.PaymentStatus{models.Paid, models.Pending},
+			expectedError: ErrPlanPaidOn,
+		},
+		{
+			name:          "share has no plan",
+			shareStatus:   models.Pending,
+			expectedError: sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupTestDB(t)
+			defer db.Close()
+			repo := &installmentRepositoryImpl{db: db}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT payment_status FROM payments WHERE id = \\$1 FOR UPDATE").
+				WithArgs(5).
+				WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(tt.shareStatus))
+			if tt.shareStatus != models.Processing {
+				rows := sqlmock.NewRows([]string{"status"})
+				for _, status := range tt.installments {
+					rows.AddRow(status)
+				}
+				mock.ExpectQuery("SELECT i.status FROM installments i JOIN installment_plans p ON p.id = i.plan_id WHERE p.payment_id = \\$1 FOR UPDATE OF i").
+					WithArgs(5).
+					WillReturnRows(rows)
+			}
+			if tt.expectedError != ErrPlanPaidOn {
+				mock.ExpectExec("DELETE FROM installment_plans WHERE payment_id = \\$1").
+					WithArgs(5).
+					WillReturnResult(sqlmock.NewResult(0, tt.deleted))
+			}
+			if tt.expectedError != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			err := repo.DeletePlan(context.Background(), 5)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_LATE_FEE_POLICIES_TABLE = `CREATE TABLE IF NOT EXISTS late_fee_policies(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL UNIQUE REFERENCES apartments(id) ON DELETE CASCADE,
+		fee_type VARCHAR(20) NOT NULL,
+		flat_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+		percentage DECIMAL(6,2) NOT NULL DEFAULT 0,
+		daily_rate DECIMAL(6,3) NOT NULL DEFAULT 0,
+		cap DECIMAL(12,2) NOT NULL DEFAULT 0,
+		grace_days INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+)
+
+type LateFeePolicyRepository interface {
+	UpsertLateFeePolicy(ctx context.Context, policy models.LateFeePolicy) (int, error)
+	GetLateFeePolicy(apartmentID int) (*models.LateFeePolicy, error)
+	GetAllLateFeePolicies() ([]models.LateFeePolicy, error)
+	DeleteLateFeePolicy(apartmentID int) error
+}
+
+type lateFeePolicyRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewLateFeePolicyRepository(autoCreate bool, db *sqlx.DB) LateFeePolicyRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_LATE_FEE_POLICIES_TABLE); err != nil {
+			log.Fatalf("failed to create late_fee_policies table: %v", err)
+		}
+	}
+	return &lateFeePolicyRepositoryImpl{db: db}
+}
+
+func (r *lateFeePolicyRepositoryImpl) UpsertLateFeePolicy(ctx context.Context, policy models.LateFeePolicy) (int, error) {
+	query := `INSERT INTO late_fee_policies (apartment_id, fee_type, flat_amount, percentage, daily_rate, cap, grace_days)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  ON CONFLICT (apartment_id) DO UPDATE SET
+			  fee_type = EXCLUDED.fee_type,
+			  flat_amount = EXCLUDED.flat_amount,
+			  percentage = EXCLUDED.percentage,
+			  daily_rate = EXCLUDED.daily_rate,
+			  cap = EXCLUDED.cap,
+			  grace_days = EXCLUDED.grace_days,
+			  updated_at = CURRENT_TIMESTAMP
+			  RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		policy.ApartmentID,
+		policy.FeeType,
+		policy.FlatAmount,
+		policy.Percentage,
+		policy.DailyRate,
+		policy.Cap,
+		policy.GraceDays).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *lateFeePolicyRepositoryImpl) GetLateFeePolicy(apartmentID int) (*models.LateFeePolicy, error) {
+	var policy models.LateFeePolicy
+	query := `SELECT id, apartment_id, fee_type, flat_amount, percentage, daily_rate, cap, grace_days, created_at, updated_at
+			  FROM late_fee_policies WHERE apartment_id = $1`
+	err := r.db.Get(&policy, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *lateFeePolicyRepositoryImpl) GetAllLateFeePolicies() ([]models.LateFeePolicy, error) {
+	var policies []models.LateFeePolicy
+	query := `SELECT id, apartment_id, fee_type, flat_amount, percentage, daily_rate, cap, grace_days, created_at, updated_at
+			  FROM late_fee_policies ORDER BY apartment_id`
+	err := r.db.Select(&policies, query)
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *lateFeePolicyRepositoryImpl) DeleteLateFeePolicy(apartmentID int) error {
+	query := `DELETE FROM late_fee_policies WHERE apartment_id = $1`
+	_, err := r.db.Exec(query, apartmentID)
+	return err
+}
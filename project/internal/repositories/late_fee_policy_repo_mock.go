@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLateFeePolicyRepository struct {
+	mock.Mock
+}
+
+func (m *MockLateFeePolicyRepository) UpsertLateFeePolicy(ctx context.Context, policy models.LateFeePolicy) (int, error) {
+	args := m.Called(ctx, policy)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLateFeePolicyRepository) GetLateFeePolicy(apartmentID int) (*models.LateFeePolicy, error) {
+	args := m.Called(apartmentID)
+	if policy, ok := args.Get(0).(*models.LateFeePolicy); ok {
+		return policy, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLateFeePolicyRepository) GetAllLateFeePolicies() ([]models.LateFeePolicy, error) {
+	args := m.Called()
+	if policies, ok := args.Get(0).([]models.LateFeePolicy); ok {
+		return policies, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLateFeePolicyRepository) DeleteLateFeePolicy(apartmentID int) error {
+	args := m.Called(apartmentID)
+	return args.Error(0)
+}
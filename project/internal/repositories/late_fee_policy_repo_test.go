@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLateFeePolicyRepository_UpsertLateFeePolicy(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &lateFeePolicyRepositoryImpl{db: db}
+	policy := models.LateFeePolicy{
+		ApartmentID: 7,
+		FeeType:     models.DailyInterestFee,
+		DailyRate:   0.5,
+		Cap:         money.Amount(500000),
+		GraceDays:   3,
+	}
+
+	mock.ExpectQuery("INSERT INTO late_fee_policies .* ON CONFLICT \\(apartment_id\\) DO UPDATE").
+		WithArgs(policy.ApartmentID, policy.FeeType, policy.FlatAmount, policy.Percentage, policy.DailyRate, policy.Cap, policy.GraceDays).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	id, err := repo.UpsertLateFeePolicy(context.Background(), policy)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLateFeePolicyRepository_GetLateFeePolicy(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &lateFeePolicyRepositoryImpl{db: db}
+
+	t.Run("found", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "apartment_id", "fee_type", "flat_amount", "percentage", "daily_rate", "cap", "grace_days"}).
+			AddRow(2, 7, models.FlatLateFee, "50000.00", 0, 0, "0.00", 5)
+		mock.ExpectQuery("SELECT (.+) FROM late_fee_policies WHERE apartment_id = \\$1").
+			WithArgs(7).
+			WillReturnRows(rows)
+
+		policy, err := repo.GetLateFeePolicy(7)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.FlatLateFee, policy.FeeType)
+		assert.Equal(t, money.Amount(5000000), policy.FlatAmount)
+		assert.Equal(t, 5, policy.GraceDays)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM late_fee_policies WHERE apartment_id = \\$1").
+			WithArgs(8).
+			WillReturnError(sql.ErrNoRows)
+
+		policy, err := repo.GetLateFeePolicy(8)
+
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, policy)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_JOURNAL_ENTRIES_TABLE = `CREATE TABLE IF NOT EXISTS journal_entries(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		entry_type VARCHAR(30) NOT NULL,
+		reference VARCHAR(100) NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// a line is either a debit or a credit, entries are only ever appended
+	CREATE_JOURNAL_LINES_TABLE = `CREATE TABLE IF NOT EXISTS journal_lines(
+		id SERIAL PRIMARY KEY,
+		entry_id INTEGER NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+		account VARCHAR(50) NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		debit DECIMAL(12,2) NOT NULL DEFAULT 0,
+		credit DECIMAL(12,2) NOT NULL DEFAULT 0,
+		CHECK (debit >= 0 AND credit >= 0 AND (debit = 0 OR credit = 0))
+	);`
+
+	CREATE_JOURNAL_LINES_ACCOUNT_INDEX = `CREATE INDEX IF NOT EXISTS journal_lines_account ON journal_lines(account, user_id);`
+)
+
+var ErrUnbalancedEntry = errors.New("journal entry debits and credits do not balance")
+
+type LedgerRepository interface {
+	PostEntry(ctx context.Context, entry models.JournalEntry) (int, error)
+	GetEntries(apartmentID int) ([]models.JournalEntry, error)
+	GetAccountBalances(apartmentID int) ([]models.AccountBalance, error)
+	GetTrialBalance(apartmentID int) ([]models.AccountBalance, error)
+	GetAccountLines(apartmentID int, account models.LedgerAccount, userID *int) ([]models.AccountLine, error)
+	GetAccountLinesBetween(apartmentID int, account models.LedgerAccount, userID *int, from, to time.Time) ([]models.AccountLine, error)
+	GetAccountBalancesAt(apartmentID int, account models.LedgerAccount, userID *int, at time.Time) ([]models.AccountBalance, error)
+}
+
+type ledgerRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewLedgerRepository(autoCreate bool, db *sqlx.DB) LedgerRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_JOURNAL_ENTRIES_TABLE); err != nil {
+			log.Fatalf("failed to create journal_entries table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_JOURNAL_LINES_TABLE); err != nil {
+			log.Fatalf("failed to create journal_lines table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_JOURNAL_LINES_ACCOUNT_INDEX); err != nil {
+			log.Fatalf("failed to create journal_lines account index: %v", err)
+		}
+	}
+	return &ledgerRepositoryImpl{db: db}
+}
+
+func (r *ledgerRepositoryImpl) PostEntry(ctx context.Context, entry models.JournalEntry) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = postJournalEntry(ctx, tx, &entry); err != nil {
+		return 0, err
+	}
+	return entry.ID, nil
+}
+
+func (r *ledgerRepositoryImpl) GetEntries(apartmentID int) ([]models.JournalEntry, error) {
+	var entries []models.JournalEntry
+	query := `SELECT id, apartment_id, entry_type, reference, description, currency, created_at
+			  FROM journal_entries WHERE apartment_id = $1 ORDER BY id`
+	if err := r.db.Select(&entries, query, apartmentID); err != nil {
+		return nil, err
+	}
+
+	var lines []models.JournalLine
+	query = `SELECT l.id, l.entry_id, l.account, l.user_id, l.debit, l.credit
+			 FROM journal_lines l JOIN journal_entries e ON e.id = l.entry_id
+			 WHERE e.apartment_id = $1 ORDER BY l.id`
+	if err := r.db.Select(&lines, query, apartmentID); err != nil {
+		return nil, err
+	}
+
+	byEntry := make(map[int]int, len(entries))
+	for i := range entries {
+		byEntry[entries[i].ID] = i
+	}
+	for _, line := range lines {
+		if i, ok := byEntry[line.EntryID]; ok {
+			entries[i].Lines = append(entries[i].Lines, line)
+		}
+	}
+	return entries, nil
+}
+
+// balance of every account, receivable and wallet accounts broken down per resident
+func (r *ledgerRepositoryImpl) GetAccountBalances(apartmentID int) ([]models.AccountBalance, error) {
+	var balances []models.AccountBalance
+	query := `SELECT l.account, l.user_id, e.currency, SUM(l.debit) AS debit, SUM(l.credit) AS credit,
+			  SUM(l.debit) - SUM(l.credit) AS balance
+			  FROM journal_lines l JOIN journal_entries e ON e.id = l.entry_id
+			  WHERE e.apartment_id = $1
+			  GROUP BY l.account, l.user_id, e.currency
+			  ORDER BY l.account, e.currency, l.user_id`
+	if err := r.db.Select(&balances, query, apartmentID); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// the same balances totalled per account
+func (r *ledgerRepositoryImpl) GetTrialBalance(apartmentID int) ([]models.AccountBalance, error) {
+	var balances []models.AccountBalance
+	query := `SELECT l.account, e.currency, SUM(l.debit) AS debit, SUM(l.credit) AS credit,
+			  SUM(l.debit) - SUM(l.credit) AS balance
+			  FROM journal_lines l JOIN journal_entries e ON e.id = l.entry_id
+			  WHERE e.apartment_id = $1
+			  GROUP BY l.account, e.currency
+			  ORDER BY e.currency, l.account`
+	if err := r.db.Select(&balances, query, apartmentID); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// the statement of one account with the running balance after every line. a nil user covers
+// the lines of all residents
+func (r *ledgerRepositoryImpl) GetAccountLines(apartmentID int, account models.LedgerAccount, userID *int) ([]models.AccountLine, error) {
+	var lines []models.AccountLine
+	query := `SELECT e.id AS entry_id, e.entry_type, e.reference, e.description, e.currency, l.debit, l.credit,
+			  SUM(l.debit - l.credit) OVER (PARTITION BY e.currency ORDER BY e.id, l.id) AS running_balance,
+			  e.created_at
+			  FROM journal_lines l JOIN journal_entries e ON e.id = l.entry_id
+			  WHERE e.apartment_id = $1 AND l.account = $2 AND ($3::INTEGER IS NULL OR l.user_id = $3)
+			  ORDER BY e.id, l.id`
+	if err := r.db.Select(&lines, query, apartmentID, account, userID); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// the lines posted from from up to but not including to. the running balance still counts everything
+// posted before, so it matches the full statement
+func (r *ledgerRepositoryImpl) GetAccountLinesBetween(apartmentID int, account models.LedgerAccount, userID *int, from, to time.Time) ([]models.AccountLine, error) {
+	var lines []models.AccountLine
+	query := `SELECT entry_id, entry_type, reference, description, currency, debit, credit, running_balance, created_at FROM (
+			  SELECT e.id AS entry_id, e.entry_type, e.reference, e.description, e.currency, l.debit, l.credit,
+			  SUM(l.debit - l.credit) OVER (PARTITION BY e.currency ORDER BY e.id, l.id) AS running_balance,
+			  e.created_at, l.id AS line_id
+			  FROM journal_lines l JOIN journal_entries e ON e.id = l.entry_id
+			  WHERE e.apartment_id = $1 AND l.account = $2 AND ($3::INTEGER IS NULL OR l.user_id = $3)
+			  ) statement
+			  WHERE created_at >= $4 AND created_at < $5
+			  ORDER BY entry_id, line_id`
+	if err := r.db.Select(&lines, query, apartmentID, account, userID, from, to); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// the balance of an account per currency from the lines posted before at
+func (r *ledgerRepositoryImpl) GetAccountBalancesAt(apartmentID int, account models.LedgerAccount, userID *int, at time.Time) ([]models.AccountBalance, error) {
+	var balances []models.AccountBalance
+	query := `SELECT l.account, e.currency, SUM(l.debit) AS debit, SUM(l.credit) AS credit,
+			  SUM(l.debit) - SUM(l.credit) AS balance
+			  FROM journal_lines l JOIN journal_entries e ON e.id = l.entry_id
+			  WHERE e.apartment_id = $1 AND l.account = $2 AND ($3::INTEGER IS NULL OR l.user_id = $3) AND e.created_at < $4
+			  GROUP BY l.account, e.currency
+			  ORDER BY e.currency`
+	if err := r.db.Select(&balances, query, apartmentID, account, userID, at); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// appends a balanced entry inside the caller's transaction, so bookkeeping commits or rolls back
+// together with the change it records
+func postJournalEntry(ctx context.Context, tx *sqlx.Tx, entry *models.JournalEntry) error {
+	if !entry.Balanced() {
+		return ErrUnbalancedEntry
+	}
+
+	if err := tx.QueryRowContext(ctx, `INSERT INTO journal_entries (apartment_id, entry_type, reference, description, currency)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		entry.ApartmentID,
+		entry.EntryType,
+		entry.Reference,
+		entry.Description,
+		entry.Currency).Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		return err
+	}
+
+	for i := range entry.Lines {
+		line := &entry.Lines[i]
+		line.EntryID = entry.ID
+		if err := tx.QueryRowContext(ctx, `INSERT INTO journal_lines (entry_id, account, user_id, debit, credit)
+				  VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			entry.ID, line.Account, line.UserID, line.Debit, line.Credit).Scan(&line.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLedgerRepository struct {
+	mock.Mock
+}
+
+func (m *MockLedgerRepository) PostEntry(ctx context.Context, entry models.JournalEntry) (int, error) {
+	args := m.Called(ctx, entry)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetEntries(apartmentID int) ([]models.JournalEntry, error) {
+	args := m.Called(apartmentID)
+	if entries, ok := args.Get(0).([]models.JournalEntry); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetAccountBalances(apartmentID int) ([]models.AccountBalance, error) {
+	args := m.Called(apartmentID)
+	if balances, ok := args.Get(0).([]models.AccountBalance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetTrialBalance(apartmentID int) ([]models.AccountBalance, error) {
+	args := m.Called(apartmentID)
+	if balances, ok := args.Get(0).([]models.AccountBalance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetAccountLines(apartmentID int, account models.LedgerAccount, userID *int) ([]models.AccountLine, error) {
+	args := m.Called(apartmentID, account, userID)
+	if lines, ok := args.Get(0).([]models.AccountLine); ok {
+		return lines, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetAccountLinesBetween(apartmentID int, account models.LedgerAccount, userID *int, from, to time.Time) ([]models.AccountLine, error) {
+	args := m.Called(apartmentID, account, userID, from, to)
+	if lines, ok := args.Get(0).([]models.AccountLine); ok {
+		return lines, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerRepository) GetAccountBalancesAt(apartmentID int, account models.LedgerAccount, userID *int, at time.Time) ([]models.AccountBalance, error) {
+	args := m.Called(apartmentID, account, userID, at)
+	if balances, ok := args.Get(0).([]models.AccountBalance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
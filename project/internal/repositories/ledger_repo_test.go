@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// expects postJournalEntry to write the entry and the given lines in order
+func expectJournalEntry(mock sqlmock.Sqlmock, entryID, apartmentID int, entryType models.JournalEntryType, lines ...models.JournalLine) {
+	mock.ExpectQuery("INSERT INTO journal_entries").
+		WithArgs(apartmentID, entryType, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(entryID, time.Now()))
+	for i, line := range lines {
+		var userID interface{}
+		if line.UserID != nil {
+			userID = *line.UserID
+		}
+		mock.ExpectQuery("INSERT INTO journal_lines").
+			WithArgs(entryID, line.Account, userID, line.Debit, line.Credit).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i + 1))
+	}
+}
+
+func TestLedgerRepository_PostEntry(t *testing.T) {
+	resident := 2
+
+	t.Run("writes a balanced entry", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &ledgerRepositoryImpl{db: db}
+
+		entry := models.NewJournalEntry(7, models.DivisionEntry, "bill:11", "water bill", money.IRR).
+			Debit(models.ResidentReceivable, &resident, 3000).
+			Credit(models.BillsToDivide, nil, 3000)
+
+		mock.ExpectBegin()
+		expectJournalEntry(mock, 5, 7, models.DivisionEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: 3000},
+			models.JournalLine{Account: models.BillsToDivide, Credit: 3000})
+		mock.ExpectCommit()
+
+		id, err := repo.PostEntry(context.Background(), *entry)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects an unbalanced entry", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &ledgerRepositoryImpl{db: db}
+
+		entry := models.NewJournalEntry(7, models.DivisionEntry, "bill:11", "water bill", money.IRR).
+			Debit(models.ResidentReceivable, &resident, 3000).
+			Credit(models.BillsToDivide, nil, 2000)
+
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		_, err := repo.PostEntry(context.Background(), *entry)
+
+		assert.ErrorIs(t, err, ErrUnbalancedEntry)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLedgerRepository_GetAccountLines(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &ledgerRepositoryImpl{db: db}
+	resident := 2
+
+	mock.ExpectQuery("SUM\\(l.debit - l.credit\\) OVER").
+		WithArgs(7, models.ResidentReceivable, resident).
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id", "entry_type", "reference", "description", "currency", "debit", "credit", "running_balance", "created_at"}).
+			AddRow(1, models.DivisionEntry, "bill:11", "water bill", money.IRR, "30.00", "0.00", "30.00", time.Now()).
+			AddRow(2, models.PaymentEntry, "transaction:4", "Paid through simulator", money.IRR, "0.00", "20.00", "10.00", time.Now()))
+
+	lines, err := repo.GetAccountLines(7, models.ResidentReceivable, &resident)
+
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, money.Amount(1000), lines[1].RunningBalance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedgerRepository_GetAccountLinesBetween(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &ledgerRepositoryImpl{db: db}
+	resident := 2
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SUM\\(l.debit - l.credit\\) OVER (.+) WHERE created_at >= \\$4 AND created_at < \\$5").
+		WithArgs(7, models.ResidentReceivable, resident, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id", "entry_type", "reference", "description", "currency", "debit", "credit", "running_balance", "created_at"}).
+			AddRow(5, models.PaymentEntry, "transaction:4", "Paid through simulator", money.IRR, "0.00", "20.00", "10.00", from.Add(time.Hour)))
+
+	lines, err := repo.GetAccountLinesBetween(7, models.ResidentReceivable, &resident, from, to)
+
+	assert.NoError(t, err)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, money.Amount(1000), lines[0].RunningBalance, "the running balance includes what was posted before the range")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedgerRepository_GetAccountBalancesAt(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &ledgerRepositoryImpl{db: db}
+	resident := 2
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT l.account, e.currency, (.+) AND e.created_at < \\$4").
+		WithArgs(7, models.ResidentReceivable, resident, at).
+		WillReturnRows(sqlmock.NewRows([]string{"account", "currency", "debit", "credit", "balance"}).
+			AddRow(models.ResidentReceivable, money.IRR, "30.00", "0.00", "30.00"))
+
+	balances, err := repo.GetAccountBalancesAt(7, models.ResidentReceivable, &resident, at)
+
+	assert.NoError(t, err)
+	assert.Len(t, balances, 1)
+	assert.Equal(t, money.Amount(3000), balances[0].Balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_METERS_TABLE = `CREATE TABLE IF NOT EXISTS meters(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		meter_type VARCHAR(50) NOT NULL,
+		serial_number VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (apartment_id, user_id, meter_type)
+	);`
+
+	CREATE_METER_READINGS_TABLE = `CREATE TABLE IF NOT EXISTS meter_readings(
+		id SERIAL PRIMARY KEY,
+		meter_id INTEGER NOT NULL REFERENCES meters(id) ON DELETE CASCADE,
+		reading_value DECIMAL(14,3) NOT NULL,
+		read_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		image_key VARCHAR(2000) NOT NULL DEFAULT '',
+		submitted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+)
+
+type MeterRepository interface {
+	CreateMeter(ctx context.Context, meter models.Meter) (int, error)
+	GetMeterByID(id int) (*models.Meter, error)
+	GetMetersByApartment(apartmentID int) ([]models.Meter, error)
+	GetMetersByApartmentAndType(apartmentID int, meterType models.BillType) ([]models.Meter, error)
+	DeleteMeter(id int) error
+	CreateReading(ctx context.Context, reading models.MeterReading) (int, error)
+	GetReadingsByMeter(meterID int) ([]models.MeterReading, error)
+}
+
+type meterRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewMeterRepository(autoCreate bool, db *sqlx.DB) MeterRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_METERS_TABLE); err != nil {
+			log.Fatalf("failed to create meters table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_METER_READINGS_TABLE); err != nil {
+			log.Fatalf("failed to create meter_readings table: %v", err)
+		}
+	}
+	return &meterRepositoryImpl{db: db}
+}
+
+func (r *meterRepositoryImpl) CreateMeter(ctx context.Context, meter models.Meter) (int, error) {
+	query := `INSERT INTO meters (apartment_id, user_id, meter_type, serial_number)
+			  VALUES ($1, $2, $3, $4) RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		meter.ApartmentID,
+		meter.UserID,
+		meter.MeterType,
+		meter.SerialNumber).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *meterRepositoryImpl) GetMeterByID(id int) (*models.Meter, error) {
+	var meter models.Meter
+	query := `SELECT id, apartment_id, user_id, meter_type, serial_number, created_at, updated_at
+			  FROM meters WHERE id = $1`
+	err := r.db.Get(&meter, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &meter, nil
+}
+
+func (r *meterRepositoryImpl) GetMetersByApartment(apartmentID int) ([]models.Meter, error) {
+	var meters []models.Meter
+	query := `SELECT id, apartment_id, user_id, meter_type, serial_number, created_at, updated_at
+			  FROM meters WHERE apartment_id = $1 ORDER BY user_id, meter_type`
+	err := r.db.Select(&meters, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return meters, nil
+}
+
+func (r *meterRepositoryImpl) GetMetersByApartmentAndType(apartmentID int, meterType models.BillType) ([]models.Meter, error) {
+	var meters []models.Meter
+	query := `SELECT id, apartment_id, user_id, meter_type, serial_number, created_at, updated_at
+			  FROM meters WHERE apartment_id = $1 AND meter_type = $2 ORDER BY user_id`
+	err := r.db.Select(&meters, query, apartmentID, meterType)
+	if err != nil {
+		return nil, err
+	}
+	return meters, nil
+}
+
+func (r *meterRepositoryImpl) DeleteMeter(id int) error {
+	query := `DELETE FROM meters WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *meterRepositoryImpl) CreateReading(ctx context.Context, reading models.MeterReading) (int, error) {
+	query := `INSERT INTO meter_readings (meter_id, reading_value, read_at, image_key, submitted_by)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		reading.MeterID,
+		reading.Value,
+		reading.ReadAt,
+		reading.ImageKey,
+		reading.SubmittedBy).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// readings are returned oldest first
+func (r *meterRepositoryImpl) GetReadingsByMeter(meterID int) ([]models.MeterReading, error) {
+	var readings []models.MeterReading
+	query := `SELECT id, meter_id, reading_value, read_at, image_key, submitted_by, created_at, updated_at
+			  FROM meter_readings WHERE meter_id = $1 ORDER BY read_at ASC, id ASC`
+	err := r.db.Select(&readings, query, meterID)
+	if err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
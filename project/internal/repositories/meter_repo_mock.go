@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMeterRepository struct {
+	mock.Mock
+}
+
+func (m *MockMeterRepository) CreateMeter(ctx context.Context, meter models.Meter) (int, error) {
+	args := m.Called(ctx, meter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockMeterRepository) GetMeterByID(id int) (*models.Meter, error) {
+	args := m.Called(id)
+	if meter, ok := args.Get(0).(*models.Meter); ok {
+		return meter, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMeterRepository) GetMetersByApartment(apartmentID int) ([]models.Meter, error) {
+	args := m.Called(apartmentID)
+	if meters, ok := args.Get(0).([]models.Meter); ok {
+		return meters, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMeterRepository) GetMetersByApartmentAndType(apartmentID int, meterType models.BillType) ([]models.Meter, error) {
+	args := m.Called(apartmentID, meterType)
+	if meters, ok := args.Get(0).([]models.Meter); ok {
+		return meters, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMeterRepository) DeleteMeter(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockMeterRepository) CreateReading(ctx context.Context, reading models.MeterReading) (int, error) {
+	args := m.Called(ctx, reading)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockMeterRepository) GetReadingsByMeter(meterID int) ([]models.MeterReading, error) {
+	args := m.Called(meterID)
+	if readings, ok := args.Get(0).([]models.MeterReading); ok {
+		return readings, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeterRepository_CreateMeter(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &meterRepositoryImpl{db: db}
+	meter := models.Meter{ApartmentID: 1, UserID: 2, MeterType: models.WaterBill, SerialNumber: "W-100"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO meters").
+			WithArgs(meter.ApartmentID, meter.UserID, meter.MeterType, meter.SerialNumber).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+		id, err := repo.CreateMeter(context.Background(), meter)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+	})
+
+	t.Run("duplicate meter", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO meters").
+			WillReturnError(sql.ErrConnDone)
+
+		id, err := repo.CreateMeter(context.Background(), meter)
+		assert.Error(t, err)
+		assert.Equal(t, 0, id)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMeterRepository_GetReadingsByMeter(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &meterRepositoryImpl{db: db}
+	now := time.Now()
+	columns := []string{"id", "meter_id", "reading_value", "read_at", "image_key", "submitted_by", "created_at", "updated_at"}
+
+	mock.ExpectQuery(`SELECT (.+) FROM meter_readings WHERE meter_id = \$1 ORDER BY read_at ASC`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, 3, 100.5, now.AddDate(0, -1, 0), "", 2, now, now).
+			AddRow(2, 3, 130.25, now, "readings/photo.jpg", 2, now, now))
+
+	readings, err := repo.GetReadingsByMeter(3)
+	assert.NoError(t, err)
+	assert.Len(t, readings, 2)
+	assert.Equal(t, 130.25, readings[1].Value)
+	assert.Equal(t, "readings/photo.jpg", readings[1].ImageKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
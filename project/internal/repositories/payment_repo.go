@@ -2,10 +2,17 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 )
 
 const (
@@ -14,22 +21,71 @@ const (
 		bill_id INTEGER REFERENCES bills(id) ON DELETE CASCADE,
 		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
 		amount DECIMAL(12, 2) NOT NULL,
+		amount_paid DECIMAL(12, 2) NOT NULL DEFAULT 0,
+		amount_refunded DECIMAL(12, 2) NOT NULL DEFAULT 0,
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
 		paid_at TIMESTAMP WITH TIME ZONE,
 		payment_status VARCHAR(50) NOT NULL,
+		split_strategy VARCHAR(20) NOT NULL DEFAULT 'equal',
+		kind VARCHAR(20) NOT NULL DEFAULT 'share',
+		parent_payment_id INTEGER REFERENCES payments(id) ON DELETE CASCADE,
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 	);`
+	// adds to the paid amount and moves the payment to partially_paid or paid, overpaying matches no row
+	RECORD_PARTIAL_PAYMENT = `UPDATE payments SET
+		amount_paid = amount_paid + $1,
+		payment_status = CASE WHEN amount_paid + $1 >= amount THEN 'paid' ELSE 'partially_paid' END,
+		paid_at = CASE WHEN amount_paid + $1 >= amount THEN CURRENT_TIMESTAMP ELSE paid_at END,
+		updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND payment_status IN ('pending', 'partially_paid', 'processing') AND amount_paid + $1 <= amount`
+	// the status history of payments, only ever appended to
+	CREATE_PAYMENT_EVENTS_TABLE = `CREATE TABLE IF NOT EXISTS payment_events(
+		id SERIAL PRIMARY KEY,
+		payment_id INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+		from_status VARCHAR(50) NOT NULL,
+		to_status VARCHAR(50) NOT NULL,
+		actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+	// what a payment covers of each line item of an itemized bill
+	CREATE_PAYMENT_LINE_ITEMS_TABLE = `CREATE TABLE IF NOT EXISTS payment_line_items(
+		payment_id INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+		line_item_id INTEGER NOT NULL REFERENCES bill_line_items(id) ON DELETE CASCADE,
+		amount DECIMAL(12,2) NOT NULL,
+		PRIMARY KEY (payment_id, line_item_id)
+	);`
+	// appended to RECORD_PARTIAL_PAYMENT to learn whose receivable the payment settles
+	RETURNING_PAYMENT_OWNER = ` RETURNING user_id, (SELECT apartment_id FROM bills WHERE bills.id = payments.bill_id), currency`
+	// a share gets at most one penalty, which keeps the late-fee job idempotent
+	CREATE_PAYMENTS_PENALTY_INDEX = `CREATE UNIQUE INDEX IF NOT EXISTS payments_one_penalty_per_share
+		ON payments(parent_payment_id) WHERE kind = 'penalty';`
+	// a resident has one share of a bill, so dividing it twice at the same time can't charge them twice
+	CREATE_PAYMENTS_SHARE_INDEX = `CREATE UNIQUE INDEX IF NOT EXISTS payments_one_share_per_resident
+		ON payments(bill_id, user_id) WHERE kind = 'share';`
+)
+
+var (
+	ErrInvalidPaymentTransition = errors.New("payment status change is not allowed")
+	ErrPaymentExists            = errors.New("resident already has a share of this bill")
 )
 
 type PaymentRepository interface {
-	CreatePayment(ctx context.Context, payment models.Payment) (int, error)
+	CreatePayment(ctx context.Context, payment models.Payment, journal *models.JournalEntry) (int, error)
 	GetPaymentByID(id int) (*models.Payment, error)
 	GetPaymentByBillAndUser(billID, userID int) (*models.Payment, error)
 	GetPaymentsByUser(userID int) ([]models.Payment, error)
 	GetPendingPaymentsByUser(userID int) ([]models.Payment, error)
 	GetPaymentsByBill(billID int) ([]models.Payment, error)
-	UpdatePaymentStatus(ctx context.Context, payment models.Payment) error
-	UpdatePaymentsStatus(ctx context.Context, payments []models.Payment) error
+	GetPaymentsByParent(parentID int) ([]models.Payment, error)
+	GetOverdueShares(apartmentID int, deadlineBefore time.Time) ([]models.Payment, error)
+	UpdatePaymentStatus(ctx context.Context, payment models.Payment, actorID *int, reason string) error
+	UpdatePaymentsStatus(ctx context.Context, payments []models.Payment, actorID *int, reason string) error
+	UpdatePendingAmount(ctx context.Context, id int, amount money.Amount, journal *models.JournalEntry) error
+	WriteOffPayment(ctx context.Context, id, managerID int, note string) (*models.JournalEntry, error)
+	GetPaymentEvents(paymentID int) ([]models.PaymentEvent, error)
+	GetBreakdowns(paymentIDs []int) (map[int][]models.ShareLineItem, error)
 	DeletePayment(id int) error
 }
 
@@ -42,29 +98,56 @@ func NewPaymentRepository(autoCreate bool, db *sqlx.DB) PaymentRepository {
 		if _, err := db.Exec(CREATE_PAYMENTS_TABLE); err != nil {
 			log.Fatalf("failed to create payments table: %v", err)
 		}
+		if _, err := db.Exec(CREATE_PAYMENTS_PENALTY_INDEX); err != nil {
+			log.Fatalf("failed to create payments penalty index: %v", err)
+		}
+		if _, err := db.Exec(CREATE_PAYMENTS_SHARE_INDEX); err != nil {
+			log.Fatalf("failed to create payments share index: %v", err)
+		}
+		if _, err := db.Exec(CREATE_PAYMENT_EVENTS_TABLE); err != nil {
+			log.Fatalf("failed to create payment_events table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_PAYMENT_LINE_ITEMS_TABLE); err != nil {
+			log.Fatalf("failed to create payment_line_items table: %v", err)
+		}
 	}
 	return &paymentRepositoryImpl{db: db}
 }
 
-func (r *paymentRepositoryImpl) CreatePayment(ctx context.Context, payment models.Payment) (int, error) {
-	query := `INSERT INTO payments (bill_id, user_id, amount, paid_at, payment_status) 
-			  VALUES ($1, $2, $3, $4, $5) 
-			  RETURNING id`
-	var id int
-	if err := r.db.QueryRowContext(ctx, query,
-		payment.BillID,
-		payment.UserID,
-		payment.Amount,
-		payment.PaidAt,
-		payment.PaymentStatus).Scan(&id); err != nil {
+// stores the payment, starts its status history and books it. the journal's reference is set to the
+// new payment. a second share of the same bill for the same resident returns ErrPaymentExists
+func (r *paymentRepositoryImpl) CreatePayment(ctx context.Context, payment models.Payment, journal *models.JournalEntry) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
 		return 0, err
 	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if id, err = insertPayment(ctx, tx, payment); err != nil {
+		return 0, err
+	}
+	// an extra charge takes a settled bill back to divided
+	if err = syncBillSettlement(ctx, tx, id); err != nil {
+		return 0, err
+	}
+	if journal != nil && len(journal.Lines) > 0 {
+		journal.Reference = fmt.Sprintf("payment:%d", id)
+		if err = postJournalEntry(ctx, tx, journal); err != nil {
+			return 0, err
+		}
+	}
 	return id, nil
 }
 
 func (r *paymentRepositoryImpl) GetPaymentByID(id int) (*models.Payment, error) {
 	var payment models.Payment
-	query := `SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at 
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at 
 			  FROM payments WHERE id = $1`
 	err := r.db.Get(&payment, query, id)
 	if err != nil {
@@ -75,8 +158,8 @@ func (r *paymentRepositoryImpl) GetPaymentByID(id int) (*models.Payment, error)
 
 func (r *paymentRepositoryImpl) GetPaymentByBillAndUser(billID, userID int) (*models.Payment, error) {
 	var payment models.Payment
-	query := `SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at 
-			  FROM payments WHERE bill_id = $1 AND user_id = $2`
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at 
+			  FROM payments WHERE bill_id = $1 AND user_id = $2 AND kind = 'share'`
 	err := r.db.Get(&payment, query, billID, userID)
 	if err != nil {
 		return nil, err
@@ -86,7 +169,7 @@ func (r *paymentRepositoryImpl) GetPaymentByBillAndUser(billID, userID int) (*mo
 
 func (r *paymentRepositoryImpl) GetPaymentsByUser(userID int) ([]models.Payment, error) {
 	var payments []models.Payment
-	query := `SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at 
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at 
 			  FROM payments WHERE user_id = $1`
 	err := r.db.Select(&payments, query, userID)
 	if err != nil {
@@ -97,8 +180,9 @@ func (r *paymentRepositoryImpl) GetPaymentsByUser(userID int) ([]models.Payment,
 
 func (r *paymentRepositoryImpl) GetPendingPaymentsByUser(userID int) ([]models.Payment, error) {
 	var payments []models.Payment
-	query := `SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at 
-			  FROM payments WHERE user_id = $1 and payment_status = 'pending'`
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at,
+			  EXISTS (SELECT 1 FROM installment_plans WHERE payment_id = payments.id) AS on_installments
+			  FROM payments WHERE user_id = $1 and payment_status IN ('pending', 'partially_paid', 'processing') ORDER BY id`
 	err := r.db.Select(&payments, query, userID)
 	if err != nil {
 		return nil, err
@@ -108,8 +192,8 @@ func (r *paymentRepositoryImpl) GetPendingPaymentsByUser(userID int) ([]models.P
 
 func (r *paymentRepositoryImpl) GetPaymentsByBill(billID int) ([]models.Payment, error) {
 	var payments []models.Payment
-	query := `SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at 
-			  FROM payments WHERE bill_id = $1`
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at 
+			  FROM payments WHERE bill_id = $1 ORDER BY id`
 	err := r.db.Select(&payments, query, billID)
 	if err != nil {
 		return nil, err
@@ -117,17 +201,43 @@ func (r *paymentRepositoryImpl) GetPaymentsByBill(billID int) ([]models.Payment,
 	return payments, nil
 }
 
-func (r *paymentRepositoryImpl) UpdatePaymentStatus(ctx context.Context, payment models.Payment) error {
-	query := `UPDATE payments SET 
-			  payment_status = :payment_status,
-			  paid_at = :paid_at,
-			  updated_at = CURRENT_TIMESTAMP
-			  WHERE id = :id`
-	_, err := r.db.NamedExecContext(ctx, query, payment)
-	return err
+func (r *paymentRepositoryImpl) GetPaymentsByParent(parentID int) ([]models.Payment, error) {
+	var payments []models.Payment
+	query := `SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at 
+			  FROM payments WHERE parent_payment_id = $1 ORDER BY id`
+	err := r.db.Select(&payments, query, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// unsettled shares of the apartment's bills whose deadline (billing deadline, else due date) is before the given day.
+// partly paid shares still owe the rest, and shares in a checkout stay overdue until it actually settles them.
+// shares on an installment plan follow the plan's schedule instead
+func (r *paymentRepositoryImpl) GetOverdueShares(apartmentID int, deadlineBefore time.Time) ([]models.Payment, error) {
+	var payments []models.Payment
+	query := `SELECT p.id, p.bill_id, p.user_id, p.amount, p.amount_paid, p.amount_refunded, p.currency, p.paid_at, p.payment_status, p.split_strategy, p.kind, p.parent_payment_id, p.created_at, p.updated_at 
+			  FROM payments p JOIN bills b ON b.id = p.bill_id
+			  WHERE b.apartment_id = $1 AND p.kind = 'share' AND p.payment_status IN ('pending', 'partially_paid', 'processing')
+			  AND COALESCE(b.billing_deadline, b.due_date) < $2
+			  AND NOT EXISTS (SELECT 1 FROM installment_plans ip WHERE ip.payment_id = p.id)
+			  ORDER BY p.id`
+	err := r.db.Select(&payments, query, apartmentID, deadlineBefore)
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
 }
 
-func (r *paymentRepositoryImpl) UpdatePaymentsStatus(ctx context.Context, payments []models.Payment) error {
+// moves the payment to another status, as long as the state machine allows it
+func (r *paymentRepositoryImpl) UpdatePaymentStatus(ctx context.Context, payment models.Payment, actorID *int, reason string) error {
+	return r.UpdatePaymentsStatus(ctx, []models.Payment{payment}, actorID, reason)
+}
+
+// all or nothing, one invalid transition leaves every payment as it was. payments are locked in id
+// order like the checkouts lock them
+func (r *paymentRepositoryImpl) UpdatePaymentsStatus(ctx context.Context, payments []models.Payment, actorID *int, reason string) (err error) {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
@@ -143,21 +253,236 @@ func (r *paymentRepositoryImpl) UpdatePaymentsStatus(ctx context.Context, paymen
 	query := `UPDATE payments SET 
 			  payment_status = :payment_status,
 			  paid_at = :paid_at,
+			  amount_paid = CASE WHEN :payment_status = 'paid' THEN amount ELSE amount_paid END,
 			  updated_at = CURRENT_TIMESTAMP
 			  WHERE id = :id`
 
-	for _, payment := range payments {
-		_, err = tx.NamedExecContext(ctx, query, payment)
-		if err != nil {
+	sorted := make([]models.Payment, len(payments))
+	copy(sorted, payments)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	for _, payment := range sorted {
+		var from models.PaymentStatus
+		if from, err = lockPaymentStatus(ctx, tx, payment.ID); err != nil {
+			return err
+		}
+		if !from.CanTransitionTo(payment.PaymentStatus) {
+			return fmt.Errorf("%w: payment %d from %s to %s", ErrInvalidPaymentTransition, payment.ID, from, payment.PaymentStatus)
+		}
+
+		if _, err = tx.NamedExecContext(ctx, query, payment); err != nil {
+			return err
+		}
+		if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+			PaymentID:  payment.ID,
+			FromStatus: from,
+			ToStatus:   payment.PaymentStatus,
+			ActorID:    actorID,
+			Reason:     reason,
+		}); err != nil {
 			return err
 		}
 	}
+	ids := make([]int, len(sorted))
+	for i, payment := range sorted {
+		ids[i] = payment.ID
+	}
+	return syncBillSettlement(ctx, tx, ids...)
+}
 
+// only pending payments can change their amount, a paid one needs an adjustment instead
+// books the change with the journal in the same transaction
+func (r *paymentRepositoryImpl) UpdatePendingAmount(ctx context.Context, id int, amount money.Amount, journal *models.JournalEntry) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	query := `UPDATE payments SET amount = $1, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $2 AND payment_status = 'pending'`
+	result, err := tx.ExecContext(ctx, query, amount, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("payment is no longer pending")
+	}
+	if journal != nil && len(journal.Lines) > 0 {
+		return postJournalEntry(ctx, tx, journal)
+	}
 	return nil
 }
 
+// the line item breakdowns of the payments, keyed by payment. payments of bills without line items
+// have none
+func (r *paymentRepositoryImpl) GetBreakdowns(paymentIDs []int) (map[int][]models.ShareLineItem, error) {
+	breakdowns := make(map[int][]models.ShareLineItem)
+	if len(paymentIDs) == 0 {
+		return breakdowns, nil
+	}
+
+	var parts []models.ShareLineItem
+	query := `SELECT pl.payment_id, pl.line_item_id, li.name, li.category, pl.amount
+			  FROM payment_line_items pl JOIN bill_line_items li ON li.id = pl.line_item_id
+			  WHERE pl.payment_id = ANY($1) ORDER BY pl.payment_id, li.id`
+	if err := r.db.Select(&parts, query, pq.Array(paymentIDs)); err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		breakdowns[part.PaymentID] = append(breakdowns[part.PaymentID], part)
+	}
+	return breakdowns, nil
+}
+
+// closes what is left of an unsettled payment as bad debt and books it in the same transaction
+func (r *paymentRepositoryImpl) WriteOffPayment(ctx context.Context, id, managerID int, note string) (journal *models.JournalEntry, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	from, err := lockPaymentStatus(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentNotPayable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var userID, apartmentID int
+	var currency money.Currency
+	var outstanding money.Amount
+	err = tx.QueryRowContext(ctx, `UPDATE payments SET payment_status = 'written_off', updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $1 AND payment_status IN ('pending', 'partially_paid')
+			  RETURNING user_id, (SELECT apartment_id FROM bills WHERE bills.id = payments.bill_id), currency, amount - amount_paid`, id).
+		Scan(&userID, &apartmentID, &currency, &outstanding)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentNotPayable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+		PaymentID:  id,
+		FromStatus: from,
+		ToStatus:   models.WrittenOff,
+		ActorID:    &managerID,
+		Reason:     note,
+	}); err != nil {
+		return nil, err
+	}
+	if err = syncBillSettlement(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	journal = models.NewJournalEntry(apartmentID, models.WriteOffEntry, fmt.Sprintf("payment:%d", id), note, currency).
+		Debit(models.BadDebt, nil, outstanding).
+		Credit(models.ResidentReceivable, &userID, outstanding)
+	if err = postJournalEntry(ctx, tx, journal); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
 func (r *paymentRepositoryImpl) DeletePayment(id int) error {
 	query := `DELETE FROM payments WHERE id = $1`
 	_, err := r.db.Exec(query, id)
 	return err
 }
+
+func (r *paymentRepositoryImpl) GetPaymentEvents(paymentID int) ([]models.PaymentEvent, error) {
+	var events []models.PaymentEvent
+	query := `SELECT id, payment_id, from_status, to_status, actor_id, reason, created_at
+			  FROM payment_events WHERE payment_id = $1 ORDER BY id`
+	if err := r.db.Select(&events, query, paymentID); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// inserts the payment with the event that starts its history, inside the caller's transaction
+func insertPayment(ctx context.Context, tx *sqlx.Tx, payment models.Payment) (int, error) {
+	query := `INSERT INTO payments (bill_id, user_id, amount, amount_paid, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
+			  ON CONFLICT (bill_id, user_id) WHERE kind = 'share' DO NOTHING
+			  RETURNING id`
+	var id int
+	err := tx.QueryRowContext(ctx, query,
+		payment.BillID,
+		payment.UserID,
+		payment.Amount,
+		payment.AmountPaid,
+		payment.Currency,
+		payment.PaidAt,
+		payment.PaymentStatus,
+		payment.SplitStrategy,
+		payment.Kind,
+		payment.ParentPaymentID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPaymentExists
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordPaymentEvent(ctx, tx, models.PaymentEvent{
+		PaymentID: id,
+		ToStatus:  payment.PaymentStatus,
+		Reason:    "created",
+	}); err != nil {
+		return 0, err
+	}
+	if err := insertBreakdown(ctx, tx, id, payment.Breakdown); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func insertBreakdown(ctx context.Context, tx *sqlx.Tx, paymentID int, breakdown []models.ShareLineItem) error {
+	for _, part := range breakdown {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO payment_line_items (payment_id, line_item_id, amount) VALUES ($1, $2, $3)`,
+			paymentID, part.LineItemID, part.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locks the payment until the transaction ends and returns its status, so the change that follows
+// is recorded with the status it came from
+func lockPaymentStatus(ctx context.Context, tx *sqlx.Tx, id int) (models.PaymentStatus, error) {
+	var status models.PaymentStatus
+	err := tx.QueryRowContext(ctx, `SELECT payment_status FROM payments WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	return status, err
+}
+
+// appends a status change to the payment's history inside the caller's transaction. staying in the
+// same status, like a second partial payment, is not a transition and isn't recorded
+func recordPaymentEvent(ctx context.Context, tx *sqlx.Tx, event models.PaymentEvent) error {
+	if event.FromStatus == event.ToStatus {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO payment_events (payment_id, from_status, to_status, actor_id, reason)
+			  VALUES ($1, $2, $3, $4, $5)`,
+		event.PaymentID, event.FromStatus, event.ToStatus, event.ActorID, event.Reason)
+	return err
+}
@@ -2,8 +2,10 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -11,8 +13,8 @@ type MockPaymentRepository struct {
 	mock.Mock
 }
 
-func (m *MockPaymentRepository) CreatePayment(ctx context.Context, payment models.Payment) (int, error) {
-	args := m.Called(ctx, payment)
+func (m *MockPaymentRepository) CreatePayment(ctx context.Context, payment models.Payment, journal *models.JournalEntry) (int, error) {
+	args := m.Called(ctx, payment, journal)
 	return args.Int(0), args.Error(1)
 }
 
@@ -56,16 +58,61 @@ func (m *MockPaymentRepository) GetPaymentsByBill(billID int) ([]models.Payment,
 	return nil, args.Error(1)
 }
 
-func (m *MockPaymentRepository) UpdatePaymentStatus(ctx context.Context, payment models.Payment) error {
-	args := m.Called(ctx, payment)
+func (m *MockPaymentRepository) GetPaymentsByParent(parentID int) ([]models.Payment, error) {
+	args := m.Called(parentID)
+	if payments, ok := args.Get(0).([]models.Payment); ok {
+		return payments, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetOverdueShares(apartmentID int, deadlineBefore time.Time) ([]models.Payment, error) {
+	args := m.Called(apartmentID, deadlineBefore)
+	if payments, ok := args.Get(0).([]models.Payment); ok {
+		return payments, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepository) UpdatePaymentStatus(ctx context.Context, payment models.Payment, actorID *int, reason string) error {
+	args := m.Called(ctx, payment, actorID, reason)
 	return args.Error(0)
 }
 
-func (m *MockPaymentRepository) UpdatePaymentsStatus(ctx context.Context, payments []models.Payment) error {
-	args := m.Called(ctx, payments)
+func (m *MockPaymentRepository) UpdatePaymentsStatus(ctx context.Context, payments []models.Payment, actorID *int, reason string) error {
+	args := m.Called(ctx, payments, actorID, reason)
 	return args.Error(0)
 }
 
+func (m *MockPaymentRepository) UpdatePendingAmount(ctx context.Context, id int, amount money.Amount, journal *models.JournalEntry) error {
+	args := m.Called(ctx, id, amount, journal)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) WriteOffPayment(ctx context.Context, id, managerID int, note string) (*models.JournalEntry, error) {
+	args := m.Called(ctx, id, managerID, note)
+	if journal, ok := args.Get(0).(*models.JournalEntry); ok {
+		return journal, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetPaymentEvents(paymentID int) ([]models.PaymentEvent, error) {
+	args := m.Called(paymentID)
+	if events, ok := args.Get(0).([]models.PaymentEvent); ok {
+		return events, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepository) GetBreakdowns(paymentIDs []int) (map[int][]models.ShareLineItem, error) {
+	args := m.Called(paymentIDs)
+	if breakdowns, ok := args.Get(0).(map[int][]models.ShareLineItem); ok {
+		return breakdowns, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockPaymentRepository) DeletePayment(id int) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -9,6 +9,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -27,6 +28,10 @@ func TestNewPaymentRepository(t *testing.T) {
 
 	t.Run("with autoCreate true", func(t *testing.T) {
 		mock.ExpectExec("CREATE TABLE IF NOT EXISTS payments").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE UNIQUE INDEX IF NOT EXISTS payments_one_penalty_per_share").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE UNIQUE INDEX IF NOT EXISTS payments_one_share_per_resident").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS payment_events").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE IF NOT EXISTS payment_line_items").WillReturnResult(sqlmock.NewResult(0, 0))
 
 		repo := NewPaymentRepository(true, db)
 		assert.NotNil(t, repo)
@@ -54,32 +59,89 @@ func TestPaymentRepository_CreatePayment(t *testing.T) {
 	payment := models.Payment{
 		BillID:        1,
 		UserID:        1,
-		Amount:        "100.50",
+		Amount:        money.Amount(10050),
 		PaidAt:        time.Now(),
 		PaymentStatus: models.Pending,
 	}
 
 	t.Run("successful creation", func(t *testing.T) {
 		expectedID := 1
+		resident := payment.UserID
+		journal := models.NewJournalEntry(7, models.PenaltyEntry, "", "late fee", money.IRR).
+			Debit(models.ResidentReceivable, &resident, payment.Amount).
+			Credit(models.PenaltyIncome, nil, payment.Amount)
+		mock.ExpectBegin()
 		mock.ExpectQuery("INSERT INTO payments").
-			WithArgs(payment.BillID, payment.UserID, payment.Amount, payment.PaidAt, payment.PaymentStatus).
+			WithArgs(payment.BillID, payment.UserID, payment.Amount, payment.AmountPaid, payment.Currency, payment.PaidAt, payment.PaymentStatus, payment.SplitStrategy, payment.Kind, payment.ParentPaymentID).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedID))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(expectedID, models.PaymentStatus(""), models.Pending, nil, "created").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// a new charge on a settled bill opens it again
+		expectBillSettlement(mock, 1, models.BillSettled, true)
+		expectJournalEntry(mock, 4, 7, models.PenaltyEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: payment.Amount},
+			models.JournalLine{Account: models.PenaltyIncome, Credit: payment.Amount})
+		mock.ExpectCommit()
 
-		id, err := repo.CreatePayment(ctx, payment)
+		id, err := repo.CreatePayment(ctx, payment, journal)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedID, id)
+		assert.Equal(t, "payment:1", journal.Reference)
 
 		err = mock.ExpectationsWereMet()
 		assert.NoError(t, err)
 	})
 
+	t.Run("adjustment of an itemized bill keeps its breakdown", func(t *testing.T) {
+		adjustment := payment
+		adjustment.Kind = models.AdjustmentKind
+		adjustment.Breakdown = []models.ShareLineItem{
+			{LineItemID: 4, Amount: 2000},
+			{LineItemID: 5, Amount: 8050},
+		}
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payments").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO payment_line_items").WithArgs(2, 4, money.Amount(2000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO payment_line_items").WithArgs(2, 5, money.Amount(8050)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectBillSettlement(mock, 1, models.BillDivided, true)
+		mock.ExpectCommit()
+
+		id, err := repo.CreatePayment(ctx, adjustment, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("share already created by a concurrent division", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payments .* ON CONFLICT \\(bill_id, user_id\\) WHERE kind = 'share' DO NOTHING").
+			WithArgs(payment.BillID, payment.UserID, payment.Amount, payment.AmountPaid, payment.Currency, payment.PaidAt, payment.PaymentStatus, payment.SplitStrategy, payment.Kind, payment.ParentPaymentID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		id, err := repo.CreatePayment(ctx, payment, nil)
+
+		assert.ErrorIs(t, err, ErrPaymentExists)
+		assert.Equal(t, 0, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("database error", func(t *testing.T) {
+		mock.ExpectBegin()
 		mock.ExpectQuery("INSERT INTO payments").
-			WithArgs(payment.BillID, payment.UserID, payment.Amount, payment.PaidAt, payment.PaymentStatus).
+			WithArgs(payment.BillID, payment.UserID, payment.Amount, payment.AmountPaid, payment.Currency, payment.PaidAt, payment.PaymentStatus, payment.SplitStrategy, payment.Kind, payment.ParentPaymentID).
 			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
 
-		id, err := repo.CreatePayment(ctx, payment)
+		id, err := repo.CreatePayment(ctx, payment, nil)
 
 		assert.Error(t, err)
 		assert.Equal(t, 0, id)
@@ -106,16 +168,16 @@ func TestPaymentRepository_GetPaymentByID(t *testing.T) {
 			},
 			BillID:        1,
 			UserID:        1,
-			Amount:        "100.50",
+			Amount:        money.Amount(10050),
 			PaidAt:        time.Now(),
 			PaymentStatus: models.Paid,
 		}
 
 		rows := sqlmock.NewRows([]string{"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at"}).
-			AddRow(expectedPayment.ID, expectedPayment.BillID, expectedPayment.UserID, expectedPayment.Amount,
+			AddRow(expectedPayment.ID, expectedPayment.BillID, expectedPayment.UserID, expectedPayment.Amount.String(),
 				expectedPayment.PaidAt, expectedPayment.PaymentStatus, expectedPayment.CreatedAt, expectedPayment.UpdatedAt)
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE id = \\$1").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE id = \\$1").
 			WithArgs(paymentID).
 			WillReturnRows(rows)
 
@@ -134,7 +196,7 @@ func TestPaymentRepository_GetPaymentByID(t *testing.T) {
 	})
 
 	t.Run("payment not found", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE id = \\$1").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE id = \\$1").
 			WithArgs(paymentID).
 			WillReturnError(sql.ErrNoRows)
 
@@ -165,16 +227,16 @@ func TestPaymentRepository_GetPaymentByBillAndUser(t *testing.T) {
 			},
 			BillID:        billID,
 			UserID:        userID,
-			Amount:        "100.50",
+			Amount:        money.Amount(10050),
 			PaidAt:        time.Now(),
 			PaymentStatus: models.Paid,
 		}
 
 		rows := sqlmock.NewRows([]string{"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at"}).
-			AddRow(expectedPayment.ID, expectedPayment.BillID, expectedPayment.UserID, expectedPayment.Amount,
+			AddRow(expectedPayment.ID, expectedPayment.BillID, expectedPayment.UserID, expectedPayment.Amount.String(),
 				expectedPayment.PaidAt, expectedPayment.PaymentStatus, expectedPayment.CreatedAt, expectedPayment.UpdatedAt)
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE bill_id = \\$1 AND user_id = \\$2").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE bill_id = \\$1 AND user_id = \\$2").
 			WithArgs(billID, userID).
 			WillReturnRows(rows)
 
@@ -202,7 +264,7 @@ func TestPaymentRepository_GetPaymentsByUser(t *testing.T) {
 			AddRow(1, 1, userID, "100.50", time.Now(), models.Paid, time.Now(), time.Now()).
 			AddRow(2, 2, userID, "200.00", time.Now(), models.Pending, time.Now(), time.Now())
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE user_id = \\$1").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE user_id = \\$1").
 			WithArgs(userID).
 			WillReturnRows(rows)
 
@@ -220,7 +282,7 @@ func TestPaymentRepository_GetPaymentsByUser(t *testing.T) {
 	t.Run("no payments found", func(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at"})
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE user_id = \\$1").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE user_id = \\$1").
 			WithArgs(userID).
 			WillReturnRows(rows)
 
@@ -240,6 +302,7 @@ func TestPaymentRepository_UpdatePaymentStatus(t *testing.T) {
 
 	repo := &paymentRepositoryImpl{db: db}
 	ctx := context.Background()
+	manager := 4
 
 	payment := models.Payment{
 		BaseModel:     models.BaseModel{ID: 1},
@@ -248,11 +311,20 @@ func TestPaymentRepository_UpdatePaymentStatus(t *testing.T) {
 	}
 
 	t.Run("successful update", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments WHERE id = \\$1 FOR UPDATE").
+			WithArgs(payment.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
 		mock.ExpectExec("UPDATE payments SET").
-			WithArgs(payment.PaymentStatus, payment.PaidAt, payment.ID).
+			WithArgs(payment.PaymentStatus, payment.PaidAt, payment.PaymentStatus, payment.ID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(payment.ID, models.Pending, models.Paid, &manager, "paid in cash").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectBillSettlement(mock, 1, models.BillDivided, true)
+		mock.ExpectCommit()
 
-		err := repo.UpdatePaymentStatus(ctx, payment)
+		err := repo.UpdatePaymentStatus(ctx, payment, &manager, "paid in cash")
 
 		assert.NoError(t, err)
 
@@ -260,12 +332,32 @@ func TestPaymentRepository_UpdatePaymentStatus(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("transition not allowed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(payment.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Refunded))
+		mock.ExpectRollback()
+
+		err := repo.UpdatePaymentStatus(ctx, payment, &manager, "paid in cash")
+
+		assert.ErrorIs(t, err, ErrInvalidPaymentTransition)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+
 	t.Run("database error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(payment.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
 		mock.ExpectExec("UPDATE payments SET").
-			WithArgs(payment.PaymentStatus, payment.PaidAt, payment.ID).
+			WithArgs(payment.PaymentStatus, payment.PaidAt, payment.PaymentStatus, payment.ID).
 			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
 
-		err := repo.UpdatePaymentStatus(ctx, payment)
+		err := repo.UpdatePaymentStatus(ctx, payment, nil, "")
 
 		assert.Error(t, err)
 		assert.Equal(t, sql.ErrConnDone, err)
@@ -299,14 +391,22 @@ func TestPaymentRepository_UpdatePaymentsStatus(t *testing.T) {
 		mock.ExpectBegin()
 
 		for _, payment := range payments {
+			mock.ExpectQuery("SELECT payment_status FROM payments").
+				WithArgs(payment.ID).
+				WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Processing))
 			mock.ExpectExec("UPDATE payments SET").
-				WithArgs(payment.PaymentStatus, payment.PaidAt, payment.ID).
+				WithArgs(payment.PaymentStatus, payment.PaidAt, payment.PaymentStatus, payment.ID).
 				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO payment_events").
+				WithArgs(payment.ID, models.Processing, models.Paid, nil, "settled").
+				WillReturnResult(sqlmock.NewResult(1, 1))
 		}
+		// the last open payments of the bill were paid
+		expectBillSettlement(mock, 1, models.BillDivided, false)
 
 		mock.ExpectCommit()
 
-		err := repo.UpdatePaymentsStatus(ctx, payments)
+		err := repo.UpdatePaymentsStatus(ctx, payments, nil, "settled")
 
 		assert.NoError(t, err)
 
@@ -314,23 +414,27 @@ func TestPaymentRepository_UpdatePaymentsStatus(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
-	t.Run("transaction rollback on error", func(t *testing.T) {
+	t.Run("invalid transition rolls back the batch", func(t *testing.T) {
 		mock.ExpectBegin()
 
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(payments[0].ID).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
 		mock.ExpectExec("UPDATE payments SET").
-			WithArgs(payments[0].PaymentStatus, payments[0].PaidAt, payments[0].ID).
+			WithArgs(payments[0].PaymentStatus, payments[0].PaidAt, payments[0].PaymentStatus, payments[0].ID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		mock.ExpectExec("UPDATE payments SET").
-			WithArgs(payments[1].PaymentStatus, payments[1].PaidAt, payments[1].ID).
-			WillReturnError(sql.ErrConnDone)
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(payments[1].ID).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.WrittenOff))
 
 		mock.ExpectRollback()
 
-		err := repo.UpdatePaymentsStatus(ctx, payments)
+		err := repo.UpdatePaymentsStatus(ctx, payments, nil, "settled")
 
-		assert.Error(t, err)
-		assert.Equal(t, sql.ErrConnDone, err)
+		assert.ErrorIs(t, err, ErrInvalidPaymentTransition)
 
 		err = mock.ExpectationsWereMet()
 		assert.NoError(t, err)
@@ -384,7 +488,7 @@ func TestPaymentRepository_GetPendingPaymentsByUser(t *testing.T) {
 			"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at",
 		}).AddRow(1, 1, userID, "50.00", time.Now(), models.Pending, time.Now(), time.Now())
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE user_id = \\$1 and payment_status = 'pending'").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at, EXISTS \\(SELECT 1 FROM installment_plans WHERE payment_id = payments.id\\) AS on_installments FROM payments WHERE user_id = \\$1 and payment_status IN \\('pending', 'partially_paid', 'processing'\\)").
 			WithArgs(userID).
 			WillReturnRows(rows)
 
@@ -403,7 +507,7 @@ func TestPaymentRepository_GetPendingPaymentsByUser(t *testing.T) {
 			"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at",
 		})
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE user_id = \\$1 and payment_status = 'pending'").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at, EXISTS \\(SELECT 1 FROM installment_plans WHERE payment_id = payments.id\\) AS on_installments FROM payments WHERE user_id = \\$1 and payment_status IN \\('pending', 'partially_paid', 'processing'\\)").
 			WithArgs(userID).
 			WillReturnRows(rows)
 
@@ -429,7 +533,7 @@ func TestPaymentRepository_GetPaymentsByBill(t *testing.T) {
 			"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at",
 		}).AddRow(1, billID, 1, "75.00", time.Now(), models.Paid, time.Now(), time.Now())
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE bill_id = \\$1").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE bill_id = \\$1").
 			WithArgs(billID).
 			WillReturnRows(rows)
 
@@ -448,7 +552,7 @@ func TestPaymentRepository_GetPaymentsByBill(t *testing.T) {
 			"id", "bill_id", "user_id", "amount", "paid_at", "payment_status", "created_at", "updated_at",
 		})
 
-		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, paid_at, payment_status, created_at, updated_at FROM payments WHERE bill_id = \\$1").
+		mock.ExpectQuery("SELECT id, bill_id, user_id, amount, amount_paid, amount_refunded, currency, paid_at, payment_status, split_strategy, kind, parent_payment_id, created_at, updated_at FROM payments WHERE bill_id = \\$1").
 			WithArgs(billID).
 			WillReturnRows(rows)
 
@@ -461,3 +565,142 @@ func TestPaymentRepository_GetPaymentsByBill(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestPaymentRepository_GetOverdueShares(t *testing.T) {
+	db, mock := setupPaymentTestDB(t)
+	defer db.Close()
+
+	repo := &paymentRepositoryImpl{db: db}
+	cutoff := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	rows := sqlmock.NewRows([]string{
+		"id", "bill_id", "user_id", "amount", "payment_status", "kind",
+	}).AddRow(4, 2, 3, "120.00", models.Pending, models.ShareKind)
+
+	mock.ExpectQuery("FROM payments p JOIN bills b ON b.id = p.bill_id WHERE b.apartment_id = \\$1 AND p.kind = 'share' AND p.payment_status IN \\('pending', 'partially_paid', 'processing'\\) AND COALESCE\\(b.billing_deadline, b.due_date\\) < \\$2").
+		WithArgs(7, cutoff).
+		WillReturnRows(rows)
+
+	payments, err := repo.GetOverdueShares(7, cutoff)
+
+	assert.NoError(t, err)
+	assert.Len(t, payments, 1)
+	assert.Equal(t, money.Amount(12000), payments[0].Amount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaymentRepository_UpdatePendingAmount(t *testing.T) {
+	resident := 2
+	journal := func() *models.JournalEntry {
+		return models.NewJournalEntry(7, models.PenaltyEntry, "payment:9", "late fee", money.IRR).
+			Debit(models.ResidentReceivable, &resident, 500).
+			Credit(models.PenaltyIncome, nil, 500)
+	}
+
+	t.Run("books the change with it", func(t *testing.T) {
+		db, mock := setupPaymentTestDB(t)
+		defer db.Close()
+		repo := &paymentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE payments SET amount = \\$1").WithArgs(money.Amount(1500), 9).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectJournalEntry(mock, 6, 7, models.PenaltyEntry,
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Debit: 500},
+			models.JournalLine{Account: models.PenaltyIncome, Credit: 500})
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.UpdatePendingAmount(context.Background(), 9, 1500, journal()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("no longer pending", func(t *testing.T) {
+		db, mock := setupPaymentTestDB(t)
+		defer db.Close()
+		repo := &paymentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE payments SET amount = \\$1").WithArgs(money.Amount(1500), 9).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.Error(t, repo.UpdatePendingAmount(context.Background(), 9, 1500, journal()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPaymentRepository_WriteOffPayment(t *testing.T) {
+	resident := 3
+	manager := 1
+
+	t.Run("books the rest as bad debt", func(t *testing.T) {
+		db, mock := setupPaymentTestDB(t)
+		defer db.Close()
+		repo := &paymentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.PartiallyPaid))
+		mock.ExpectQuery("UPDATE payments SET payment_status = 'written_off'").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "apartment_id", "currency", "outstanding"}).AddRow(3, 7, money.IRR, "80.00"))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(4, models.PartiallyPaid, models.WrittenOff, &manager, "moved out").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectBillSettlement(mock, 11, models.BillDivided, true)
+		expectJournalEntry(mock, 2, 7, models.WriteOffEntry,
+			models.JournalLine{Account: models.BadDebt, Debit: 8000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Credit: 8000})
+		mock.ExpectCommit()
+
+		entry, err := repo.WriteOffPayment(context.Background(), 4, manager, "moved out")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, entry.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("settled payment", func(t *testing.T) {
+		db, mock := setupPaymentTestDB(t)
+		defer db.Close()
+		repo := &paymentRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Paid))
+		mock.ExpectQuery("UPDATE payments SET payment_status = 'written_off'").
+			WithArgs(4).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.WriteOffPayment(context.Background(), 4, manager, "moved out")
+
+		assert.ErrorIs(t, err, ErrPaymentNotPayable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPaymentRepository_Breakdowns(t *testing.T) {
+	db, mock := setupPaymentTestDB(t)
+	defer db.Close()
+	repo := &paymentRepositoryImpl{db: db}
+
+	t.Run("groups the parts by payment", func(t *testing.T) {
+		mock.ExpectQuery("SELECT pl.payment_id, pl.line_item_id, li.name, li.category, pl.amount FROM payment_line_items pl JOIN bill_line_items li").
+			WillReturnRows(sqlmock.NewRows([]string{"payment_id", "line_item_id", "name", "category", "amount"}).
+				AddRow(21, 4, "base charge", models.BaseCharge, "10.00").
+				AddRow(21, 5, "consumption", models.ConsumptionCharge, "25.00").
+				AddRow(22, 4, "base charge", models.BaseCharge, "10.00"))
+
+		breakdowns, err := repo.GetBreakdowns([]int{21, 22, 23})
+
+		require.NoError(t, err)
+		assert.Len(t, breakdowns[21], 2)
+		assert.Equal(t, money.Amount(2500), breakdowns[21][1].Amount)
+		assert.Len(t, breakdowns[22], 1)
+		assert.Empty(t, breakdowns[23])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,378 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+const (
+	CREATE_PAYMENT_TRANSACTIONS_TABLE = `CREATE TABLE IF NOT EXISTS payment_transactions(
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		gateway VARCHAR(50) NOT NULL,
+		session_id VARCHAR(255) UNIQUE,
+		redirect_url TEXT NOT NULL DEFAULT '',
+		amount DECIMAL(12,2) NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		status VARCHAR(20) NOT NULL DEFAULT 'processing',
+		idempotency_key VARCHAR(255) NOT NULL,
+		gateway_ref VARCHAR(255) NOT NULL DEFAULT '',
+		expires_at TIMESTAMP WITH TIME ZONE,
+		wallet_id INTEGER, -- top-ups only, wallets are created after this table so there is no foreign key
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, idempotency_key)
+	);`
+
+	CREATE_TRANSACTION_ITEMS_TABLE = `CREATE TABLE IF NOT EXISTS transaction_items(
+		id SERIAL PRIMARY KEY,
+		transaction_id INTEGER NOT NULL REFERENCES payment_transactions(id) ON DELETE CASCADE,
+		payment_id INTEGER NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+		installment_id INTEGER REFERENCES installments(id) ON DELETE SET NULL,
+		amount DECIMAL(12,2) NOT NULL
+	);`
+)
+
+var (
+	ErrPaymentNotPayable       = errors.New("payment is already paid or has a checkout in progress")
+	ErrDuplicateIdempotencyKey = errors.New("a transaction with this idempotency key already exists")
+)
+
+type PaymentTransactionRepository interface {
+	CreateTransaction(ctx context.Context, transaction models.PaymentTransaction) (int, error)
+	SetCheckout(ctx context.Context, id int, sessionID, redirectURL string, expiresAt time.Time) error
+	GetTransactionByID(id int) (*models.PaymentTransaction, error)
+	GetTransactionBySession(sessionID string) (*models.PaymentTransaction, error)
+	GetTransactionByIdempotencyKey(userID int, key string) (*models.PaymentTransaction, error)
+	GetExpiredTransactions(before time.Time) ([]models.PaymentTransaction, error)
+	GetSucceededTransactionsByPayment(paymentID int) ([]models.PaymentTransaction, error)
+	CompleteTransaction(ctx context.Context, id int, gatewayRef string) (bool, error)
+	CloseTransaction(ctx context.Context, id int, status models.TransactionStatus) (bool, error)
+}
+
+type paymentTransactionRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewPaymentTransactionRepository(autoCreate bool, db *sqlx.DB) PaymentTransactionRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_PAYMENT_TRANSACTIONS_TABLE); err != nil {
+			log.Fatalf("failed to create payment_transactions table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_TRANSACTION_ITEMS_TABLE); err != nil {
+			log.Fatalf("failed to create transaction_items table: %v", err)
+		}
+	}
+	return &paymentTransactionRepositoryImpl{db: db}
+}
+
+// stores the transaction and locks its payments in the processing status, so a payment can't be
+// in two checkouts at once. a wallet top-up has no items. losing a race for the idempotency key
+// returns ErrDuplicateIdempotencyKey
+func (r *paymentTransactionRepositoryImpl) CreateTransaction(ctx context.Context, transaction models.PaymentTransaction) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO payment_transactions (user_id, gateway, amount, currency, status, idempotency_key, wallet_id)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (user_id, idempotency_key) DO NOTHING RETURNING id`,
+		transaction.UserID,
+		transaction.Gateway,
+		transaction.Amount,
+		transaction.Currency,
+		models.TransactionProcessing,
+		transaction.IdempotencyKey,
+		transaction.WalletID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDuplicateIdempotencyKey
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range byPayment(transaction.Items) {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO transaction_items (transaction_id, payment_id, installment_id, amount)
+				  VALUES ($1, $2, $3, $4)`,
+			id, item.PaymentID, item.InstallmentID, item.Amount); err != nil {
+			return 0, err
+		}
+
+		from, err := lockPaymentStatus(ctx, tx, item.PaymentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrPaymentNotPayable
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err = checkInstallmentItem(ctx, tx, item); err != nil {
+			return 0, err
+		}
+		result, err := tx.ExecContext(ctx, `UPDATE payments SET payment_status = 'processing', updated_at = CURRENT_TIMESTAMP
+				  WHERE id = $1 AND user_id = $2 AND payment_status IN ('pending', 'partially_paid')`,
+			item.PaymentID, transaction.UserID)
+		if err != nil {
+			return 0, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if rowsAffected == 0 {
+			return 0, ErrPaymentNotPayable
+		}
+		if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+			PaymentID:  item.PaymentID,
+			FromStatus: from,
+			ToStatus:   models.Processing,
+			ActorID:    &transaction.UserID,
+			Reason:     fmt.Sprintf("checkout started (transaction %d)", id),
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func (r *paymentTransactionRepositoryImpl) SetCheckout(ctx context.Context, id int, sessionID, redirectURL string, expiresAt time.Time) error {
+	query := `UPDATE payment_transactions SET session_id = $1, redirect_url = $2, expires_at = $3, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $4`
+	_, err := r.db.ExecContext(ctx, query, sessionID, redirectURL, expiresAt, id)
+	return err
+}
+
+func (r *paymentTransactionRepositoryImpl) GetTransactionByID(id int) (*models.PaymentTransaction, error) {
+	return r.getTransaction(`id = $1`, id)
+}
+
+func (r *paymentTransactionRepositoryImpl) GetTransactionBySession(sessionID string) (*models.PaymentTransaction, error) {
+	return r.getTransaction(`session_id = $1`, sessionID)
+}
+
+func (r *paymentTransactionRepositoryImpl) GetTransactionByIdempotencyKey(userID int, key string) (*models.PaymentTransaction, error) {
+	return r.getTransaction(`user_id = $1 AND idempotency_key = $2`, userID, key)
+}
+
+func (r *paymentTransactionRepositoryImpl) getTransaction(condition string, args ...interface{}) (*models.PaymentTransaction, error) {
+	var transaction models.PaymentTransaction
+	query := `SELECT id, user_id, gateway, session_id, redirect_url, amount, currency, status, idempotency_key, gateway_ref, expires_at, wallet_id, created_at, updated_at
+			  FROM payment_transactions WHERE ` + condition
+	if err := r.db.Get(&transaction, query, args...); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT id, transaction_id, payment_id, installment_id, amount FROM transaction_items WHERE transaction_id = $1 ORDER BY id`
+	if err := r.db.Select(&transaction.Items, query, transaction.ID); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// checkouts still processing after the gateway session expired, the resident never came back
+func (r *paymentTransactionRepositoryImpl) GetExpiredTransactions(before time.Time) ([]models.PaymentTransaction, error) {
+	var transactions []models.PaymentTransaction
+	query := `SELECT id, user_id, gateway, session_id, redirect_url, amount, currency, status, idempotency_key, gateway_ref, expires_at, wallet_id, created_at, updated_at
+			  FROM payment_transactions WHERE status = 'processing' AND expires_at < $1 ORDER BY id`
+	if err := r.db.Select(&transactions, query, before); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// the settled transactions that paid towards the payment, newest first. only the items of that
+// payment are loaded
+func (r *paymentTransactionRepositoryImpl) GetSucceededTransactionsByPayment(paymentID int) ([]models.PaymentTransaction, error) {
+	var transactions []models.PaymentTransaction
+	query := `SELECT id, user_id, gateway, session_id, redirect_url, amount, currency, status, idempotency_key, gateway_ref, expires_at, wallet_id, created_at, updated_at
+			  FROM payment_transactions t WHERE status = 'succeeded'
+			  AND EXISTS (SELECT 1 FROM transaction_items i WHERE i.transaction_id = t.id AND i.payment_id = $1)
+			  ORDER BY id DESC`
+	if err := r.db.Select(&transactions, query, paymentID); err != nil {
+		return nil, err
+	}
+
+	for i := range transactions {
+		query = `SELECT id, transaction_id, payment_id, installment_id, amount FROM transaction_items
+				 WHERE transaction_id = $1 AND payment_id = $2 ORDER BY id`
+		if err := r.db.Select(&transactions[i].Items, query, transactions[i].ID, paymentID); err != nil {
+			return nil, err
+		}
+	}
+	return transactions, nil
+}
+
+// books a verified gateway payment on every item, or credits the wallet of a top-up. returns false
+// when the transaction was already settled, so a repeated callback changes nothing
+func (r *paymentTransactionRepositoryImpl) CompleteTransaction(ctx context.Context, id int, gatewayRef string) (completed bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !completed {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var walletID *int
+	var amount money.Amount
+	var gateway string
+	err = tx.QueryRowContext(ctx, `UPDATE payment_transactions SET status = 'succeeded', gateway_ref = $1, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $2 AND status = 'processing' RETURNING wallet_id, amount, gateway`, gatewayRef, id).Scan(&walletID, &amount, &gateway)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if walletID != nil {
+		entry := models.WalletTransaction{
+			WalletID:             *walletID,
+			Type:                 models.WalletTopUp,
+			Amount:               amount,
+			PaymentTransactionID: &id,
+		}
+		wallet, err := addWalletTransaction(ctx, tx, &entry)
+		if err != nil {
+			return false, err
+		}
+		if err = postWalletEntry(ctx, tx, wallet, entry); err != nil {
+			return false, err
+		}
+	}
+
+	var items []models.TransactionItem
+	if err = tx.SelectContext(ctx, &items, `SELECT id, transaction_id, payment_id, installment_id, amount
+			  FROM transaction_items WHERE transaction_id = $1 ORDER BY payment_id, id`, id); err != nil {
+		return false, err
+	}
+
+	// a batch checkout can pay shares of several apartments, each gets its own journal entry
+	var journals []*models.JournalEntry
+	byApartment := make(map[int]*models.JournalEntry)
+	for _, item := range items {
+		var from, status models.PaymentStatus
+		if from, err = lockPaymentStatus(ctx, tx, item.PaymentID); err != nil {
+			return false, err
+		}
+		var userID, apartmentID int
+		var currency money.Currency
+		if err = tx.QueryRowContext(ctx, RECORD_PARTIAL_PAYMENT+RETURNING_PAYMENT_OWNER+`, payment_status`, item.Amount, item.PaymentID).
+			Scan(&userID, &apartmentID, &currency, &status); err != nil {
+			return false, err
+		}
+		if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+			PaymentID:  item.PaymentID,
+			FromStatus: from,
+			ToStatus:   status,
+			Reason:     fmt.Sprintf("paid through %s (transaction %d)", gateway, id),
+		}); err != nil {
+			return false, err
+		}
+		journal, ok := byApartment[apartmentID]
+		if !ok {
+			journal = models.NewJournalEntry(apartmentID, models.PaymentEntry, fmt.Sprintf("transaction:%d", id), "Paid through "+gateway, currency)
+			byApartment[apartmentID] = journal
+			journals = append(journals, journal)
+		}
+		journal.Debit(models.ApartmentFund, nil, item.Amount).Credit(models.ResidentReceivable, &userID, item.Amount)
+		if item.InstallmentID != nil {
+			if _, err = tx.ExecContext(ctx, `UPDATE installments SET status = 'paid', paid_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+					  WHERE id = $1 AND status = 'pending'`, *item.InstallmentID); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err = syncBillSettlement(ctx, tx, itemPayments(items)...); err != nil {
+		return false, err
+	}
+
+	for _, journal := range journals {
+		if err = postJournalEntry(ctx, tx, journal); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ends a checkout that failed or expired and releases its payments. returns false when it was already settled
+func (r *paymentTransactionRepositoryImpl) CloseTransaction(ctx context.Context, id int, status models.TransactionStatus) (closed bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !closed {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	result, err := tx.ExecContext(ctx, `UPDATE payment_transactions SET status = $1, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = $2 AND status = 'processing'`, status, id)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	var released []models.PaymentEvent
+	if err = tx.SelectContext(ctx, &released, `UPDATE payments SET
+			  payment_status = CASE WHEN amount_paid > 0 THEN 'partially_paid' ELSE 'pending' END,
+			  updated_at = CURRENT_TIMESTAMP
+			  WHERE payment_status = 'processing' AND id IN (SELECT payment_id FROM transaction_items WHERE transaction_id = $1)
+			  RETURNING id AS payment_id, payment_status AS to_status`, id); err != nil {
+		return false, err
+	}
+	for _, event := range released {
+		event.FromStatus = models.Processing
+		event.Reason = fmt.Sprintf("checkout %s (transaction %d)", status, id)
+		if err = recordPaymentEvent(ctx, tx, event); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// the items in payment order. every transaction locks its payments in this order, so two of them
+// over the same payments wait for each other instead of deadlocking
+func byPayment(items []models.TransactionItem) []models.TransactionItem {
+	sorted := make([]models.TransactionItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].PaymentID < sorted[j].PaymentID })
+	return sorted
+}
+
+func itemPayments(items []models.TransactionItem) []int {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.PaymentID
+	}
+	return ids
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPaymentTransactionRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentTransactionRepository) CreateTransaction(ctx context.Context, transaction models.PaymentTransaction) (int, error) {
+	args := m.Called(ctx, transaction)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) SetCheckout(ctx context.Context, id int, sessionID, redirectURL string, expiresAt time.Time) error {
+	args := m.Called(ctx, id, sessionID, redirectURL, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockPaymentTransactionRepository) GetTransactionByID(id int) (*models.PaymentTransaction, error) {
+	args := m.Called(id)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) GetTransactionBySession(sessionID string) (*models.PaymentTransaction, error) {
+	args := m.Called(sessionID)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) GetTransactionByIdempotencyKey(userID int, key string) (*models.PaymentTransaction, error) {
+	args := m.Called(userID, key)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) GetExpiredTransactions(before time.Time) ([]models.PaymentTransaction, error) {
+	args := m.Called(before)
+	if transactions, ok := args.Get(0).([]models.PaymentTransaction); ok {
+		return transactions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) GetSucceededTransactionsByPayment(paymentID int) ([]models.PaymentTransaction, error) {
+	args := m.Called(paymentID)
+	if transactions, ok := args.Get(0).([]models.PaymentTransaction); ok {
+		return transactions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) CompleteTransaction(ctx context.Context, id int, gatewayRef string) (bool, error) {
+	args := m.Called(ctx, id, gatewayRef)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentTransactionRepository) CloseTransaction(ctx context.Context, id int, status models.TransactionStatus) (bool, error) {
+	args := m.Called(ctx, id, status)
+	return args.Bool(0), args.Error(1)
+}
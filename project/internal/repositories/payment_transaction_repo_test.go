@@ -0,0 +1,268 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentTransactionRepository_CreateTransaction(t *testing.T) {
+	transaction := models.PaymentTransaction{
+		UserID:         2,
+		Gateway:        "simulator",
+		Amount:         7000,
+		Currency:       money.IRR,
+		IdempotencyKey: "idemp123",
+		Items:          []models.TransactionItem{{PaymentID: 5, Amount: 7000}},
+	}
+
+	t.Run("locks the payments", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WithArgs(2, "simulator", money.Amount(7000), money.IRR, models.TransactionProcessing, "idemp123", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec("INSERT INTO transaction_items").
+			WithArgs(4, 5, nil, money.Amount(7000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT payment_status FROM payments WHERE id = \\$1 FOR UPDATE").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
+		expectInstallmentPlans(mock, false)
+		mock.ExpectExec("UPDATE payments SET payment_status = 'processing'").
+			WithArgs(5, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(5, models.Pending, models.Processing, 2, "checkout started (transaction 4)").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		id, err := repo.CreateTransaction(context.Background(), transaction)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("locks the payments in id order", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		batch := transaction
+		batch.Items = []models.TransactionItem{{PaymentID: 9, Amount: 2000}, {PaymentID: 5, Amount: 5000}}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		for _, paymentID := range []int{5, 9} {
+			mock.ExpectExec("INSERT INTO transaction_items").
+				WithArgs(4, paymentID, nil, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery("SELECT payment_status FROM payments WHERE id = \\$1 FOR UPDATE").
+				WithArgs(paymentID).
+				WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
+			expectInstallmentPlans(mock, false)
+			mock.ExpectExec("UPDATE payments SET payment_status = 'processing'").
+				WithArgs(paymentID, 2).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO payment_events").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		mock.ExpectCommit()
+
+		_, err := repo.CreateTransaction(context.Background(), batch)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("idempotency key taken by a concurrent request", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions .* ON CONFLICT \\(user_id, idempotency_key\\) DO NOTHING").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.CreateTransaction(context.Background(), transaction)
+
+		assert.ErrorIs(t, err, ErrDuplicateIdempotencyKey)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("payment already in a checkout", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec("INSERT INTO transaction_items").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Processing))
+		expectInstallmentPlans(mock, false)
+		mock.ExpectExec("UPDATE payments SET payment_status = 'processing'").
+			WithArgs(5, 2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := repo.CreateTransaction(context.Background(), transaction)
+
+		assert.ErrorIs(t, err, ErrPaymentNotPayable)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("payment on an installment plan", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec("INSERT INTO transaction_items").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.PartiallyPaid))
+		expectInstallmentPlans(mock, true)
+		mock.ExpectRollback()
+
+		_, err := repo.CreateTransaction(context.Background(), transaction)
+
+		assert.ErrorIs(t, err, ErrPaymentOnInstallments)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPaymentTransactionRepository_CompleteTransaction(t *testing.T) {
+	resident := 2
+
+	t.Run("books the items", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_transactions SET status = 'succeeded'").
+			WithArgs("tx_1", 4).
+			WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "amount", "gateway"}).AddRow(nil, "50.00", "simulator"))
+		mock.ExpectQuery("FROM transaction_items WHERE transaction_id = \\$1").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "payment_id", "installment_id", "amount"}).
+				AddRow(1, 4, 5, 9, "50.00"))
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Processing))
+		mock.ExpectQuery("UPDATE payments SET").
+			WithArgs(money.Amount(5000), 5).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "apartment_id", "currency", "payment_status"}).AddRow(2, 7, money.IRR, models.Paid))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(5, models.Processing, models.Paid, nil, "paid through simulator (transaction 4)").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE installments SET status = 'paid'").
+			WithArgs(9).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectBillSettlement(mock, 11, models.BillDivided, false)
+		expectJournalEntry(mock, 1, 7, models.PaymentEntry,
+			models.JournalLine{Account: models.ApartmentFund, Debit: 5000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Credit: 5000})
+		mock.ExpectCommit()
+
+		completed, err := repo.CompleteTransaction(context.Background(), 4, "tx_1")
+
+		assert.NoError(t, err)
+		assert.True(t, completed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("credits the wallet of a top-up", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_transactions SET status = 'succeeded'").
+			WithArgs("tx_1", 4).
+			WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "amount", "gateway"}).AddRow(3, "200.00", "simulator"))
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1").
+			WithArgs(money.Amount(20000), 3).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "user_id", "apartment_id", "currency"}).AddRow("250.00", 2, 7, money.IRR))
+		mock.ExpectQuery("INSERT INTO wallet_transactions").
+			WithArgs(3, models.WalletTopUp, money.Amount(20000), money.Amount(25000), nil, sqlmock.AnyArg(), "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+		expectJournalEntry(mock, 1, 7, models.TopUpEntry,
+			models.JournalLine{Account: models.ApartmentFund, Debit: 20000},
+			models.JournalLine{Account: models.ResidentWallet, UserID: &resident, Credit: 20000})
+		mock.ExpectQuery("FROM transaction_items WHERE transaction_id = \\$1").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "payment_id", "installment_id", "amount"}))
+		mock.ExpectCommit()
+
+		completed, err := repo.CompleteTransaction(context.Background(), 4, "tx_1")
+
+		assert.NoError(t, err)
+		assert.True(t, completed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already settled", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &paymentTransactionRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE payment_transactions SET status = 'succeeded'").
+			WithArgs("tx_1", 4).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		completed, err := repo.CompleteTransaction(context.Background(), 4, "tx_1")
+
+		assert.NoError(t, err)
+		assert.False(t, completed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPaymentTransactionRepository_CloseTransaction(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &paymentTransactionRepositoryImpl{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE payment_transactions SET status = \\$1").
+		WithArgs(models.TransactionExpired, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE payments SET payment_status = CASE WHEN amount_paid > 0 THEN 'partially_paid' ELSE 'pending' END").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_id", "to_status"}).AddRow(5, models.Pending).AddRow(6, models.PartiallyPaid))
+	mock.ExpectExec("INSERT INTO payment_events").
+		WithArgs(5, models.Processing, models.Pending, nil, "checkout expired (transaction 4)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO payment_events").
+		WithArgs(6, models.Processing, models.PartiallyPaid, nil, "checkout expired (transaction 4)").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	closed, err := repo.CloseTransaction(context.Background(), 4, models.TransactionExpired)
+
+	assert.NoError(t, err)
+	assert.True(t, closed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
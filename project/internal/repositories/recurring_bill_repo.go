@@ -0,0 +1,225 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_RECURRING_BILLS_TABLE = `CREATE TABLE IF NOT EXISTS recurring_bills(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		created_by INTEGER NOT NULL REFERENCES users(id),
+		bill_type VARCHAR(50) NOT NULL,
+		amount DECIMAL(12,2) NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		description TEXT NOT NULL DEFAULT '',
+		day_of_month INTEGER NOT NULL CHECK (day_of_month BETWEEN 1 AND 28),
+		interval_months INTEGER NOT NULL DEFAULT 1 CHECK (interval_months > 0),
+		due_after_days INTEGER NOT NULL DEFAULT 0,
+		start_date DATE NOT NULL,
+		auto_divide BOOLEAN NOT NULL DEFAULT FALSE,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// one row per generated period, the unique key is what keeps a month from being billed twice
+	CREATE_RECURRING_BILL_RUNS_TABLE = `CREATE TABLE IF NOT EXISTS recurring_bill_runs(
+		id SERIAL PRIMARY KEY,
+		recurring_bill_id INTEGER NOT NULL REFERENCES recurring_bills(id) ON DELETE CASCADE,
+		period VARCHAR(7) NOT NULL,
+		bill_id INTEGER REFERENCES bills(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (recurring_bill_id, period)
+	);`
+)
+
+type RecurringBillRepository interface {
+	CreateRecurringBill(ctx context.Context, template models.RecurringBill) (int, error)
+	GetRecurringBillByID(id int) (*models.RecurringBill, error)
+	GetRecurringBillsByApartment(apartmentID int) ([]models.RecurringBill, error)
+	GetActiveRecurringBills() ([]models.RecurringBill, error)
+	UpdateRecurringBill(ctx context.Context, template models.RecurringBill) error
+	DeleteRecurringBill(id int) error
+	GetGeneratedPeriods(recurringBillID int) ([]string, error)
+	CreateBillForPeriod(ctx context.Context, recurringBillID int, period string, bill models.Bill, journal *models.JournalEntry, approval *models.BillApproval) (int, bool, error)
+}
+
+type recurringBillRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewRecurringBillRepository(autoCreate bool, db *sqlx.DB) RecurringBillRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_RECURRING_BILLS_TABLE); err != nil {
+			log.Fatalf("failed to create recurring_bills table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_RECURRING_BILL_RUNS_TABLE); err != nil {
+			log.Fatalf("failed to create recurring_bill_runs table: %v", err)
+		}
+	}
+	return &recurringBillRepositoryImpl{db: db}
+}
+
+const recurringBillColumns = `id, apartment_id, created_by, bill_type, amount, currency, description, day_of_month,
+	interval_months, due_after_days, start_date, auto_divide, active, created_at, updated_at`
+
+func (r *recurringBillRepositoryImpl) CreateRecurringBill(ctx context.Context, template models.RecurringBill) (int, error) {
+	query := `INSERT INTO recurring_bills (apartment_id, created_by, bill_type, amount, currency, description,
+				day_of_month, interval_months, due_after_days, start_date, auto_divide, active)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		template.ApartmentID,
+		template.CreatedBy,
+		template.BillType,
+		template.Amount,
+		template.Currency,
+		template.Description,
+		template.DayOfMonth,
+		template.IntervalMonths,
+		template.DueAfterDays,
+		template.StartDate,
+		template.AutoDivide,
+		template.Active).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *recurringBillRepositoryImpl) GetRecurringBillByID(id int) (*models.RecurringBill, error) {
+	var template models.RecurringBill
+	query := `SELECT ` + recurringBillColumns + ` FROM recurring_bills WHERE id = $1`
+	err := r.db.Get(&template, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *recurringBillRepositoryImpl) GetRecurringBillsByApartment(apartmentID int) ([]models.RecurringBill, error) {
+	var templates []models.RecurringBill
+	query := `SELECT ` + recurringBillColumns + ` FROM recurring_bills WHERE apartment_id = $1 ORDER BY id`
+	err := r.db.Select(&templates, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *recurringBillRepositoryImpl) GetActiveRecurringBills() ([]models.RecurringBill, error) {
+	var templates []models.RecurringBill
+	query := `SELECT ` + recurringBillColumns + ` FROM recurring_bills WHERE active = TRUE ORDER BY id`
+	err := r.db.Select(&templates, query)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *recurringBillRepositoryImpl) UpdateRecurringBill(ctx context.Context, template models.RecurringBill) error {
+	query := `UPDATE recurring_bills SET
+			  bill_type = :bill_type,
+			  amount = :amount,
+			  currency = :currency,
+			  description = :description,
+			  day_of_month = :day_of_month,
+			  interval_months = :interval_months,
+			  due_after_days = :due_after_days,
+			  start_date = :start_date,
+			  auto_divide = :auto_divide,
+			  active = :active,
+			  updated_at = CURRENT_TIMESTAMP
+			  WHERE id = :id`
+	_, err := r.db.NamedExecContext(ctx, query, template)
+	return err
+}
+
+func (r *recurringBillRepositoryImpl) DeleteRecurringBill(id int) error {
+	query := `DELETE FROM recurring_bills WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *recurringBillRepositoryImpl) GetGeneratedPeriods(recurringBillID int) ([]string, error) {
+	var periods []string
+	query := `SELECT period FROM recurring_bill_runs WHERE recurring_bill_id = $1 ORDER BY period`
+	err := r.db.Select(&periods, query, recurringBillID)
+	if err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+// claims the period, creates its bill and books it in one transaction. the journal's reference is
+// filled in with the new bill, and a bill held for approval gets the request stored with it.
+// created is false when the period was already generated, by an earlier run or another instance
+func (r *recurringBillRepositoryImpl) CreateBillForPeriod(ctx context.Context, recurringBillID int, period string, bill models.Bill, journal *models.JournalEntry, approval *models.BillApproval) (billID int, created bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		if err != nil || !created {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var runID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO recurring_bill_runs (recurring_bill_id, period)
+			  VALUES ($1, $2) ON CONFLICT (recurring_bill_id, period) DO NOTHING RETURNING id`,
+		recurringBillID, period).Scan(&runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO bills (apartment_id, bill_type, total_amount, currency, due_date, billing_deadline, period_start, period_end, description, image_url, status, auto_divide)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		bill.ApartmentID,
+		bill.BillType,
+		bill.TotalAmount,
+		bill.Currency,
+		bill.DueDate,
+		bill.BillingDeadline,
+		bill.PeriodStart,
+		bill.PeriodEnd,
+		bill.Description,
+		bill.ImageURL,
+		bill.Status,
+		bill.AutoDivide).Scan(&billID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE recurring_bill_runs SET bill_id = $1 WHERE id = $2`, billID, runID); err != nil {
+		return 0, false, err
+	}
+
+	if journal != nil && len(journal.Lines) > 0 {
+		journal.Reference = fmt.Sprintf("bill:%d", billID)
+		if err = postJournalEntry(ctx, tx, journal); err != nil {
+			return 0, false, err
+		}
+	}
+	if approval != nil {
+		approval.BillID = billID
+		if approval.ID, err = insertBillApproval(ctx, tx, *approval); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return billID, true, nil
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRecurringBillRepository struct {
+	mock.Mock
+}
+
+func (m *MockRecurringBillRepository) CreateRecurringBill(ctx context.Context, template models.RecurringBill) (int, error) {
+	args := m.Called(ctx, template)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRecurringBillRepository) GetRecurringBillByID(id int) (*models.RecurringBill, error) {
+	args := m.Called(id)
+	if template, ok := args.Get(0).(*models.RecurringBill); ok {
+		return template, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRecurringBillRepository) GetRecurringBillsByApartment(apartmentID int) ([]models.RecurringBill, error) {
+	args := m.Called(apartmentID)
+	if templates, ok := args.Get(0).([]models.RecurringBill); ok {
+		return templates, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRecurringBillRepository) GetActiveRecurringBills() ([]models.RecurringBill, error) {
+	args := m.Called()
+	if templates, ok := args.Get(0).([]models.RecurringBill); ok {
+		return templates, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRecurringBillRepository) UpdateRecurringBill(ctx context.Context, template models.RecurringBill) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *MockRecurringBillRepository) DeleteRecurringBill(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRecurringBillRepository) GetGeneratedPeriods(recurringBillID int) ([]string, error) {
+	args := m.Called(recurringBillID)
+	if periods, ok := args.Get(0).([]string); ok {
+		return periods, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRecurringBillRepository) CreateBillForPeriod(ctx context.Context, recurringBillID int, period string, bill models.Bill, journal *models.JournalEntry, approval *models.BillApproval) (int, bool, error) {
+	args := m.Called(ctx, recurringBillID, period, bill, journal, approval)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecurringBillRepository_CreateBillForPeriod(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &recurringBillRepositoryImpl{db: db}
+	bill := models.Bill{
+		ApartmentID:     7,
+		BillType:        models.MaintenanceBill,
+		TotalAmount:     money.Amount(150000),
+		Currency:        money.IRR,
+		DueDate:         "2025-03-11",
+		BillingDeadline: "2025-03-11",
+		Status:          models.BillPublished,
+		Description:     "janitor (2025-03)",
+	}
+
+	journal := func() *models.JournalEntry {
+		return models.NewJournalEntry(7, models.BillEntry, "bill:0", "maintenance bill", money.IRR).
+			Debit(models.BillsToDivide, nil, 150000).
+			Credit(models.UtilityPayable, nil, 150000)
+	}
+
+	t.Run("claims the period, creates the bill and books it", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO recurring_bill_runs").
+			WithArgs(5, "2025-03").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectQuery("INSERT INTO bills").
+			WithArgs(bill.ApartmentID, bill.BillType, bill.TotalAmount, bill.Currency, bill.DueDate, bill.BillingDeadline, bill.PeriodStart, bill.PeriodEnd, bill.Description, bill.ImageURL, bill.Status, bill.AutoDivide).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+		mock.ExpectExec("UPDATE recurring_bill_runs SET bill_id").
+			WithArgs(40, 9).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectJournalEntry(mock, 3, 7, models.BillEntry,
+			models.JournalLine{Account: models.BillsToDivide, Debit: 150000},
+			models.JournalLine{Account: models.UtilityPayable, Credit: 150000})
+		mock.ExpectCommit()
+
+		entry := journal()
+		billID, created, err := repo.CreateBillForPeriod(context.Background(), 5, "2025-03", bill, entry, nil)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, 40, billID)
+		assert.Equal(t, "bill:40", entry.Reference)
+	})
+
+	t.Run("held bill is stored with its approval request", func(t *testing.T) {
+		held := bill
+		held.Status = models.BillPendingApproval
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO recurring_bill_runs").
+			WithArgs(5, "2025-04").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectQuery("INSERT INTO bills").
+			WithArgs(held.ApartmentID, held.BillType, held.TotalAmount, held.Currency, held.DueDate, held.BillingDeadline, held.PeriodStart, held.PeriodEnd, held.Description, held.ImageURL, models.BillPendingApproval, held.AutoDivide).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(41))
+		mock.ExpectExec("UPDATE recurring_bill_runs SET bill_id").
+			WithArgs(41, 10).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectJournalEntry(mock, 4, 7, models.BillEntry,
+			models.JournalLine{Account: models.BillsToDivide, Debit: 150000},
+			models.JournalLine{Account: models.UtilityPayable, Credit: 150000})
+		mock.ExpectQuery("INSERT INTO bill_approvals").
+			WithArgs(41, 1, models.ApprovalRequested, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectCommit()
+
+		approval := &models.BillApproval{UserID: 1, Decision: models.ApprovalRequested}
+		billID, created, err := repo.CreateBillForPeriod(context.Background(), 5, "2025-04", held, journal(), approval)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, 41, billID)
+		assert.Equal(t, 41, approval.BillID)
+	})
+
+	t.Run("failed posting rolls the period back", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO recurring_bill_runs").
+			WithArgs(5, "2025-05").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectQuery("INSERT INTO bills").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+		mock.ExpectExec("UPDATE recurring_bill_runs SET bill_id").
+			WithArgs(42, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO journal_entries").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, created, err := repo.CreateBillForPeriod(context.Background(), 5, "2025-05", bill, journal(), nil)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.False(t, created)
+	})
+
+	t.Run("period already generated", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO recurring_bill_runs").
+			WithArgs(5, "2025-03").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		billID, created, err := repo.CreateBillForPeriod(context.Background(), 5, "2025-03", bill, journal(), nil)
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, 0, billID)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
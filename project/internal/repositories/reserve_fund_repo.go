@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+const (
+	CREATE_CAPITAL_EXPENSES_TABLE = `CREATE TABLE IF NOT EXISTS capital_expenses(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		description TEXT NOT NULL,
+		receipt_key VARCHAR(255) NOT NULL,
+		spent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+	// contributions are what residents actually paid of reserve fund bills, less refunds. penalties on
+	// those bills are income like any other penalty and stay out of the fund
+	RESERVE_CONTRIBUTIONS = `SELECT 'contribution' AS type, p.amount_paid - p.amount_refunded AS amount, p.currency,
+			  p.bill_id, p.user_id, NULL::INTEGER AS expense_id, b.description, '' AS receipt_key,
+			  COALESCE(p.paid_at, p.updated_at) AS occurred_at
+			  FROM payments p JOIN bills b ON b.id = p.bill_id
+			  WHERE b.apartment_id = $1 AND b.bill_type = 'reserve_fund' AND p.kind <> 'penalty'
+			  AND p.amount_paid - p.amount_refunded > 0`
+	RESERVE_EXPENSES = `SELECT 'expense' AS type, amount, currency, NULL::INTEGER AS bill_id, NULL::INTEGER AS user_id,
+			  id AS expense_id, description, receipt_key, spent_at AS occurred_at
+			  FROM capital_expenses WHERE apartment_id = $1`
+	RESERVE_BALANCES = `SELECT currency,
+			  SUM(CASE WHEN type = 'contribution' THEN amount ELSE 0 END) AS contributions,
+			  SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END) AS expenses,
+			  SUM(CASE WHEN type = 'contribution' THEN amount ELSE -amount END) AS balance
+			  FROM (` + RESERVE_CONTRIBUTIONS + ` UNION ALL ` + RESERVE_EXPENSES + `) t
+			  GROUP BY currency ORDER BY currency`
+)
+
+var ErrInsufficientReserve = errors.New("the reserve fund doesn't have enough for this expense")
+
+type ReserveFundRepository interface {
+	RecordExpense(ctx context.Context, expense models.CapitalExpense, journal *models.JournalEntry) (int, error)
+	GetBalances(apartmentID int) ([]models.ReserveBalance, error)
+	GetTransactions(apartmentID int) ([]models.ReserveTransaction, error)
+}
+
+type reserveFundRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewReserveFundRepository(autoCreate bool, db *sqlx.DB) ReserveFundRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_CAPITAL_EXPENSES_TABLE); err != nil {
+			log.Fatalf("failed to create capital_expenses table: %v", err)
+		}
+	}
+	return &reserveFundRepositoryImpl{db: db}
+}
+
+// stores the expense if the fund can cover it and books it. the apartment row is locked so two expenses
+// recorded at once can't both spend the same balance. the journal's reference is set to the new expense
+func (r *reserveFundRepositoryImpl) RecordExpense(ctx context.Context, expense models.CapitalExpense, journal *models.JournalEntry) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT id FROM apartments WHERE id = $1 FOR UPDATE`, expense.ApartmentID); err != nil {
+		return 0, err
+	}
+
+	var balances []models.ReserveBalance
+	if err = tx.SelectContext(ctx, &balances, RESERVE_BALANCES, expense.ApartmentID); err != nil {
+		return 0, err
+	}
+	var available money.Amount
+	for _, balance := range balances {
+		if balance.Currency == expense.Currency {
+			available = balance.Balance
+		}
+	}
+	if expense.Amount > available {
+		return 0, fmt.Errorf("%w: %s %s available", ErrInsufficientReserve, available, expense.Currency)
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO capital_expenses (apartment_id, amount, currency, description, receipt_key, spent_at, created_by)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		expense.ApartmentID,
+		expense.Amount,
+		expense.Currency,
+		expense.Description,
+		expense.ReceiptKey,
+		expense.SpentAt,
+		expense.CreatedBy).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if journal != nil && len(journal.Lines) > 0 {
+		journal.Reference = fmt.Sprintf("expense:%d", id)
+		if err = postJournalEntry(ctx, tx, journal); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func (r *reserveFundRepositoryImpl) GetBalances(apartmentID int) ([]models.ReserveBalance, error) {
+	var balances []models.ReserveBalance
+	if err := r.db.Select(&balances, RESERVE_BALANCES, apartmentID); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// the newest first
+func (r *reserveFundRepositoryImpl) GetTransactions(apartmentID int) ([]models.ReserveTransaction, error) {
+	var transactions []models.ReserveTransaction
+	query := RESERVE_CONTRIBUTIONS + ` UNION ALL ` + RESERVE_EXPENSES + ` ORDER BY occurred_at DESC`
+	if err := r.db.Select(&transactions, query, apartmentID); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReserveFundRepository struct {
+	mock.Mock
+}
+
+func (m *MockReserveFundRepository) RecordExpense(ctx context.Context, expense models.CapitalExpense, journal *models.JournalEntry) (int, error) {
+	args := m.Called(ctx, expense, journal)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockReserveFundRepository) GetBalances(apartmentID int) ([]models.ReserveBalance, error) {
+	args := m.Called(apartmentID)
+	if balances, ok := args.Get(0).([]models.ReserveBalance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockReserveFundRepository) GetTransactions(apartmentID int) ([]models.ReserveTransaction, error) {
+	args := m.Called(apartmentID)
+	if transactions, ok := args.Get(0).([]models.ReserveTransaction); ok {
+		return transactions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
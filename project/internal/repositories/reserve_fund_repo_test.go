@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveFundRepository_RecordExpense(t *testing.T) {
+	spentAt := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	expense := models.CapitalExpense{
+		ApartmentID: 7,
+		Amount:      money.Amount(200000),
+		Currency:    money.IRR,
+		Description: "roof repair",
+		ReceiptKey:  "bills/1_roof.pdf",
+		SpentAt:     spentAt,
+		CreatedBy:   1,
+	}
+	balanceColumns := []string{"currency", "contributions", "expenses", "balance"}
+
+	t.Run("covered by the fund", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &reserveFundRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT id FROM apartments WHERE id = \\$1 FOR UPDATE").
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT currency, (.+) FROM \\(SELECT 'contribution' AS type").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(balanceColumns).AddRow(money.IRR, "5000.00", "1000.00", "4000.00"))
+		mock.ExpectQuery("INSERT INTO capital_expenses").
+			WithArgs(7, money.Amount(200000), money.IRR, "roof repair", "bills/1_roof.pdf", spentAt, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		expectJournalEntry(mock, 8, 7, models.CapitalExpenseEntry,
+			models.JournalLine{Account: models.ReserveFund, Debit: 200000},
+			models.JournalLine{Account: models.ApartmentFund, Credit: 200000})
+		mock.ExpectCommit()
+
+		journal := models.NewJournalEntry(7, models.CapitalExpenseEntry, "", "roof repair", money.IRR).
+			Debit(models.ReserveFund, nil, 200000).
+			Credit(models.ApartmentFund, nil, 200000)
+		id, err := repo.RecordExpense(context.Background(), expense, journal)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, id)
+		assert.Equal(t, "expense:3", journal.Reference)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("more than the balance", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &reserveFundRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT id FROM apartments WHERE id = \\$1 FOR UPDATE").
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT currency, (.+) FROM \\(SELECT 'contribution' AS type").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(balanceColumns).AddRow(money.IRR, "1500.00", "0.00", "1500.00"))
+		mock.ExpectRollback()
+
+		_, err := repo.RecordExpense(context.Background(), expense, nil)
+
+		assert.ErrorIs(t, err, ErrInsufficientReserve)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing collected in the currency", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &reserveFundRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT id FROM apartments WHERE id = \\$1 FOR UPDATE").
+			WithArgs(7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT currency, (.+) FROM \\(SELECT 'contribution' AS type").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(balanceColumns).AddRow(money.USD, "5000.00", "0.00", "5000.00"))
+		mock.ExpectRollback()
+
+		_, err := repo.RecordExpense(context.Background(), expense, nil)
+
+		assert.ErrorIs(t, err, ErrInsufficientReserve)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestReserveFundRepository_GetTransactions(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &reserveFundRepositoryImpl{db: db}
+
+	occurredAt := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"type", "amount", "currency", "bill_id", "user_id", "expense_id", "description", "receipt_key", "occurred_at"}).
+		AddRow(models.ReserveExpense, "2000.00", money.IRR, nil, nil, 3, "roof repair", "bills/1_roof.pdf", occurredAt).
+		AddRow(models.ReserveContribution, "500.00", money.IRR, 11, 2, nil, "March reserve", "", occurredAt)
+	mock.ExpectQuery("FROM payments p JOIN bills b (.+) b.bill_type = 'reserve_fund' (.+) UNION ALL (.+) FROM capital_expenses (.+) ORDER BY occurred_at DESC").
+		WithArgs(7).
+		WillReturnRows(rows)
+
+	transactions, err := repo.GetTransactions(7)
+
+	assert.NoError(t, err)
+	if assert.Len(t, transactions, 2) {
+		assert.Equal(t, 3, *transactions[0].ExpenseID)
+		assert.Nil(t, transactions[0].BillID)
+		assert.Equal(t, money.Amount(50000), transactions[1].Amount)
+		assert.Equal(t, 2, *transactions[1].UserID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
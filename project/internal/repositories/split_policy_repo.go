@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_SPLIT_POLICIES_TABLE = `CREATE TABLE IF NOT EXISTS split_policies(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		bill_type VARCHAR(50) NOT NULL DEFAULT '',
+		strategy VARCHAR(20) NOT NULL,
+		equal_weight DECIMAL(6,2) NOT NULL DEFAULT 0,
+		area_weight DECIMAL(6,2) NOT NULL DEFAULT 0,
+		occupants_weight DECIMAL(6,2) NOT NULL DEFAULT 0,
+		percentage_weight DECIMAL(6,2) NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (apartment_id, bill_type)
+	);`
+)
+
+type SplitPolicyRepository interface {
+	UpsertSplitPolicy(ctx context.Context, policy models.SplitPolicy) (int, error)
+	GetSplitPolicy(apartmentID int, billType models.BillType) (*models.SplitPolicy, error)
+	GetSplitPoliciesByApartment(apartmentID int) ([]models.SplitPolicy, error)
+	DeleteSplitPolicy(apartmentID int, billType models.BillType) error
+}
+
+type splitPolicyRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewSplitPolicyRepository(autoCreate bool, db *sqlx.DB) SplitPolicyRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_SPLIT_POLICIES_TABLE); err != nil {
+			log.Fatalf("failed to create split_policies table: %v", err)
+		}
+	}
+	return &splitPolicyRepositoryImpl{db: db}
+}
+
+func (r *splitPolicyRepositoryImpl) UpsertSplitPolicy(ctx context.Context, policy models.SplitPolicy) (int, error) {
+	query := `INSERT INTO split_policies (apartment_id, bill_type, strategy, equal_weight, area_weight, occupants_weight, percentage_weight)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  ON CONFLICT (apartment_id, bill_type) DO UPDATE SET
+			  strategy = EXCLUDED.strategy,
+			  equal_weight = EXCLUDED.equal_weight,
+			  area_weight = EXCLUDED.area_weight,
+			  occupants_weight = EXCLUDED.occupants_weight,
+			  percentage_weight = EXCLUDED.percentage_weight,
+			  updated_at = CURRENT_TIMESTAMP
+			  RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		policy.ApartmentID,
+		policy.BillType,
+		policy.Strategy,
+		policy.EqualWeight,
+		policy.AreaWeight,
+		policy.OccupantsWeight,
+		policy.PercentageWeight).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// returns the policy for the bill type, falling back to the apartment-wide default (empty bill type)
+func (r *splitPolicyRepositoryImpl) GetSplitPolicy(apartmentID int, billType models.BillType) (*models.SplitPolicy, error) {
+	var policy models.SplitPolicy
+	query := `SELECT id, apartment_id, bill_type, strategy, equal_weight, area_weight, occupants_weight, percentage_weight, created_at, updated_at
+			  FROM split_policies WHERE apartment_id = $1 AND bill_type IN ($2, '')
+			  ORDER BY bill_type = '' ASC LIMIT 1`
+	err := r.db.Get(&policy, query, apartmentID, billType)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *splitPolicyRepositoryImpl) GetSplitPoliciesByApartment(apartmentID int) ([]models.SplitPolicy, error) {
+	var policies []models.SplitPolicy
+	query := `SELECT id, apartment_id, bill_type, strategy, equal_weight, area_weight, occupants_weight, percentage_weight, created_at, updated_at
+			  FROM split_policies WHERE apartment_id = $1 ORDER BY bill_type ASC`
+	err := r.db.Select(&policies, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *splitPolicyRepositoryImpl) DeleteSplitPolicy(apartmentID int, billType models.BillType) error {
+	query := `DELETE FROM split_policies WHERE apartment_id = $1 AND bill_type = $2`
+	_, err := r.db.Exec(query, apartmentID, billType)
+	return err
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSplitPolicyRepository struct {
+	mock.Mock
+}
+
+func (m *MockSplitPolicyRepository) UpsertSplitPolicy(ctx context.Context, policy models.SplitPolicy) (int, error) {
+	args := m.Called(ctx, policy)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSplitPolicyRepository) GetSplitPolicy(apartmentID int, billType models.BillType) (*models.SplitPolicy, error) {
+	args := m.Called(apartmentID, billType)
+	if policy, ok := args.Get(0).(*models.SplitPolicy); ok {
+		return policy, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSplitPolicyRepository) GetSplitPoliciesByApartment(apartmentID int) ([]models.SplitPolicy, error) {
+	args := m.Called(apartmentID)
+	if policies, ok := args.Get(0).([]models.SplitPolicy); ok {
+		return policies, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSplitPolicyRepository) DeleteSplitPolicy(apartmentID int, billType models.BillType) error {
+	args := m.Called(apartmentID, billType)
+	return args.Error(0)
+}
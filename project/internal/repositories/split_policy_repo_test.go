@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPolicyRepository_UpsertSplitPolicy(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &splitPolicyRepositoryImpl{db: db}
+	policy := models.SplitPolicy{
+		ApartmentID:     1,
+		BillType:        models.WaterBill,
+		Strategy:        models.SplitMixed,
+		EqualWeight:     30,
+		OccupantsWeight: 70,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO split_policies").
+			WithArgs(policy.ApartmentID, policy.BillType, policy.Strategy, policy.EqualWeight,
+				policy.AreaWeight, policy.OccupantsWeight, policy.PercentageWeight).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+		id, err := repo.UpsertSplitPolicy(context.Background(), policy)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, id)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO split_policies").
+			WillReturnError(sql.ErrConnDone)
+
+		id, err := repo.UpsertSplitPolicy(context.Background(), policy)
+		assert.Error(t, err)
+		assert.Equal(t, 0, id)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSplitPolicyRepository_GetSplitPolicy(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := &splitPolicyRepositoryImpl{db: db}
+	now := time.Now()
+	columns := []string{"id", "apartment_id", "bill_type", "strategy", "equal_weight", "area_weight",
+		"occupants_weight", "percentage_weight", "created_at", "updated_at"}
+
+	t.Run("falls back to apartment default", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM split_policies WHERE apartment_id = \$1 AND bill_type IN \(\$2, ''\)`).
+			WithArgs(1, models.GasBill).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 1, "", "area", 0, 0, 0, 0, now, now))
+
+		policy, err := repo.GetSplitPolicy(1, models.GasBill)
+		assert.NoError(t, err)
+		assert.Equal(t, models.SplitByArea, policy.Strategy)
+		assert.Equal(t, models.BillType(""), policy.BillType)
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM split_policies`).
+			WithArgs(1, models.GasBill).
+			WillReturnError(sql.ErrNoRows)
+
+		policy, err := repo.GetSplitPolicy(1, models.GasBill)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, policy)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+const (
+	CREATE_STATEMENT_DOCUMENTS_TABLE = `CREATE TABLE IF NOT EXISTS statement_documents(
+		id SERIAL PRIMARY KEY,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		period_from DATE NOT NULL,
+		period_to DATE NOT NULL,
+		document_key VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+)
+
+type StatementRepository interface {
+	CreateStatementDocument(ctx context.Context, document models.StatementDocument) (int, error)
+	GetStatementDocument(id int) (*models.StatementDocument, error)
+	GetStatementDocumentsByUser(userID int) ([]models.StatementDocument, error)
+}
+
+type statementRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewStatementRepository(autoCreate bool, db *sqlx.DB) StatementRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_STATEMENT_DOCUMENTS_TABLE); err != nil {
+			log.Fatalf("failed to create statement_documents table: %v", err)
+		}
+	}
+	return &statementRepositoryImpl{db: db}
+}
+
+func (r *statementRepositoryImpl) CreateStatementDocument(ctx context.Context, document models.StatementDocument) (int, error) {
+	query := `INSERT INTO statement_documents (apartment_id, user_id, period_from, period_to, document_key)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		document.ApartmentID,
+		document.UserID,
+		document.PeriodFrom,
+		document.PeriodTo,
+		document.DocumentKey).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *statementRepositoryImpl) GetStatementDocument(id int) (*models.StatementDocument, error) {
+	var document models.StatementDocument
+	query := `SELECT id, apartment_id, user_id, period_from, period_to, document_key, created_at, updated_at
+			  FROM statement_documents WHERE id = $1`
+	if err := r.db.Get(&document, query, id); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// the newest first
+func (r *statementRepositoryImpl) GetStatementDocumentsByUser(userID int) ([]models.StatementDocument, error) {
+	var documents []models.StatementDocument
+	query := `SELECT id, apartment_id, user_id, period_from, period_to, document_key, created_at, updated_at
+			  FROM statement_documents WHERE user_id = $1 ORDER BY id DESC`
+	if err := r.db.Select(&documents, query, userID); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
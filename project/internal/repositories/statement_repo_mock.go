@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStatementRepository struct {
+	mock.Mock
+}
+
+func (m *MockStatementRepository) CreateStatementDocument(ctx context.Context, document models.StatementDocument) (int, error) {
+	args := m.Called(ctx, document)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStatementRepository) GetStatementDocument(id int) (*models.StatementDocument, error) {
+	args := m.Called(id)
+	if document, ok := args.Get(0).(*models.StatementDocument); ok {
+		return document, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementRepository) GetStatementDocumentsByUser(userID int) ([]models.StatementDocument, error) {
+	args := m.Called(userID)
+	if documents, ok := args.Get(0).([]models.StatementDocument); ok {
+		return documents, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var statementDocumentColumns = []string{"id", "apartment_id", "user_id", "period_from", "period_to", "document_key", "created_at", "updated_at"}
+
+func TestStatementRepository_CreateStatementDocument(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statementRepositoryImpl{db: db}
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	document := models.StatementDocument{ApartmentID: 7, UserID: 2, PeriodFrom: from, PeriodTo: to, DocumentKey: "bills/1_statement.pdf"}
+
+	mock.ExpectQuery("INSERT INTO statement_documents").
+		WithArgs(7, 2, from, to, "bills/1_statement.pdf").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	id, err := repo.CreateStatementDocument(context.Background(), document)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatementRepository_GetStatementDocument(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statementRepositoryImpl{db: db}
+	now := time.Now()
+
+	t.Run("found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM statement_documents WHERE id = \\$1").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows(statementDocumentColumns).AddRow(4, 7, 2, now, now, "bills/1_statement.pdf", now, now))
+
+		document, err := repo.GetStatementDocument(4)
+		assert.NoError(t, err)
+		assert.Equal(t, "bills/1_statement.pdf", document.DocumentKey)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM statement_documents WHERE id = \\$1").
+			WithArgs(5).
+			WillReturnError(sql.ErrNoRows)
+
+		document, err := repo.GetStatementDocument(5)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, document)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatementRepository_GetStatementDocumentsByUser(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statementRepositoryImpl{db: db}
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM statement_documents WHERE user_id = \\$1 ORDER BY id DESC").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(statementDocumentColumns).
+			AddRow(5, 7, 2, now, now, "bills/2_statement.pdf", now, now).
+			AddRow(4, 7, 2, now, now, "bills/1_statement.pdf", now, now))
+
+	documents, err := repo.GetStatementDocumentsByUser(2)
+
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+// bills residents were charged for, drafts and cancelled bills don't count
+const statsBillStatuses = `('published', 'divided', 'settled')`
+
+// aggregates over bills and payments for the manager dashboard, everything is summed in the database
+type StatsRepository interface {
+	GetCollectionSummary(apartmentID int, from, today time.Time) ([]models.CollectionSummary, error)
+	GetBilledVsCollected(apartmentID int, from time.Time) ([]models.MonthlyTotals, error)
+	GetDaysToPay(apartmentID int, from time.Time) ([]models.DaysToPayBucket, error)
+	GetCategoryTotals(apartmentID int, from time.Time) ([]models.CategoryTotals, error)
+	GetTopDebtors(apartmentID int, today time.Time, limit int) ([]models.Debtor, error)
+}
+
+type statsRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewStatsRepository(db *sqlx.DB) StatsRepository {
+	return &statsRepositoryImpl{db: db}
+}
+
+func (r *statsRepositoryImpl) GetCollectionSummary(apartmentID int, from, today time.Time) ([]models.CollectionSummary, error) {
+	var summaries []models.CollectionSummary
+	query := `SELECT p.currency,
+			  SUM(CASE WHEN COALESCE(b.period_start, b.due_date) >= $2 THEN p.amount ELSE 0 END) AS charged,
+			  SUM(CASE WHEN COALESCE(b.period_start, b.due_date) >= $2 THEN p.amount_paid - p.amount_refunded ELSE 0 END) AS collected,
+			  SUM(CASE WHEN p.payment_status IN ('pending', 'partially_paid', 'processing') THEN p.amount - p.amount_paid ELSE 0 END) AS outstanding,
+			  SUM(CASE WHEN p.payment_status IN ('pending', 'partially_paid', 'processing') AND COALESCE(b.billing_deadline, b.due_date) < $3
+			  THEN p.amount - p.amount_paid ELSE 0 END) AS overdue,
+			  COUNT(CASE WHEN p.payment_status IN ('pending', 'partially_paid', 'processing') AND COALESCE(b.billing_deadline, b.due_date) < $3
+			  THEN 1 END) AS overdue_payments
+			  FROM payments p JOIN bills b ON b.id = p.bill_id
+			  WHERE b.apartment_id = $1 AND p.kind <> 'penalty' AND p.payment_status <> 'cancelled'
+			  GROUP BY p.currency ORDER BY p.currency`
+	if err := r.db.Select(&summaries, query, apartmentID, from, today); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// one row per month and currency that had anything billed or collected
+func (r *statsRepositoryImpl) GetBilledVsCollected(apartmentID int, from time.Time) ([]models.MonthlyTotals, error) {
+	var totals []models.MonthlyTotals
+	query := `WITH billed AS (
+				SELECT date_trunc('month', COALESCE(period_start, due_date))::DATE AS month, currency, SUM(total_amount) AS amount
+				FROM bills
+				WHERE apartment_id = $1 AND status IN ` + statsBillStatuses + ` AND COALESCE(period_start, due_date) >= $2
+				GROUP BY 1, 2
+			  ), collected AS (
+				SELECT date_trunc('month', COALESCE(p.paid_at, p.updated_at))::DATE AS month, p.currency, SUM(p.amount_paid - p.amount_refunded) AS amount
+				FROM payments p JOIN bills b ON b.id = p.bill_id
+				WHERE b.apartment_id = $1 AND p.kind <> 'penalty' AND p.amount_paid > 0 AND COALESCE(p.paid_at, p.updated_at) >= $2
+				GROUP BY 1, 2
+			  )
+			  SELECT COALESCE(b.month, c.month) AS month, COALESCE(b.currency, c.currency) AS currency,
+			  COALESCE(b.amount, 0) AS billed, COALESCE(c.amount, 0) AS collected
+			  FROM billed b FULL OUTER JOIN collected c ON c.month = b.month AND c.currency = b.currency
+			  ORDER BY 1, 2`
+	if err := r.db.Select(&totals, query, apartmentID, from); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// shares paid in full since from, counted in buckets of days from charge to payment: up to 7, 14, 30,
+// 60 days and longer
+func (r *statsRepositoryImpl) GetDaysToPay(apartmentID int, from time.Time) ([]models.DaysToPayBucket, error) {
+	var buckets []models.DaysToPayBucket
+	query := `SELECT CASE WHEN days <= 7 THEN 1 WHEN days <= 14 THEN 2 WHEN days <= 30 THEN 3 WHEN days <= 60 THEN 4 ELSE 5 END AS bucket,
+			  COUNT(*) AS payments, SUM(days) AS total_days, COUNT(CASE WHEN late THEN 1 END) AS paid_late
+			  FROM (
+				SELECT GREATEST(p.paid_at::DATE - p.created_at::DATE, 0) AS days,
+				p.paid_at::DATE > COALESCE(b.billing_deadline, b.due_date) AS late
+				FROM payments p JOIN bills b ON b.id = p.bill_id
+				WHERE b.apartment_id = $1 AND p.kind = 'share' AND p.paid_at IS NOT NULL AND p.paid_at >= $2
+			  ) paid
+			  GROUP BY 1 ORDER BY 1`
+	if err := r.db.Select(&buckets, query, apartmentID, from); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// the largest categories first
+func (r *statsRepositoryImpl) GetCategoryTotals(apartmentID int, from time.Time) ([]models.CategoryTotals, error) {
+	var totals []models.CategoryTotals
+	query := `SELECT b.bill_type AS category, b.currency, COUNT(*) AS bills, SUM(b.total_amount) AS billed,
+			  SUM(COALESCE(p.collected, 0)) AS collected
+			  FROM bills b LEFT JOIN (
+				SELECT bill_id, SUM(amount_paid - amount_refunded) AS collected
+				FROM payments WHERE kind <> 'penalty' GROUP BY bill_id
+			  ) p ON p.bill_id = b.id
+			  WHERE b.apartment_id = $1 AND b.status IN ` + statsBillStatuses + ` AND COALESCE(b.period_start, b.due_date) >= $2
+			  GROUP BY b.bill_type, b.currency
+			  ORDER BY billed DESC, b.bill_type`
+	if err := r.db.Select(&totals, query, apartmentID, from); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// residents by what they still owe on their bills, the most first. penalties are left out like in the
+// collection summary
+func (r *statsRepositoryImpl) GetTopDebtors(apartmentID int, today time.Time, limit int) ([]models.Debtor, error) {
+	var debtors []models.Debtor
+	query := `SELECT p.user_id, u.username, u.full_name, p.currency, SUM(p.amount - p.amount_paid) AS outstanding,
+			  SUM(CASE WHEN COALESCE(b.billing_deadline, b.due_date) < $2 THEN p.amount - p.amount_paid ELSE 0 END) AS overdue,
+			  COUNT(*) AS open_payments
+			  FROM payments p JOIN bills b ON b.id = p.bill_id JOIN users u ON u.id = p.user_id
+			  WHERE b.apartment_id = $1 AND p.kind <> 'penalty' AND p.payment_status IN ('pending', 'partially_paid', 'processing')
+			  GROUP BY p.user_id, u.username, u.full_name, p.currency
+			  HAVING SUM(p.amount - p.amount_paid) > 0
+			  ORDER BY outstanding DESC, p.user_id
+			  LIMIT $3`
+	if err := r.db.Select(&debtors, query, apartmentID, today, limit); err != nil {
+		return nil, err
+	}
+	return debtors, nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStatsRepository struct {
+	mock.Mock
+}
+
+func (m *MockStatsRepository) GetCollectionSummary(apartmentID int, from, today time.Time) ([]models.CollectionSummary, error) {
+	args := m.Called(apartmentID, from, today)
+	if summaries, ok := args.Get(0).([]models.CollectionSummary); ok {
+		return summaries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatsRepository) GetBilledVsCollected(apartmentID int, from time.Time) ([]models.MonthlyTotals, error) {
+	args := m.Called(apartmentID, from)
+	if totals, ok := args.Get(0).([]models.MonthlyTotals); ok {
+		return totals, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatsRepository) GetDaysToPay(apartmentID int, from time.Time) ([]models.DaysToPayBucket, error) {
+	args := m.Called(apartmentID, from)
+	if buckets, ok := args.Get(0).([]models.DaysToPayBucket); ok {
+		return buckets, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatsRepository) GetCategoryTotals(apartmentID int, from time.Time) ([]models.CategoryTotals, error) {
+	args := m.Called(apartmentID, from)
+	if totals, ok := args.Get(0).([]models.CategoryTotals); ok {
+		return totals, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatsRepository) GetTopDebtors(apartmentID int, today time.Time, limit int) ([]models.Debtor, error) {
+	args := m.Called(apartmentID, today, limit)
+	if debtors, ok := args.Get(0).([]models.Debtor); ok {
+		return debtors, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
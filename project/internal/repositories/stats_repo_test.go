@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsRepository_GetCollectionSummary(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statsRepositoryImpl{db: db}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	today := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT p.currency, (.+) FROM payments p JOIN bills b (.+) GROUP BY p.currency").
+		WithArgs(7, from, today).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "charged", "collected", "outstanding", "overdue", "overdue_payments"}).
+			AddRow(money.IRR, "1000.00", "750.00", "250.00", "100.00", 2))
+
+	summaries, err := repo.GetCollectionSummary(7, from, today)
+
+	assert.NoError(t, err)
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, money.Amount(25000), summaries[0].Outstanding)
+	assert.Equal(t, 2, summaries[0].OverduePayments)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsRepository_GetBilledVsCollected(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statsRepositoryImpl{db: db}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("WITH billed AS (.+) FULL OUTER JOIN collected").
+		WithArgs(7, from).
+		WillReturnRows(sqlmock.NewRows([]string{"month", "currency", "billed", "collected"}).
+			AddRow(from, money.IRR, "500.00", "300.00").
+			AddRow(from.AddDate(0, 1, 0), money.IRR, "0.00", "200.00"))
+
+	totals, err := repo.GetBilledVsCollected(7, from)
+
+	assert.NoError(t, err)
+	assert.Len(t, totals, 2)
+	assert.Equal(t, money.Amount(20000), totals[1].Collected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsRepository_GetDaysToPay(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statsRepositoryImpl{db: db}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT CASE WHEN days <= 7 THEN 1 (.+) GROUP BY 1 ORDER BY 1").
+		WithArgs(7, from).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "payments", "total_days", "paid_late"}).
+			AddRow(1, 4, 10, 0).
+			AddRow(5, 1, 75, 1))
+
+	buckets, err := repo.GetDaysToPay(7, from)
+
+	assert.NoError(t, err)
+	assert.Len(t, buckets, 2)
+	assert.Equal(t, 75, buckets[1].TotalDays)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsRepository_GetCategoryTotals(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statsRepositoryImpl{db: db}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT b.bill_type AS category, (.+) GROUP BY b.bill_type, b.currency").
+		WithArgs(7, from).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "currency", "bills", "billed", "collected"}).
+			AddRow(models.WaterBill, money.IRR, 3, "900.00", "600.00"))
+
+	totals, err := repo.GetCategoryTotals(7, from)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.WaterBill, totals[0].Category)
+	assert.Equal(t, money.Amount(90000), totals[0].Billed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsRepository_GetTopDebtors(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+	repo := &statsRepositoryImpl{db: db}
+	today := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("SELECT p.user_id, (.+) WHERE b.apartment_id = \\$1 AND p.kind <> 'penalty' (.+) ORDER BY outstanding DESC, p.user_id LIMIT \\$3").
+			WithArgs(7, today, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "full_name", "currency", "outstanding", "overdue", "open_payments"}).
+				AddRow(2, "sara", "Sara Ahmadi", money.IRR, "300.00", "120.00", 3))
+
+		debtors, err := repo.GetTopDebtors(7, today, 5)
+		assert.NoError(t, err)
+		assert.Len(t, debtors, 1)
+		assert.Equal(t, money.Amount(12000), debtors[0].Overdue)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT p.user_id").WillReturnError(sql.ErrConnDone)
+
+		debtors, err := repo.GetTopDebtors(7, today, 5)
+		assert.Error(t, err)
+		assert.Nil(t, debtors)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
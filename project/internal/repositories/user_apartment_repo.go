@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
@@ -11,20 +12,33 @@ import (
 
 const (
 	CREATE_USER_APARTMENT_TABLE = `CREATE TABLE IF NOT EXISTS user_apartments(
+		id SERIAL PRIMARY KEY,
 		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
 		apartment_id INTEGER REFERENCES apartments(id) ON DELETE CASCADE,
 		is_manager BOOLEAN DEFAULT FALSE,
+		unit_area DECIMAL(10,2) NOT NULL DEFAULT 0,
+		occupants_count INTEGER NOT NULL DEFAULT 1,
+		share_percentage DECIMAL(5,2) NOT NULL DEFAULT 0,
+		joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		left_at TIMESTAMP,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		PRIMARY KEY (user_id, apartment_id)
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
+
+	// memberships are kept after leaving, so only the current one has to be unique
+	CREATE_USER_APARTMENT_ACTIVE_INDEX = `CREATE UNIQUE INDEX IF NOT EXISTS user_apartments_active_idx
+		ON user_apartments (user_id, apartment_id) WHERE left_at IS NULL;`
 )
 
 type UserApartmentRepository interface {
 	CreateUserApartment(ctx context.Context, user_apartment models.User_apartment) error
 	GetResidentsInApartment(apartmentID int) ([]models.User, error)
+	GetMembershipsInApartment(apartmentID int) ([]models.User_apartment, error)
+	GetMembershipsInPeriod(apartmentID int, from, to time.Time) ([]models.User_apartment, error)
 	GetUserApartmentByID(userID, apartmentID int) (*models.User_apartment, error)
 	UpdateUserApartment(ctx context.Context, user_apartment models.User_apartment) error
+	UpdateShareFactors(ctx context.Context, user_apartment models.User_apartment) error
+	LeaveApartment(ctx context.Context, userID, apartmentID int) error
 	DeleteUserApartment(userID, apartmentID int) error
 	DeleteUserFromApartments(userID int) error
 	GetAllApartmentsForAResident(residentID int) ([]models.Apartment, error)
@@ -42,6 +56,9 @@ func NewUserApartmentRepository(autoCreate bool, db *sqlx.DB) UserApartmentRepos
 		if _, err := db.Exec(CREATE_USER_APARTMENT_TABLE); err != nil {
 			log.Fatalf("failed to create user_apartments table: %v", err)
 		}
+		if _, err := db.Exec(CREATE_USER_APARTMENT_ACTIVE_INDEX); err != nil {
+			log.Fatalf("failed to create user_apartments index: %v", err)
+		}
 	}
 	return &userApartmentRepositoryImpl{db: db}
 }
@@ -55,8 +72,8 @@ func (r *userApartmentRepositoryImpl) CreateUserApartment(ctx context.Context, u
 
 func (r *userApartmentRepositoryImpl) GetUserApartmentByID(userID, apartmentID int) (*models.User_apartment, error) {
 	var userApartment models.User_apartment
-	query := `SELECT user_id, apartment_id, is_manager, created_at, updated_at 
-			  FROM user_apartments WHERE user_id = $1 AND apartment_id = $2`
+	query := `SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at 
+			  FROM user_apartments WHERE user_id = $1 AND apartment_id = $2 AND left_at IS NULL`
 	err := r.db.Get(&userApartment, query, userID, apartmentID)
 	if err != nil {
 		return nil, err
@@ -67,11 +84,49 @@ func (r *userApartmentRepositoryImpl) GetUserApartmentByID(userID, apartmentID i
 func (r *userApartmentRepositoryImpl) UpdateUserApartment(ctx context.Context, user_apartment models.User_apartment) error {
 	query := `UPDATE user_apartments 
 			  SET is_manager = :is_manager, updated_at = CURRENT_TIMESTAMP 
-			  WHERE user_id = :user_id AND apartment_id = :apartment_id`
+			  WHERE user_id = :user_id AND apartment_id = :apartment_id AND left_at IS NULL`
 	_, err := r.db.NamedExecContext(ctx, query, user_apartment)
 	return err
 }
 
+func (r *userApartmentRepositoryImpl) UpdateShareFactors(ctx context.Context, user_apartment models.User_apartment) error {
+	query := `UPDATE user_apartments 
+			  SET unit_area = :unit_area, occupants_count = :occupants_count,
+			  share_percentage = :share_percentage, updated_at = CURRENT_TIMESTAMP 
+			  WHERE user_id = :user_id AND apartment_id = :apartment_id AND left_at IS NULL`
+	result, err := r.db.NamedExecContext(ctx, query, user_apartment)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("not in apartment")
+	}
+	return nil
+}
+
+// ends the current membership but keeps the row, earlier billing periods still need it
+func (r *userApartmentRepositoryImpl) LeaveApartment(ctx context.Context, userID, apartmentID int) error {
+	query := `UPDATE user_apartments 
+			  SET left_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP 
+			  WHERE user_id = $1 AND apartment_id = $2 AND left_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, userID, apartmentID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("not in apartment")
+	}
+	return nil
+}
+
 func (r *userApartmentRepositoryImpl) DeleteUserApartment(userID, apartmentID int) error {
 	query := `DELETE FROM user_apartments WHERE user_id = $1 AND apartment_id = $2`
 	_, err := r.db.Exec(query, userID, apartmentID)
@@ -83,7 +138,7 @@ func (r *userApartmentRepositoryImpl) GetResidentsInApartment(apartmentID int) (
 	query := `SELECT u.id, u.username, u.email, u.phone, u.full_name, u.user_type, u.created_at, u.updated_at
           FROM users u
           JOIN user_apartments ua ON u.id = ua.user_id
-          WHERE ua.apartment_id = $1`
+          WHERE ua.apartment_id = $1 AND ua.left_at IS NULL`
 	err := r.db.Select(&residents, query, apartmentID)
 	if err != nil {
 		return nil, err
@@ -91,12 +146,39 @@ func (r *userApartmentRepositoryImpl) GetResidentsInApartment(apartmentID int) (
 	return residents, nil
 }
 
+// returns the membership rows with share factors, ordered by user id so divisions are deterministic
+func (r *userApartmentRepositoryImpl) GetMembershipsInApartment(apartmentID int) ([]models.User_apartment, error) {
+	var memberships []models.User_apartment
+	query := `SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at
+			  FROM user_apartments WHERE apartment_id = $1 AND left_at IS NULL
+			  ORDER BY user_id ASC`
+	err := r.db.Select(&memberships, query, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// returns every membership, current or past, that overlaps [from, to), ordered by user and join time
+func (r *userApartmentRepositoryImpl) GetMembershipsInPeriod(apartmentID int, from, to time.Time) ([]models.User_apartment, error) {
+	var memberships []models.User_apartment
+	query := `SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at
+			  FROM user_apartments
+			  WHERE apartment_id = $1 AND joined_at < $3 AND (left_at IS NULL OR left_at > $2)
+			  ORDER BY user_id ASC, joined_at ASC`
+	err := r.db.Select(&memberships, query, apartmentID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
 func (r *userApartmentRepositoryImpl) GetAllApartmentsForAResident(residentID int) ([]models.Apartment, error) {
 	var apartments []models.Apartment
 	query := `SELECT a.id, a.apartment_name, a.address, a.units_count, a.manager_id, a.created_at, a.updated_at
 			  FROM apartments a
 			  JOIN user_apartments ua ON a.id = ua.apartment_id
-			  WHERE ua.user_id = $1`
+			  WHERE ua.user_id = $1 AND ua.left_at IS NULL`
 	err := r.db.Select(&apartments, query, residentID)
 	if err != nil {
 		return nil, err
@@ -107,7 +189,7 @@ func (r *userApartmentRepositoryImpl) GetAllApartmentsForAResident(residentID in
 func (r *userApartmentRepositoryImpl) IsUserManagerOfApartment(ctx context.Context, userID, apartmentID int) (bool, error) {
 	var isManager bool
 	query := `SELECT is_manager FROM user_apartments 
-			  WHERE user_id = $1 AND apartment_id = $2`
+			  WHERE user_id = $1 AND apartment_id = $2 AND left_at IS NULL`
 	err := r.db.GetContext(ctx, &isManager, query, userID, apartmentID)
 	if err != nil || !isManager {
 		if !isManager {
@@ -122,7 +204,7 @@ func (r *userApartmentRepositoryImpl) IsUserInApartment(ctx context.Context, use
 	var exists bool
 	query := `SELECT EXISTS(
 		SELECT 1 FROM user_apartments 
-		WHERE user_id = $1 AND apartment_id = $2
+		WHERE user_id = $1 AND apartment_id = $2 AND left_at IS NULL
 	)`
 	err := r.db.GetContext(ctx, &exists, query, userID, apartmentID)
 	if err != nil || !exists {
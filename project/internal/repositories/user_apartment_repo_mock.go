@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
 	"github.com/stretchr/testify/mock"
@@ -24,6 +25,22 @@ func (m *MockUserApartmentRepository) GetResidentsInApartment(apartmentID int) (
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
+func (m *MockUserApartmentRepository) GetMembershipsInApartment(apartmentID int) ([]models.User_apartment, error) {
+	args := m.Called(apartmentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.User_apartment), args.Error(1)
+}
+
+func (m *MockUserApartmentRepository) GetMembershipsInPeriod(apartmentID int, from, to time.Time) ([]models.User_apartment, error) {
+	args := m.Called(apartmentID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.User_apartment), args.Error(1)
+}
+
 func (m *MockUserApartmentRepository) GetUserApartmentByID(userID, apartmentID int) (*models.User_apartment, error) {
 	args := m.Called(userID, apartmentID)
 	if args.Get(0) == nil {
@@ -37,6 +54,16 @@ func (m *MockUserApartmentRepository) UpdateUserApartment(ctx context.Context, u
 	return args.Error(0)
 }
 
+func (m *MockUserApartmentRepository) UpdateShareFactors(ctx context.Context, userApartment models.User_apartment) error {
+	args := m.Called(ctx, userApartment)
+	return args.Error(0)
+}
+
+func (m *MockUserApartmentRepository) LeaveApartment(ctx context.Context, userID, apartmentID int) error {
+	args := m.Called(ctx, userID, apartmentID)
+	return args.Error(0)
+}
+
 func (m *MockUserApartmentRepository) DeleteUserApartment(userID, apartmentID int) error {
 	args := m.Called(userID, apartmentID)
 	return args.Error(0)
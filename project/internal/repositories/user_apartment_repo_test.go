@@ -64,7 +64,7 @@ func TestUserApartmentRepository_GetUserApartmentByID(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"user_id", "apartment_id", "is_manager", "created_at", "updated_at"}).
 			AddRow(userID, apartmentID, true, now, now)
 
-		mock.ExpectQuery(`SELECT user_id, apartment_id, is_manager, created_at, updated_at FROM user_apartments`).
+		mock.ExpectQuery(`SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at FROM user_apartments`).
 			WithArgs(userID, apartmentID).
 			WillReturnRows(rows)
 
@@ -77,7 +77,7 @@ func TestUserApartmentRepository_GetUserApartmentByID(t *testing.T) {
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		mock.ExpectQuery(`SELECT user_id, apartment_id, is_manager, created_at, updated_at FROM user_apartments`).
+		mock.ExpectQuery(`SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at FROM user_apartments`).
 			WithArgs(userID, apartmentID).
 			WillReturnError(sql.ErrNoRows)
 
@@ -124,6 +124,83 @@ func TestUserApartmentRepository_UpdateUserApartment(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUserApartmentRepository_UpdateShareFactors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewUserApartmentRepository(false, sqlxDB)
+
+	userApartment := models.User_apartment{
+		UserID:          1,
+		ApartmentID:     2,
+		UnitArea:        120,
+		OccupantsCount:  5,
+		SharePercentage: 40,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE user_apartments SET unit_area`).
+			WithArgs(userApartment.UnitArea, userApartment.OccupantsCount, userApartment.SharePercentage, userApartment.UserID, userApartment.ApartmentID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UpdateShareFactors(context.Background(), userApartment)
+		assert.NoError(t, err)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE user_apartments SET unit_area`).
+			WithArgs(userApartment.UnitArea, userApartment.OccupantsCount, userApartment.SharePercentage, userApartment.UserID, userApartment.ApartmentID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UpdateShareFactors(context.Background(), userApartment)
+		assert.EqualError(t, err, "not in apartment")
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserApartmentRepository_GetMembershipsInApartment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewUserApartmentRepository(false, sqlxDB)
+
+	apartmentID := 2
+	now := time.Now()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"user_id", "apartment_id", "is_manager", "unit_area", "occupants_count", "share_percentage", "created_at", "updated_at"}).
+			AddRow(1, apartmentID, true, 0, 1, 0, now, now).
+			AddRow(3, apartmentID, false, 85.5, 3, 60, now, now)
+
+		mock.ExpectQuery(`SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at FROM user_apartments WHERE apartment_id = \$1`).
+			WithArgs(apartmentID).
+			WillReturnRows(rows)
+
+		memberships, err := repo.GetMembershipsInApartment(apartmentID)
+		assert.NoError(t, err)
+		assert.Len(t, memberships, 2)
+		assert.Equal(t, 85.5, memberships[1].UnitArea)
+		assert.Equal(t, 3, memberships[1].OccupantsCount)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT id, user_id, apartment_id, is_manager, unit_area, occupants_count, share_percentage, joined_at, left_at, created_at, updated_at FROM user_apartments WHERE apartment_id = \$1`).
+			WithArgs(apartmentID).
+			WillReturnError(sql.ErrConnDone)
+
+		memberships, err := repo.GetMembershipsInApartment(apartmentID)
+		assert.Error(t, err)
+		assert.Nil(t, memberships)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUserApartmentRepository_DeleteUserApartment(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -156,6 +233,62 @@ func TestUserApartmentRepository_DeleteUserApartment(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUserApartmentRepository_LeaveApartment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewUserApartmentRepository(false, sqlxDB)
+
+	t.Run("keeps the membership row", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE user_apartments SET left_at = CURRENT_TIMESTAMP`).
+			WithArgs(1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.LeaveApartment(context.Background(), 1, 2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("not a current member", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE user_apartments SET left_at = CURRENT_TIMESTAMP`).
+			WithArgs(1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.LeaveApartment(context.Background(), 1, 2)
+		assert.EqualError(t, err, "not in apartment")
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserApartmentRepository_GetMembershipsInPeriod(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	repo := NewUserApartmentRepository(false, sqlxDB)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	left := from.AddDate(0, 0, 10)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "apartment_id", "is_manager", "joined_at", "left_at"}).
+		AddRow(1, 2, 7, false, from.AddDate(0, -3, 0), left).
+		AddRow(4, 5, 7, false, left, nil)
+
+	mock.ExpectQuery(`SELECT (.+) FROM user_apartments WHERE apartment_id = \$1 AND joined_at < \$3 AND \(left_at IS NULL OR left_at > \$2\)`).
+		WithArgs(7, from, to).
+		WillReturnRows(rows)
+
+	memberships, err := repo.GetMembershipsInPeriod(7, from, to)
+	assert.NoError(t, err)
+	assert.Len(t, memberships, 2)
+	assert.Equal(t, left, *memberships[0].LeftAt)
+	assert.Nil(t, memberships[1].LeftAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUserApartmentRepository_GetResidentsInApartment(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -0,0 +1,297 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+const (
+	CREATE_WALLETS_TABLE = `CREATE TABLE IF NOT EXISTS wallets(
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		apartment_id INTEGER NOT NULL REFERENCES apartments(id) ON DELETE CASCADE,
+		currency VARCHAR(3) NOT NULL DEFAULT 'IRR',
+		balance DECIMAL(12,2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+		auto_pay BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, apartment_id)
+	);`
+
+	// the ledger is append-only, the balance on wallets is kept in step with it in the same transaction
+	CREATE_WALLET_TRANSACTIONS_TABLE = `CREATE TABLE IF NOT EXISTS wallet_transactions(
+		id SERIAL PRIMARY KEY,
+		wallet_id INTEGER NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+		type VARCHAR(20) NOT NULL,
+		amount DECIMAL(12,2) NOT NULL,
+		balance_after DECIMAL(12,2) NOT NULL,
+		payment_id INTEGER REFERENCES payments(id) ON DELETE SET NULL,
+		payment_transaction_id INTEGER REFERENCES payment_transactions(id) ON DELETE SET NULL,
+		note TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// moves the balance by a signed amount, a debit below zero matches no row
+	UPDATE_WALLET_BALANCE = `UPDATE wallets SET balance = balance + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND balance + $1 >= 0 RETURNING balance, user_id, apartment_id, currency`
+	INSERT_WALLET_TRANSACTION = `INSERT INTO wallet_transactions (wallet_id, type, amount, balance_after, payment_id, payment_transaction_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient wallet balance")
+	ErrWalletCurrency    = errors.New("wallet is in another currency")
+)
+
+type WalletRepository interface {
+	GetOrCreateWallet(ctx context.Context, userID, apartmentID int, currency money.Currency) (*models.Wallet, error)
+	GetWallet(userID, apartmentID int) (*models.Wallet, error)
+	GetWalletsByUser(userID int) ([]models.Wallet, error)
+	SetAutoPay(ctx context.Context, walletID int, enabled bool) error
+	GetTransactions(walletID int) ([]models.WalletTransaction, error)
+	AddTransaction(ctx context.Context, entry models.WalletTransaction) (*models.WalletTransaction, error)
+	PayFromWallet(ctx context.Context, walletID int, transaction models.PaymentTransaction) (int, error)
+}
+
+type walletRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewWalletRepository(autoCreate bool, db *sqlx.DB) WalletRepository {
+	if autoCreate {
+		if _, err := db.Exec(CREATE_WALLETS_TABLE); err != nil {
+			log.Fatalf("failed to create wallets table: %v", err)
+		}
+		if _, err := db.Exec(CREATE_WALLET_TRANSACTIONS_TABLE); err != nil {
+			log.Fatalf("failed to create wallet_transactions table: %v", err)
+		}
+	}
+	return &walletRepositoryImpl{db: db}
+}
+
+func (r *walletRepositoryImpl) GetOrCreateWallet(ctx context.Context, userID, apartmentID int, currency money.Currency) (*models.Wallet, error) {
+	query := `INSERT INTO wallets (user_id, apartment_id, currency) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id, apartment_id) DO NOTHING`
+	if _, err := r.db.ExecContext(ctx, query, userID, apartmentID, currency); err != nil {
+		return nil, err
+	}
+	return r.GetWallet(userID, apartmentID)
+}
+
+func (r *walletRepositoryImpl) GetWallet(userID, apartmentID int) (*models.Wallet, error) {
+	var wallet models.Wallet
+	query := `SELECT id, user_id, apartment_id, currency, balance, auto_pay, created_at, updated_at
+			  FROM wallets WHERE user_id = $1 AND apartment_id = $2`
+	if err := r.db.Get(&wallet, query, userID, apartmentID); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *walletRepositoryImpl) GetWalletsByUser(userID int) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	query := `SELECT id, user_id, apartment_id, currency, balance, auto_pay, created_at, updated_at
+			  FROM wallets WHERE user_id = $1 ORDER BY apartment_id`
+	if err := r.db.Select(&wallets, query, userID); err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+func (r *walletRepositoryImpl) SetAutoPay(ctx context.Context, walletID int, enabled bool) error {
+	query := `UPDATE wallets SET auto_pay = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, enabled, walletID)
+	return err
+}
+
+func (r *walletRepositoryImpl) GetTransactions(walletID int) ([]models.WalletTransaction, error) {
+	var entries []models.WalletTransaction
+	query := `SELECT id, wallet_id, type, amount, balance_after, payment_id, payment_transaction_id, note, created_at
+			  FROM wallet_transactions WHERE wallet_id = $1 ORDER BY id`
+	if err := r.db.Select(&entries, query, walletID); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// books a single credit or debit. a debit larger than the balance fails with ErrInsufficientFunds
+func (r *walletRepositoryImpl) AddTransaction(ctx context.Context, entry models.WalletTransaction) (result *models.WalletTransaction, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	wallet, err := addWalletTransaction(ctx, tx, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if err = postWalletEntry(ctx, tx, wallet, entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// settles payments from the wallet balance. the payments are recorded as a succeeded transaction of the
+// wallet gateway so idempotency keys work the same as for gateway checkouts
+func (r *walletRepositoryImpl) PayFromWallet(ctx context.Context, walletID int, transaction models.PaymentTransaction) (id int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO payment_transactions (user_id, gateway, amount, currency, status, idempotency_key)
+			  VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (user_id, idempotency_key) DO NOTHING RETURNING id`,
+		transaction.UserID,
+		models.WalletGateway,
+		transaction.Amount,
+		transaction.Currency,
+		models.TransactionSucceeded,
+		transaction.IdempotencyKey).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDuplicateIdempotencyKey
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var wallet *models.Wallet
+	for _, item := range byPayment(transaction.Items) {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO transaction_items (transaction_id, payment_id, installment_id, amount)
+				  VALUES ($1, $2, $3, $4)`,
+			id, item.PaymentID, item.InstallmentID, item.Amount); err != nil {
+			return 0, err
+		}
+
+		var from, status models.PaymentStatus
+		from, err = lockPaymentStatus(ctx, tx, item.PaymentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrPaymentNotPayable
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err = checkInstallmentItem(ctx, tx, item); err != nil {
+			return 0, err
+		}
+		// a payment with an open gateway checkout is left to that checkout
+		err = tx.QueryRowContext(ctx, RECORD_PARTIAL_PAYMENT+` AND payment_status <> 'processing' AND user_id = $3 RETURNING payment_status`,
+			item.Amount, item.PaymentID, transaction.UserID).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrPaymentNotPayable
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err = recordPaymentEvent(ctx, tx, models.PaymentEvent{
+			PaymentID:  item.PaymentID,
+			FromStatus: from,
+			ToStatus:   status,
+			ActorID:    &transaction.UserID,
+			Reason:     fmt.Sprintf("paid from wallet (transaction %d)", id),
+		}); err != nil {
+			return 0, err
+		}
+
+		paymentID := item.PaymentID
+		entry := models.WalletTransaction{
+			WalletID:             walletID,
+			Type:                 models.WalletDeduction,
+			Amount:               -item.Amount,
+			PaymentID:            &paymentID,
+			PaymentTransactionID: &id,
+		}
+		if wallet, err = addWalletTransaction(ctx, tx, &entry); err != nil {
+			return 0, err
+		}
+	}
+	if err = syncBillSettlement(ctx, tx, itemPayments(transaction.Items)...); err != nil {
+		return 0, err
+	}
+	if wallet == nil {
+		return id, nil
+	}
+
+	// the wallet credit pays off what the resident owed
+	journal := models.NewJournalEntry(wallet.ApartmentID, models.PaymentEntry, fmt.Sprintf("transaction:%d", id), "Paid from wallet", wallet.Currency).
+		Debit(models.ResidentWallet, &wallet.UserID, transaction.Amount).
+		Credit(models.ResidentReceivable, &wallet.UserID, transaction.Amount)
+	if err = postJournalEntry(ctx, tx, journal); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// the resident's wallet in the apartment inside the caller's transaction, created empty when they
+// have none yet
+func walletOf(ctx context.Context, tx *sqlx.Tx, userID, apartmentID int, currency money.Currency) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := tx.GetContext(ctx, &wallet, `INSERT INTO wallets (user_id, apartment_id, currency) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id, apartment_id) DO UPDATE SET updated_at = wallets.updated_at
+			  RETURNING id, user_id, apartment_id, currency, balance, auto_pay, created_at, updated_at`,
+		userID, apartmentID, currency)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// moves the wallet balance and appends the matching ledger entry, inside the caller's transaction.
+// returns the owner and currency of the wallet for bookkeeping
+func addWalletTransaction(ctx context.Context, tx *sqlx.Tx, entry *models.WalletTransaction) (*models.Wallet, error) {
+	wallet := models.Wallet{BaseModel: models.BaseModel{ID: entry.WalletID}}
+	if err := tx.QueryRowContext(ctx, UPDATE_WALLET_BALANCE, entry.Amount, entry.WalletID).
+		Scan(&entry.BalanceAfter, &wallet.UserID, &wallet.ApartmentID, &wallet.Currency); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInsufficientFunds
+		}
+		return nil, err
+	}
+	wallet.Balance = entry.BalanceAfter
+
+	if err := tx.QueryRowContext(ctx, INSERT_WALLET_TRANSACTION,
+		entry.WalletID,
+		entry.Type,
+		entry.Amount,
+		entry.BalanceAfter,
+		entry.PaymentID,
+		entry.PaymentTransactionID,
+		entry.Note).Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// books a wallet credit or debit that isn't a payment in the apartment's journal. top-ups come from
+// the apartment fund, anything else is a manager adjustment
+func postWalletEntry(ctx context.Context, tx *sqlx.Tx, wallet *models.Wallet, entry models.WalletTransaction) error {
+	entryType, counter, description := models.WalletAdjustmentEntry, models.WalletAdjustments, entry.Note
+	if entry.Type == models.WalletTopUp {
+		entryType, counter, description = models.TopUpEntry, models.ApartmentFund, "Wallet top-up"
+	}
+
+	journal := models.NewJournalEntry(wallet.ApartmentID, entryType, fmt.Sprintf("wallet_transaction:%d", entry.ID), description, wallet.Currency).
+		Debit(counter, nil, entry.Amount).
+		Credit(models.ResidentWallet, &wallet.UserID, entry.Amount)
+	return postJournalEntry(ctx, tx, journal)
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWalletRepository struct {
+	mock.Mock
+}
+
+func (m *MockWalletRepository) GetOrCreateWallet(ctx context.Context, userID, apartmentID int, currency money.Currency) (*models.Wallet, error) {
+	args := m.Called(ctx, userID, apartmentID, currency)
+	if wallet, ok := args.Get(0).(*models.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) GetWallet(userID, apartmentID int) (*models.Wallet, error) {
+	args := m.Called(userID, apartmentID)
+	if wallet, ok := args.Get(0).(*models.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) GetWalletsByUser(userID int) ([]models.Wallet, error) {
+	args := m.Called(userID)
+	if wallets, ok := args.Get(0).([]models.Wallet); ok {
+		return wallets, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) SetAutoPay(ctx context.Context, walletID int, enabled bool) error {
+	args := m.Called(ctx, walletID, enabled)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) GetTransactions(walletID int) ([]models.WalletTransaction, error) {
+	args := m.Called(walletID)
+	if entries, ok := args.Get(0).([]models.WalletTransaction); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) AddTransaction(ctx context.Context, entry models.WalletTransaction) (*models.WalletTransaction, error) {
+	args := m.Called(ctx, entry)
+	if result, ok := args.Get(0).(*models.WalletTransaction); ok {
+		return result, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) PayFromWallet(ctx context.Context, walletID int, transaction models.PaymentTransaction) (int, error) {
+	args := m.Called(ctx, walletID, transaction)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletRepository_AddTransaction(t *testing.T) {
+	resident := 2
+
+	t.Run("credits the balance", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &walletRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1").
+			WithArgs(money.Amount(5000), 3).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "user_id", "apartment_id", "currency"}).AddRow("80.00", 2, 7, money.IRR))
+		mock.ExpectQuery("INSERT INTO wallet_transactions").
+			WithArgs(3, models.WalletAdjustment, money.Amount(5000), money.Amount(8000), nil, nil, "goodwill").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, time.Now()))
+		expectJournalEntry(mock, 1, 7, models.WalletAdjustmentEntry,
+			models.JournalLine{Account: models.WalletAdjustments, Debit: 5000},
+			models.JournalLine{Account: models.ResidentWallet, UserID: &resident, Credit: 5000})
+		mock.ExpectCommit()
+
+		entry, err := repo.AddTransaction(context.Background(), models.WalletTransaction{
+			WalletID: 3, Type: models.WalletAdjustment, Amount: 5000, Note: "goodwill",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 9, entry.ID)
+		assert.Equal(t, money.Amount(8000), entry.BalanceAfter)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("debit below zero", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &walletRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1").
+			WithArgs(money.Amount(-5000), 3).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.AddTransaction(context.Background(), models.WalletTransaction{
+			WalletID: 3, Type: models.WalletAdjustment, Amount: -5000,
+		})
+
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletRepository_PayFromWallet(t *testing.T) {
+	resident := 2
+	transaction := models.PaymentTransaction{
+		UserID:         2,
+		Amount:         3000,
+		Currency:       money.IRR,
+		IdempotencyKey: "idemp123",
+		Items:          []models.TransactionItem{{PaymentID: 5, Amount: 3000}},
+	}
+
+	t.Run("settles the payments", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &walletRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WithArgs(2, models.WalletGateway, money.Amount(3000), money.IRR, models.TransactionSucceeded, "idemp123").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec("INSERT INTO transaction_items").
+			WithArgs(7, 5, nil, money.Amount(3000)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
+		expectInstallmentPlans(mock, false)
+		mock.ExpectQuery("UPDATE payments SET").
+			WithArgs(money.Amount(3000), 5, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Paid))
+		mock.ExpectExec("INSERT INTO payment_events").
+			WithArgs(5, models.Pending, models.Paid, 2, "paid from wallet (transaction 7)").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("UPDATE wallets SET balance").
+			WithArgs(money.Amount(-3000), 3).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "user_id", "apartment_id", "currency"}).AddRow("20.00", 2, 7, money.IRR))
+		mock.ExpectQuery("INSERT INTO wallet_transactions").
+			WithArgs(3, models.WalletDeduction, money.Amount(-3000), money.Amount(2000), sqlmock.AnyArg(), sqlmock.AnyArg(), "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(10, time.Now()))
+		expectBillSettlement(mock, 11, models.BillDivided, true)
+		expectJournalEntry(mock, 1, 7, models.PaymentEntry,
+			models.JournalLine{Account: models.ResidentWallet, UserID: &resident, Debit: 3000},
+			models.JournalLine{Account: models.ResidentReceivable, UserID: &resident, Credit: 3000})
+		mock.ExpectCommit()
+
+		id, err := repo.PayFromWallet(context.Background(), 3, transaction)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("idempotency key taken by a concurrent request", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &walletRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions .* ON CONFLICT \\(user_id, idempotency_key\\) DO NOTHING").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		_, err := repo.PayFromWallet(context.Background(), 3, transaction)
+
+		assert.ErrorIs(t, err, ErrDuplicateIdempotencyKey)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &walletRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec("INSERT INTO transaction_items").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.PartiallyPaid))
+		expectInstallmentPlans(mock, false)
+		mock.ExpectQuery("UPDATE payments SET").
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.PartiallyPaid))
+		mock.ExpectQuery("UPDATE wallets SET balance").
+			WithArgs(money.Amount(-3000), 3).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.PayFromWallet(context.Background(), 3, transaction)
+
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("payment on an installment plan", func(t *testing.T) {
+		db, mock := setupTestDB(t)
+		defer db.Close()
+		repo := &walletRepositoryImpl{db: db}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO payment_transactions").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec("INSERT INTO transaction_items").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT payment_status FROM payments").
+			WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(models.Pending))
+		expectInstallmentPlans(mock, true)
+		mock.ExpectRollback()
+
+		_, err := repo.PayFromWallet(context.Background(), 3, transaction)
+
+		assert.ErrorIs(t, err, ErrPaymentOnInstallments)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
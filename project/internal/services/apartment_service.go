@@ -9,6 +9,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
@@ -24,6 +25,8 @@ type ApartmentService interface {
 	InviteUserToApartment(ctx context.Context, managerID, apartmentID int, telegramUsername string) (map[string]interface{}, error)
 	JoinApartment(ctx context.Context, userID int, token string) (map[string]interface{}, error)
 	LeaveApartment(ctx context.Context, userID, apartmentID int) error
+	UpdateResidentShares(ctx context.Context, managerID, apartmentID, residentID int, req dto.ResidentSharesRequest) error
+	SetShareVisibility(ctx context.Context, managerID, apartmentID int, visibility models.ShareVisibility) error
 }
 
 type apartmentServiceImpl struct {
@@ -274,9 +277,64 @@ func (s *apartmentServiceImpl) JoinApartment(ctx context.Context, userID int, in
 func (s *apartmentServiceImpl) LeaveApartment(ctx context.Context, userID, apartmentID int) error {
 	logrus.Infof("User %d is leaving apartment %d", userID, apartmentID)
 
-	if err := s.userApartmentRepo.DeleteUserApartment(userID, apartmentID); err != nil {
+	if err := s.userApartmentRepo.LeaveApartment(ctx, userID, apartmentID); err != nil {
 		logrus.WithError(err).Error("Failed to leave apartment")
 		return fmt.Errorf("failed to leave apartment: %w", err)
 	}
 	return nil
 }
+
+func (s *apartmentServiceImpl) UpdateResidentShares(ctx context.Context, managerID, apartmentID, residentID int, req dto.ResidentSharesRequest) error {
+	logrus.WithFields(logrus.Fields{
+		"managerID":   managerID,
+		"apartmentID": apartmentID,
+		"residentID":  residentID,
+	}).Info("Updating resident share factors")
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return fmt.Errorf("only apartment managers can update resident shares")
+	}
+
+	if req.UnitArea < 0 || req.OccupantsCount < 0 {
+		return fmt.Errorf("unit area and occupants count cannot be negative")
+	}
+	if req.SharePercentage < 0 || req.SharePercentage > 100 {
+		return fmt.Errorf("share percentage must be between 0 and 100")
+	}
+
+	userApartment := models.User_apartment{
+		UserID:          residentID,
+		ApartmentID:     apartmentID,
+		UnitArea:        req.UnitArea,
+		OccupantsCount:  req.OccupantsCount,
+		SharePercentage: req.SharePercentage,
+	}
+
+	if err := s.userApartmentRepo.UpdateShareFactors(ctx, userApartment); err != nil {
+		logrus.WithError(err).Error("Failed to update resident share factors")
+		return fmt.Errorf("failed to update resident shares: %w", err)
+	}
+	return nil
+}
+
+// decides whether residents see the names behind the other shares of a bill
+func (s *apartmentServiceImpl) SetShareVisibility(ctx context.Context, managerID, apartmentID int, visibility models.ShareVisibility) error {
+	logrus.WithFields(logrus.Fields{
+		"managerID":   managerID,
+		"apartmentID": apartmentID,
+		"visibility":  visibility,
+	}).Info("Updating share visibility")
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return fmt.Errorf("only apartment managers can change share visibility")
+	}
+	if !visibility.Valid() {
+		return fmt.Errorf("share visibility must be %q or %q", models.AnonymousShares, models.NamedShares)
+	}
+
+	if err := s.apartmentRepo.SetShareVisibility(ctx, apartmentID, visibility); err != nil {
+		logrus.WithError(err).Error("Failed to update share visibility")
+		return fmt.Errorf("failed to update share visibility: %w", err)
+	}
+	return nil
+}
@@ -748,7 +748,7 @@ func TestLeaveApartment(t *testing.T) {
 			userID:      1,
 			apartmentID: 1,
 			mockSetup: func(userAptRepo *repositories.MockUserApartmentRepository) {
-				userAptRepo.On("DeleteUserApartment", 1, 1).Return(nil)
+				userAptRepo.On("LeaveApartment", mock.Anything, 1, 1).Return(nil)
 			},
 		},
 		{
@@ -756,7 +756,7 @@ func TestLeaveApartment(t *testing.T) {
 			userID:      1,
 			apartmentID: 1,
 			mockSetup: func(userAptRepo *repositories.MockUserApartmentRepository) {
-				userAptRepo.On("DeleteUserApartment", 1, 1).Return(errors.New("database error"))
+				userAptRepo.On("LeaveApartment", mock.Anything, 1, 1).Return(errors.New("database error"))
 			},
 			expectedError: "failed to leave apartment",
 		},
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// large bills wait for a second manager or the apartment's representative before they can be divided.
+// whoever asked for the approval can't give it
+type ApprovalService interface {
+	SetApprovalPolicy(ctx context.Context, managerID, apartmentID int, req dto.ApprovalPolicyRequest) (*models.ApprovalPolicy, error)
+	GetApprovalPolicy(ctx context.Context, userID, apartmentID int) (*models.ApprovalPolicy, error)
+	DeleteApprovalPolicy(ctx context.Context, managerID, apartmentID int) error
+	Requires(bill models.Bill) (bool, error)
+	Approved(bill models.Bill) (bool, error)
+	RequestApproval(ctx context.Context, requesterID int, bill models.Bill) (bool, error)
+	NotifyApprovers(ctx context.Context, requesterID int, bill models.Bill)
+	GetPendingApprovals(ctx context.Context, userID, apartmentID int) ([]PendingApproval, error)
+	GetBillApprovals(ctx context.Context, userID, billID int) ([]models.BillApproval, error)
+	ApproveBill(ctx context.Context, userID, billID int, comment string) (models.BillStatus, error)
+	UseBillDivider(divider BillDivider)
+	RejectBill(ctx context.Context, userID, billID int, comment string) (models.BillStatus, error)
+}
+
+// divides bills once they are approved. it is the bill service, which needs the approval service
+// itself, so it is handed over after both are built
+type BillDivider interface {
+	DivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error)
+	RedivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error)
+}
+
+type PendingApproval struct {
+	Bill        models.Bill `json:"bill"`
+	RequestedBy int         `json:"requested_by"`
+	RequestedAt time.Time   `json:"requested_at"`
+	CanApprove  bool        `json:"can_approve"` // false for whoever asked for the approval
+}
+
+type approvalServiceImpl struct {
+	repo                repositories.ApprovalRepository
+	billRepo            repositories.BillRepository
+	userApartmentRepo   repositories.UserApartmentRepository
+	notificationService notification.Notification
+	divider             BillDivider
+}
+
+func NewApprovalService(
+	repo repositories.ApprovalRepository,
+	billRepo repositories.BillRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	notificationService notification.Notification,
+) ApprovalService {
+	return &approvalServiceImpl{
+		repo:                repo,
+		billRepo:            billRepo,
+		userApartmentRepo:   userApartmentRepo,
+		notificationService: notificationService,
+	}
+}
+
+func (s *approvalServiceImpl) SetApprovalPolicy(ctx context.Context, managerID, apartmentID int, req dto.ApprovalPolicyRequest) (*models.ApprovalPolicy, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"manager_id":   managerID,
+		"apartment_id": apartmentID,
+	})
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		logger.Warn("Non-manager user attempted to set an approval policy")
+		return nil, fmt.Errorf("only apartment managers can set the approval policy")
+	}
+
+	if req.Threshold <= 0 {
+		return nil, fmt.Errorf("threshold must be positive")
+	}
+	if req.Currency == "" {
+		req.Currency = money.DefaultCurrency
+	}
+	if !req.Currency.Valid() {
+		return nil, fmt.Errorf("invalid currency (use a three letter ISO code)")
+	}
+
+	members, err := s.userApartmentRepo.GetMembershipsInApartment(apartmentID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get apartment members")
+		return nil, fmt.Errorf("failed to get apartment members: %w", err)
+	}
+	managers, isMember := 0, false
+	for _, member := range members {
+		if member.IsManager {
+			managers++
+		}
+		if req.RepresentativeID != nil && member.UserID == *req.RepresentativeID {
+			isMember = true
+		}
+	}
+	if req.RepresentativeID != nil && !isMember {
+		return nil, fmt.Errorf("the representative has to be a member of the apartment")
+	}
+	//someone other than the manager who asks has to be able to approve
+	if managers < 2 && req.RepresentativeID == nil {
+		return nil, fmt.Errorf("approvals need a second manager or a representative")
+	}
+
+	policy := models.ApprovalPolicy{
+		ApartmentID:      apartmentID,
+		Threshold:        req.Threshold,
+		Currency:         req.Currency,
+		RepresentativeID: req.RepresentativeID,
+	}
+	id, err := s.repo.UpsertApprovalPolicy(ctx, policy)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save approval policy")
+		return nil, fmt.Errorf("failed to save approval policy: %w", err)
+	}
+	policy.ID = id
+
+	logger.Info("Approval policy saved")
+	return &policy, nil
+}
+
+func (s *approvalServiceImpl) GetApprovalPolicy(ctx context.Context, userID, apartmentID int) (*models.ApprovalPolicy, error) {
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+
+	policy, err := s.repo.GetApprovalPolicy(apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("no approval policy set: %w", err)
+	}
+	return policy, nil
+}
+
+// bills that were waiting for approval stay pending, any manager other than the requester can still decide
+func (s *approvalServiceImpl) DeleteApprovalPolicy(ctx context.Context, managerID, apartmentID int) error {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return fmt.Errorf("only apartment managers can delete the approval policy")
+	}
+	if err := s.repo.DeleteApprovalPolicy(apartmentID); err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to delete approval policy")
+		return fmt.Errorf("failed to delete approval policy: %w", err)
+	}
+	return nil
+}
+
+// whether the apartment's policy holds the bill for approval
+func (s *approvalServiceImpl) Requires(bill models.Bill) (bool, error) {
+	policy, err := s.policyOf(bill.ApartmentID)
+	if err != nil {
+		return false, err
+	}
+	return policy != nil && policy.Requires(bill), nil
+}
+
+// whether the bill's amount may be charged to the residents: it is under the threshold, it was never
+// held, or the last decision on it was an approval
+func (s *approvalServiceImpl) Approved(bill models.Bill) (bool, error) {
+	required, err := s.Requires(bill)
+	if err != nil || !required {
+		return !required, err
+	}
+	approvals, err := s.repo.GetApprovalsByBill(bill.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("bill_id", bill.ID).Error("Failed to get bill approvals")
+		return false, fmt.Errorf("failed to get bill approvals: %w", err)
+	}
+	return len(approvals) == 0 || approvals[len(approvals)-1].Decision == models.BillApproved, nil
+}
+
+// holds the bill for approval when the apartment's policy asks for it and tells the approvers,
+// returns whether it was held. bills under the threshold are left as they are
+func (s *approvalServiceImpl) RequestApproval(ctx context.Context, requesterID int, bill models.Bill) (bool, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": requesterID,
+		"bill_id": bill.ID,
+	})
+
+	required, err := s.Requires(bill)
+	if err != nil || !required {
+		return false, err
+	}
+
+	_, err = s.repo.RecordApproval(ctx, models.BillApproval{
+		BillID:   bill.ID,
+		UserID:   requesterID,
+		Decision: models.ApprovalRequested,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to request approval")
+		return false, fmt.Errorf("failed to request approval: %w", err)
+	}
+	logger.Info("Bill is waiting for approval")
+
+	s.NotifyApprovers(ctx, requesterID, bill)
+	return true, nil
+}
+
+// tells everyone who can decide on the held bill, except the requester
+func (s *approvalServiceImpl) NotifyApprovers(ctx context.Context, requesterID int, bill models.Bill) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": requesterID,
+		"bill_id": bill.ID,
+	})
+
+	policy, err := s.policyOf(bill.ApartmentID)
+	if err != nil || policy == nil {
+		logger.Warn("No approval policy to find approvers with")
+		return
+	}
+	approvers, err := s.approvers(ctx, bill.ApartmentID, policy, requesterID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to find approvers to notify")
+		return
+	}
+	message := fmt.Sprintf("*Bill waiting for approval*\n\nBill #%d (%s) of %s %s needs your approval before it can be divided.",
+		bill.ID, bill.BillType, bill.TotalAmount, bill.Currency)
+	for _, approverID := range approvers {
+		s.notify(ctx, approverID, message)
+	}
+}
+
+func (s *approvalServiceImpl) GetPendingApprovals(ctx context.Context, userID, apartmentID int) ([]PendingApproval, error) {
+	policy, err := s.policyOf(apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isApprover(ctx, userID, apartmentID, policy) {
+		return nil, fmt.Errorf("only managers and the representative can view pending approvals")
+	}
+
+	bills, err := s.billRepo.GetBillsByApartmentID(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get bills")
+		return nil, fmt.Errorf("failed to get bills: %w", err)
+	}
+
+	pending := []PendingApproval{}
+	for _, bill := range bills {
+		if bill.Status != models.BillPendingApproval {
+			continue
+		}
+		request, err := s.lastRequest(bill.ID)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, PendingApproval{
+			Bill:        bill,
+			RequestedBy: request.UserID,
+			RequestedAt: request.CreatedAt,
+			CanApprove:  request.UserID != userID,
+		})
+	}
+	return pending, nil
+}
+
+func (s *approvalServiceImpl) GetBillApprovals(ctx context.Context, userID, billID int) ([]models.BillApproval, error) {
+	bill, err := s.billRepo.GetBillByID(billID)
+	if err != nil {
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, bill.ApartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+
+	approvals, err := s.repo.GetApprovalsByBill(billID)
+	if err != nil {
+		logrus.WithError(err).WithField("bill_id", billID).Error("Failed to get bill approvals")
+		return nil, fmt.Errorf("failed to get bill approvals: %w", err)
+	}
+	return approvals, nil
+}
+
+func (s *approvalServiceImpl) UseBillDivider(divider BillDivider) {
+	s.divider = divider
+}
+
+// bills generated to be divided right away are divided once approved, and ones that already had
+// shares are re-divided at the approved amount
+func (s *approvalServiceImpl) ApproveBill(ctx context.Context, userID, billID int, comment string) (models.BillStatus, error) {
+	return s.decide(ctx, userID, billID, models.BillApproved, comment)
+}
+
+// the bill goes back to draft so the manager can fix it and publish it again. a bill that already
+// has shares goes back to divided and keeps its old charges until an edit of it is approved
+func (s *approvalServiceImpl) RejectBill(ctx context.Context, userID, billID int, comment string) (models.BillStatus, error) {
+	if comment == "" {
+		return "", fmt.Errorf("a comment is required to reject a bill")
+	}
+	return s.decide(ctx, userID, billID, models.BillRejected, comment)
+}
+
+func (s *approvalServiceImpl) decide(ctx context.Context, userID, billID int, decision models.ApprovalDecision, comment string) (models.BillStatus, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"bill_id":  billID,
+		"decision": decision,
+	})
+
+	bill, err := s.billRepo.GetBillByID(billID)
+	if err != nil {
+		return "", fmt.Errorf("bill not found: %w", err)
+	}
+	policy, err := s.policyOf(bill.ApartmentID)
+	if err != nil {
+		return "", err
+	}
+	if !s.isApprover(ctx, userID, bill.ApartmentID, policy) {
+		logger.Warn("User without approval rights attempted to decide on a bill")
+		return "", fmt.Errorf("only managers and the representative can approve bills")
+	}
+	if bill.Status != models.BillPendingApproval {
+		return "", fmt.Errorf("%w: bill %d is %s", repositories.ErrBillNotPendingApproval, billID, bill.Status)
+	}
+
+	request, err := s.lastRequest(billID)
+	if err != nil {
+		return "", err
+	}
+	if request.UserID == userID {
+		return "", fmt.Errorf("approval has to come from someone other than the manager who asked for it")
+	}
+
+	status, err := s.repo.RecordApproval(ctx, models.BillApproval{
+		BillID:   billID,
+		UserID:   userID,
+		Decision: decision,
+		Comment:  comment,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to record approval decision")
+		return "", fmt.Errorf("failed to record decision: %w", err)
+	}
+	logger.Info("Approval decision recorded")
+
+	divided := decision == models.BillApproved && s.divideApproved(ctx, logger, *bill, request.UserID, status)
+	if divided {
+		status = models.BillDivided
+	}
+
+	message := fmt.Sprintf("*Bill %s*\n\nBill #%d (%s) of %s %s was %s.", decision, bill.ID, bill.BillType, bill.TotalAmount, bill.Currency, decision)
+	switch {
+	case decision != models.BillApproved:
+	case divided:
+		message += " The residents were charged their shares."
+	case status == models.BillPublished:
+		message += " It can be divided now."
+	default:
+		message += " It can be re-divided now."
+	}
+	if comment != "" {
+		message += "\nComment: " + comment
+	}
+	s.notify(ctx, request.UserID, message)
+	return status, nil
+}
+
+// divides the approved bill when it was generated to be divided or re-divides it when it already had
+// shares, on behalf of the manager who asked for the approval. returns whether it did, a bill that
+// couldn't be divided stays approved for the managers to divide by hand
+func (s *approvalServiceImpl) divideApproved(ctx context.Context, logger *logrus.Entry, bill models.Bill, requesterID int, status models.BillStatus) bool {
+	if s.divider == nil {
+		return false
+	}
+	var err error
+	switch {
+	case status.Divided():
+		_, err = s.divider.RedivideBill(ctx, requesterID, bill.ID)
+	case bill.AutoDivide:
+		_, err = s.divider.DivideBill(ctx, requesterID, bill.ID)
+	default:
+		return false
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Failed to divide approved bill, it is left for manual division")
+		return false
+	}
+	return true
+}
+
+func (s *approvalServiceImpl) policyOf(apartmentID int) (*models.ApprovalPolicy, error) {
+	policy, err := s.repo.GetApprovalPolicy(apartmentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get approval policy")
+		return nil, fmt.Errorf("failed to get approval policy: %w", err)
+	}
+	return policy, nil
+}
+
+func (s *approvalServiceImpl) isApprover(ctx context.Context, userID, apartmentID int, policy *models.ApprovalPolicy) bool {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID); err == nil && ok {
+		return true
+	}
+	if policy == nil || policy.RepresentativeID == nil || *policy.RepresentativeID != userID {
+		return false
+	}
+	ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID)
+	return err == nil && ok
+}
+
+// the current managers and the representative, without the requester
+func (s *approvalServiceImpl) approvers(ctx context.Context, apartmentID int, policy *models.ApprovalPolicy, requesterID int) ([]int, error) {
+	members, err := s.userApartmentRepo.GetMembershipsInApartment(apartmentID)
+	if err != nil {
+		return nil, err
+	}
+	var approvers []int
+	for _, member := range members {
+		if member.UserID == requesterID {
+			continue
+		}
+		if member.IsManager || (policy.RepresentativeID != nil && member.UserID == *policy.RepresentativeID) {
+			approvers = append(approvers, member.UserID)
+		}
+	}
+	return approvers, nil
+}
+
+func (s *approvalServiceImpl) lastRequest(billID int) (*models.BillApproval, error) {
+	approvals, err := s.repo.GetApprovalsByBill(billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bill approvals: %w", err)
+	}
+	for i := len(approvals) - 1; i >= 0; i-- {
+		if approvals[i].Decision == models.ApprovalRequested {
+			return &approvals[i], nil
+		}
+	}
+	return nil, fmt.Errorf("bill %d has no approval request", billID)
+}
+
+func (s *approvalServiceImpl) notify(ctx context.Context, userID int, message string) {
+	if err := s.notificationService.SendNotification(ctx, userID, message); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to send approval notification")
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockApprovalService struct {
+	mock.Mock
+}
+
+func (m *MockApprovalService) SetApprovalPolicy(ctx context.Context, managerID, apartmentID int, req dto.ApprovalPolicyRequest) (*models.ApprovalPolicy, error) {
+	args := m.Called(ctx, managerID, apartmentID, req)
+	if policy, ok := args.Get(0).(*models.ApprovalPolicy); ok {
+		return policy, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockApprovalService) GetApprovalPolicy(ctx context.Context, userID, apartmentID int) (*models.ApprovalPolicy, error) {
+	args := m.Called(ctx, userID, apartmentID)
+	if policy, ok := args.Get(0).(*models.ApprovalPolicy); ok {
+		return policy, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockApprovalService) DeleteApprovalPolicy(ctx context.Context, managerID, apartmentID int) error {
+	args := m.Called(ctx, managerID, apartmentID)
+	return args.Error(0)
+}
+
+func (m *MockApprovalService) Requires(bill models.Bill) (bool, error) {
+	args := m.Called(bill)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockApprovalService) Approved(bill models.Bill) (bool, error) {
+	args := m.Called(bill)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockApprovalService) NotifyApprovers(ctx context.Context, requesterID int, bill models.Bill) {
+	m.Called(ctx, requesterID, bill)
+}
+
+func (m *MockApprovalService) RequestApproval(ctx context.Context, requesterID int, bill models.Bill) (bool, error) {
+	args := m.Called(ctx, requesterID, bill)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockApprovalService) GetPendingApprovals(ctx context.Context, userID, apartmentID int) ([]PendingApproval, error) {
+	args := m.Called(ctx, userID, apartmentID)
+	if pending, ok := args.Get(0).([]PendingApproval); ok {
+		return pending, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockApprovalService) GetBillApprovals(ctx context.Context, userID, billID int) ([]models.BillApproval, error) {
+	args := m.Called(ctx, userID, billID)
+	if approvals, ok := args.Get(0).([]models.BillApproval); ok {
+		return approvals, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockApprovalService) ApproveBill(ctx context.Context, userID, billID int, comment string) (models.BillStatus, error) {
+	args := m.Called(ctx, userID, billID, comment)
+	return args.Get(0).(models.BillStatus), args.Error(1)
+}
+
+func (m *MockApprovalService) UseBillDivider(divider BillDivider) {
+	m.Called(divider)
+}
+
+func (m *MockApprovalService) RejectBill(ctx context.Context, userID, billID int, comment string) (models.BillStatus, error) {
+	args := m.Called(ctx, userID, billID, comment)
+	return args.Get(0).(models.BillStatus), args.Error(1)
+}
+
+type MockBillDivider struct {
+	mock.Mock
+}
+
+func (m *MockBillDivider) DivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error) {
+	args := m.Called(ctx, userID, billID)
+	if response, ok := args.Get(0).(map[string]interface{}); ok {
+		return response, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockBillDivider) RedivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error) {
+	args := m.Called(ctx, userID, billID)
+	if response, ok := args.Get(0).(map[string]interface{}); ok {
+		return response, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
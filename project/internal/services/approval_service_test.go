@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSetApprovalPolicy(t *testing.T) {
+	representative := 3
+	members := []models.User_apartment{
+		{UserID: 1, ApartmentID: 7, IsManager: true},
+		{UserID: 3, ApartmentID: 7},
+	}
+
+	tests := []struct {
+		name          string
+		req           dto.ApprovalPolicyRequest
+		expectedError string
+	}{
+		{name: "representative approves", req: dto.ApprovalPolicyRequest{Threshold: 10000000, RepresentativeID: &representative}},
+		{name: "single manager without a representative", req: dto.ApprovalPolicyRequest{Threshold: 10000000}, expectedError: "second manager or a representative"},
+		{name: "representative from another apartment", req: dto.ApprovalPolicyRequest{Threshold: 10000000, RepresentativeID: new(int)}, expectedError: "member of the apartment"},
+		{name: "no threshold", req: dto.ApprovalPolicyRequest{RepresentativeID: &representative}, expectedError: "threshold must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockApprovalRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockUserAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+			mockRepo.On("UpsertApprovalPolicy", mock.Anything, mock.Anything).Return(2, nil)
+
+			service := NewApprovalService(mockRepo, nil, mockUserAptRepo, nil)
+			policy, err := service.SetApprovalPolicy(context.Background(), 1, 7, tt.req)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "UpsertApprovalPolicy", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 2, policy.ID)
+			assert.Equal(t, money.DefaultCurrency, policy.Currency)
+		})
+	}
+}
+
+func TestRequestApproval(t *testing.T) {
+	representative := 3
+	policy := &models.ApprovalPolicy{ApartmentID: 7, Threshold: 10000000, Currency: money.IRR, RepresentativeID: &representative}
+	members := []models.User_apartment{
+		{UserID: 1, ApartmentID: 7, IsManager: true},
+		{UserID: 2, ApartmentID: 7, IsManager: true},
+		{UserID: 3, ApartmentID: 7},
+		{UserID: 4, ApartmentID: 7},
+	}
+
+	t.Run("bill under the threshold is not held", func(t *testing.T) {
+		mockRepo := new(repositories.MockApprovalRepository)
+		mockRepo.On("GetApprovalPolicy", 7).Return(policy, nil)
+
+		service := NewApprovalService(mockRepo, nil, nil, nil)
+		held, err := service.RequestApproval(context.Background(), 1, models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, TotalAmount: 9999999, Currency: money.IRR})
+
+		assert.NoError(t, err)
+		assert.False(t, held)
+		mockRepo.AssertNotCalled(t, "RecordApproval", mock.Anything, mock.Anything)
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		mockRepo := new(repositories.MockApprovalRepository)
+		mockRepo.On("GetApprovalPolicy", 7).Return(nil, sql.ErrNoRows)
+
+		service := NewApprovalService(mockRepo, nil, nil, nil)
+		held, err := service.RequestApproval(context.Background(), 1, models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, TotalAmount: 90000000, Currency: money.IRR})
+
+		assert.NoError(t, err)
+		assert.False(t, held)
+	})
+
+	t.Run("large bill waits and the other approvers are told", func(t *testing.T) {
+		mockRepo := new(repositories.MockApprovalRepository)
+		mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+		mockNotificationService := new(notification.MockNotification)
+		mockRepo.On("GetApprovalPolicy", 7).Return(policy, nil)
+		mockRepo.On("RecordApproval", mock.Anything, models.BillApproval{BillID: 11, UserID: 1, Decision: models.ApprovalRequested}).Return(models.BillPendingApproval, nil)
+		mockUserAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+		mockNotificationService.On("SendNotification", mock.Anything, 2, mock.Anything).Return(nil).Once()
+		mockNotificationService.On("SendNotification", mock.Anything, 3, mock.Anything).Return(nil).Once()
+
+		service := NewApprovalService(mockRepo, nil, mockUserAptRepo, mockNotificationService)
+		held, err := service.RequestApproval(context.Background(), 1, models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, TotalAmount: 10000000, Currency: money.IRR})
+
+		assert.NoError(t, err)
+		assert.True(t, held)
+		mockRepo.AssertExpectations(t)
+		mockNotificationService.AssertExpectations(t)
+	})
+}
+
+func TestApprovedBill(t *testing.T) {
+	policy := &models.ApprovalPolicy{ApartmentID: 7, Threshold: 10000000, Currency: money.IRR}
+	large := models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, TotalAmount: 20000000, Currency: money.IRR}
+
+	tests := []struct {
+		name      string
+		bill      models.Bill
+		approvals []models.BillApproval
+		expected  bool
+	}{
+		{name: "under the threshold", bill: models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, TotalAmount: 5000000, Currency: money.IRR}, expected: true},
+		{name: "never held", bill: large, approvals: []models.BillApproval{}, expected: true},
+		{name: "approved", bill: large, approvals: []models.BillApproval{
+			{BillID: 11, UserID: 1, Decision: models.ApprovalRequested},
+			{BillID: 11, UserID: 2, Decision: models.BillApproved},
+		}, expected: true},
+		{name: "last edit rejected", bill: large, approvals: []models.BillApproval{
+			{BillID: 11, UserID: 1, Decision: models.ApprovalRequested},
+			{BillID: 11, UserID: 2, Decision: models.BillApproved},
+			{BillID: 11, UserID: 1, Decision: models.ApprovalRequested},
+			{BillID: 11, UserID: 2, Decision: models.BillRejected},
+		}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockApprovalRepository)
+			mockRepo.On("GetApprovalPolicy", 7).Return(policy, nil)
+			mockRepo.On("GetApprovalsByBill", 11).Return(tt.approvals, nil)
+
+			service := NewApprovalService(mockRepo, nil, nil, nil)
+			approved, err := service.Approved(tt.bill)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, approved)
+		})
+	}
+}
+
+func TestApproveBill(t *testing.T) {
+	representative := 3
+	policy := &models.ApprovalPolicy{ApartmentID: 7, Threshold: 10000000, Currency: money.IRR, RepresentativeID: &representative}
+	pending := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 20000000, Currency: money.IRR, Status: models.BillPendingApproval}
+	approvals := []models.BillApproval{{ID: 5, BillID: 11, UserID: 1, Decision: models.ApprovalRequested}}
+
+	tests := []struct {
+		name          string
+		userID        int
+		bill          *models.Bill
+		expectedError string
+	}{
+		{name: "representative approves", userID: 3, bill: pending},
+		{name: "requester can't approve their own bill", userID: 1, bill: pending, expectedError: "someone other than the manager"},
+		{name: "regular resident", userID: 4, bill: pending, expectedError: "only managers and the representative"},
+		{name: "bill that is not waiting", userID: 3, bill: &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, Status: models.BillPublished}, expectedError: "not waiting for approval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockApprovalRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockNotificationService := new(notification.MockNotification)
+			mockBillRepo.On("GetBillByID", 11).Return(tt.bill, nil)
+			mockRepo.On("GetApprovalPolicy", 7).Return(policy, nil)
+			mockRepo.On("GetApprovalsByBill", 11).Return(approvals, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, mock.Anything, 7).Return(false, nil)
+			mockUserAptRepo.On("IsUserInApartment", mock.Anything, mock.Anything, 7).Return(true, nil)
+			mockRepo.On("RecordApproval", mock.Anything, mock.MatchedBy(func(a models.BillApproval) bool {
+				return a.BillID == 11 && a.UserID == tt.userID && a.Decision == models.BillApproved
+			})).Return(models.BillPublished, nil)
+			mockNotificationService.On("SendNotification", mock.Anything, 1, mock.Anything).Return(nil)
+
+			service := NewApprovalService(mockRepo, mockBillRepo, mockUserAptRepo, mockNotificationService)
+			status, err := service.ApproveBill(context.Background(), tt.userID, 11, "")
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "RecordApproval", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, models.BillPublished, status)
+			mockRepo.AssertExpectations(t)
+			mockNotificationService.AssertNumberOfCalls(t, "SendNotification", 1)
+		})
+	}
+}
+
+func TestApproveBill_Divides(t *testing.T) {
+	representative := 3
+	policy := &models.ApprovalPolicy{ApartmentID: 7, Threshold: 10000000, Currency: money.IRR, RepresentativeID: &representative}
+	approvals := []models.BillApproval{{ID: 5, BillID: 11, UserID: 1, Decision: models.ApprovalRequested}}
+	held := func(autoDivide bool) *models.Bill {
+		return &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, TotalAmount: 20000000, Currency: money.IRR, Status: models.BillPendingApproval, AutoDivide: autoDivide}
+	}
+
+	tests := []struct {
+		name           string
+		bill           *models.Bill
+		recorded       models.BillStatus
+		setupDivider   func(*MockBillDivider)
+		expectedStatus models.BillStatus
+	}{
+		{
+			name:     "generated bill is divided for the manager who asked",
+			bill:     held(true),
+			recorded: models.BillPublished,
+			setupDivider: func(divider *MockBillDivider) {
+				divider.On("DivideBill", mock.Anything, 1, 11).Return(map[string]interface{}{}, nil).Once()
+			},
+			expectedStatus: models.BillDivided,
+		},
+		{
+			name:     "raised divided bill is re-divided",
+			bill:     held(false),
+			recorded: models.BillDivided,
+			setupDivider: func(divider *MockBillDivider) {
+				divider.On("RedivideBill", mock.Anything, 1, 11).Return(map[string]interface{}{}, nil).Once()
+			},
+			expectedStatus: models.BillDivided,
+		},
+		{
+			name:           "manual bill waits for a manager to divide it",
+			bill:           held(false),
+			recorded:       models.BillPublished,
+			setupDivider:   func(divider *MockBillDivider) {},
+			expectedStatus: models.BillPublished,
+		},
+		{
+			name:     "failed division leaves the bill approved",
+			bill:     held(true),
+			recorded: models.BillPublished,
+			setupDivider: func(divider *MockBillDivider) {
+				divider.On("DivideBill", mock.Anything, 1, 11).Return(nil, errors.New("no residents")).Once()
+			},
+			expectedStatus: models.BillPublished,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockApprovalRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockNotificationService := new(notification.MockNotification)
+			mockDivider := new(MockBillDivider)
+			mockBillRepo.On("GetBillByID", 11).Return(tt.bill, nil)
+			mockRepo.On("GetApprovalPolicy", 7).Return(policy, nil)
+			mockRepo.On("GetApprovalsByBill", 11).Return(approvals, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 3, 7).Return(false, nil)
+			mockUserAptRepo.On("IsUserInApartment", mock.Anything, 3, 7).Return(true, nil)
+			mockRepo.On("RecordApproval", mock.Anything, mock.Anything).Return(tt.recorded, nil)
+			mockNotificationService.On("SendNotification", mock.Anything, 1, mock.Anything).Return(nil)
+			tt.setupDivider(mockDivider)
+
+			service := NewApprovalService(mockRepo, mockBillRepo, mockUserAptRepo, mockNotificationService)
+			service.UseBillDivider(mockDivider)
+			status, err := service.ApproveBill(context.Background(), 3, 11, "")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, status)
+			mockDivider.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRejectBill_NeedsComment(t *testing.T) {
+	mockRepo := new(repositories.MockApprovalRepository)
+
+	service := NewApprovalService(mockRepo, nil, nil, nil)
+	_, err := service.RejectBill(context.Background(), 3, 11, "")
+
+	assert.ErrorContains(t, err, "comment is required")
+	mockRepo.AssertNotCalled(t, "RecordApproval", mock.Anything, mock.Anything)
+}
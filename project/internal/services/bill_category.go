@@ -0,0 +1,84 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+)
+
+var billCategoryName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// the apartment's own category for the bill type, or the built-in one. types that are neither are rejected
+func findBillCategory(repo repositories.BillCategoryRepository, apartmentID int, billType models.BillType) (*models.BillCategory, error) {
+	builtIn, isBuiltIn := models.BuiltInBillCategory(billType)
+
+	category, err := repo.GetBillCategory(apartmentID, billType)
+	if err == nil {
+		category.BuiltIn = isBuiltIn
+		return category, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get bill category: %w", err)
+	}
+	if !isBuiltIn {
+		return nil, fmt.Errorf("invalid bill type %q, add it as a bill category first", billType)
+	}
+	builtIn.ApartmentID = apartmentID
+	return &builtIn, nil
+}
+
+// the built-in categories with the apartment's changes applied, followed by its own categories
+func mergeBillCategories(apartmentID int, stored []models.BillCategory) []models.BillCategory {
+	byName := make(map[models.BillType]models.BillCategory, len(stored))
+	for _, category := range stored {
+		byName[category.Name] = category
+	}
+
+	categories := make([]models.BillCategory, 0, len(models.BuiltInBillCategories)+len(stored))
+	for _, builtIn := range models.BuiltInBillCategories {
+		category, ok := byName[builtIn.Name]
+		if !ok {
+			category = builtIn
+			category.ApartmentID = apartmentID
+		}
+		category.BuiltIn = true
+		categories = append(categories, category)
+		delete(byName, builtIn.Name)
+	}
+
+	var custom []models.BillCategory
+	for _, category := range byName {
+		custom = append(custom, category)
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i].Name < custom[j].Name })
+	return append(categories, custom...)
+}
+
+func validateBillCategory(category models.BillCategory) error {
+	if !billCategoryName.MatchString(string(category.Name)) {
+		return fmt.Errorf("category name must start with a lowercase letter and only use lowercase letters, digits and underscores")
+	}
+	if utf8.RuneCountInString(category.Icon) > 16 {
+		return fmt.Errorf("icon is too long")
+	}
+	if category.DueAfterDays < 0 || category.DueAfterDays > 365 {
+		return fmt.Errorf("due days must be between 0 and 365")
+	}
+
+	switch category.DefaultStrategy {
+	case "":
+	case models.SplitMixed:
+		return fmt.Errorf("a mixed split needs weights, set it as the split policy of the bill type instead")
+	default:
+		if err := validateSplitPolicy(models.SplitPolicy{BillType: category.Name, Strategy: category.DefaultStrategy}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
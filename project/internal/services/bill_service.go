@@ -2,18 +2,19 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"strconv"
+	"sort"
 	"time"
 
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
-	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/payment"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
 	"github.com/sirupsen/logrus"
 )
@@ -22,21 +23,62 @@ type BillService interface {
 	CreateBill(ctx context.Context, userID, apartmentID int, req dto.CreateBillRequest, file io.ReadCloser, handler *multipart.FileHeader) (map[string]interface{}, error)
 	GetBillByID(ctx context.Context, id int) (map[string]interface{}, error)
 	GetBillsByApartmentID(ctx context.Context, apartmentID int) ([]models.Bill, error)
-	UpdateBill(ctx context.Context, id, apartmentID int, billType string, totalAmount float64, dueDate, billingDeadline, description string) error
-	DeleteBill(ctx context.Context, id int) error
-	PayBills(ctx context.Context, userID int, paymentIDs []int, idempotentKey string) error
-	PayBatchBills(ctx context.Context, userID int, idempotentKey string) (map[string]interface{}, error)
-	GetUnpaidBills(ctx context.Context, userID int) ([]models.Payment, error)
+	GetResidentBills(ctx context.Context, userID, apartmentID int) ([]models.Bill, error)
+	PublishBill(ctx context.Context, userID, billID int) (models.BillStatus, error)
+	CancelBill(ctx context.Context, userID, billID int) (*models.JournalEntry, error)
+	UpdateBill(ctx context.Context, userID, id, apartmentID int, billType string, totalAmount money.Amount, dueDate, billingDeadline, description string, lineItems []dto.LineItemRequest) error
+	DeleteBill(ctx context.Context, userID, id int) error
+	PayBills(ctx context.Context, userID int, paymentIDs []int, fromWallet bool, idempotentKey string) (*models.PaymentTransaction, error)
+	PayBatchBills(ctx context.Context, userID int, fromWallet bool, idempotentKey string) (map[string]interface{}, error)
+	PayPartial(ctx context.Context, userID, paymentID int, amount money.Amount, idempotentKey string) (*models.PaymentTransaction, error)
+	GetUnpaidBills(ctx context.Context, userID int) ([]UnpaidPayment, error)
 	GetBillWithPaymentStatus(ctx context.Context, userID, billID int) (map[string]interface{}, error)
+	GetBillDetail(ctx context.Context, userID, billID int) (*BillDetail, error)
 	GetUserPaymentHistory(ctx context.Context, userID int) ([]PaymentHistoryItem, error)
-	DivideBillByType(ctx context.Context, userID, apartmentID int, billType models.BillType) (map[string]interface{}, error)
-	DivideAllBills(ctx context.Context, userID, apartmentID int) (map[string]interface{}, error)
+	GetPaymentEvents(ctx context.Context, userID, paymentID int) ([]models.PaymentEvent, error)
+	DivideBillByType(ctx context.Context, userID, apartmentID int, billType models.BillType, dryRun bool) (map[string]interface{}, error)
+	DivideAllBills(ctx context.Context, userID, apartmentID int, dryRun bool) (map[string]interface{}, error)
+	DivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error)
+	RedivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error)
+	SetSplitPolicy(ctx context.Context, userID, apartmentID int, req dto.SplitPolicyRequest) (*models.SplitPolicy, error)
+	GetSplitPolicies(ctx context.Context, userID, apartmentID int) ([]models.SplitPolicy, error)
+	SetBillCategory(ctx context.Context, userID, apartmentID int, req dto.BillCategoryRequest) (*models.BillCategory, error)
+	GetBillCategories(ctx context.Context, userID, apartmentID int) ([]models.BillCategory, error)
+	DeleteBillCategory(ctx context.Context, userID, apartmentID int, name models.BillType) error
 }
 
 type PaymentHistoryItem struct {
-	Bill          models.Bill    `json:"bill"`
-	Payment       models.Payment `json:"payment"`
-	ApartmentName string         `json:"apartment_name"`
+	Bill          models.Bill      `json:"bill"`
+	Payment       models.Payment   `json:"payment"`
+	Penalties     []models.Payment `json:"penalties,omitempty"`
+	ApartmentName string           `json:"apartment_name"`
+}
+
+// a bill as its residents see it, with the receipt and how it was split between them
+type BillDetail struct {
+	Bill          models.Bill          `json:"bill"`
+	ReceiptURL    string               `json:"receipt_url,omitempty"`
+	SplitStrategy models.SplitStrategy `json:"split_strategy"`
+	Shares        []ResidentShare      `json:"shares"`
+}
+
+// one resident's part of a bill with the adjustments made to it after edits. apartments that keep
+// shares anonymous only number the other residents
+type ResidentShare struct {
+	UserID     *int                   `json:"user_id,omitempty"`
+	Name       string                 `json:"name"`
+	Mine       bool                   `json:"mine"`
+	Amount     money.Amount           `json:"amount"`
+	AmountPaid money.Amount           `json:"amount_paid"`
+	Status     models.PaymentStatus   `json:"status"`
+	Breakdown  []models.ShareLineItem `json:"breakdown,omitempty"`
+}
+
+// a pending payment with the late fees charged on it
+type UnpaidPayment struct {
+	models.Payment
+	Penalties []models.Payment `json:"penalties,omitempty"`
+	TotalDue  money.Amount     `json:"total_due"`
 }
 
 type billServiceImpl struct {
@@ -45,8 +87,13 @@ type billServiceImpl struct {
 	apartmentRepo       repositories.ApartmentRepository
 	userApartmentRepo   repositories.UserApartmentRepository
 	paymentRepo         repositories.PaymentRepository
+	splitPolicyRepo     repositories.SplitPolicyRepository
+	categoryRepo        repositories.BillCategoryRepository
+	meterRepo           repositories.MeterRepository
 	imageService        image.Image
-	paymentService      payment.Payment
+	checkoutService     CheckoutService
+	walletService       WalletService
+	approvalService     ApprovalService
 	notificationService notification.Notification
 }
 
@@ -56,8 +103,13 @@ func NewBillService(
 	apartmentRepo repositories.ApartmentRepository,
 	userApartmentRepo repositories.UserApartmentRepository,
 	paymentRepo repositories.PaymentRepository,
+	splitPolicyRepo repositories.SplitPolicyRepository,
+	categoryRepo repositories.BillCategoryRepository,
+	meterRepo repositories.MeterRepository,
 	imageService image.Image,
-	paymentService payment.Payment,
+	checkoutService CheckoutService,
+	walletService WalletService,
+	approvalService ApprovalService,
 	notificationService notification.Notification,
 ) BillService {
 	return &billServiceImpl{
@@ -66,8 +118,13 @@ func NewBillService(
 		apartmentRepo:       apartmentRepo,
 		userApartmentRepo:   userApartmentRepo,
 		paymentRepo:         paymentRepo,
+		splitPolicyRepo:     splitPolicyRepo,
+		categoryRepo:        categoryRepo,
+		meterRepo:           meterRepo,
 		imageService:        imageService,
-		paymentService:      paymentService,
+		checkoutService:     checkoutService,
+		walletService:       walletService,
+		approvalService:     approvalService,
 		notificationService: notificationService,
 	}
 }
@@ -99,21 +156,39 @@ func (s *billServiceImpl) CreateBill(ctx context.Context, userID, apartmentID in
 		return nil, fmt.Errorf("only apartment managers can create bills")
 	}
 
-	if req.BillType == "" || req.TotalAmount <= 0 || req.DueDate == "" {
+	if req.BillType == "" || (req.TotalAmount <= 0 && len(req.LineItems) == 0) {
+		logger.Error("Missing required fields for bill creation")
+		return nil, fmt.Errorf("missing required fields")
+	}
+
+	category, err := findBillCategory(s.categoryRepo, apartmentID, req.BillType)
+	if err != nil {
+		logger.WithError(err).WithField("provided_type", req.BillType).Error("Invalid bill type provided")
+		return nil, err
+	}
+
+	//categories with a due-day offset let the manager leave out the due date
+	if req.DueDate == "" && category.DueAfterDays > 0 {
+		req.DueDate = time.Now().AddDate(0, 0, category.DueAfterDays).Format("2006-01-02")
+	}
+	if req.DueDate == "" {
 		logger.Error("Missing required fields for bill creation")
 		return nil, fmt.Errorf("missing required fields")
 	}
 
-	validBillTypes := map[models.BillType]bool{
-		models.WaterBill:       true,
-		models.ElectricityBill: true,
-		models.GasBill:         true,
-		models.MaintenanceBill: true,
-		models.OtherBill:       true,
+	lineItems, totalAmount, err := billLineItems(req.BillType, req.TotalAmount, req.LineItems)
+	if err != nil {
+		logger.WithError(err).Error("Invalid line items")
+		return nil, err
+	}
+	req.TotalAmount = totalAmount
+
+	if req.Currency == "" {
+		req.Currency = money.DefaultCurrency
 	}
-	if !validBillTypes[models.BillType(req.BillType)] {
-		logger.WithField("provided_type", req.BillType).Error("Invalid bill type provided")
-		return nil, fmt.Errorf("invalid bill type")
+	if !req.Currency.Valid() {
+		logger.WithField("provided_currency", req.Currency).Error("Invalid currency provided")
+		return nil, fmt.Errorf("invalid currency (use a three letter ISO code)")
 	}
 
 	if _, err := time.Parse("2006-01-02", req.DueDate); err != nil {
@@ -127,6 +202,12 @@ func (s *billServiceImpl) CreateBill(ctx context.Context, userID, apartmentID in
 		}
 	}
 
+	periodStart, periodEnd, err := parseBillingPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		logger.WithError(err).Error("Invalid billing period")
+		return nil, err
+	}
+
 	var imageKey string
 	if file != nil {
 		logger.Debug("Processing image upload")
@@ -153,13 +234,18 @@ func (s *billServiceImpl) CreateBill(ctx context.Context, userID, apartmentID in
 		ApartmentID:     apartmentID,
 		BillType:        models.BillType(req.BillType),
 		TotalAmount:     req.TotalAmount,
+		Currency:        req.Currency,
 		DueDate:         req.DueDate,
 		BillingDeadline: req.BillingDeadline,
+		PeriodStart:     periodStart,
+		PeriodEnd:       periodEnd,
 		Description:     req.Description,
 		ImageURL:        "http://localhost:9000/mybucket/" + imageKey,
+		Status:          models.BillDraft,
+		LineItems:       lineItems,
 	}
 
-	billID, err := s.repo.CreateBill(ctx, bill)
+	billID, err := s.repo.CreateBill(ctx, bill, billJournalEntry(bill, models.BillEntry, bill.TotalAmount))
 	if err != nil {
 		logger.WithError(err).Error("Failed to create bill in database")
 		if imageKey != "" {
@@ -171,22 +257,96 @@ func (s *billServiceImpl) CreateBill(ctx context.Context, userID, apartmentID in
 	}
 
 	logger.WithField("bill_id", billID).Info("Bill created successfully")
+	bill.ID = billID
 
 	response := map[string]interface{}{
 		"id":             billID,
 		"total_amount":   req.TotalAmount,
+		"currency":       req.Currency,
+		"line_items":     len(lineItems),
 		"image_uploaded": imageKey != "",
-		"status":         "Bill created successfully. Use divide endpoints to create payment records for residents.",
+		"bill_status":    bill.Status,
+		"status":         "Bill created as a draft. Publish it, then use divide endpoints to create payment records for residents.",
 	}
 
 	return response, nil
 }
 
-func (s *billServiceImpl) DivideBillByType(ctx context.Context, userID, apartmentID int, billType models.BillType) (map[string]interface{}, error) {
+// both dates are optional together, a bill without a period is split between the current members
+func parseBillingPeriod(start, end string) (*time.Time, *time.Time, error) {
+	if start == "" && end == "" {
+		return nil, nil, nil
+	}
+	if start == "" || end == "" {
+		return nil, nil, fmt.Errorf("billing period needs both a start and an end date")
+	}
+	periodStart, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid period start format (use YYYY-MM-DD)")
+	}
+	periodEnd, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid period end format (use YYYY-MM-DD)")
+	}
+	if periodEnd.Before(periodStart) {
+		return nil, nil, fmt.Errorf("billing period ends before it starts")
+	}
+	return &periodStart, &periodEnd, nil
+}
+
+// checks the requested line items and returns them with the bill total they add up to. a total that
+// was given as well has to match, a bill without line items keeps its total
+func billLineItems(billType models.BillType, total money.Amount, requested []dto.LineItemRequest) ([]models.BillLineItem, money.Amount, error) {
+	if len(requested) == 0 {
+		return nil, total, nil
+	}
+
+	items := make([]models.BillLineItem, 0, len(requested))
+	for i, req := range requested {
+		if req.Name == "" {
+			return nil, 0, fmt.Errorf("line item %d needs a name", i+1)
+		}
+		if req.Amount <= 0 {
+			return nil, 0, fmt.Errorf("line item %q needs a positive amount", req.Name)
+		}
+		if req.Category == "" {
+			req.Category = models.OtherCharge
+		}
+		if !models.LineItemCategories[req.Category] {
+			return nil, 0, fmt.Errorf("invalid category %q for line item %q", req.Category, req.Name)
+		}
+		switch req.SplitStrategy {
+		case "":
+		case models.SplitMixed:
+			return nil, 0, fmt.Errorf("line item %q can't use a mixed split, set it on the split policy of the bill type instead", req.Name)
+		default:
+			if err := validateSplitPolicy(models.SplitPolicy{BillType: billType, Strategy: req.SplitStrategy}); err != nil {
+				return nil, 0, fmt.Errorf("line item %q: %w", req.Name, err)
+			}
+		}
+		items = append(items, models.BillLineItem{
+			Name:          req.Name,
+			Category:      req.Category,
+			Amount:        req.Amount,
+			SplitStrategy: req.SplitStrategy,
+		})
+	}
+
+	sum := models.LineItemsTotal(items)
+	if total != 0 && total != sum {
+		return nil, 0, fmt.Errorf("total amount %s doesn't match the line items, they add up to %s", total, sum)
+	}
+	return items, sum, nil
+}
+
+// each bill is divided in its own transaction, so a failure leaves that bill untouched and the
+// others divided. a dry run returns the shares that would be created without writing anything
+func (s *billServiceImpl) DivideBillByType(ctx context.Context, userID, apartmentID int, billType models.BillType, dryRun bool) (map[string]interface{}, error) {
 	logger := logrus.WithFields(logrus.Fields{
 		"user_id":      userID,
 		"apartment_id": apartmentID,
 		"bill_type":    billType,
+		"dry_run":      dryRun,
 	})
 
 	logger.Info("Starting bill division by type")
@@ -201,17 +361,17 @@ func (s *billServiceImpl) DivideBillByType(ctx context.Context, userID, apartmen
 		return nil, fmt.Errorf("only apartment managers can divide bills")
 	}
 
-	residents, err := s.userApartmentRepo.GetResidentsInApartment(apartmentID)
+	members, err := s.userApartmentRepo.GetMembershipsInApartment(apartmentID)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get residents")
 		return nil, fmt.Errorf("failed to get residents: %w", err)
 	}
-	if len(residents) == 0 {
+	if len(members) == 0 {
 		logger.Warn("No residents found in apartment")
 		return nil, fmt.Errorf("no residents found in apartment")
 	}
 
-	logger.WithField("residents_count", len(residents)).Debug("Retrieved residents for bill division")
+	logger.WithField("residents_count", len(members)).Debug("Retrieved residents for bill division")
 
 	//bills of specific type that haven't been divided yet
 	bills, err := s.repo.GetUndividedBillsByTypeAndApartment(apartmentID, billType)
@@ -224,87 +384,70 @@ func (s *billServiceImpl) DivideBillByType(ctx context.Context, userID, apartmen
 		return nil, fmt.Errorf("no undivided bills of type %s found", billType)
 	}
 
-	logger.WithField("bills_count", len(bills)).Info("Processing undivided bills")
-
-	var processedBills []int
-	var failedBills []int
-	var totalFailedPayments int
-
+	policy := s.resolveSplitPolicy(apartmentID, billType)
+	sharesByBill := make(map[int][]models.Payment, len(bills))
 	for _, bill := range bills {
-		billLogger := logger.WithFields(logrus.Fields{
-			"bill_id":     bill.ID,
-			"bill_amount": bill.TotalAmount,
-		})
-
-		amountPerResident := bill.TotalAmount / float64(len(residents))
-		billProcessed := true
+		sharesByBill[bill.ID], err = s.splitBill(bill, policy, members)
+		if err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"bill_id":  bill.ID,
+				"strategy": policy.Strategy,
+			}).Error("Failed to compute share weights")
+			return nil, fmt.Errorf("failed to apply %s split: %w", policy.Strategy, err)
+		}
+	}
 
-		for _, resident := range residents {
-			//checking if payment record already exists
-			existingPayment, _ := s.paymentRepo.GetPaymentByBillAndUser(bill.ID, resident.ID)
-			if existingPayment != nil {
-				continue // if payment record already exists
-			}
+	response := map[string]interface{}{
+		"bill_type":       billType,
+		"split_strategy":  policy.Strategy,
+		"residents_count": len(members),
+	}
 
-			payment := models.Payment{
-				BaseModel: models.BaseModel{
-					CreatedAt: time.Now(),
-					UpdatedAt: time.Now(),
-				},
-				BillID:        bill.ID,
-				UserID:        resident.ID,
-				Amount:        fmt.Sprintf("%.2f", amountPerResident),
-				PaymentStatus: models.Pending,
-			}
+	if dryRun {
+		response["dry_run"] = true
+		response["shares"] = plannedShares(bills, sharesByBill)
+		return response, nil
+	}
 
-			_, err := s.paymentRepo.CreatePayment(ctx, payment)
-			if err != nil {
-				billLogger.WithError(err).WithField("resident_id", resident.ID).Error("Failed to create payment record")
-				totalFailedPayments++
-				billProcessed = false
-				continue
-			}
+	logger.WithFields(logrus.Fields{
+		"bills_count": len(bills),
+		"strategy":    policy.Strategy,
+	}).Info("Processing undivided bills")
 
-			//sending notification
-			if err := s.notificationService.SendBillNotification(ctx, resident.ID, bill, amountPerResident); err != nil {
-				billLogger.WithError(err).WithField("resident_id", resident.ID).Warn("Failed to send notification")
-			}
-		}
+	var processedBills []int
+	var skippedBills []int
+	var failedBills []int
 
-		if billProcessed {
+	for _, bill := range bills {
+		_, err := s.divideShares(ctx, logger, bill, sharesByBill[bill.ID])
+		switch {
+		case err == nil:
 			processedBills = append(processedBills, bill.ID)
-			billLogger.Debug("Bill processed successfully")
-		} else {
+		case errors.Is(err, repositories.ErrBillAlreadyDivided), errors.Is(err, repositories.ErrBillNotDivisible):
+			skippedBills = append(skippedBills, bill.ID)
+		default:
 			failedBills = append(failedBills, bill.ID)
-			billLogger.Error("Bill processing failed")
 		}
 	}
 
 	logger.WithFields(logrus.Fields{
 		"processed_count": len(processedBills),
+		"skipped_count":   len(skippedBills),
 		"failed_count":    len(failedBills),
 	}).Info("Bill division completed")
 
-	response := map[string]interface{}{
-		"bill_type":       billType,
-		"residents_count": len(residents),
-		"processed_bills": processedBills,
-		"processed_count": len(processedBills),
-	}
-
-	if len(failedBills) > 0 {
-		response["warning"] = fmt.Sprintf("Failed to process %d bills completely", len(failedBills))
-		response["failed_bills"] = failedBills
-		response["failed_payments_count"] = totalFailedPayments
-	}
+	response["processed_bills"] = processedBills
+	response["processed_count"] = len(processedBills)
+	divisionOutcome(response, skippedBills, failedBills)
 
 	return response, nil
 }
 
-func (s *billServiceImpl) DivideAllBills(ctx context.Context, userID, apartmentID int) (map[string]interface{}, error) {
+func (s *billServiceImpl) DivideAllBills(ctx context.Context, userID, apartmentID int, dryRun bool) (map[string]interface{}, error) {
 	logger := logrus.WithFields(logrus.Fields{
 		"user_id":      userID,
 		"apartment_id": apartmentID,
+		"dry_run":      dryRun,
 	})
 
 	logger.Info("Starting division of all bills")
@@ -320,12 +463,12 @@ func (s *billServiceImpl) DivideAllBills(ctx context.Context, userID, apartmentI
 	}
 
 	// Get current residents
-	residents, err := s.userApartmentRepo.GetResidentsInApartment(apartmentID)
+	members, err := s.userApartmentRepo.GetMembershipsInApartment(apartmentID)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get residents")
 		return nil, fmt.Errorf("failed to get residents: %w", err)
 	}
-	if len(residents) == 0 {
+	if len(members) == 0 {
 		logger.Warn("No residents found in apartment")
 		return nil, fmt.Errorf("no residents found in apartment")
 	}
@@ -341,291 +484,1470 @@ func (s *billServiceImpl) DivideAllBills(ctx context.Context, userID, apartmentI
 		return nil, fmt.Errorf("no undivided bills found in apartment")
 	}
 
-	logger.WithFields(logrus.Fields{
-		"residents_count": len(residents),
-		"bills_count":     len(bills),
-	}).Info("Processing all undivided bills")
+	//resolving every policy up front so a misconfigured one doesn't leave some bills divided and others not
+	policies := make(map[models.BillType]models.SplitPolicy)
+	strategies := make(map[models.BillType]models.SplitStrategy)
+	sharesByBill := make(map[int][]models.Payment, len(bills))
+	for _, bill := range bills {
+		policy, ok := policies[bill.BillType]
+		if !ok {
+			policy = s.resolveSplitPolicy(apartmentID, bill.BillType)
+			policies[bill.BillType] = policy
+			strategies[bill.BillType] = policy.Strategy
+		}
+		sharesByBill[bill.ID], err = s.splitBill(bill, policy, members)
+		if err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"bill_id":   bill.ID,
+				"bill_type": bill.BillType,
+				"strategy":  policy.Strategy,
+			}).Error("Failed to compute share weights")
+			return nil, fmt.Errorf("failed to apply %s split for %s bills: %w", policy.Strategy, bill.BillType, err)
+		}
+	}
 
-	var processedBills []int
-	var failedBills []int
-	var totalFailedPayments int
 	billTypeCount := make(map[models.BillType]int)
-
 	for _, bill := range bills {
-		amountPerResident := bill.TotalAmount / float64(len(residents))
-		billProcessed := true
 		billTypeCount[bill.BillType]++
+	}
 
-		for _, resident := range residents {
-			existingPayment, _ := s.paymentRepo.GetPaymentByBillAndUser(bill.ID, resident.ID)
-			if existingPayment != nil {
-				continue
-			}
+	response := map[string]interface{}{
+		"residents_count":      len(members),
+		"bill_types_processed": billTypeCount,
+		"split_strategies":     strategies,
+	}
 
-			payment := models.Payment{
-				BaseModel: models.BaseModel{
-					CreatedAt: time.Now(),
-					UpdatedAt: time.Now(),
-				},
-				BillID:        bill.ID,
-				UserID:        resident.ID,
-				Amount:        fmt.Sprintf("%.2f", amountPerResident),
-				PaymentStatus: models.Pending,
-			}
+	if dryRun {
+		response["dry_run"] = true
+		response["shares"] = plannedShares(bills, sharesByBill)
+		return response, nil
+	}
 
-			_, err := s.paymentRepo.CreatePayment(ctx, payment)
-			if err != nil {
-				logger.WithError(err).WithFields(logrus.Fields{
-					"bill_id":     bill.ID,
-					"resident_id": resident.ID,
-				}).Error("Failed to create payment record")
-				totalFailedPayments++
-				billProcessed = false
-				continue
-			}
+	logger.WithFields(logrus.Fields{
+		"residents_count": len(members),
+		"bills_count":     len(bills),
+	}).Info("Processing all undivided bills")
 
-			//sending notification
-			if err := s.notificationService.SendBillNotification(ctx, resident.ID, bill, amountPerResident); err != nil {
-				logger.WithError(err).WithFields(logrus.Fields{
-					"bill_id":     bill.ID,
-					"resident_id": resident.ID,
-				}).Warn("Failed to send notification")
-			}
-		}
+	var processedBills []int
+	var skippedBills []int
+	var failedBills []int
+	var createdShares []models.Payment
 
-		if billProcessed {
+	for _, bill := range bills {
+		created, err := s.divideShares(ctx, logger, bill, sharesByBill[bill.ID])
+		switch {
+		case err == nil:
 			processedBills = append(processedBills, bill.ID)
-		} else {
+			createdShares = append(createdShares, created...)
+		case errors.Is(err, repositories.ErrBillAlreadyDivided), errors.Is(err, repositories.ErrBillNotDivisible):
+			skippedBills = append(skippedBills, bill.ID)
+		default:
 			failedBills = append(failedBills, bill.ID)
 		}
 	}
 
+	// residents with auto-pay on get their new shares settled from their wallet right away
+	autoPaid := s.walletService.AutoPayShares(ctx, apartmentID, createdShares)
+
 	logger.WithFields(logrus.Fields{
 		"processed_count":      len(processedBills),
+		"skipped_count":        len(skippedBills),
 		"failed_count":         len(failedBills),
+		"auto_paid_count":      autoPaid,
 		"bill_types_processed": billTypeCount,
 	}).Info("All bills division completed")
 
-	response := map[string]interface{}{
-		"residents_count":      len(residents),
-		"processed_bills":      processedBills,
-		"processed_count":      len(processedBills),
-		"bill_types_processed": billTypeCount,
+	response["processed_bills"] = processedBills
+	response["processed_count"] = len(processedBills)
+	response["auto_paid_count"] = autoPaid
+	divisionOutcome(response, skippedBills, failedBills)
+
+	return response, nil
+}
+
+func (s *billServiceImpl) DivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"bill_id": billID,
+	})
+
+	bill, err := s.repo.GetBillByID(billID)
+	if err != nil {
+		logger.WithError(err).Error("Bill not found")
+		return nil, fmt.Errorf("bill not found: %w", err)
 	}
 
-	if len(failedBills) > 0 {
-		response["warning"] = fmt.Sprintf("Failed to process %d bills completely", len(failedBills))
-		response["failed_bills"] = failedBills
-		response["failed_payments_count"] = totalFailedPayments
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to divide a bill")
+		return nil, fmt.Errorf("only apartment managers can divide bills")
 	}
 
-	return response, nil
-}
+	members, err := s.userApartmentRepo.GetMembershipsInApartment(bill.ApartmentID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get residents")
+		return nil, fmt.Errorf("failed to get residents: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no residents found in apartment")
+	}
 
-func (s *billServiceImpl) GetBillByID(ctx context.Context, id int) (map[string]interface{}, error) {
-	bill, err := s.repo.GetBillByID(id)
+	policy := s.resolveSplitPolicy(bill.ApartmentID, bill.BillType)
+	shares, err := s.splitBill(*bill, policy, members)
 	if err != nil {
-		logrus.WithError(err).WithField("bill_id", id).Error("Failed to get bill by ID")
-		return nil, fmt.Errorf("failed to get bill: %w", err)
+		logger.WithError(err).WithField("strategy", policy.Strategy).Error("Failed to compute share weights")
+		return nil, fmt.Errorf("failed to apply %s split: %w", policy.Strategy, err)
 	}
 
-	var imageURL string
-	if bill.ImageURL != "" {
-		imageURL, err = s.imageService.GetImageURL(ctx, bill.ImageURL)
-		if err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{
-				"bill_id":   id,
-				"image_key": bill.ImageURL,
-			}).Warn("Failed to generate image URL")
-		}
+	created, err := s.divideShares(ctx, logger, *bill, shares)
+	if err != nil {
+		return nil, fmt.Errorf("failed to divide bill: %w", err)
 	}
 
 	return map[string]interface{}{
-		"id":               bill.ID,
-		"apartment_id":     bill.ApartmentID,
-		"bill_type":        bill.BillType,
-		"total_amount":     bill.TotalAmount,
-		"due_date":         bill.DueDate,
-		"billing_deadline": bill.BillingDeadline,
-		"description":      bill.Description,
-		"image_url":        imageURL,
-		"created_at":       bill.CreatedAt,
-		"updated_at":       bill.UpdatedAt,
+		"bill_id":         bill.ID,
+		"split_strategy":  policy.Strategy,
+		"residents_count": len(members),
+		"shares_count":    len(created),
 	}, nil
 }
 
-func (s *billServiceImpl) GetBillsByApartmentID(ctx context.Context, apartmentID int) ([]models.Bill, error) {
-	bills, err := s.repo.GetBillsByApartmentID(apartmentID)
-	if err != nil {
-		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get bills by apartment ID")
-		return nil, fmt.Errorf("failed to get bills: %w", err)
+// a policy for the bill type comes first, then the default strategy of its category and then the
+// apartment-wide policy. falls back to an equal split when none of them is set
+func (s *billServiceImpl) resolveSplitPolicy(apartmentID int, billType models.BillType) models.SplitPolicy {
+	policy, err := s.splitPolicyRepo.GetSplitPolicy(apartmentID, billType)
+	if err == nil && policy.BillType == billType {
+		return *policy
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"apartment_id": apartmentID,
+			"bill_type":    billType,
+		}).Warn("Failed to load split policy, using equal split")
+	}
+
+	if category := s.resolveBillCategory(apartmentID, billType); category.DefaultStrategy != "" {
+		return models.SplitPolicy{
+			ApartmentID: apartmentID,
+			BillType:    billType,
+			Strategy:    category.DefaultStrategy,
+		}
+	}
+	if err == nil {
+		return *policy
+	}
+	return models.SplitPolicy{
+		ApartmentID: apartmentID,
+		BillType:    billType,
+		Strategy:    models.SplitEqual,
 	}
-	return bills, nil
 }
 
-func (s *billServiceImpl) UpdateBill(ctx context.Context, id, apartmentID int, billType string, totalAmount float64, dueDate, billingDeadline, description string) error {
-	logger := logrus.WithFields(logrus.Fields{
-		"bill_id":      id,
-		"apartment_id": apartmentID,
-		"bill_type":    billType,
-		"amount":       totalAmount,
-	})
+// like findBillCategory, but bills of a type that was removed since still get a bare category
+func (s *billServiceImpl) resolveBillCategory(apartmentID int, billType models.BillType) models.BillCategory {
+	category, err := findBillCategory(s.categoryRepo, apartmentID, billType)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"apartment_id": apartmentID,
+			"bill_type":    billType,
+		}).Debug("Bill category not found, using its defaults")
+		return models.BillCategory{ApartmentID: apartmentID, Name: billType}
+	}
+	return *category
+}
 
-	logger.Info("Updating bill")
+// bills with a billing period are shared by everyone who lived in the apartment during it,
+// pro-rated by days of residence. bills without one are shared by the current members
+func (s *billServiceImpl) billShares(bill models.Bill, policy models.SplitPolicy, current []models.User_apartment) ([]models.User_apartment, []float64, error) {
+	if bill.PeriodStart == nil || bill.PeriodEnd == nil {
+		weights, err := s.billWeights(bill, policy, current)
+		return current, weights, err
+	}
 
-	bill := models.Bill{
-		BaseModel: models.BaseModel{
-			ID:        id,
-			UpdatedAt: time.Now(),
-		},
-		ApartmentID:     apartmentID,
-		BillType:        models.BillType(billType),
-		TotalAmount:     totalAmount,
-		DueDate:         dueDate,
-		BillingDeadline: billingDeadline,
-		Description:     description,
+	members, err := s.userApartmentRepo.GetMembershipsInPeriod(bill.ApartmentID, *bill.PeriodStart, bill.PeriodEnd.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get members of the billing period: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, nil, fmt.Errorf("nobody lived in the apartment during the billing period")
 	}
 
-	if err := s.repo.UpdateBill(ctx, bill); err != nil {
-		logger.WithError(err).Error("Failed to update bill")
-		return fmt.Errorf("failed to update bill: %w", err)
+	//measured consumption already reflects how long someone lived there
+	if policy.Strategy == models.SplitByMeter {
+		members = latestMemberships(members)
+		weights, err := s.billWeights(bill, policy, members)
+		return members, weights, err
 	}
 
-	logger.Info("Bill updated successfully")
-	return nil
+	weights, err := s.billWeights(bill, policy, members)
+	if err != nil {
+		return nil, nil, err
+	}
+	members, weights = prorateWeights(members, weights, *bill.PeriodStart, *bill.PeriodEnd)
+	return members, weights, nil
 }
 
-func (s *billServiceImpl) DeleteBill(ctx context.Context, id int) error {
-	logger := logrus.WithField("bill_id", id)
-	logger.Info("Deleting bill")
+// consumption splits depend on the bill's own meter window, every other strategy only on the members
+func (s *billServiceImpl) billWeights(bill models.Bill, policy models.SplitPolicy, members []models.User_apartment) ([]float64, error) {
+	if policy.Strategy != models.SplitByMeter {
+		return shareWeights(policy, members)
+	}
 
-	bill, err := s.repo.GetBillByID(id)
+	meters, err := s.meterRepo.GetMetersByApartmentAndType(bill.ApartmentID, bill.BillType)
 	if err != nil {
-		logger.WithError(err).Error("Failed to get bill for deletion")
-		return fmt.Errorf("failed to get bill: %w", err)
+		return nil, fmt.Errorf("failed to get meters: %w", err)
+	}
+	if len(meters) == 0 {
+		return nil, fmt.Errorf("no %s meters registered in apartment", bill.BillType)
 	}
 
-	if err := s.repo.DeleteBill(id); err != nil {
-		logger.WithError(err).Error("Failed to delete bill from database")
-		return fmt.Errorf("failed to delete bill: %w", err)
+	readings := make(map[int][]models.MeterReading, len(meters))
+	for _, meter := range meters {
+		meterReadings, err := s.meterRepo.GetReadingsByMeter(meter.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get readings of meter %d: %w", meter.ID, err)
+		}
+		readings[meter.ID] = meterReadings
 	}
 
-	if bill.ImageURL != "" {
-		if err := s.imageService.DeleteImage(ctx, bill.ImageURL); err != nil {
-			logger.WithError(err).WithField("image_key", bill.ImageURL).Warn("Failed to delete associated image")
-		} else {
-			logger.WithField("image_key", bill.ImageURL).Debug("Associated image deleted successfully")
+	var start, end time.Time
+	if bill.PeriodStart != nil && bill.PeriodEnd != nil {
+		start, end = *bill.PeriodStart, bill.PeriodEnd.AddDate(0, 0, 1)
+	} else {
+		bills, err := s.repo.GetBillsByApartmentID(bill.ApartmentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get previous bills: %w", err)
 		}
+		start, end = consumptionWindow(bill, bills)
 	}
 
-	logger.Info("Bill deleted successfully")
-	return nil
+	weights, estimated, err := consumptionWeights(members, meters, readings, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(estimated) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"bill_id":        bill.ID,
+			"estimated_user": estimated,
+		}).Warn("Missing meter readings, using estimated consumption")
+	}
+	return weights, nil
 }
 
-func (s *billServiceImpl) PayBills(ctx context.Context, userID int, paymentIDs []int, idempotentKey string) error {
-	logger := logrus.WithFields(logrus.Fields{
-		"user_id": userID,
-	})
+// computes the pending shares of one bill without storing them. the line items of an itemized bill
+// are split one by one, with their own strategy or the bill's, and a resident's share is the sum of
+// their parts
+func (s *billServiceImpl) splitBill(bill models.Bill, policy models.SplitPolicy, current []models.User_apartment) ([]models.Payment, error) {
+	if len(bill.LineItems) == 0 {
+		members, weights, err := s.billShares(bill, policy, current)
+		if err != nil {
+			return nil, err
+		}
+		return planShares(bill, members, weights, policy.Strategy)
+	}
 
-	logger.Info("Processing bill payment")
+	parts := make(map[int]map[int]money.Amount)
+	for _, item := range bill.LineItems {
+		itemPolicy := policy
+		if item.SplitStrategy != "" && item.SplitStrategy != policy.Strategy {
+			itemPolicy = models.SplitPolicy{ApartmentID: bill.ApartmentID, BillType: bill.BillType, Strategy: item.SplitStrategy}
+		}
+		itemBill := bill
+		itemBill.TotalAmount = item.Amount
+		members, weights, err := s.billShares(itemBill, itemPolicy, current)
+		if err != nil {
+			return nil, fmt.Errorf("line item %q: %w", item.Name, err)
+		}
+		amounts, err := item.Amount.Allocate(weights)
+		if err != nil {
+			return nil, fmt.Errorf("line item %q: %w", item.Name, err)
+		}
+		for i, member := range members {
+			if parts[member.UserID] == nil {
+				parts[member.UserID] = make(map[int]money.Amount)
+			}
+			parts[member.UserID][item.ID] += amounts[i]
+		}
+	}
+	return itemizedShares(bill, parts, policy.Strategy), nil
+}
 
-	if err := s.paymentService.PayBills(paymentIDs, idempotentKey); err != nil {
-		logger.WithError(err).Error("Payment processing failed")
-		return fmt.Errorf("payment failed: %w", err)
+func planShares(bill models.Bill, members []models.User_apartment, weights []float64, strategy models.SplitStrategy) ([]models.Payment, error) {
+	//the member order is stable (by user id), so rounding leftovers always land on the same residents
+	amounts, err := bill.TotalAmount.Allocate(weights)
+	if err != nil {
+		return nil, err
 	}
 
-	var payments []models.Payment
-	for _, paymentID := range paymentIDs {
-		payments = append(payments, models.Payment{
+	now := time.Now()
+	var shares []models.Payment
+	for i, member := range members {
+		if amounts[i] <= 0 {
+			continue // members with no share factor don't pay for this bill
+		}
+		shares = append(shares, models.Payment{
 			BaseModel: models.BaseModel{
-				UpdatedAt: time.Now(),
-				ID:        paymentID,
+				CreatedAt: now,
+				UpdatedAt: now,
 			},
-			UserID:        userID,
-			PaidAt:        time.Now(),
-			PaymentStatus: models.Paid,
+			BillID:        bill.ID,
+			UserID:        member.UserID,
+			Amount:        amounts[i],
+			Currency:      bill.Currency,
+			PaymentStatus: models.Pending,
+			SplitStrategy: strategy,
+			Kind:          models.ShareKind,
 		})
 	}
+	return shares, nil
+}
 
-	if err := s.paymentRepo.UpdatePaymentsStatus(ctx, payments); err != nil {
-		logger.WithError(err).Error("Failed to update payment status")
-		return fmt.Errorf("failed to update payments status: %w", err)
+// one share per resident made of their parts of the line items, in user id order like planShares
+func itemizedShares(bill models.Bill, parts map[int]map[int]money.Amount, strategy models.SplitStrategy) []models.Payment {
+	userIDs := make([]int, 0, len(parts))
+	for userID := range parts {
+		userIDs = append(userIDs, userID)
 	}
-
-	logger.Info("Bill payment completed successfully")
-	return nil
+	sort.Ints(userIDs)
+
+	now := time.Now()
+	var shares []models.Payment
+	for _, userID := range userIDs {
+		breakdown := breakdownOf(bill.LineItems, parts[userID])
+		var amount money.Amount
+		for _, part := range breakdown {
+			amount += part.Amount
+		}
+		if amount <= 0 {
+			continue
+		}
+		shares = append(shares, models.Payment{
+			BaseModel: models.BaseModel{
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			BillID:        bill.ID,
+			UserID:        userID,
+			Amount:        amount,
+			Currency:      bill.Currency,
+			PaymentStatus: models.Pending,
+			SplitStrategy: strategy,
+			Kind:          models.ShareKind,
+			Breakdown:     breakdown,
+		})
+	}
+	return shares
 }
 
-func (s *billServiceImpl) PayBatchBills(ctx context.Context, userID int, idempotentKey string) (map[string]interface{}, error) {
-	logger := logrus.WithFields(logrus.Fields{
+// stores the shares of one bill together with their ledger entry in one transaction and notifies the
+// residents once it has committed
+func (s *billServiceImpl) divideShares(ctx context.Context, logger *logrus.Entry, bill models.Bill, shares []models.Payment) ([]models.Payment, error) {
+	billLogger := logger.WithFields(logrus.Fields{
+		"bill_id":     bill.ID,
+		"bill_amount": bill.TotalAmount,
+	})
+
+	charges := make(map[int]money.Amount)
+	var userIDs []int
+	for _, share := range shares {
+		userIDs = append(userIDs, share.UserID)
+		charges[share.UserID] += share.Amount
+	}
+
+	created, err := s.repo.DivideBill(ctx, bill.ID, shares, chargesJournalEntry(bill, models.DivisionEntry, userIDs, charges))
+	if errors.Is(err, repositories.ErrBillAlreadyDivided) {
+		billLogger.Debug("Bill already divided by a concurrent division")
+		return nil, err
+	}
+	if err != nil {
+		billLogger.WithError(err).Error("Bill processing failed")
+		return nil, err
+	}
+
+	bill.Icon = s.resolveBillCategory(bill.ApartmentID, bill.BillType).Icon
+	for _, share := range created {
+		if err := s.notificationService.SendBillNotification(ctx, share.UserID, bill, share.Amount); err != nil {
+			billLogger.WithError(err).WithField("resident_id", share.UserID).Warn("Failed to send notification")
+		}
+	}
+
+	billLogger.Debug("Bill processed successfully")
+	return created, nil
+}
+
+func plannedShares(bills []models.Bill, sharesByBill map[int][]models.Payment) []models.Payment {
+	var shares []models.Payment
+	for _, bill := range bills {
+		shares = append(shares, sharesByBill[bill.ID]...)
+	}
+	return shares
+}
+
+func divisionOutcome(response map[string]interface{}, skippedBills, failedBills []int) {
+	if len(skippedBills) > 0 {
+		response["skipped_bills"] = skippedBills
+	}
+	if len(failedBills) > 0 {
+		response["warning"] = fmt.Sprintf("Failed to divide %d bills, they were left undivided", len(failedBills))
+		response["failed_bills"] = failedBills
+	}
+}
+
+func (s *billServiceImpl) SetSplitPolicy(ctx context.Context, userID, apartmentID int, req dto.SplitPolicyRequest) (*models.SplitPolicy, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"apartment_id": apartmentID,
+		"bill_type":    req.BillType,
+		"strategy":     req.Strategy,
+	})
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to set split policy")
+		return nil, fmt.Errorf("only apartment managers can set split policies")
+	}
+
+	if req.BillType != "" {
+		if _, err := findBillCategory(s.categoryRepo, apartmentID, req.BillType); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := models.SplitPolicy{
+		ApartmentID: apartmentID,
+		BillType:    req.BillType,
+		Strategy:    req.Strategy,
+	}
+	if req.Strategy == models.SplitMixed {
+		policy.EqualWeight = req.EqualWeight
+		policy.AreaWeight = req.AreaWeight
+		policy.OccupantsWeight = req.OccupantsWeight
+		policy.PercentageWeight = req.PercentageWeight
+	}
+	if err := validateSplitPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	id, err := s.splitPolicyRepo.UpsertSplitPolicy(ctx, policy)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save split policy")
+		return nil, fmt.Errorf("failed to save split policy: %w", err)
+	}
+	policy.ID = id
+
+	logger.Info("Split policy saved")
+	return &policy, nil
+}
+
+func (s *billServiceImpl) GetSplitPolicies(ctx context.Context, userID, apartmentID int) ([]models.SplitPolicy, error) {
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID)
+	if err != nil || !isManager {
+		return nil, fmt.Errorf("only apartment managers can view split policies")
+	}
+
+	policies, err := s.splitPolicyRepo.GetSplitPoliciesByApartment(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get split policies")
+		return nil, fmt.Errorf("failed to get split policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (s *billServiceImpl) SetBillCategory(ctx context.Context, userID, apartmentID int, req dto.BillCategoryRequest) (*models.BillCategory, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"apartment_id": apartmentID,
+		"name":         req.Name,
+	})
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to set a bill category")
+		return nil, fmt.Errorf("only apartment managers can set bill categories")
+	}
+
+	category := models.BillCategory{
+		ApartmentID:     apartmentID,
+		Name:            req.Name,
+		Icon:            req.Icon,
+		DefaultStrategy: req.DefaultSplitStrategy,
+		DueAfterDays:    req.DueAfterDays,
+	}
+	if err := validateBillCategory(category); err != nil {
+		return nil, err
+	}
+
+	id, err := s.categoryRepo.UpsertBillCategory(ctx, category)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save bill category")
+		return nil, fmt.Errorf("failed to save bill category: %w", err)
+	}
+	category.ID = id
+	_, category.BuiltIn = models.BuiltInBillCategory(category.Name)
+
+	logger.Info("Bill category saved")
+	return &category, nil
+}
+
+// residents see the categories too, their icons are used in bill messages
+func (s *billServiceImpl) GetBillCategories(ctx context.Context, userID, apartmentID int) ([]models.BillCategory, error) {
+	isMember, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID)
+	if err != nil || !isMember {
+		return nil, fmt.Errorf("only members of the apartment can view its bill categories")
+	}
+
+	stored, err := s.categoryRepo.GetBillCategoriesByApartment(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get bill categories")
+		return nil, fmt.Errorf("failed to get bill categories: %w", err)
+	}
+	return mergeBillCategories(apartmentID, stored), nil
+}
+
+// deleting a built-in category restores its defaults. bills that already use a removed
+// category keep it, but new bills can't be created with it
+func (s *billServiceImpl) DeleteBillCategory(ctx context.Context, userID, apartmentID int, name models.BillType) error {
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID)
+	if err != nil || !isManager {
+		return fmt.Errorf("only apartment managers can delete bill categories")
+	}
+
+	if err := s.categoryRepo.DeleteBillCategory(apartmentID, name); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"apartment_id": apartmentID,
+			"name":         name,
+		}).Error("Failed to delete bill category")
+		return fmt.Errorf("failed to delete bill category: %w", err)
+	}
+	return nil
+}
+
+func (s *billServiceImpl) GetBillByID(ctx context.Context, id int) (map[string]interface{}, error) {
+	bill, err := s.repo.GetBillByID(id)
+	if err != nil {
+		logrus.WithError(err).WithField("bill_id", id).Error("Failed to get bill by ID")
+		return nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+
+	var imageURL string
+	if bill.ImageURL != "" {
+		imageURL, err = s.imageService.GetImageURL(ctx, bill.ImageURL)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"bill_id":   id,
+				"image_key": bill.ImageURL,
+			}).Warn("Failed to generate image URL")
+		}
+	}
+
+	return map[string]interface{}{
+		"id":               bill.ID,
+		"apartment_id":     bill.ApartmentID,
+		"bill_type":        bill.BillType,
+		"total_amount":     bill.TotalAmount,
+		"currency":         bill.Currency,
+		"due_date":         bill.DueDate,
+		"billing_deadline": bill.BillingDeadline,
+		"description":      bill.Description,
+		"line_items":       bill.LineItems,
+		"status":           bill.Status,
+		"image_url":        imageURL,
+		"created_at":       bill.CreatedAt,
+		"updated_at":       bill.UpdatedAt,
+	}, nil
+}
+
+func (s *billServiceImpl) GetBillsByApartmentID(ctx context.Context, apartmentID int) ([]models.Bill, error) {
+	bills, err := s.repo.GetBillsByApartmentID(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get bills by apartment ID")
+		return nil, fmt.Errorf("failed to get bills: %w", err)
+	}
+	return bills, nil
+}
+
+// the bills a resident of the apartment can see, drafts stay with the manager until they are published
+func (s *billServiceImpl) GetResidentBills(ctx context.Context, userID, apartmentID int) ([]models.Bill, error) {
+	isResident, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify membership: %w", err)
+	}
+	if !isResident {
+		return nil, fmt.Errorf("user is not a resident of this apartment")
+	}
+
+	bills, err := s.repo.GetBillsByApartmentID(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get bills by apartment ID")
+		return nil, fmt.Errorf("failed to get bills: %w", err)
+	}
+
+	visible := make([]models.Bill, 0, len(bills))
+	for _, bill := range bills {
+		if bill.Status.VisibleToResidents() {
+			visible = append(visible, bill)
+		}
+	}
+	return visible, nil
+}
+
+// penalties are left out, they are between the resident and the manager
+func (s *billServiceImpl) GetBillDetail(ctx context.Context, userID, billID int) (*BillDetail, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"bill_id": billID,
+	})
+
+	bill, err := s.repo.GetBillByID(billID)
+	if err != nil {
+		logger.WithError(err).Error("Bill not found")
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, bill.ApartmentID); err != nil || !ok {
+		logger.Warn("User attempted to view a bill of another apartment")
+		return nil, fmt.Errorf("user is not a resident of this apartment")
+	}
+	if !bill.Status.VisibleToResidents() {
+		return nil, fmt.Errorf("bill not found: %w", sql.ErrNoRows)
+	}
+
+	apartment, err := s.apartmentRepo.GetApartmentByID(bill.ApartmentID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get apartment")
+		return nil, fmt.Errorf("failed to get apartment: %w", err)
+	}
+	payments, err := s.paymentRepo.GetPaymentsByBill(billID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get bill payments")
+		return nil, fmt.Errorf("failed to get bill payments: %w", err)
+	}
+
+	detail := &BillDetail{Bill: *bill, Shares: []ResidentShare{}}
+	detail.Bill.Icon = s.resolveBillCategory(bill.ApartmentID, bill.BillType).Icon
+	if bill.ImageURL != "" {
+		detail.ReceiptURL, err = s.imageService.GetImageURL(ctx, bill.ImageURL)
+		if err != nil {
+			logger.WithError(err).WithField("image_key", bill.ImageURL).Warn("Failed to generate image URL")
+		}
+	}
+
+	var shares []models.Payment
+	adjustments := make(map[int][]models.Payment)
+	for _, payment := range payments {
+		switch {
+		case payment.Kind == models.ShareKind:
+			shares = append(shares, payment)
+		case payment.Kind == models.AdjustmentKind && payment.ParentPaymentID != nil:
+			adjustments[*payment.ParentPaymentID] = append(adjustments[*payment.ParentPaymentID], payment)
+		}
+	}
+	if len(shares) == 0 {
+		detail.SplitStrategy = s.resolveSplitPolicy(bill.ApartmentID, bill.BillType).Strategy
+		return detail, nil
+	}
+	detail.SplitStrategy = shares[0].SplitStrategy
+
+	ids := make([]int, len(shares))
+	for i, share := range shares {
+		ids[i] = share.ID
+	}
+	breakdowns := s.breakdownsOf(ids)
+
+	for i, share := range shares {
+		resident := ResidentShare{
+			Name:       fmt.Sprintf("Resident %d", i+1),
+			Mine:       share.UserID == userID,
+			Amount:     share.Amount,
+			AmountPaid: share.AmountPaid - share.AmountRefunded,
+			Status:     share.PaymentStatus,
+			Breakdown:  breakdowns[share.ID],
+		}
+		for _, adjustment := range adjustments[share.ID] {
+			resident.Amount += adjustment.Amount
+			resident.AmountPaid += adjustment.AmountPaid - adjustment.AmountRefunded
+		}
+		if resident.Mine || apartment.ShareVisibility == models.NamedShares {
+			resident.UserID = &shares[i].UserID
+		}
+		if apartment.ShareVisibility == models.NamedShares {
+			if user, err := s.userRepo.GetUserByID(share.UserID); err == nil {
+				resident.Name = user.Username
+				if user.FullName != "" {
+					resident.Name = user.FullName
+				}
+			} else {
+				logger.WithError(err).WithField("resident_id", share.UserID).Warn("Failed to get resident name")
+			}
+		}
+		detail.Shares = append(detail.Shares, resident)
+	}
+	return detail, nil
+}
+
+// makes a draft bill visible to the residents and ready to be divided. bills that need approval
+// wait for it first, the returned status tells which of the two happened
+func (s *billServiceImpl) PublishBill(ctx context.Context, userID, billID int) (models.BillStatus, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"bill_id": billID,
+	})
+
+	bill, err := s.repo.GetBillByID(billID)
+	if err != nil {
+		logger.WithError(err).Error("Bill not found")
+		return "", fmt.Errorf("bill not found: %w", err)
+	}
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to publish a bill")
+		return "", fmt.Errorf("only apartment managers can publish bills")
+	}
+
+	if bill.Status != models.BillDraft {
+		return "", fmt.Errorf("%w: bill %d is %s", repositories.ErrInvalidBillTransition, billID, bill.Status)
+	}
+	held, err := s.approvalService.RequestApproval(ctx, userID, *bill)
+	if err != nil {
+		return "", err
+	}
+	if held {
+		return models.BillPendingApproval, nil
+	}
+
+	if err := s.repo.UpdateBillStatus(ctx, billID, models.BillPublished); err != nil {
+		logger.WithError(err).Error("Failed to publish bill")
+		return "", fmt.Errorf("failed to publish bill: %w", err)
+	}
+
+	logger.Info("Bill published")
+	return models.BillPublished, nil
+}
+
+// voids the bill and its unpaid shares. residents who still had a share to pay are told it is gone
+func (s *billServiceImpl) CancelBill(ctx context.Context, userID, billID int) (*models.JournalEntry, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"bill_id": billID,
+	})
+
+	bill, err := s.repo.GetBillByID(billID)
+	if err != nil {
+		logger.WithError(err).Error("Bill not found")
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to cancel a bill")
+		return nil, fmt.Errorf("only apartment managers can cancel bills")
+	}
+
+	journal, err := s.repo.CancelBill(ctx, billID, userID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to cancel bill")
+		return nil, fmt.Errorf("failed to cancel bill: %w", err)
+	}
+
+	logger.Info("Bill cancelled")
+
+	voided := make(map[int]money.Amount)
+	var residents []int
+	for _, line := range journal.Lines {
+		if line.Account != models.ResidentReceivable || line.UserID == nil {
+			continue
+		}
+		if _, ok := voided[*line.UserID]; !ok {
+			residents = append(residents, *line.UserID)
+		}
+		voided[*line.UserID] += line.Credit - line.Debit
+	}
+	for _, residentID := range residents {
+		if voided[residentID] <= 0 {
+			continue
+		}
+		message := fmt.Sprintf("Bill #%d (%s) was cancelled. You no longer have to pay %s %s for it.",
+			bill.ID, bill.BillType, voided[residentID], bill.Currency)
+		if err := s.notificationService.SendNotification(ctx, residentID, message); err != nil {
+			logger.WithError(err).WithField("resident_id", residentID).Warn("Failed to send notification")
+		}
+	}
+	return journal, nil
+}
+
+func (s *billServiceImpl) UpdateBill(ctx context.Context, userID, id, apartmentID int, billType string, totalAmount money.Amount, dueDate, billingDeadline, description string, lineItems []dto.LineItemRequest) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"bill_id":      id,
+		"apartment_id": apartmentID,
+		"bill_type":    billType,
+		"amount":       totalAmount,
+	})
+
+	logger.Info("Updating bill")
+
+	previous, err := s.repo.GetBillByID(id)
+	if err != nil {
+		logger.WithError(err).Error("Bill not found")
+		return fmt.Errorf("bill not found: %w", err)
+	}
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, previous.ApartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to update a bill")
+		return fmt.Errorf("only apartment managers can update bills")
+	}
+	//its shares and ledger entries stay with the apartment it was billed to
+	if apartmentID != 0 && apartmentID != previous.ApartmentID {
+		return fmt.Errorf("a bill can't be moved to another apartment")
+	}
+	apartmentID = previous.ApartmentID
+
+	//the approvers decide on what they were shown
+	if previous.Status == models.BillPendingApproval {
+		return fmt.Errorf("%w: bill %d", repositories.ErrBillAwaitingApproval, id)
+	}
+
+	if models.BillType(billType) != previous.BillType {
+		if _, err := findBillCategory(s.categoryRepo, apartmentID, models.BillType(billType)); err != nil {
+			return err
+		}
+	}
+
+	//nil line items keep the stored ones, an empty list turns the bill back into a single amount
+	var items []models.BillLineItem
+	switch {
+	case lineItems != nil:
+		items, totalAmount, err = billLineItems(models.BillType(billType), totalAmount, lineItems)
+		if err != nil {
+			return err
+		}
+		if items == nil {
+			items = []models.BillLineItem{}
+		}
+	case len(previous.LineItems) > 0:
+		if totalAmount != 0 && totalAmount != previous.TotalAmount {
+			return fmt.Errorf("the total of an itemized bill follows its line items, change them instead")
+		}
+		totalAmount = previous.TotalAmount
+	}
+
+	bill := models.Bill{
+		BaseModel: models.BaseModel{
+			ID:        id,
+			UpdatedAt: time.Now(),
+		},
+		ApartmentID:     apartmentID,
+		BillType:        models.BillType(billType),
+		TotalAmount:     totalAmount,
+		DueDate:         dueDate,
+		BillingDeadline: billingDeadline,
+		Description:     description,
+		LineItems:       items,
+	}
+
+	//a bill that grew may need approval again before it is divided, a divided one is only re-divided
+	//once the new amount is approved. so is one whose last edit was rejected. a held bill is moved
+	//to pending_approval by the update itself, so the new amount is never dividable without sign-off
+	changed := *previous
+	changed.BillType = bill.BillType
+	changed.TotalAmount = totalAmount
+	var approval *models.BillApproval
+	if previous.Status == models.BillPublished || previous.Status.Divided() {
+		needsApproval := totalAmount > previous.TotalAmount
+		if !needsApproval && previous.Status.Divided() {
+			approved, err := s.approvalService.Approved(changed)
+			if err != nil {
+				return fmt.Errorf("failed to check the bill's approval: %w", err)
+			}
+			needsApproval = !approved
+		}
+		if needsApproval {
+			required, err := s.approvalService.Requires(changed)
+			if err != nil {
+				return err
+			}
+			if required {
+				approval = &models.BillApproval{UserID: userID, Decision: models.ApprovalRequested}
+			}
+		}
+	}
+
+	if err := s.repo.UpdateBill(ctx, bill, billChangeJournalEntry(*previous, bill.BillType, totalAmount), approval); err != nil {
+		logger.WithError(err).Error("Failed to update bill")
+		return fmt.Errorf("failed to update bill: %w", err)
+	}
+
+	logger.Info("Bill updated successfully")
+
+	if approval != nil {
+		logger.Info("Bill is waiting for approval")
+		s.approvalService.NotifyApprovers(ctx, userID, changed)
+		return nil
+	}
+
+	//a bill that was already divided has to follow the new amount
+	payments, err := s.paymentRepo.GetPaymentsByBill(id)
+	if err != nil {
+		logger.WithError(err).Error("Failed to check existing payments")
+		return fmt.Errorf("bill updated but failed to check existing payments: %w", err)
+	}
+	if len(payments) == 0 {
+		return nil
+	}
+
+	updated, err := s.repo.GetBillByID(id)
+	if err != nil {
+		return fmt.Errorf("bill updated but failed to reload it: %w", err)
+	}
+	if _, err := s.redivide(ctx, logger, *updated, payments); err != nil {
+		logger.WithError(err).Error("Failed to re-divide updated bill")
+		return fmt.Errorf("bill updated but failed to re-divide it: %w", err)
+	}
+	return nil
+}
+
+func (s *billServiceImpl) RedivideBill(ctx context.Context, userID, billID int) (map[string]interface{}, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id": userID,
+		"bill_id": billID,
+	})
+
+	bill, err := s.repo.GetBillByID(billID)
+	if err != nil {
+		logger.WithError(err).Error("Bill not found")
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to re-divide a bill")
+		return nil, fmt.Errorf("only apartment managers can re-divide bills")
+	}
+	approved, err := s.approvalService.Approved(*bill)
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		return nil, fmt.Errorf("%w: bill %d", repositories.ErrBillNotApproved, billID)
+	}
+
+	payments, err := s.paymentRepo.GetPaymentsByBill(billID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get bill payments")
+		return nil, fmt.Errorf("failed to get bill payments: %w", err)
+	}
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("bill has not been divided yet")
+	}
+
+	return s.redivide(ctx, logger, *bill, payments)
+}
+
+// brings every resident's charges for the bill in line with a fresh split.
+// pending shares are changed in place, anything already paid is corrected with an adjustment
+// so the payment history stays untouched, a negative one is paid back into the resident's wallet.
+// the changes are planned from the payments given and applied in one transaction, which fails if
+// the payments moved on since
+func (s *billServiceImpl) redivide(ctx context.Context, logger *logrus.Entry, bill models.Bill, payments []models.Payment) (map[string]interface{}, error) {
+	members, err := s.userApartmentRepo.GetMembershipsInApartment(bill.ApartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get residents: %w", err)
+	}
+
+	policy := s.resolveSplitPolicy(bill.ApartmentID, bill.BillType)
+	planned, err := s.splitBill(bill, policy, members)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply %s split: %w", policy.Strategy, err)
+	}
+
+	target := make(map[int]money.Amount)
+	targetParts := make(map[int]map[int]money.Amount)
+	var userIDs []int
+	for _, share := range planned {
+		userIDs = append(userIDs, share.UserID)
+		target[share.UserID] = share.Amount
+		targetParts[share.UserID] = partsByItem(share.Breakdown)
+	}
+
+	charged := make(map[int]money.Amount)
+	shareOf := make(map[int]models.Payment)
+	var chargedIDs []int
+	for _, payment := range payments {
+		if payment.Kind != models.ShareKind && payment.Kind != models.AdjustmentKind {
+			continue
+		}
+		if _, ok := target[payment.UserID]; !ok {
+			if _, seen := charged[payment.UserID]; !seen {
+				userIDs = append(userIDs, payment.UserID)
+			}
+		}
+		charged[payment.UserID] += payment.Amount
+		chargedIDs = append(chargedIDs, payment.ID)
+		if payment.Kind == models.ShareKind {
+			shareOf[payment.UserID] = payment
+		}
+	}
+
+	//an itemized bill keeps every change broken down by line item, so the parts of a resident's
+	//payments always add up to their part of each item
+	var breakdowns map[int][]models.ShareLineItem
+	chargedParts := make(map[int]map[int]money.Amount)
+	if len(bill.LineItems) > 0 {
+		breakdowns, err = s.paymentRepo.GetBreakdowns(chargedIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get share breakdowns: %w", err)
+		}
+		for _, payment := range payments {
+			if breakdown, ok := breakdowns[payment.ID]; ok {
+				if chargedParts[payment.UserID] == nil {
+					chargedParts[payment.UserID] = make(map[int]money.Amount)
+				}
+				for _, part := range breakdown {
+					chargedParts[payment.UserID][part.LineItemID] += part.Amount
+				}
+			}
+		}
+	}
+
+	plan := models.Redivision{BillID: bill.ID, ApartmentID: bill.ApartmentID, Seen: payments}
+	changes := make(map[int]money.Amount)
+	credited := make(map[int]bool)
+	updatedShares, adjustments := 0, 0
+	for _, userID := range userIDs {
+		delta := target[userID] - charged[userID]
+		if delta == 0 {
+			continue
+		}
+
+		deltaParts := make(map[int]money.Amount)
+		for _, item := range bill.LineItems {
+			deltaParts[item.ID] = targetParts[userID][item.ID] - chargedParts[userID][item.ID]
+		}
+
+		share, hasShare := shareOf[userID]
+		switch {
+		case hasShare && share.PaymentStatus == models.Pending && share.Amount+delta == 0:
+			plan.Deleted = append(plan.Deleted, share.ID)
+			updatedShares++
+		case hasShare && share.PaymentStatus == models.Pending && share.Amount+delta > 0:
+			updated := models.Payment{BaseModel: models.BaseModel{ID: share.ID}, Amount: share.Amount + delta}
+			if len(bill.LineItems) > 0 {
+				parts := partsByItem(breakdowns[share.ID])
+				for itemID, amount := range deltaParts {
+					parts[itemID] += amount
+				}
+				updated.Breakdown = breakdownOf(bill.LineItems, parts)
+			}
+			plan.Updated = append(plan.Updated, updated)
+			updatedShares++
+		case !hasShare && charged[userID] == 0:
+			plan.Created = append(plan.Created, models.Payment{
+				BillID:        bill.ID,
+				UserID:        userID,
+				Amount:        delta,
+				Currency:      bill.Currency,
+				PaymentStatus: models.Pending,
+				SplitStrategy: policy.Strategy,
+				Kind:          models.ShareKind,
+				Breakdown:     breakdownOf(bill.LineItems, deltaParts),
+			})
+			updatedShares++
+		default:
+			adjustment := models.Payment{
+				BillID:        bill.ID,
+				UserID:        userID,
+				Amount:        delta,
+				Currency:      bill.Currency,
+				PaymentStatus: models.Pending,
+				SplitStrategy: policy.Strategy,
+				Kind:          models.AdjustmentKind,
+				Breakdown:     breakdownOf(bill.LineItems, deltaParts),
+			}
+			if hasShare {
+				adjustment.ParentPaymentID = &share.ID
+			}
+			//a credit has nothing left to collect, it is settled into the resident's wallet at once
+			if delta < 0 {
+				adjustment.PaymentStatus = models.Paid
+				adjustment.AmountPaid = delta
+				adjustment.PaidAt = time.Now()
+				credited[userID] = true
+			}
+			plan.Created = append(plan.Created, adjustment)
+			adjustments++
+		}
+		changes[userID] = delta
+	}
+
+	var changedUsers []int
+	for _, userID := range userIDs {
+		if _, ok := changes[userID]; ok {
+			changedUsers = append(changedUsers, userID)
+		}
+	}
+	plan.Journal = chargesJournalEntry(bill, models.RedivisionEntry, changedUsers, changes)
+	for _, userID := range changedUsers {
+		if credited[userID] {
+			plan.Journal.Credit(models.ResidentReceivable, &userID, changes[userID]).
+				Debit(models.ResidentWallet, &userID, changes[userID])
+		}
+	}
+
+	if err := s.repo.RedivideBill(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to re-divide bill: %w", err)
+	}
+
+	for _, userID := range changedUsers {
+		message := fmt.Sprintf("Bill #%d (%s) was updated. Your share is now %s %s (change: %s %s).",
+			bill.ID, bill.BillType, target[userID], bill.Currency, changes[userID], bill.Currency)
+		if credited[userID] {
+			message += " What you paid too much was added to your wallet."
+		}
+		if err := s.notificationService.SendNotification(ctx, userID, message); err != nil {
+			logger.WithError(err).WithField("resident_id", userID).Warn("Failed to send notification")
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"updated_shares": updatedShares,
+		"adjustments":    adjustments,
+	}).Info("Bill re-divided")
+
+	return map[string]interface{}{
+		"bill_id":        bill.ID,
+		"split_strategy": policy.Strategy,
+		"updated_shares": updatedShares,
+		"adjustments":    adjustments,
+		"changes":        changes,
+	}, nil
+}
+
+func (s *billServiceImpl) DeleteBill(ctx context.Context, userID, id int) error {
+	logger := logrus.WithFields(logrus.Fields{
 		"user_id": userID,
+		"bill_id": id,
+	})
+	logger.Info("Deleting bill")
+
+	bill, err := s.repo.GetBillByID(id)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get bill for deletion")
+		return fmt.Errorf("failed to get bill: %w", err)
+	}
+
+	isManager, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID)
+	if err != nil || !isManager {
+		logger.Warn("Non-manager user attempted to delete a bill")
+		return fmt.Errorf("only apartment managers can delete bills")
+	}
+
+	if err := s.repo.DeleteBill(ctx, id); err != nil {
+		logger.WithError(err).Error("Failed to delete bill from database")
+		return fmt.Errorf("failed to delete bill: %w", err)
+	}
+
+	if bill.ImageURL != "" {
+		if err := s.imageService.DeleteImage(ctx, bill.ImageURL); err != nil {
+			logger.WithError(err).WithField("image_key", bill.ImageURL).Warn("Failed to delete associated image")
+		} else {
+			logger.WithField("image_key", bill.ImageURL).Debug("Associated image deleted successfully")
+		}
+	}
+
+	logger.Info("Bill deleted successfully")
+	return nil
+}
+
+// starts a gateway checkout for the given payments. they stay in the processing status until the
+// gateway confirms the payment through its callback. paying from the wallet settles them right away
+func (s *billServiceImpl) PayBills(ctx context.Context, userID int, paymentIDs []int, fromWallet bool, idempotentKey string) (*models.PaymentTransaction, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"from_wallet": fromWallet,
+	})
+
+	logger.Info("Processing bill payment")
+
+	if len(paymentIDs) == 0 {
+		return nil, fmt.Errorf("no payments to pay")
+	}
+
+	var currency money.Currency
+	payments := make([]models.Payment, 0, len(paymentIDs))
+	items := make([]models.TransactionItem, 0, len(paymentIDs))
+	for _, paymentID := range paymentIDs {
+		payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+		if err != nil {
+			return nil, fmt.Errorf("payment not found: %w", err)
+		}
+		if payment.UserID != userID {
+			logger.WithField("payment_id", paymentID).Warn("User attempted to pay someone else's payment")
+			return nil, fmt.Errorf("payment does not belong to the user")
+		}
+		if payment.PaymentStatus != models.Pending && payment.PaymentStatus != models.PartiallyPaid {
+			return nil, fmt.Errorf("payment %d is already paid or being paid", paymentID)
+		}
+		if currency != "" && payment.Currency != currency {
+			return nil, fmt.Errorf("payments in different currencies must be paid separately")
+		}
+		currency = payment.Currency
+		payments = append(payments, *payment)
+		items = append(items, models.TransactionItem{PaymentID: paymentID, Amount: payment.Outstanding()})
+	}
+
+	if fromWallet {
+		byApartment, err := s.groupByApartment(payments)
+		if err != nil {
+			return nil, err
+		}
+		if len(byApartment) > 1 {
+			return nil, fmt.Errorf("payments of different apartments must be paid from their own wallets")
+		}
+		var apartmentID int
+		for id := range byApartment {
+			apartmentID = id
+		}
+
+		transaction, err := s.walletService.PayFromWallet(ctx, userID, apartmentID, currency, items, idempotentKey)
+		if err != nil {
+			logger.WithError(err).Warn("Wallet payment failed")
+			return nil, fmt.Errorf("payment failed: %w", err)
+		}
+		logger.WithField("transaction_id", transaction.ID).Info("Bills paid from wallet")
+		return transaction, nil
+	}
+
+	transaction, err := s.checkoutService.StartCheckout(ctx, userID, currency, items, "Bill payment", idempotentKey)
+	if err != nil {
+		logger.WithError(err).Error("Payment processing failed")
+		return nil, fmt.Errorf("payment failed: %w", err)
+	}
+
+	logger.WithField("transaction_id", transaction.ID).Info("Bill payment checkout started")
+	return transaction, nil
+}
+
+// the apartment every payment's bill belongs to, each apartment has its own wallet
+func (s *billServiceImpl) groupByApartment(payments []models.Payment) (map[int][]models.Payment, error) {
+	apartments := make(map[int]int)
+	groups := make(map[int][]models.Payment)
+	for _, payment := range payments {
+		apartmentID, ok := apartments[payment.BillID]
+		if !ok {
+			bill, err := s.repo.GetBillByID(payment.BillID)
+			if err != nil {
+				return nil, fmt.Errorf("bill not found: %w", err)
+			}
+			apartmentID = bill.ApartmentID
+			apartments[payment.BillID] = apartmentID
+		}
+		groups[apartmentID] = append(groups[apartmentID], payment)
+	}
+	return groups, nil
+}
+
+// pays part of what is left on one of the user's payments, paying the rest settles it
+func (s *billServiceImpl) PayPartial(ctx context.Context, userID, paymentID int, amount money.Amount, idempotentKey string) (*models.PaymentTransaction, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"payment_id": paymentID,
+		"amount":     amount,
+	})
+
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found: %w", err)
+	}
+	if payment.UserID != userID {
+		logger.Warn("User attempted to pay someone else's payment")
+		return nil, fmt.Errorf("payment does not belong to the user")
+	}
+	if payment.PaymentStatus != models.Pending && payment.PaymentStatus != models.PartiallyPaid {
+		return nil, fmt.Errorf("payment is already settled or being paid")
+	}
+	if amount <= 0 || amount > payment.Outstanding() {
+		return nil, fmt.Errorf("amount must be between 0 and the outstanding %s %s", payment.Outstanding(), payment.Currency)
+	}
+
+	items := []models.TransactionItem{{PaymentID: paymentID, Amount: amount}}
+	transaction, err := s.checkoutService.StartCheckout(ctx, userID, payment.Currency, items, "Partial bill payment", idempotentKey)
+	if err != nil {
+		logger.WithError(err).Error("Payment processing failed")
+		return nil, fmt.Errorf("payment failed: %w", err)
+	}
+
+	logger.WithField("transaction_id", transaction.ID).Info("Partial payment checkout started")
+	return transaction, nil
+}
+
+// starts one checkout per currency for everything the user still owes, or pays it from the wallet
+// of each apartment. payments that already have a checkout in progress are left alone
+func (s *billServiceImpl) PayBatchBills(ctx context.Context, userID int, fromWallet bool, idempotentKey string) (map[string]interface{}, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"from_wallet": fromWallet,
 	})
 
 	logger.Info("Processing batch bill payment")
 
-	var totalAmount float64
 	paymentss, err := s.paymentRepo.GetPendingPaymentsByUser(userID)
 	if err != nil {
 		return nil, errors.New("internal server error")
 	}
-	paymentIds := make([]int, 10)
+	payable := make([]models.Payment, 0, len(paymentss))
+	totals := make(map[money.Currency]money.Amount)
 
 	for _, payment := range paymentss {
-		paymentIds = append(paymentIds, payment.ID)
-
-		amount, err := strconv.ParseFloat(payment.Amount, 64)
-		if err != nil {
+		// shares on an installment plan are paid through their installments
+		if payment.PaymentStatus == models.Processing || payment.OnInstallments {
 			continue
 		}
-
-		totalAmount += amount
+		payable = append(payable, payment)
+		totals[payment.Currency] += payment.Outstanding()
 	}
 
-	if len(paymentIds) == 0 {
+	if len(payable) == 0 {
 		logger.Warn("No valid unpaid bills found for batch payment")
 		return nil, fmt.Errorf("no valid unpaid bills found")
 	}
 
 	logger.WithFields(logrus.Fields{
-		"valid_bills_count": len(paymentIds),
-		"total_amount":      totalAmount,
+		"totals": totals,
 	}).Info("Processing batch payment for valid bills")
 
-	if err := s.paymentService.PayBills(paymentIds, idempotentKey); err != nil {
+	var transactions []*models.PaymentTransaction
+	if fromWallet {
+		transactions, err = s.payBatchFromWallets(ctx, userID, payable, idempotentKey)
+	} else {
+		transactions, err = s.payBatchByCheckout(ctx, userID, payable, idempotentKey)
+	}
+	if err != nil {
 		logger.WithError(err).Error("Batch payment processing failed")
 		return nil, fmt.Errorf("batch payment failed: %w", err)
 	}
 
-	var payments []models.Payment
-	for _, paymentID := range paymentIds {
-		payments = append(payments, models.Payment{
-			BaseModel: models.BaseModel{
-				ID:        paymentID,
-				UpdatedAt: time.Now(),
-			},
-			UserID:        userID,
-			PaidAt:        time.Now(),
-			PaymentStatus: models.Paid,
-		})
+	logger.WithFields(logrus.Fields{
+		"totals":       totals,
+		"transactions": len(transactions),
+	}).Info("Batch payment processed")
+
+	response := map[string]interface{}{
+		"status":    "checkout started",
+		"checkouts": transactions,
+		"totals":    totals,
+	}
+	if fromWallet {
+		response["status"] = "paid from wallet"
+	}
+	if len(totals) == 1 {
+		for currency, total := range totals {
+			response["total_amount"] = total
+			response["currency"] = currency
+		}
 	}
+	return response, nil
+}
 
-	if err := s.paymentRepo.UpdatePaymentsStatus(ctx, payments); err != nil {
-		logger.WithError(err).Error("Failed to update batch payment status")
-		return nil, fmt.Errorf("failed to update payments status: %w", err)
+// one checkout per currency, the idempotency key gets the currency appended when there are several
+func (s *billServiceImpl) payBatchByCheckout(ctx context.Context, userID int, payments []models.Payment, idempotentKey string) ([]*models.PaymentTransaction, error) {
+	items := make(map[money.Currency][]models.TransactionItem)
+	for _, payment := range payments {
+		items[payment.Currency] = append(items[payment.Currency], models.TransactionItem{PaymentID: payment.ID, Amount: payment.Outstanding()})
 	}
 
-	logger.WithFields(logrus.Fields{
-		"total_amount": totalAmount,
-	}).Info("Batch payment completed successfully")
+	currencies := make([]money.Currency, 0, len(items))
+	for currency := range items {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
 
-	return map[string]interface{}{
-		"status":       "batch payment successful",
-		"total_amount": totalAmount,
-	}, nil
+	checkouts := make([]*models.PaymentTransaction, 0, len(currencies))
+	for _, currency := range currencies {
+		key := idempotentKey
+		if len(currencies) > 1 {
+			key = idempotentKey + ":" + string(currency)
+		}
+		transaction, err := s.checkoutService.StartCheckout(ctx, userID, currency, items[currency], "Batch bill payment", key)
+		if err != nil {
+			return nil, err
+		}
+		checkouts = append(checkouts, transaction)
+	}
+	return checkouts, nil
+}
+
+// one wallet payment per apartment, the idempotency key gets the apartment appended when there are several
+func (s *billServiceImpl) payBatchFromWallets(ctx context.Context, userID int, payments []models.Payment, idempotentKey string) ([]*models.PaymentTransaction, error) {
+	byApartment, err := s.groupByApartment(payments)
+	if err != nil {
+		return nil, err
+	}
+
+	apartmentIDs := make([]int, 0, len(byApartment))
+	for apartmentID := range byApartment {
+		apartmentIDs = append(apartmentIDs, apartmentID)
+	}
+	sort.Ints(apartmentIDs)
+
+	transactions := make([]*models.PaymentTransaction, 0, len(apartmentIDs))
+	for _, apartmentID := range apartmentIDs {
+		group := byApartment[apartmentID]
+		items := make([]models.TransactionItem, 0, len(group))
+		for _, payment := range group {
+			if payment.Currency != group[0].Currency {
+				return nil, fmt.Errorf("payments in different currencies must be paid separately")
+			}
+			items = append(items, models.TransactionItem{PaymentID: payment.ID, Amount: payment.Outstanding()})
+		}
+
+		key := idempotentKey
+		if len(apartmentIDs) > 1 {
+			key = fmt.Sprintf("%s:%d", idempotentKey, apartmentID)
+		}
+		transaction, err := s.walletService.PayFromWallet(ctx, userID, apartmentID, group[0].Currency, items, key)
+		if err != nil {
+			return nil, fmt.Errorf("apartment %d: %w", apartmentID, err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
 }
 
-func (s *billServiceImpl) GetUnpaidBills(ctx context.Context, userID int) ([]models.Payment, error) {
-	return s.paymentRepo.GetPendingPaymentsByUser(userID)
+// pending penalties are listed under the share they were charged on, unless that share is already paid
+func (s *billServiceImpl) GetUnpaidBills(ctx context.Context, userID int) ([]UnpaidPayment, error) {
+	payments, err := s.paymentRepo.GetPendingPaymentsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingIDs := make(map[int]bool, len(payments))
+	ids := make([]int, 0, len(payments))
+	for _, payment := range payments {
+		pendingIDs[payment.ID] = true
+		ids = append(ids, payment.ID)
+	}
+	breakdowns := s.breakdownsOf(ids)
+	penalties := make(map[int][]models.Payment)
+	for _, payment := range payments {
+		if payment.Kind == models.PenaltyKind && payment.ParentPaymentID != nil && pendingIDs[*payment.ParentPaymentID] {
+			penalties[*payment.ParentPaymentID] = append(penalties[*payment.ParentPaymentID], payment)
+		}
+	}
+
+	unpaid := make([]UnpaidPayment, 0, len(payments))
+	for _, payment := range payments {
+		if payment.Kind == models.PenaltyKind && payment.ParentPaymentID != nil && pendingIDs[*payment.ParentPaymentID] {
+			continue
+		}
+		payment.Breakdown = breakdowns[payment.ID]
+		item := UnpaidPayment{Payment: payment, Penalties: penalties[payment.ID], TotalDue: payment.Outstanding()}
+		for _, penalty := range item.Penalties {
+			item.TotalDue += penalty.Outstanding()
+		}
+		unpaid = append(unpaid, item)
+	}
+	return unpaid, nil
 }
 
 func (s *billServiceImpl) GetBillWithPaymentStatus(ctx context.Context, userID, billID int) (map[string]interface{}, error) {
@@ -647,12 +1969,42 @@ func (s *billServiceImpl) GetBillWithPaymentStatus(ctx context.Context, userID,
 		return nil, fmt.Errorf("payment record not found: %w", err)
 	}
 
-	return map[string]interface{}{
+	response := map[string]interface{}{
 		"bill":           bill,
 		"payment_status": payment.PaymentStatus,
 		"amount_due":     payment.Amount,
 		"paid_at":        payment.PaidAt,
-	}, nil
+	}
+	if breakdown := s.breakdownsOf([]int{payment.ID})[payment.ID]; len(breakdown) > 0 {
+		response["breakdown"] = breakdown
+	}
+	return response, nil
+}
+
+// what each payment covers of its bill's line items. a failure only costs the breakdown, the
+// payments are still shown
+func (s *billServiceImpl) breakdownsOf(paymentIDs []int) map[int][]models.ShareLineItem {
+	breakdowns, err := s.paymentRepo.GetBreakdowns(paymentIDs)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get share breakdowns")
+		return nil
+	}
+	return breakdowns
+}
+
+func (s *billServiceImpl) penaltiesOf(paymentID int) []models.Payment {
+	children, err := s.paymentRepo.GetPaymentsByParent(paymentID)
+	if err != nil {
+		logrus.WithError(err).WithField("payment_id", paymentID).Warn("Failed to get penalties of payment")
+		return nil
+	}
+	var penalties []models.Payment
+	for _, child := range children {
+		if child.Kind == models.PenaltyKind {
+			penalties = append(penalties, child)
+		}
+	}
+	return penalties
 }
 
 func (s *billServiceImpl) GetUserPaymentHistory(ctx context.Context, userID int) ([]PaymentHistoryItem, error) {
@@ -675,12 +2027,22 @@ func (s *billServiceImpl) GetUserPaymentHistory(ctx context.Context, userID int)
 				history = append(history, PaymentHistoryItem{
 					Bill:          bill,
 					Payment:       *payment,
+					Penalties:     s.penaltiesOf(payment.ID),
 					ApartmentName: apartment.ApartmentName,
 				})
 			}
 		}
 	}
 
+	ids := make([]int, len(history))
+	for i, item := range history {
+		ids[i] = item.Payment.ID
+	}
+	breakdowns := s.breakdownsOf(ids)
+	for i := range history {
+		history[i].Payment.Breakdown = breakdowns[history[i].Payment.ID]
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"user_id":       userID,
 		"history_count": len(history),
@@ -688,3 +2050,26 @@ func (s *billServiceImpl) GetUserPaymentHistory(ctx context.Context, userID int)
 
 	return history, nil
 }
+
+// the status history of one payment, for the resident who owes it and the apartment's managers
+func (s *billServiceImpl) GetPaymentEvents(ctx context.Context, userID, paymentID int) ([]models.PaymentEvent, error) {
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found")
+	}
+	if payment.UserID != userID {
+		bill, err := s.repo.GetBillByID(payment.BillID)
+		if err != nil {
+			return nil, fmt.Errorf("payment not found")
+		}
+		if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID); err != nil || !ok {
+			return nil, fmt.Errorf("payment not found")
+		}
+	}
+
+	events, err := s.paymentRepo.GetPaymentEvents(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment history: %w", err)
+	}
+	return events, nil
+}
@@ -2,13 +2,17 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
-	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/payment"
 	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -20,42 +24,54 @@ func TestPayBills(t *testing.T) {
 		userID        int
 		paymentIDs    []int
 		idempotentKey string
-		setupMocks    func(*repositories.MockPaymentRepository, *payment.MockPayment)
+		setupMocks    func(*repositories.MockPaymentRepository, *MockCheckoutService)
 		expectedError error
 	}{
 		{
-			name:          "successful payment",
+			name:          "starts a checkout",
 			userID:        1,
 			paymentIDs:    []int{1, 2},
 			idempotentKey: "idemp123",
-			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, paymentService *payment.MockPayment) {
-				paymentService.On("PayBills", []int{1, 2}, "idemp123").Return(nil)
-				paymentRepo.On("UpdatePaymentsStatus", mock.Anything, mock.MatchedBy(func(payments []models.Payment) bool {
-					return len(payments) == 2 && payments[0].PaymentStatus == models.Paid
-				})).Return(nil)
+			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, checkoutService *MockCheckoutService) {
+				paymentRepo.On("GetPaymentByID", 1).Return(&models.Payment{BaseModel: models.BaseModel{ID: 1}, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Pending}, nil)
+				paymentRepo.On("GetPaymentByID", 2).Return(&models.Payment{BaseModel: models.BaseModel{ID: 2}, UserID: 1, Amount: 3000, AmountPaid: 1000, Currency: money.IRR, PaymentStatus: models.PartiallyPaid}, nil)
+				checkoutService.On("StartCheckout", mock.Anything, 1, money.IRR, []models.TransactionItem{
+					{PaymentID: 1, Amount: 5000},
+					{PaymentID: 2, Amount: 2000},
+				}, mock.Anything, "idemp123").Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 7}, Amount: 7000}, nil)
 			},
 			expectedError: nil,
 		},
 		{
-			name:          "payment service failure",
+			name:          "gateway failure",
 			userID:        1,
 			paymentIDs:    []int{1},
 			idempotentKey: "idemp123",
-			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, paymentService *payment.MockPayment) {
-				paymentService.On("PayBills", []int{1}, "idemp123").Return(errors.New("payment failed"))
+			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, checkoutService *MockCheckoutService) {
+				paymentRepo.On("GetPaymentByID", 1).Return(&models.Payment{BaseModel: models.BaseModel{ID: 1}, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Pending}, nil)
+				checkoutService.On("StartCheckout", mock.Anything, 1, money.IRR, mock.Anything, mock.Anything, "idemp123").Return(nil, errors.New("gateway down"))
 			},
 			expectedError: errors.New("payment failed"),
 		},
 		{
-			name:          "status update failure",
+			name:          "payment already in a checkout",
 			userID:        1,
 			paymentIDs:    []int{1},
 			idempotentKey: "idemp123",
-			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, paymentService *payment.MockPayment) {
-				paymentService.On("PayBills", []int{1}, "idemp123").Return(nil)
-				paymentRepo.On("UpdatePaymentsStatus", mock.Anything, mock.Anything).Return(errors.New("update failed"))
+			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, checkoutService *MockCheckoutService) {
+				paymentRepo.On("GetPaymentByID", 1).Return(&models.Payment{BaseModel: models.BaseModel{ID: 1}, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Processing}, nil)
 			},
-			expectedError: errors.New("failed to update payments status"),
+			expectedError: errors.New("already paid or being paid"),
+		},
+		{
+			name:          "someone else's payment",
+			userID:        2,
+			paymentIDs:    []int{1},
+			idempotentKey: "idemp123",
+			setupMocks: func(paymentRepo *repositories.MockPaymentRepository, checkoutService *MockCheckoutService) {
+				paymentRepo.On("GetPaymentByID", 1).Return(&models.Payment{BaseModel: models.BaseModel{ID: 1}, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Pending}, nil)
+			},
+			expectedError: errors.New("does not belong"),
 		},
 	}
 
@@ -63,12 +79,12 @@ func TestPayBills(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 
 			mockPaymentRepo := new(repositories.MockPaymentRepository)
-			mockPaymentService := new(payment.MockPayment)
+			mockCheckoutService := new(MockCheckoutService)
 			_ = new(repositories.MockBillRepository)
 			mockImageService := new(image.MockImage)
 			mockNotificationService := new(notification.MockNotification)
 
-			tt.setupMocks(mockPaymentRepo, mockPaymentService)
+			tt.setupMocks(mockPaymentRepo, mockCheckoutService)
 
 			billService := NewBillService(
 				nil,
@@ -76,22 +92,1297 @@ func TestPayBills(t *testing.T) {
 				nil,
 				nil,
 				mockPaymentRepo,
+				nil,
+				nil,
+				nil,
 				mockImageService,
-				mockPaymentService,
+				mockCheckoutService,
+				nil,
+				nil,
 				mockNotificationService,
 			)
 
-			err := billService.PayBills(context.Background(), tt.userID, tt.paymentIDs, tt.idempotentKey)
+			transaction, err := billService.PayBills(context.Background(), tt.userID, tt.paymentIDs, false, tt.idempotentKey)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
 				assert.Contains(t, err.Error(), tt.expectedError.Error())
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, 7, transaction.ID)
+			}
+
+			mockPaymentRepo.AssertExpectations(t)
+			mockCheckoutService.AssertExpectations(t)
+		})
+	}
+}
+
+// a category repo for apartments that only use the built-in categories
+func builtInCategories() *repositories.MockBillCategoryRepository {
+	repo := new(repositories.MockBillCategoryRepository)
+	repo.On("GetBillCategory", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
+	return repo
+}
+
+func dividedShares(billID, firstID int, amounts map[int]money.Amount, userIDs ...int) []models.Payment {
+	var shares []models.Payment
+	for i, userID := range userIDs {
+		shares = append(shares, models.Payment{BaseModel: models.BaseModel{ID: firstID + i}, BillID: billID, UserID: userID, Amount: amounts[userID], Kind: models.ShareKind})
+	}
+	return shares
+}
+
+func TestDivideBillByType(t *testing.T) {
+	members := []models.User_apartment{
+		{UserID: 1, ApartmentID: 7, IsManager: true},
+		{UserID: 2, ApartmentID: 7, UnitArea: 90},
+		{UserID: 3, ApartmentID: 7, UnitArea: 30},
+	}
+	bills := []models.Bill{
+		{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000, Currency: money.IRR},
+		{BaseModel: models.BaseModel{ID: 12}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 3000, Currency: money.IRR},
+	}
+	sharesOf := func(billID int, match func(p models.Payment) bool) interface{} {
+		return mock.MatchedBy(func(shares []models.Payment) bool {
+			for _, share := range shares {
+				if share.BillID != billID || !match(share) {
+					return false
+				}
+			}
+			return len(shares) > 0
+		})
+	}
+
+	tests := []struct {
+		name          string
+		dryRun        bool
+		setupMocks    func(*repositories.MockUserApartmentRepository, *repositories.MockSplitPolicyRepository, *repositories.MockBillRepository)
+		expectedError string
+		check         func(t *testing.T, response map[string]interface{})
+	}{
+		{
+			name: "divides by area and skips members without area",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(&models.SplitPolicy{Strategy: models.SplitByArea}, nil)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return(bills[:1], nil)
+				billRepo.On("DivideBill", mock.Anything, 11, mock.MatchedBy(func(shares []models.Payment) bool {
+					return len(shares) == 2 &&
+						shares[0].UserID == 2 && shares[0].Amount == 4500 && shares[0].Currency == money.IRR && shares[0].SplitStrategy == models.SplitByArea &&
+						shares[1].UserID == 3 && shares[1].Amount == 1500 && shares[1].Kind == models.ShareKind
+				}), mock.MatchedBy(func(e *models.JournalEntry) bool {
+					return e.EntryType == models.DivisionEntry && e.Reference == "bill:11"
+				})).Return(dividedShares(11, 1, map[int]money.Amount{2: 4500, 3: 1500}, 2, 3), nil).Once()
+			},
+			check: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, 1, response["processed_count"])
+				assert.NotContains(t, response, "warning")
+			},
+		},
+		{
+			name: "falls back to equal split without a policy",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return(bills[:1], nil)
+				billRepo.On("DivideBill", mock.Anything, 11, sharesOf(11, func(p models.Payment) bool {
+					return p.Amount == 2000 && p.SplitStrategy == models.SplitEqual
+				}), mock.Anything).Return(dividedShares(11, 1, map[int]money.Amount{1: 2000, 2: 2000, 3: 2000}, 1, 2, 3), nil).Once()
+			},
+			check: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, 1, response["processed_count"])
+			},
+		},
+		{
+			name: "a failed bill is left undivided and the others are divided",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return(bills, nil)
+				billRepo.On("DivideBill", mock.Anything, 11, mock.Anything, mock.Anything).Return(nil, errors.New("connection reset"))
+				billRepo.On("DivideBill", mock.Anything, 12, mock.Anything, mock.Anything).Return(dividedShares(12, 4, map[int]money.Amount{1: 1000, 2: 1000, 3: 1000}, 1, 2, 3), nil)
+			},
+			check: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, []int{12}, response["processed_bills"])
+				assert.Equal(t, []int{11}, response["failed_bills"])
+				assert.Contains(t, response, "warning")
+			},
+		},
+		{
+			name: "bill divided concurrently is skipped",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return(bills[:1], nil)
+				billRepo.On("DivideBill", mock.Anything, 11, mock.Anything, mock.Anything).Return(nil, repositories.ErrBillAlreadyDivided)
+			},
+			check: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, 0, response["processed_count"])
+				assert.Equal(t, []int{11}, response["skipped_bills"])
+				assert.NotContains(t, response, "warning")
+			},
+		},
+		{
+			name:   "dry run returns the shares without dividing",
+			dryRun: true,
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(&models.SplitPolicy{Strategy: models.SplitByArea}, nil)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return(bills, nil)
+			},
+			check: func(t *testing.T, response map[string]interface{}) {
+				assert.Equal(t, true, response["dry_run"])
+				shares := response["shares"].([]models.Payment)
+				if assert.Len(t, shares, 4) {
+					assert.Equal(t, money.Amount(4500), shares[0].Amount)
+					assert.Equal(t, 12, shares[2].BillID)
+					assert.Equal(t, money.Amount(2250), shares[2].Amount)
+				}
+			},
+		},
+		{
+			name:   "line items are split with their own strategy",
+			dryRun: true,
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return([]models.Bill{{
+					BaseModel: models.BaseModel{ID: 13}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 5400, Currency: money.IRR,
+					LineItems: []models.BillLineItem{
+						{ID: 1, BillID: 13, Name: "base", Category: models.BaseCharge, Amount: 3000},
+						{ID: 2, BillID: 13, Name: "usage", Category: models.ConsumptionCharge, Amount: 2400, SplitStrategy: models.SplitByArea},
+					},
+				}}, nil)
+			},
+			check: func(t *testing.T, response map[string]interface{}) {
+				shares := response["shares"].([]models.Payment)
+				if assert.Len(t, shares, 3) {
+					assert.Equal(t, money.Amount(1000), shares[0].Amount)
+					assert.Len(t, shares[0].Breakdown, 1, "members without area only pay the base charge")
+					assert.Equal(t, money.Amount(2800), shares[1].Amount)
+					assert.Equal(t, []models.ShareLineItem{
+						{LineItemID: 1, Name: "base", Category: models.BaseCharge, Amount: 1000},
+						{LineItemID: 2, Name: "usage", Category: models.ConsumptionCharge, Amount: 1800},
+					}, shares[1].Breakdown)
+					assert.Equal(t, money.Amount(1600), shares[2].Amount)
+				}
+			},
+		},
+		{
+			name: "misconfigured policy aborts before creating payments",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, policyRepo *repositories.MockSplitPolicyRepository, billRepo *repositories.MockBillRepository) {
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				policyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(&models.SplitPolicy{Strategy: models.SplitByPercentage}, nil)
+				billRepo.On("GetUndividedBillsByTypeAndApartment", 7, models.WaterBill).Return(bills, nil)
+			},
+			expectedError: "failed to apply percentage split",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockNotificationService := new(notification.MockNotification)
+			mockNotificationService.ExpectAnyNotificationCall(nil)
+
+			tt.setupMocks(mockUserAptRepo, mockPolicyRepo, mockBillRepo)
+
+			billService := NewBillService(
+				mockBillRepo,
+				nil,
+				nil,
+				mockUserAptRepo,
+				nil,
+				mockPolicyRepo,
+				builtInCategories(),
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				mockNotificationService,
+			)
+
+			response, err := billService.DivideBillByType(context.Background(), 1, 7, models.WaterBill, tt.dryRun)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				tt.check(t, response)
+			}
+			if tt.dryRun || tt.expectedError != "" {
+				mockBillRepo.AssertNotCalled(t, "DivideBill", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				mockNotificationService.AssertNotCalled(t, "SendBillNotification", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockUserAptRepo.AssertExpectations(t)
+			mockPolicyRepo.AssertExpectations(t)
+			mockBillRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDivideAllBills_AutoPay(t *testing.T) {
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockWalletService := new(MockWalletService)
+	mockNotificationService := new(notification.MockNotification)
+	mockNotificationService.ExpectAnyNotificationCall(nil)
+
+	members := []models.User_apartment{
+		{UserID: 2, ApartmentID: 7},
+		{UserID: 3, ApartmentID: 7},
+	}
+	bills := []models.Bill{{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000, Currency: money.IRR}}
+
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockUserAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+	mockPolicyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+	mockBillRepo.On("GetUndividedBillsByApartment", 7).Return(bills, nil)
+	// both shares are charged in one entry against the bill, inside the division's transaction
+	mockBillRepo.On("DivideBill", mock.Anything, 11, mock.Anything, mock.MatchedBy(func(e *models.JournalEntry) bool {
+		return e.EntryType == models.DivisionEntry && e.Reference == "bill:11" && len(e.Lines) == 3 &&
+			e.Lines[2].Account == models.BillsToDivide && e.Lines[2].Credit == 6000
+	})).Return(dividedShares(11, 21, map[int]money.Amount{2: 3000, 3: 3000}, 2, 3), nil).Once()
+	mockWalletService.On("AutoPayShares", mock.Anything, 7, mock.MatchedBy(func(shares []models.Payment) bool {
+		return len(shares) == 2 && shares[0].ID == 21 && shares[1].ID == 22 && shares[0].Amount == 3000
+	})).Return(1)
+
+	billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, mockPolicyRepo, builtInCategories(), nil, nil, nil, mockWalletService, nil, mockNotificationService)
+	response, err := billService.DivideAllBills(context.Background(), 1, 7, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, response["auto_paid_count"])
+	mockBillRepo.AssertExpectations(t)
+	mockWalletService.AssertExpectations(t)
+	mockNotificationService.AssertNumberOfCalls(t, "SendBillNotification", 2)
+}
+
+func TestDivideAllBills_DryRun(t *testing.T) {
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockWalletService := new(MockWalletService)
+
+	members := []models.User_apartment{
+		{UserID: 2, ApartmentID: 7},
+		{UserID: 3, ApartmentID: 7},
+	}
+	bills := []models.Bill{{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6001, Currency: money.IRR}}
+
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockUserAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+	mockPolicyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+	mockBillRepo.On("GetUndividedBillsByApartment", 7).Return(bills, nil)
+
+	billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, mockPolicyRepo, builtInCategories(), nil, nil, nil, mockWalletService, nil, nil)
+	response, err := billService.DivideAllBills(context.Background(), 1, 7, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["dry_run"])
+	shares := response["shares"].([]models.Payment)
+	if assert.Len(t, shares, 2) {
+		assert.Equal(t, money.Amount(6001), shares[0].Amount+shares[1].Amount)
+		assert.Zero(t, shares[0].ID)
+	}
+	mockBillRepo.AssertNotCalled(t, "DivideBill", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockWalletService.AssertNotCalled(t, "AutoPayShares", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// "divide all" pressed several times at once: the bill lock lets one division create the shares and
+// the others find the bill divided, so every resident is charged and notified once
+func TestDivideAllBills_Concurrent(t *testing.T) {
+	const runs = 10
+
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockWalletService := new(MockWalletService)
+	mockNotificationService := new(notification.MockNotification)
+	mockNotificationService.ExpectAnyNotificationCall(nil)
+
+	members := []models.User_apartment{
+		{UserID: 2, ApartmentID: 7},
+		{UserID: 3, ApartmentID: 7},
+	}
+	bills := []models.Bill{{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000, Currency: money.IRR}}
+
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockUserAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+	mockPolicyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+	// every run reads the bill as undivided before any of them divides it
+	mockBillRepo.On("GetUndividedBillsByApartment", 7).Return(bills, nil)
+	mockBillRepo.On("DivideBill", mock.Anything, 11, mock.Anything, mock.Anything).Return(dividedShares(11, 21, map[int]money.Amount{2: 3000, 3: 3000}, 2, 3), nil).Once()
+	mockBillRepo.On("DivideBill", mock.Anything, 11, mock.Anything, mock.Anything).Return(nil, repositories.ErrBillAlreadyDivided)
+
+	var mu sync.Mutex
+	var autoPayShares []models.Payment
+	mockWalletService.On("AutoPayShares", mock.Anything, 7, mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		autoPayShares = append(autoPayShares, args.Get(2).([]models.Payment)...)
+	}).Return(0)
+
+	billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, mockPolicyRepo, builtInCategories(), nil, nil, nil, mockWalletService, nil, mockNotificationService)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := billService.DivideAllBills(context.Background(), 1, 7, false)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, autoPayShares, 2)
+	mockBillRepo.AssertNumberOfCalls(t, "DivideBill", runs)
+	mockNotificationService.AssertNumberOfCalls(t, "SendBillNotification", 2)
+}
+
+func TestPayBills_FromWallet(t *testing.T) {
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockWalletService := new(MockWalletService)
+
+	mockPaymentRepo.On("GetPaymentByID", 1).Return(&models.Payment{BaseModel: models.BaseModel{ID: 1}, BillID: 11, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Pending}, nil)
+	mockPaymentRepo.On("GetPaymentByID", 2).Return(&models.Payment{BaseModel: models.BaseModel{ID: 2}, BillID: 12, UserID: 1, Amount: 3000, Currency: money.IRR, PaymentStatus: models.Pending}, nil)
+	mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7}, nil)
+	mockBillRepo.On("GetBillByID", 12).Return(&models.Bill{BaseModel: models.BaseModel{ID: 12}, ApartmentID: 7}, nil)
+	mockWalletService.On("PayFromWallet", mock.Anything, 1, 7, money.IRR, []models.TransactionItem{
+		{PaymentID: 1, Amount: 5000},
+		{PaymentID: 2, Amount: 3000},
+	}, "idemp123").Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 9}, Gateway: models.WalletGateway, Status: models.TransactionSucceeded}, nil)
+
+	billService := NewBillService(mockBillRepo, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, nil, mockWalletService, nil, nil)
+	transaction, err := billService.PayBills(context.Background(), 1, []int{1, 2}, true, "idemp123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.TransactionSucceeded, transaction.Status)
+	mockWalletService.AssertExpectations(t)
+}
+
+func TestPayBatchBills_FromWallets(t *testing.T) {
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockWalletService := new(MockWalletService)
+
+	pending := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 4}, BillID: 11, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Pending},
+		{BaseModel: models.BaseModel{ID: 5}, BillID: 12, UserID: 1, Amount: 2000, Currency: money.IRR, PaymentStatus: models.Pending},
+	}
+	mockPaymentRepo.On("GetPendingPaymentsByUser", 1).Return(pending, nil)
+	mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7}, nil)
+	mockBillRepo.On("GetBillByID", 12).Return(&models.Bill{BaseModel: models.BaseModel{ID: 12}, ApartmentID: 8}, nil)
+	mockWalletService.On("PayFromWallet", mock.Anything, 1, 7, money.IRR, mock.Anything, "idemp123:7").Return(&models.PaymentTransaction{}, nil)
+	mockWalletService.On("PayFromWallet", mock.Anything, 1, 8, money.IRR, mock.Anything, "idemp123:8").Return(nil, repositories.ErrInsufficientFunds)
+
+	billService := NewBillService(mockBillRepo, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, nil, mockWalletService, nil, nil)
+	_, err := billService.PayBatchBills(context.Background(), 1, true, "idemp123")
+
+	assert.ErrorIs(t, err, repositories.ErrInsufficientFunds)
+	assert.ErrorContains(t, err, "apartment 8")
+	mockWalletService.AssertExpectations(t)
+}
+
+func TestPayBatchBills(t *testing.T) {
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockCheckoutService := new(MockCheckoutService)
+
+	pending := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 4}, UserID: 1, Amount: 3334, Currency: money.IRR, PaymentStatus: models.Pending},
+		{BaseModel: models.BaseModel{ID: 9}, UserID: 1, Amount: 3333, Currency: money.IRR, PaymentStatus: models.Pending},
+		{BaseModel: models.BaseModel{ID: 11}, UserID: 1, Amount: 1000, Currency: money.IRR, PaymentStatus: models.Processing},
+		{BaseModel: models.BaseModel{ID: 12}, UserID: 1, Amount: 9000, Currency: money.IRR, PaymentStatus: models.PartiallyPaid, OnInstallments: true},
+	}
+	mockPaymentRepo.On("GetPendingPaymentsByUser", 1).Return(pending, nil)
+	mockCheckoutService.On("StartCheckout", mock.Anything, 1, money.IRR, []models.TransactionItem{
+		{PaymentID: 4, Amount: 3334},
+		{PaymentID: 9, Amount: 3333},
+	}, mock.Anything, "idemp123").Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 3}, Amount: 6667}, nil)
+
+	billService := NewBillService(nil, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, mockCheckoutService, nil, nil, nil)
+
+	response, err := billService.PayBatchBills(context.Background(), 1, false, "idemp123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(6667), response["total_amount"])
+	assert.Equal(t, money.IRR, response["currency"])
+	assert.Len(t, response["checkouts"], 1)
+	mockPaymentRepo.AssertExpectations(t)
+	mockCheckoutService.AssertExpectations(t)
+}
+
+func TestPayBatchBills_OneCheckoutPerCurrency(t *testing.T) {
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockCheckoutService := new(MockCheckoutService)
+
+	pending := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 4}, UserID: 1, Amount: 5000, Currency: money.IRR, PaymentStatus: models.Pending},
+		{BaseModel: models.BaseModel{ID: 5}, UserID: 1, Amount: 200, Currency: money.USD, PaymentStatus: models.Pending},
+	}
+	mockPaymentRepo.On("GetPendingPaymentsByUser", 1).Return(pending, nil)
+	mockCheckoutService.On("StartCheckout", mock.Anything, 1, money.IRR, mock.Anything, mock.Anything, "idemp123:IRR").Return(&models.PaymentTransaction{}, nil)
+	mockCheckoutService.On("StartCheckout", mock.Anything, 1, money.USD, mock.Anything, mock.Anything, "idemp123:USD").Return(&models.PaymentTransaction{}, nil)
+
+	billService := NewBillService(nil, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, mockCheckoutService, nil, nil, nil)
+
+	response, err := billService.PayBatchBills(context.Background(), 1, false, "idemp123")
+
+	assert.NoError(t, err)
+	assert.Len(t, response["checkouts"], 2)
+	assert.NotContains(t, response, "total_amount")
+	mockCheckoutService.AssertExpectations(t)
+}
+
+func TestRedivideBill(t *testing.T) {
+	members := []models.User_apartment{
+		{UserID: 1, ApartmentID: 7, IsManager: true},
+		{UserID: 2, ApartmentID: 7},
+		{UserID: 3, ApartmentID: 7},
+	}
+	payments := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 21}, BillID: 11, UserID: 1, Amount: 2000, PaymentStatus: models.Pending, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 22}, BillID: 11, UserID: 2, Amount: 2000, PaymentStatus: models.Paid, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 23}, BillID: 11, UserID: 3, Amount: 2000, PaymentStatus: models.Pending, Kind: models.ShareKind},
+	}
+	bill := func(total money.Amount) *models.Bill {
+		return &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: total, Currency: money.IRR}
+	}
+
+	tests := []struct {
+		name          string
+		setupMocks    func(*repositories.MockUserApartmentRepository, *repositories.MockBillRepository, *repositories.MockPaymentRepository)
+		notApproved   bool
+		expectedError string
+	}{
+		{
+			name: "increase updates pending shares and charges the paid one",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				billRepo.On("GetBillByID", 11).Return(bill(9000), nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				paymentRepo.On("GetPaymentsByBill", 11).Return(payments, nil)
+				billRepo.On("RedivideBill", mock.Anything, mock.MatchedBy(func(plan models.Redivision) bool {
+					return plan.BillID == 11 && len(plan.Seen) == 3 && len(plan.Deleted) == 0 &&
+						len(plan.Updated) == 2 && plan.Updated[0].ID == 21 && plan.Updated[0].Amount == 3000 &&
+						plan.Updated[1].ID == 23 && plan.Updated[1].Amount == 3000 &&
+						len(plan.Created) == 1 && plan.Created[0].UserID == 2 && plan.Created[0].Amount == 1000 &&
+						plan.Created[0].Kind == models.AdjustmentKind && *plan.Created[0].ParentPaymentID == 22 &&
+						plan.Created[0].PaymentStatus == models.Pending &&
+						plan.Journal.EntryType == models.RedivisionEntry && plan.Journal.Balanced()
+				})).Return(nil).Once()
+			},
+		},
+		{
+			name: "decrease credits the resident who already paid",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				billRepo.On("GetBillByID", 11).Return(bill(3000), nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				paymentRepo.On("GetPaymentsByBill", 11).Return(payments, nil)
+				billRepo.On("RedivideBill", mock.Anything, mock.MatchedBy(func(plan models.Redivision) bool {
+					if len(plan.Updated) != 2 || len(plan.Created) != 1 {
+						return false
+					}
+					credit := plan.Created[0]
+					var wallet money.Amount
+					for _, line := range plan.Journal.Lines {
+						if line.Account == models.ResidentWallet && *line.UserID == 2 {
+							wallet += line.Credit
+						}
+					}
+					//the credit is settled at once so it is neither payable nor keeps the bill open
+					return plan.Updated[0].Amount == 1000 && plan.Updated[1].Amount == 1000 && plan.ApartmentID == 7 &&
+						credit.UserID == 2 && credit.Amount == -1000 && credit.Kind == models.AdjustmentKind &&
+						credit.PaymentStatus == models.Paid && credit.AmountPaid == -1000 && credit.Outstanding() == 0 &&
+						wallet == 1000 && plan.Journal.Balanced()
+				})).Return(nil).Once()
+			},
+		},
+		{
+			name: "payments changed while planning",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				billRepo.On("GetBillByID", 11).Return(bill(9000), nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				userAptRepo.On("GetMembershipsInApartment", 7).Return(members, nil)
+				paymentRepo.On("GetPaymentsByBill", 11).Return(payments, nil)
+				billRepo.On("RedivideBill", mock.Anything, mock.Anything).Return(repositories.ErrBillPaymentsChanged).Once()
+			},
+			expectedError: "try again",
+		},
+		{
+			name: "edit that is waiting for approval or was rejected",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				billRepo.On("GetBillByID", 11).Return(bill(90000000), nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			},
+			notApproved:   true,
+			expectedError: "needs approval",
+		},
+		{
+			name: "bill that was never divided",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				billRepo.On("GetBillByID", 11).Return(bill(9000), nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				paymentRepo.On("GetPaymentsByBill", 11).Return([]models.Payment{}, nil)
+			},
+			expectedError: "has not been divided",
+		},
+		{
+			name: "non manager",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				billRepo.On("GetBillByID", 11).Return(bill(9000), nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(false, errors.New("not manager"))
+			},
+			expectedError: "only apartment managers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockNotificationService := new(notification.MockNotification)
+			mockNotificationService.ExpectAnyNotificationCall(nil)
+			mockPolicyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+
+			mockApprovalService := new(MockApprovalService)
+			mockApprovalService.On("Approved", mock.Anything).Return(!tt.notApproved, nil)
+
+			tt.setupMocks(mockUserAptRepo, mockBillRepo, mockPaymentRepo)
+
+			billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, mockPaymentRepo, mockPolicyRepo, builtInCategories(), nil, nil, nil, nil, mockApprovalService, mockNotificationService)
+			response, err := billService.RedivideBill(context.Background(), 1, 11)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				if tt.notApproved {
+					mockBillRepo.AssertNotCalled(t, "RedivideBill", mock.Anything, mock.Anything)
+				}
+				return
 			}
+			assert.NoError(t, err)
+			assert.Equal(t, 2, response["updated_shares"])
+			assert.Equal(t, 1, response["adjustments"])
+			mockBillRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRedivideBill_LineItems(t *testing.T) {
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockNotificationService := new(notification.MockNotification)
+	mockNotificationService.ExpectAnyNotificationCall(nil)
+	mockApprovalService := new(MockApprovalService)
+	mockApprovalService.On("Approved", mock.Anything).Return(true, nil)
+
+	base := models.BillLineItem{ID: 1, BillID: 11, Name: "base", Category: models.BaseCharge, Amount: 3000}
+	usage := models.BillLineItem{ID: 2, BillID: 11, Name: "usage", Category: models.ConsumptionCharge, Amount: 3000, SplitStrategy: models.SplitByArea}
+	mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{
+		BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000, Currency: money.IRR,
+		LineItems: []models.BillLineItem{base, usage},
+	}, nil)
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	//the areas changed since the bill was divided evenly
+	mockUserAptRepo.On("GetMembershipsInApartment", 7).Return([]models.User_apartment{
+		{UserID: 1, ApartmentID: 7, IsManager: true, UnitArea: 100},
+		{UserID: 2, ApartmentID: 7, UnitArea: 50},
+	}, nil)
+	mockPolicyRepo.On("GetSplitPolicy", 7, models.WaterBill).Return(nil, sql.ErrNoRows)
+	mockPaymentRepo.On("GetPaymentsByBill", 11).Return([]models.Payment{
+		{BaseModel: models.BaseModel{ID: 21}, BillID: 11, UserID: 1, Amount: 3000, PaymentStatus: models.Pending, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 22}, BillID: 11, UserID: 2, Amount: 3000, PaymentStatus: models.Paid, Kind: models.ShareKind},
+	}, nil)
+	mockPaymentRepo.On("GetBreakdowns", []int{21, 22}).Return(map[int][]models.ShareLineItem{
+		21: {{PaymentID: 21, LineItemID: 1, Amount: 1500}, {PaymentID: 21, LineItemID: 2, Amount: 1500}},
+		22: {{PaymentID: 22, LineItemID: 1, Amount: 1500}, {PaymentID: 22, LineItemID: 2, Amount: 1500}},
+	}, nil)
+	mockBillRepo.On("RedivideBill", mock.Anything, mock.MatchedBy(func(plan models.Redivision) bool {
+		if len(plan.Updated) != 1 || len(plan.Created) != 1 {
+			return false
+		}
+		adjustment := plan.Created[0]
+		return plan.Updated[0].ID == 21 && plan.Updated[0].Amount == 3500 &&
+			assert.ObjectsAreEqual([]models.ShareLineItem{
+				{LineItemID: 1, Name: "base", Category: models.BaseCharge, Amount: 1500},
+				{LineItemID: 2, Name: "usage", Category: models.ConsumptionCharge, Amount: 2000},
+			}, plan.Updated[0].Breakdown) &&
+			adjustment.UserID == 2 && adjustment.Amount == -500 && adjustment.Kind == models.AdjustmentKind &&
+			len(adjustment.Breakdown) == 1 && adjustment.Breakdown[0].LineItemID == 2 && adjustment.Breakdown[0].Amount == -500
+	})).Return(nil).Once()
+
+	billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, mockPaymentRepo, mockPolicyRepo, builtInCategories(), nil, nil, nil, nil, mockApprovalService, mockNotificationService)
+	response, err := billService.RedivideBill(context.Background(), 1, 11)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, response["updated_shares"])
+	assert.Equal(t, 1, response["adjustments"])
+	mockBillRepo.AssertExpectations(t)
+}
+
+func TestUpdateBill_Approval(t *testing.T) {
+	divided := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000, Currency: money.IRR, Status: models.BillDivided}
+	held := mock.MatchedBy(func(approval *models.BillApproval) bool {
+		return approval != nil && approval.UserID == 1 && approval.Decision == models.ApprovalRequested
+	})
+	notHeld := mock.MatchedBy(func(approval *models.BillApproval) bool { return approval == nil })
+
+	tests := []struct {
+		name          string
+		amount        money.Amount
+		setupMocks    func(*MockApprovalService, *repositories.MockBillRepository, *repositories.MockPaymentRepository)
+		redivided     bool
+		expectedError string
+	}{
+		{
+			name:   "raising a divided bill above the threshold holds it with the update",
+			amount: 90000000,
+			setupMocks: func(approvalService *MockApprovalService, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				approvalService.On("Requires", mock.MatchedBy(func(bill models.Bill) bool {
+					return bill.ID == 11 && bill.TotalAmount == 90000000
+				})).Return(true, nil).Once()
+				billRepo.On("UpdateBill", mock.Anything, mock.Anything, mock.Anything, held).Return(nil).Once()
+				approvalService.On("NotifyApprovers", mock.Anything, 1, mock.Anything).Once()
+			},
+		},
+		{
+			name:   "editing a bill whose raise was rejected asks again",
+			amount: 6000,
+			setupMocks: func(approvalService *MockApprovalService, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				approvalService.On("Approved", mock.Anything).Return(false, nil).Once()
+				approvalService.On("Requires", mock.Anything).Return(true, nil).Once()
+				billRepo.On("UpdateBill", mock.Anything, mock.Anything, mock.Anything, held).Return(nil).Once()
+				approvalService.On("NotifyApprovers", mock.Anything, 1, mock.Anything).Once()
+			},
+		},
+		{
+			name:   "raise under the threshold is re-divided",
+			amount: 9000,
+			setupMocks: func(approvalService *MockApprovalService, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				approvalService.On("Requires", mock.Anything).Return(false, nil).Once()
+				billRepo.On("UpdateBill", mock.Anything, mock.Anything, mock.Anything, notHeld).Return(nil).Once()
+				paymentRepo.On("GetPaymentsByBill", 11).Return([]models.Payment{}, nil).Once()
+			},
+			redivided: true,
+		},
+		{
+			name:   "failed policy check leaves the bill as it was",
+			amount: 90000000,
+			setupMocks: func(approvalService *MockApprovalService, billRepo *repositories.MockBillRepository, paymentRepo *repositories.MockPaymentRepository) {
+				approvalService.On("Requires", mock.Anything).Return(false, errors.New("failed to get approval policy")).Once()
+			},
+			expectedError: "approval policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockApprovalService := new(MockApprovalService)
+			mockBillRepo.On("GetBillByID", 11).Return(divided, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			tt.setupMocks(mockApprovalService, mockBillRepo, mockPaymentRepo)
 
+			billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, mockPaymentRepo, nil, builtInCategories(), nil, nil, nil, nil, mockApprovalService, nil)
+			err := billService.UpdateBill(context.Background(), 1, 11, 7, string(models.WaterBill), tt.amount, "2025-08-01", "2025-08-15", "", nil)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockBillRepo.AssertNotCalled(t, "UpdateBill", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			mockBillRepo.AssertExpectations(t)
+			mockApprovalService.AssertExpectations(t)
 			mockPaymentRepo.AssertExpectations(t)
-			mockPaymentService.AssertExpectations(t)
+			if !tt.redivided {
+				mockPaymentRepo.AssertNotCalled(t, "GetPaymentsByBill", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUpdateBill_Authorization(t *testing.T) {
+	bill := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000, Currency: money.IRR, Status: models.BillDraft}
+
+	tests := []struct {
+		name          string
+		userID        int
+		apartmentID   int
+		expectedError string
+	}{
+		{name: "manager of another apartment", userID: 2, apartmentID: 7, expectedError: "only apartment managers"},
+		{name: "manager of the other apartment naming it in the request", userID: 2, apartmentID: 8, expectedError: "only apartment managers"},
+		{name: "moving the bill to another apartment", userID: 1, apartmentID: 8, expectedError: "can't be moved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockBillRepo.On("GetBillByID", 11).Return(bill, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 2, 7).Return(false, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 2, 8).Return(true, nil)
+
+			billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, nil, builtInCategories(), nil, nil, nil, nil, nil, nil)
+			err := billService.UpdateBill(context.Background(), tt.userID, 11, tt.apartmentID, string(models.WaterBill), 9000, "2025-08-01", "2025-08-15", "", nil)
+
+			assert.ErrorContains(t, err, tt.expectedError)
+			mockBillRepo.AssertNotCalled(t, "UpdateBill", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDeleteBill(t *testing.T) {
+	bill := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, Status: models.BillDraft}
+
+	tests := []struct {
+		name          string
+		userID        int
+		expectedError string
+	}{
+		{name: "manager deletes the bill", userID: 1},
+		{name: "manager of another apartment", userID: 2, expectedError: "only apartment managers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockBillRepo.On("GetBillByID", 11).Return(bill, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 2, 7).Return(false, nil)
+			mockBillRepo.On("DeleteBill", mock.Anything, 11).Return(nil)
+
+			billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+			err := billService.DeleteBill(context.Background(), tt.userID, 11)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockBillRepo.AssertNotCalled(t, "DeleteBill", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			mockBillRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBillLineItems(t *testing.T) {
+	items, total, err := billLineItems(models.WaterBill, 0, []dto.LineItemRequest{
+		{Name: "base", Category: models.BaseCharge, Amount: 2000},
+		{Name: "usage", Category: models.ConsumptionCharge, Amount: 3500, SplitStrategy: models.SplitByMeter},
+		{Name: "rounding", Amount: 1},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(5501), total)
+	assert.Equal(t, models.OtherCharge, items[2].Category)
+
+	_, _, err = billLineItems(models.WaterBill, 5000, []dto.LineItemRequest{{Name: "base", Amount: 2000}})
+	assert.ErrorContains(t, err, "doesn't match the line items")
+	_, _, err = billLineItems(models.WaterBill, 0, []dto.LineItemRequest{{Name: "base", Amount: 2000, SplitStrategy: models.SplitMixed}})
+	assert.ErrorContains(t, err, "mixed split")
+	_, _, err = billLineItems(models.WaterBill, 0, []dto.LineItemRequest{{Name: "base", Category: "snacks", Amount: 2000}})
+	assert.ErrorContains(t, err, "invalid category")
+	_, _, err = billLineItems(models.WaterBill, 0, []dto.LineItemRequest{{Amount: 2000}})
+	assert.ErrorContains(t, err, "needs a name")
+
+	items, total, err = billLineItems(models.WaterBill, 4000, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, items)
+	assert.Equal(t, money.Amount(4000), total)
+}
+
+func TestPublishBill(t *testing.T) {
+	draft := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, Status: models.BillDraft}
+	divided := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, Status: models.BillDivided}
+
+	tests := []struct {
+		name           string
+		setupMocks     func(*repositories.MockUserApartmentRepository, *repositories.MockBillRepository, *MockApprovalService)
+		expectedStatus models.BillStatus
+		expectedError  error
+	}{
+		{
+			name: "draft is published",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, approvalService *MockApprovalService) {
+				billRepo.On("GetBillByID", 11).Return(draft, nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				approvalService.On("RequestApproval", mock.Anything, 1, *draft).Return(false, nil)
+				billRepo.On("UpdateBillStatus", mock.Anything, 11, models.BillPublished).Return(nil).Once()
+			},
+			expectedStatus: models.BillPublished,
+		},
+		{
+			name: "draft above the threshold is held for approval",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, approvalService *MockApprovalService) {
+				billRepo.On("GetBillByID", 11).Return(draft, nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+				approvalService.On("RequestApproval", mock.Anything, 1, *draft).Return(true, nil)
+			},
+			expectedStatus: models.BillPendingApproval,
+		},
+		{
+			name: "bill that is past the draft",
+			setupMocks: func(userAptRepo *repositories.MockUserApartmentRepository, billRepo *repositories.MockBillRepository, approvalService *MockApprovalService) {
+				billRepo.On("GetBillByID", 11).Return(divided, nil)
+				userAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			},
+			expectedError: repositories.ErrInvalidBillTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockApprovalService := new(MockApprovalService)
+			tt.setupMocks(mockUserAptRepo, mockBillRepo, mockApprovalService)
+
+			billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, nil, nil, nil, nil, nil, nil, mockApprovalService, nil)
+			status, err := billService.PublishBill(context.Background(), 1, 11)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, status)
+			mockBillRepo.AssertExpectations(t)
+			mockApprovalService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelBill(t *testing.T) {
+	resident := 2
+	bill := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, Currency: money.IRR, Status: models.BillDivided}
+
+	t.Run("residents with a voided share are told", func(t *testing.T) {
+		mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+		mockBillRepo := new(repositories.MockBillRepository)
+		mockNotificationService := new(notification.MockNotification)
+
+		mockBillRepo.On("GetBillByID", 11).Return(bill, nil)
+		mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+		mockBillRepo.On("CancelBill", mock.Anything, 11, 1).Return(
+			models.NewJournalEntry(7, models.CancellationEntry, "bill:11", "Cancelled water bill", money.IRR).
+				Debit(models.BillsToDivide, nil, 3000).
+				Credit(models.ResidentReceivable, &resident, 3000).
+				Debit(models.UtilityPayable, nil, 3000).
+				Credit(models.BillsToDivide, nil, 3000), nil)
+		mockNotificationService.On("SendNotification", mock.Anything, resident, mock.Anything).Return(nil).Once()
+
+		billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, nil, nil, nil, nil, nil, nil, nil, mockNotificationService)
+		journal, err := billService.CancelBill(context.Background(), 1, 11)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.CancellationEntry, journal.EntryType)
+		mockNotificationService.AssertExpectations(t)
+	})
+
+	t.Run("paid bill is refused", func(t *testing.T) {
+		mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+		mockBillRepo := new(repositories.MockBillRepository)
+
+		mockBillRepo.On("GetBillByID", 11).Return(bill, nil)
+		mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+		mockBillRepo.On("CancelBill", mock.Anything, 11, 1).Return(nil, repositories.ErrBillHasPayments)
+
+		billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		_, err := billService.CancelBill(context.Background(), 1, 11)
+
+		assert.ErrorIs(t, err, repositories.ErrBillHasPayments)
+	})
+
+	t.Run("non manager", func(t *testing.T) {
+		mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+		mockBillRepo := new(repositories.MockBillRepository)
+
+		mockBillRepo.On("GetBillByID", 11).Return(bill, nil)
+		mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 2, 7).Return(false, nil)
+
+		billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		_, err := billService.CancelBill(context.Background(), 2, 11)
+
+		assert.ErrorContains(t, err, "only apartment managers")
+		mockBillRepo.AssertNotCalled(t, "CancelBill", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetUnpaidBills(t *testing.T) {
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	share := 4
+	paidShare := 2
+	mockPaymentRepo.On("GetPendingPaymentsByUser", 1).Return([]models.Payment{
+		{BaseModel: models.BaseModel{ID: 4}, BillID: 10, UserID: 1, Amount: 20000, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 7}, BillID: 10, UserID: 1, Amount: 500, Kind: models.PenaltyKind, ParentPaymentID: &share},
+		{BaseModel: models.BaseModel{ID: 8}, BillID: 9, UserID: 1, Amount: 300, Kind: models.PenaltyKind, ParentPaymentID: &paidShare},
+	}, nil)
+	mockPaymentRepo.On("GetBreakdowns", []int{4, 7, 8}).Return(map[int][]models.ShareLineItem{
+		4: {{LineItemID: 1, Name: "base charge", Category: models.BaseCharge, Amount: 5000},
+			{LineItemID: 2, Name: "consumption", Category: models.ConsumptionCharge, Amount: 15000}},
+	}, nil)
+
+	billService := NewBillService(nil, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, nil, nil, nil, nil)
+	unpaid, err := billService.GetUnpaidBills(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, unpaid, 2)
+	assert.Equal(t, 4, unpaid[0].ID)
+	assert.Len(t, unpaid[0].Penalties, 1)
+	assert.Equal(t, money.Amount(20500), unpaid[0].TotalDue)
+	assert.Len(t, unpaid[0].Breakdown, 2, "the share shows what it covers of each line item")
+	assert.Equal(t, 8, unpaid[1].ID, "a penalty on a paid share is listed on its own")
+	assert.Equal(t, money.Amount(300), unpaid[1].TotalDue)
+}
+
+func TestPayPartial(t *testing.T) {
+	pending := &models.Payment{BaseModel: models.BaseModel{ID: 4}, UserID: 1, Amount: 30000, AmountPaid: 10000, Currency: money.IRR, PaymentStatus: models.PartiallyPaid}
+
+	tests := []struct {
+		name          string
+		userID        int
+		amount        money.Amount
+		expectedError string
+	}{
+		{name: "pays part of the rest", userID: 1, amount: 5000},
+		{name: "more than outstanding", userID: 1, amount: 25000, expectedError: "outstanding"},
+		{name: "not the owner", userID: 2, amount: 5000, expectedError: "does not belong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockCheckoutService := new(MockCheckoutService)
+
+			mockPaymentRepo.On("GetPaymentByID", 4).Return(pending, nil)
+			mockCheckoutService.On("StartCheckout", mock.Anything, 1, money.IRR, []models.TransactionItem{{PaymentID: 4, Amount: tt.amount}}, mock.Anything, "idemp123").
+				Return(&models.PaymentTransaction{Amount: tt.amount, Status: models.TransactionProcessing}, nil)
+
+			billService := NewBillService(nil, nil, nil, nil, mockPaymentRepo, nil, nil, nil, nil, mockCheckoutService, nil, nil, nil)
+			transaction, err := billService.PayPartial(context.Background(), tt.userID, 4, tt.amount, "idemp123")
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockCheckoutService.AssertNotCalled(t, "StartCheckout", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, money.Amount(5000), transaction.Amount)
+			assert.Equal(t, models.TransactionProcessing, transaction.Status)
+		})
+	}
+}
+
+func TestGetPaymentEvents(t *testing.T) {
+	payment := &models.Payment{BaseModel: models.BaseModel{ID: 4}, BillID: 11, UserID: 2, PaymentStatus: models.Paid}
+	events := []models.PaymentEvent{
+		{PaymentID: 4, ToStatus: models.Pending, Reason: "created"},
+		{PaymentID: 4, FromStatus: models.Pending, ToStatus: models.Paid, Reason: "paid from wallet (transaction 7)"},
+	}
+
+	tests := []struct {
+		name          string
+		userID        int
+		isManager     bool
+		expectedError string
+	}{
+		{name: "the resident who owes it", userID: 2},
+		{name: "the apartment's manager", userID: 1, isManager: true},
+		{name: "another resident", userID: 3, expectedError: "payment not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+
+			mockPaymentRepo.On("GetPaymentByID", 4).Return(payment, nil)
+			mockPaymentRepo.On("GetPaymentEvents", 4).Return(events, nil)
+			mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7}, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, tt.userID, 7).Return(tt.isManager, nil)
+
+			billService := NewBillService(mockBillRepo, nil, nil, mockUserAptRepo, mockPaymentRepo, nil, nil, nil, nil, nil, nil, nil, nil)
+			history, err := billService.GetPaymentEvents(context.Background(), tt.userID, 4)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockPaymentRepo.AssertNotCalled(t, "GetPaymentEvents", 4)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, events, history)
+		})
+	}
+}
+
+func TestCreateBill_CustomCategory(t *testing.T) {
+	mockApartmentRepo := new(repositories.MockApartmentRepo)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockCategoryRepo := new(repositories.MockBillCategoryRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+
+	mockApartmentRepo.On("GetApartmentByID", 7).Return(&models.Apartment{}, nil)
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockCategoryRepo.On("GetBillCategory", 7, models.BillType("elevator")).Return(&models.BillCategory{ApartmentID: 7, Name: "elevator", DueAfterDays: 10}, nil)
+	mockCategoryRepo.On("GetBillCategory", 7, models.BillType("parking")).Return(nil, sql.ErrNoRows)
+	dueDate := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	mockBillRepo.On("CreateBill", mock.Anything, mock.MatchedBy(func(b models.Bill) bool {
+		return b.BillType == "elevator" && b.DueDate == dueDate
+	}), mock.MatchedBy(func(e *models.JournalEntry) bool {
+		return e.EntryType == models.BillEntry && e.Balanced() &&
+			e.Lines[0].Account == models.BillsToDivide && e.Lines[0].Debit == 5000
+	})).Return(12, nil).Once()
+
+	billService := NewBillService(mockBillRepo, nil, mockApartmentRepo, mockUserAptRepo, nil, nil, mockCategoryRepo, nil, nil, nil, nil, nil, nil)
+
+	response, err := billService.CreateBill(context.Background(), 1, 7, dto.CreateBillRequest{BillType: "elevator", TotalAmount: 5000}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, response["id"])
+
+	_, err = billService.CreateBill(context.Background(), 1, 7, dto.CreateBillRequest{BillType: "parking", TotalAmount: 5000, DueDate: dueDate}, nil, nil)
+	assert.ErrorContains(t, err, "invalid bill type")
+
+	mockBillRepo.AssertExpectations(t)
+}
+
+func TestSetBillCategory(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           dto.BillCategoryRequest
+		expectedError string
+		builtIn       bool
+	}{
+		{
+			name: "adds a category",
+			req:  dto.BillCategoryRequest{Name: "elevator", Icon: "🛗", DefaultSplitStrategy: models.SplitByArea, DueAfterDays: 14},
+		},
+		{
+			name:    "changes a built-in category",
+			req:     dto.BillCategoryRequest{Name: models.WaterBill, Icon: "🚰"},
+			builtIn: true,
+		},
+		{
+			name:          "name with spaces",
+			req:           dto.BillCategoryRequest{Name: "Elevator service"},
+			expectedError: "category name",
+		},
+		{
+			name:          "mixed default split",
+			req:           dto.BillCategoryRequest{Name: "internet", DefaultSplitStrategy: models.SplitMixed},
+			expectedError: "mixed split needs weights",
+		},
+		{
+			name:          "consumption split without meters",
+			req:           dto.BillCategoryRequest{Name: "internet", DefaultSplitStrategy: models.SplitByMeter},
+			expectedError: "only available for water, electricity and gas",
+		},
+		{
+			name:          "negative due days",
+			req:           dto.BillCategoryRequest{Name: "parking", DueAfterDays: -1},
+			expectedError: "due days",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockCategoryRepo := new(repositories.MockBillCategoryRepository)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			if tt.expectedError == "" {
+				mockCategoryRepo.On("UpsertBillCategory", mock.Anything, mock.MatchedBy(func(c models.BillCategory) bool {
+					return c.ApartmentID == 7 && c.Name == tt.req.Name && c.Icon == tt.req.Icon &&
+						c.DefaultStrategy == tt.req.DefaultSplitStrategy && c.DueAfterDays == tt.req.DueAfterDays
+				})).Return(5, nil).Once()
+			}
+
+			billService := NewBillService(nil, nil, nil, mockUserAptRepo, nil, nil, mockCategoryRepo, nil, nil, nil, nil, nil, nil)
+			category, err := billService.SetBillCategory(context.Background(), 1, 7, tt.req)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockCategoryRepo.AssertNotCalled(t, "UpsertBillCategory", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 5, category.ID)
+			assert.Equal(t, tt.builtIn, category.BuiltIn)
+			mockCategoryRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetBillCategories(t *testing.T) {
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockCategoryRepo := new(repositories.MockBillCategoryRepository)
+	mockUserAptRepo.On("IsUserInApartment", mock.Anything, 2, 7).Return(true, nil)
+	mockCategoryRepo.On("GetBillCategoriesByApartment", 7).Return([]models.BillCategory{
+		{ApartmentID: 7, Name: "parking", Icon: "🅿"},
+		{ApartmentID: 7, Name: models.GasBill, Icon: "🔥", DueAfterDays: 20},
+		{ApartmentID: 7, Name: "elevator", Icon: "🛗"},
+	}, nil)
+
+	billService := NewBillService(nil, nil, nil, mockUserAptRepo, nil, nil, mockCategoryRepo, nil, nil, nil, nil, nil, nil)
+	categories, err := billService.GetBillCategories(context.Background(), 2, 7)
+
+	assert.NoError(t, err)
+	if assert.Len(t, categories, 8) {
+		assert.Equal(t, models.WaterBill, categories[0].Name)
+		assert.Equal(t, 7, categories[0].ApartmentID)
+		assert.Equal(t, models.GasBill, categories[2].Name)
+		assert.Equal(t, 20, categories[2].DueAfterDays, "the apartment's changes replace the built-in defaults")
+		assert.True(t, categories[2].BuiltIn)
+		assert.Equal(t, models.BillType("elevator"), categories[6].Name)
+		assert.Equal(t, models.BillType("parking"), categories[7].Name)
+		assert.False(t, categories[7].BuiltIn)
+	}
+}
+
+func TestResolveSplitPolicy(t *testing.T) {
+	apartmentDefault := &models.SplitPolicy{ApartmentID: 7, Strategy: models.SplitByOccupants}
+
+	tests := []struct {
+		name     string
+		policy   *models.SplitPolicy
+		category *models.BillCategory
+		expected models.SplitStrategy
+	}{
+		{
+			name:     "policy of the bill type wins",
+			policy:   &models.SplitPolicy{ApartmentID: 7, BillType: "elevator", Strategy: models.SplitEqual},
+			category: &models.BillCategory{Name: "elevator", DefaultStrategy: models.SplitByArea},
+			expected: models.SplitEqual,
+		},
+		{
+			name:     "category default comes before the apartment-wide policy",
+			policy:   apartmentDefault,
+			category: &models.BillCategory{Name: "elevator", DefaultStrategy: models.SplitByArea},
+			expected: models.SplitByArea,
+		},
+		{
+			name:     "apartment-wide policy when the category has no default",
+			policy:   apartmentDefault,
+			category: &models.BillCategory{Name: "elevator"},
+			expected: models.SplitByOccupants,
+		},
+		{
+			name:     "equal split when nothing is set",
+			expected: models.SplitEqual,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPolicyRepo := new(repositories.MockSplitPolicyRepository)
+			mockCategoryRepo := new(repositories.MockBillCategoryRepository)
+			if tt.policy != nil {
+				mockPolicyRepo.On("GetSplitPolicy", 7, models.BillType("elevator")).Return(tt.policy, nil)
+			} else {
+				mockPolicyRepo.On("GetSplitPolicy", 7, models.BillType("elevator")).Return(nil, sql.ErrNoRows)
+			}
+			if tt.category != nil {
+				mockCategoryRepo.On("GetBillCategory", 7, models.BillType("elevator")).Return(tt.category, nil).Maybe()
+			} else {
+				mockCategoryRepo.On("GetBillCategory", 7, models.BillType("elevator")).Return(nil, sql.ErrNoRows).Maybe()
+			}
+
+			billService := &billServiceImpl{splitPolicyRepo: mockPolicyRepo, categoryRepo: mockCategoryRepo}
+			policy := billService.resolveSplitPolicy(7, "elevator")
+
+			assert.Equal(t, tt.expected, policy.Strategy)
+		})
+	}
+}
+
+func TestGetBillDetail(t *testing.T) {
+	share := 21
+	payments := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 21}, BillID: 11, UserID: 1, Amount: 3000, AmountPaid: 3000, PaymentStatus: models.Paid, Kind: models.ShareKind, SplitStrategy: models.SplitEqual},
+		{BaseModel: models.BaseModel{ID: 22}, BillID: 11, UserID: 2, Amount: 3000, PaymentStatus: models.Pending, Kind: models.ShareKind, SplitStrategy: models.SplitEqual},
+		{BaseModel: models.BaseModel{ID: 30}, BillID: 11, UserID: 1, Amount: -500, Kind: models.AdjustmentKind, ParentPaymentID: &share},
+		{BaseModel: models.BaseModel{ID: 31}, BillID: 11, UserID: 2, Amount: 200, Kind: models.PenaltyKind, ParentPaymentID: &share},
+	}
+	tests := []struct {
+		name       string
+		visibility models.ShareVisibility
+		status     models.BillStatus
+		names      []string
+		wantErr    string
+	}{
+		{name: "anonymous", visibility: models.AnonymousShares, status: models.BillDivided, names: []string{"Resident 1", "Resident 2"}},
+		{name: "named", visibility: models.NamedShares, status: models.BillDivided, names: []string{"Ali Rezaei", "sara"}},
+		{name: "draft", visibility: models.NamedShares, status: models.BillDraft, wantErr: "bill not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockApartmentRepo := new(repositories.MockApartmentRepo)
+			mockUserRepo := new(repositories.MockUserRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockImage := new(image.MockImage)
+
+			mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{
+				BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 6000,
+				Currency: money.IRR, Status: tt.status, ImageURL: "receipts/water.jpg",
+			}, nil)
+			mockUserAptRepo.On("IsUserInApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockApartmentRepo.On("GetApartmentByID", 7).Return(&models.Apartment{BaseModel: models.BaseModel{ID: 7}, ShareVisibility: tt.visibility}, nil)
+			mockPaymentRepo.On("GetPaymentsByBill", 11).Return(payments, nil)
+			mockPaymentRepo.On("GetBreakdowns", []int{21, 22}).Return(map[int][]models.ShareLineItem{}, nil)
+			mockImage.On("GetImageURL", mock.Anything, "receipts/water.jpg").Return("https://files/receipts/water.jpg", nil)
+			mockUserRepo.On("GetUserByID", 1).Return(&models.User{Username: "ali", FullName: "Ali Rezaei"}, nil)
+			mockUserRepo.On("GetUserByID", 2).Return(&models.User{Username: "sara"}, nil)
+
+			billService := NewBillService(mockBillRepo, mockUserRepo, mockApartmentRepo, mockUserAptRepo, mockPaymentRepo, nil, builtInCategories(), nil, mockImage, nil, nil, nil, nil)
+			detail, err := billService.GetBillDetail(context.Background(), 1, 11)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "https://files/receipts/water.jpg", detail.ReceiptURL)
+			assert.Equal(t, models.SplitEqual, detail.SplitStrategy)
+			assert.Len(t, detail.Shares, 2, "adjustments fold into their share and penalties stay private")
+			assert.Equal(t, money.Amount(2500), detail.Shares[0].Amount)
+			assert.True(t, detail.Shares[0].Mine)
+			assert.False(t, detail.Shares[1].Mine)
+			for i, name := range tt.names {
+				assert.Equal(t, name, detail.Shares[i].Name)
+			}
+			if tt.visibility == models.AnonymousShares {
+				assert.Nil(t, detail.Shares[1].UserID, "other residents stay anonymous")
+				mockUserRepo.AssertNotCalled(t, "GetUserByID", mock.Anything)
+			} else {
+				assert.Equal(t, 2, *detail.Shares[1].UserID)
+			}
 		})
 	}
 }
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+)
+
+// percentages are stored with two decimals, so this is enough slack for rounding
+const percentageTolerance = 0.01
+
+// returns one weight per member, in the same order, according to the split policy
+func shareWeights(policy models.SplitPolicy, members []models.User_apartment) ([]float64, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no members to split between")
+	}
+
+	switch policy.Strategy {
+	case models.SplitEqual, "":
+		return componentWeights(members, equalFactor), nil
+	case models.SplitByArea:
+		return checkedComponent(members, areaFactor, "unit area")
+	case models.SplitByOccupants:
+		return checkedComponent(members, occupantsFactor, "occupants count")
+	case models.SplitByPercentage:
+		if err := checkPercentages(members); err != nil {
+			return nil, err
+		}
+		return componentWeights(members, percentageFactor), nil
+	case models.SplitMixed:
+		return mixedWeights(policy, members)
+	default:
+		return nil, fmt.Errorf("unknown split strategy %q", policy.Strategy)
+	}
+}
+
+type shareFactor func(models.User_apartment) float64
+
+func equalFactor(models.User_apartment) float64        { return 1 }
+func areaFactor(m models.User_apartment) float64       { return m.UnitArea }
+func occupantsFactor(m models.User_apartment) float64  { return float64(m.OccupantsCount) }
+func percentageFactor(m models.User_apartment) float64 { return m.SharePercentage }
+
+func componentWeights(members []models.User_apartment, factor shareFactor) []float64 {
+	weights := make([]float64, len(members))
+	for i, member := range members {
+		weights[i] = factor(member)
+	}
+	return weights
+}
+
+func checkedComponent(members []models.User_apartment, factor shareFactor, name string) ([]float64, error) {
+	weights := componentWeights(members, factor)
+	if sumOf(weights) <= 0 {
+		return nil, fmt.Errorf("%s is not set for any member of the apartment", name)
+	}
+	return weights, nil
+}
+
+// only current members count, a former member's percentage usually moved on to whoever replaced them
+func checkPercentages(members []models.User_apartment) error {
+	var total float64
+	for _, member := range members {
+		if member.LeftAt == nil {
+			total += member.SharePercentage
+		}
+	}
+	if total < 100-percentageTolerance || total > 100+percentageTolerance {
+		return fmt.Errorf("share percentages add up to %.2f, expected 100", total)
+	}
+	return nil
+}
+
+// blends the normalized shares of every component by the policy weights
+func mixedWeights(policy models.SplitPolicy, members []models.User_apartment) ([]float64, error) {
+	components := []struct {
+		weight float64
+		factor shareFactor
+		name   string
+	}{
+		{policy.EqualWeight, equalFactor, "equal"},
+		{policy.AreaWeight, areaFactor, "unit area"},
+		{policy.OccupantsWeight, occupantsFactor, "occupants count"},
+		{policy.PercentageWeight, percentageFactor, "share percentage"},
+	}
+
+	weights := make([]float64, len(members))
+	var totalWeight float64
+	for _, component := range components {
+		if component.weight <= 0 {
+			continue
+		}
+		if component.name == "share percentage" {
+			if err := checkPercentages(members); err != nil {
+				return nil, err
+			}
+		}
+		part, err := checkedComponent(members, component.factor, component.name)
+		if err != nil {
+			return nil, err
+		}
+		partSum := sumOf(part)
+		for i := range weights {
+			weights[i] += component.weight * part[i] / partSum
+		}
+		totalWeight += component.weight
+	}
+
+	if totalWeight <= 0 {
+		return nil, fmt.Errorf("mixed split needs at least one positive weight")
+	}
+	return weights, nil
+}
+
+func validateSplitPolicy(policy models.SplitPolicy) error {
+	switch policy.Strategy {
+	case models.SplitEqual, models.SplitByArea, models.SplitByOccupants, models.SplitByPercentage:
+	case models.SplitMixed:
+		weights := []float64{policy.EqualWeight, policy.AreaWeight, policy.OccupantsWeight, policy.PercentageWeight}
+		for _, weight := range weights {
+			if weight < 0 {
+				return fmt.Errorf("mixed split weights cannot be negative")
+			}
+		}
+		if sumOf(weights) <= 0 {
+			return fmt.Errorf("mixed split needs at least one positive weight")
+		}
+	case models.SplitByMeter:
+		if !models.MeteredBillTypes[policy.BillType] {
+			return fmt.Errorf("consumption split is only available for water, electricity and gas bills")
+		}
+	default:
+		return fmt.Errorf("invalid split strategy")
+	}
+	return nil
+}
+
+// scales every weight by the part of the billing period (both ends inclusive) the member lived in the apartment.
+// repeated stays of one user are merged so everyone still gets a single share
+func prorateWeights(members []models.User_apartment, weights []float64, start, end time.Time) ([]models.User_apartment, []float64) {
+	periodDays := daysBetween(start, end) + 1
+	var merged []models.User_apartment
+	var mergedWeights []float64
+	for i, member := range members {
+		weight := weights[i] * float64(residenceDays(member, start, end)) / float64(periodDays)
+		if n := len(merged); n > 0 && merged[n-1].UserID == member.UserID {
+			merged[n-1] = member
+			mergedWeights[n-1] += weight
+			continue
+		}
+		merged = append(merged, member)
+		mergedWeights = append(mergedWeights, weight)
+	}
+	return merged, mergedWeights
+}
+
+// the join day counts as lived in, the day of leaving doesn't
+func residenceDays(member models.User_apartment, start, end time.Time) int {
+	first, last := dayOf(start), dayOf(end)
+	if joined := dayOf(member.JoinedAt); joined.After(first) {
+		first = joined
+	}
+	if member.LeftAt != nil {
+		if left := dayOf(*member.LeftAt).AddDate(0, 0, -1); left.Before(last) {
+			last = left
+		}
+	}
+	if last.Before(first) {
+		return 0
+	}
+	return daysBetween(first, last) + 1
+}
+
+// keeps the latest stay of every user, for members ordered by user and join time
+func latestMemberships(members []models.User_apartment) []models.User_apartment {
+	var latest []models.User_apartment
+	for _, member := range members {
+		if n := len(latest); n > 0 && latest[n-1].UserID == member.UserID {
+			latest[n-1] = member
+			continue
+		}
+		latest = append(latest, member)
+	}
+	return latest
+}
+
+func dayOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(dayOf(to).Sub(dayOf(from)).Hours() / 24)
+}
+
+func sumOf(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum
+}
+
+// turns amounts per line item id back into a breakdown in the bill's item order, parts that come to
+// nothing are left out
+func breakdownOf(items []models.BillLineItem, parts map[int]money.Amount) []models.ShareLineItem {
+	var breakdown []models.ShareLineItem
+	for _, item := range items {
+		if parts[item.ID] == 0 {
+			continue
+		}
+		breakdown = append(breakdown, models.ShareLineItem{
+			LineItemID: item.ID,
+			Name:       item.Name,
+			Category:   item.Category,
+			Amount:     parts[item.ID],
+		})
+	}
+	return breakdown
+}
+
+// adds the breakdowns up per line item
+func partsByItem(breakdowns ...[]models.ShareLineItem) map[int]money.Amount {
+	parts := make(map[int]money.Amount)
+	for _, breakdown := range breakdowns {
+		for _, part := range breakdown {
+			parts[part.LineItemID] += part.Amount
+		}
+	}
+	return parts
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareWeights(t *testing.T) {
+	members := []models.User_apartment{
+		{UserID: 1, UnitArea: 120, OccupantsCount: 5, SharePercentage: 60},
+		{UserID: 2, UnitArea: 40, OccupantsCount: 1, SharePercentage: 40},
+	}
+
+	tests := []struct {
+		name          string
+		policy        models.SplitPolicy
+		members       []models.User_apartment
+		total         money.Amount
+		expected      []money.Amount
+		expectedError string
+	}{
+		{
+			name:     "equal split",
+			policy:   models.SplitPolicy{Strategy: models.SplitEqual},
+			members:  members,
+			total:    10000,
+			expected: []money.Amount{5000, 5000},
+		},
+		{
+			name:     "missing policy strategy behaves like equal",
+			policy:   models.SplitPolicy{},
+			members:  members,
+			total:    10000,
+			expected: []money.Amount{5000, 5000},
+		},
+		{
+			name:     "by area",
+			policy:   models.SplitPolicy{Strategy: models.SplitByArea},
+			members:  members,
+			total:    16000,
+			expected: []money.Amount{12000, 4000},
+		},
+		{
+			name:     "by occupants",
+			policy:   models.SplitPolicy{Strategy: models.SplitByOccupants},
+			members:  members,
+			total:    12000,
+			expected: []money.Amount{10000, 2000},
+		},
+		{
+			name:     "by percentage",
+			policy:   models.SplitPolicy{Strategy: models.SplitByPercentage},
+			members:  members,
+			total:    20000,
+			expected: []money.Amount{12000, 8000},
+		},
+		{
+			name:     "mixed equal and area",
+			policy:   models.SplitPolicy{Strategy: models.SplitMixed, EqualWeight: 50, AreaWeight: 50},
+			members:  members,
+			total:    10000,
+			expected: []money.Amount{6250, 3750},
+		},
+		{
+			name:          "area not configured",
+			policy:        models.SplitPolicy{Strategy: models.SplitByArea},
+			members:       []models.User_apartment{{UserID: 1}, {UserID: 2}},
+			expectedError: "unit area is not set",
+		},
+		{
+			name:          "percentages do not add up",
+			policy:        models.SplitPolicy{Strategy: models.SplitByPercentage},
+			members:       []models.User_apartment{{UserID: 1, SharePercentage: 30}, {UserID: 2, SharePercentage: 30}},
+			expectedError: "add up to 60.00",
+		},
+		{
+			name:          "mixed without weights",
+			policy:        models.SplitPolicy{Strategy: models.SplitMixed},
+			members:       members,
+			expectedError: "at least one positive weight",
+		},
+		{
+			name:          "no members",
+			policy:        models.SplitPolicy{Strategy: models.SplitEqual},
+			expectedError: "no members",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weights, err := shareWeights(tt.policy, tt.members)
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			shares, err := tt.total.Allocate(weights)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, shares)
+		})
+	}
+}
+
+func TestValidateSplitPolicy(t *testing.T) {
+	assert.NoError(t, validateSplitPolicy(models.SplitPolicy{Strategy: models.SplitByArea}))
+	assert.NoError(t, validateSplitPolicy(models.SplitPolicy{Strategy: models.SplitMixed, OccupantsWeight: 1}))
+	assert.Error(t, validateSplitPolicy(models.SplitPolicy{Strategy: "by-mood"}))
+	assert.Error(t, validateSplitPolicy(models.SplitPolicy{Strategy: models.SplitMixed, AreaWeight: -1, EqualWeight: 2}))
+}
+
+func TestProrateWeights(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	leftAt := time.Date(2025, 4, 11, 9, 30, 0, 0, time.UTC)
+	rejoinedAt := time.Date(2025, 4, 21, 18, 0, 0, 0, time.UTC)
+
+	members := []models.User_apartment{
+		{UserID: 1, JoinedAt: start.AddDate(-1, 0, 0)},
+		{UserID: 2, JoinedAt: start.AddDate(0, -2, 0), LeftAt: &leftAt},
+		{UserID: 2, JoinedAt: rejoinedAt},
+		{UserID: 3, JoinedAt: time.Date(2025, 4, 16, 8, 0, 0, 0, time.UTC)},
+		{UserID: 4, JoinedAt: start.AddDate(0, -1, 0), LeftAt: &start},
+	}
+
+	merged, weights := prorateWeights(members, []float64{1, 1, 1, 1, 1}, start, end)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, []int{merged[0].UserID, merged[1].UserID, merged[2].UserID, merged[3].UserID})
+	assert.Nil(t, merged[1].LeftAt, "the latest stay is kept")
+	assert.InDeltaSlice(t, []float64{1, 20.0 / 30, 15.0 / 30, 0}, weights, 0.0001)
+
+	shares, err := money.Amount(30000).Allocate(weights)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(30000), money.Sum(shares...))
+	assert.Equal(t, money.Amount(0), shares[3])
+}
+
+func TestBreakdownOf(t *testing.T) {
+	items := []models.BillLineItem{
+		{ID: 1, Name: "base", Category: models.BaseCharge},
+		{ID: 2, Name: "tax", Category: models.TaxCharge},
+		{ID: 3, Name: "fee", Category: models.ServiceFee},
+	}
+
+	breakdown := breakdownOf(items, map[int]money.Amount{3: 250, 1: 1000, 2: 0})
+	assert.Equal(t, []models.ShareLineItem{
+		{LineItemID: 1, Name: "base", Category: models.BaseCharge, Amount: 1000},
+		{LineItemID: 3, Name: "fee", Category: models.ServiceFee, Amount: 250},
+	}, breakdown)
+
+	parts := partsByItem(breakdown, []models.ShareLineItem{{LineItemID: 1, Amount: -400}})
+	assert.Equal(t, map[int]money.Amount{1: 600, 3: 250}, parts)
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// how many complete months the year-end forecast averages over
+const forecastWindow = 3
+
+// monthly budgets per bill category, compared against what the apartment was actually billed
+type BudgetService interface {
+	SetBudget(ctx context.Context, managerID, apartmentID int, req dto.BudgetRequest) ([]models.Budget, error)
+	GetBudgets(ctx context.Context, managerID, apartmentID, year int) ([]models.Budget, error)
+	DeleteBudget(ctx context.Context, managerID, apartmentID int, category models.BillType, year int) error
+	GetBudgetReport(ctx context.Context, managerID, apartmentID, year int, currency money.Currency) (*models.BudgetReport, error)
+}
+
+type budgetServiceImpl struct {
+	repo              repositories.BudgetRepository
+	billRepo          repositories.BillRepository
+	categoryRepo      repositories.BillCategoryRepository
+	userApartmentRepo repositories.UserApartmentRepository
+}
+
+func NewBudgetService(
+	repo repositories.BudgetRepository,
+	billRepo repositories.BillRepository,
+	categoryRepo repositories.BillCategoryRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+) BudgetService {
+	return &budgetServiceImpl{
+		repo:              repo,
+		billRepo:          billRepo,
+		categoryRepo:      categoryRepo,
+		userApartmentRepo: userApartmentRepo,
+	}
+}
+
+func (s *budgetServiceImpl) SetBudget(ctx context.Context, managerID, apartmentID int, req dto.BudgetRequest) ([]models.Budget, error) {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can set budgets")
+	}
+	if _, err := findBillCategory(s.categoryRepo, apartmentID, req.Category); err != nil {
+		return nil, err
+	}
+	if req.Year < 2000 || req.Year > 2100 {
+		return nil, fmt.Errorf("invalid budget year")
+	}
+	if req.Currency == "" {
+		req.Currency = money.DefaultCurrency
+	}
+	if !req.Currency.Valid() {
+		return nil, fmt.Errorf("invalid currency (use a three letter ISO code)")
+	}
+
+	monthly := req.Monthly
+	switch {
+	case len(monthly) == 0:
+		if req.AnnualAmount <= 0 {
+			return nil, fmt.Errorf("budget needs an annual amount or twelve monthly amounts")
+		}
+		weights := make([]float64, 12)
+		for i := range weights {
+			weights[i] = 1
+		}
+		var err error
+		if monthly, err = req.AnnualAmount.Allocate(weights); err != nil {
+			return nil, err
+		}
+	case len(monthly) != 12:
+		return nil, fmt.Errorf("monthly budget needs twelve amounts, January first")
+	case req.AnnualAmount != 0 && req.AnnualAmount != money.Sum(monthly...):
+		return nil, fmt.Errorf("annual amount doesn't match the monthly amounts")
+	}
+
+	budgets := make([]models.Budget, 12)
+	for i, amount := range monthly {
+		if amount < 0 {
+			return nil, fmt.Errorf("budget amounts cannot be negative")
+		}
+		budgets[i] = models.Budget{
+			ApartmentID: apartmentID,
+			Category:    req.Category,
+			Year:        req.Year,
+			Month:       i + 1,
+			Amount:      amount,
+			Currency:    req.Currency,
+		}
+	}
+	if err := s.repo.SetBudgets(ctx, budgets); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"apartment_id": apartmentID,
+			"category":     req.Category,
+			"year":         req.Year,
+		}).Error("Failed to set budget")
+		return nil, fmt.Errorf("failed to set budget: %w", err)
+	}
+	return budgets, nil
+}
+
+func (s *budgetServiceImpl) GetBudgets(ctx context.Context, managerID, apartmentID, year int) ([]models.Budget, error) {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can view budgets")
+	}
+	budgets, err := s.repo.GetBudgets(apartmentID, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets: %w", err)
+	}
+	if budgets == nil {
+		budgets = []models.Budget{}
+	}
+	return budgets, nil
+}
+
+func (s *budgetServiceImpl) DeleteBudget(ctx context.Context, managerID, apartmentID int, category models.BillType, year int) error {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return fmt.Errorf("only apartment managers can delete budgets")
+	}
+	if err := s.repo.DeleteBudgets(apartmentID, category, year); err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	return nil
+}
+
+// the currency defaults to the one the year was budgeted in
+func (s *budgetServiceImpl) GetBudgetReport(ctx context.Context, managerID, apartmentID, year int, currency money.Currency) (*models.BudgetReport, error) {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can view budget reports")
+	}
+	if currency != "" && !currency.Valid() {
+		return nil, fmt.Errorf("invalid currency (use a three letter ISO code)")
+	}
+
+	budgets, err := s.repo.GetBudgets(apartmentID, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budgets: %w", err)
+	}
+	bills, err := s.billRepo.GetBillsByApartmentID(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get bills for budget report")
+		return nil, fmt.Errorf("failed to get bills: %w", err)
+	}
+
+	if currency == "" {
+		currency = money.DefaultCurrency
+		if len(budgets) > 0 {
+			currency = budgets[0].Currency
+		}
+	}
+	return budgetReport(apartmentID, year, currency, budgets, bills, time.Now()), nil
+}
+
+type budgetKey struct {
+	category models.BillType
+	year     int
+	month    int
+}
+
+// planned vs actual per category and month. the forecast keeps what was billed in the months that are
+// over and expects the average of the last few complete months for the rest of the year, or what was
+// already billed for them if that is more
+func budgetReport(apartmentID, year int, currency money.Currency, budgets []models.Budget, bills []models.Bill, now time.Time) *models.BudgetReport {
+	planned := make(map[budgetKey]money.Amount)
+	actual := make(map[budgetKey]money.Amount)
+	categories := make(map[models.BillType]bool)
+
+	for _, budget := range budgets {
+		if budget.Currency != currency {
+			continue
+		}
+		planned[budgetKey{budget.Category, budget.Year, budget.Month}] += budget.Amount
+		categories[budget.Category] = true
+	}
+	for _, bill := range bills {
+		if bill.Currency != currency || !bill.Status.VisibleToResidents() || bill.Status == models.BillCancelled {
+			continue
+		}
+		billedAt, ok := billMonth(bill)
+		if !ok {
+			logrus.WithField("bill_id", bill.ID).Warn("Skipping bill without a valid date in budget report")
+			continue
+		}
+		actual[budgetKey{bill.BillType, billedAt.Year(), int(billedAt.Month())}] += bill.TotalAmount
+		categories[bill.BillType] = true
+	}
+
+	// the months of the report's year that are over
+	current := now.Year()*12 + int(now.Month()) - 1
+	complete := current - year*12
+	if complete < 0 {
+		complete = 0
+	}
+	if complete > 12 {
+		complete = 12
+	}
+
+	names := make([]models.BillType, 0, len(categories))
+	for category := range categories {
+		names = append(names, category)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	report := &models.BudgetReport{
+		ApartmentID: apartmentID,
+		Year:        year,
+		Currency:    currency,
+		Categories:  []models.BudgetCategoryReport{},
+	}
+	for _, category := range names {
+		var trailing money.Amount
+		for i := 1; i <= forecastWindow; i++ {
+			month := current - i
+			trailing += actual[budgetKey{category, month / 12, month%12 + 1}]
+		}
+		average := trailing / forecastWindow
+
+		line := models.BudgetCategoryReport{Category: category, Months: make([]models.BudgetMonth, 12)}
+		for m := 1; m <= 12; m++ {
+			key := budgetKey{category, year, m}
+			month := models.BudgetMonth{
+				Month:    m,
+				Planned:  planned[key],
+				Actual:   actual[key],
+				Variance: planned[key] - actual[key],
+			}
+			line.Months[m-1] = month
+			line.Planned += month.Planned
+			line.Actual += month.Actual
+
+			if m <= complete || month.Actual > average {
+				line.Forecast += month.Actual
+			} else {
+				line.Forecast += average
+			}
+		}
+		if line.Planned == 0 && line.Actual == 0 && line.Forecast == 0 {
+			continue
+		}
+		line.Variance = line.Planned - line.Actual
+		line.ForecastVariance = line.Planned - line.Forecast
+
+		report.Categories = append(report.Categories, line)
+		report.Planned += line.Planned
+		report.Actual += line.Actual
+		report.Forecast += line.Forecast
+	}
+	report.Variance = report.Planned - report.Actual
+	report.ForecastVariance = report.Planned - report.Forecast
+	return report
+}
+
+// bills count towards the month their billing period starts in, or the month they are due
+func billMonth(bill models.Bill) (time.Time, bool) {
+	if bill.PeriodStart != nil {
+		return *bill.PeriodStart, true
+	}
+	due, err := parseBillDate(bill.DueDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return due, true
+}
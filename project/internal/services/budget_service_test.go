@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSetBudget(t *testing.T) {
+	monthly := make([]money.Amount, 12)
+	for i := range monthly {
+		monthly[i] = money.Amount(1000 * (i + 1))
+	}
+
+	tests := []struct {
+		name      string
+		isManager bool
+		req       dto.BudgetRequest
+		wantErr   string
+		check     func(t *testing.T, budgets []models.Budget)
+	}{
+		{
+			name:      "annual amount spread over the months",
+			isManager: true,
+			req:       dto.BudgetRequest{Category: models.WaterBill, Year: 2025, AnnualAmount: 120005},
+			check: func(t *testing.T, budgets []models.Budget) {
+				assert.Len(t, budgets, 12)
+				assert.Equal(t, money.Amount(120005), money.Sum(budgetAmounts(budgets)...))
+				assert.Equal(t, money.IRR, budgets[0].Currency)
+				assert.Equal(t, 12, budgets[11].Month)
+			},
+		},
+		{
+			name:      "monthly amounts",
+			isManager: true,
+			req:       dto.BudgetRequest{Category: models.GasBill, Year: 2025, Currency: money.USD, Monthly: monthly},
+			check: func(t *testing.T, budgets []models.Budget) {
+				assert.Equal(t, money.Amount(12000), budgets[11].Amount)
+				assert.Equal(t, money.USD, budgets[11].Currency)
+			},
+		},
+		{
+			name:      "not a manager",
+			isManager: false,
+			req:       dto.BudgetRequest{Category: models.WaterBill, Year: 2025, AnnualAmount: 1000},
+			wantErr:   "only apartment managers",
+		},
+		{
+			name:      "unknown category",
+			isManager: true,
+			req:       dto.BudgetRequest{Category: "pool", Year: 2025, AnnualAmount: 1000},
+			wantErr:   "invalid bill type",
+		},
+		{
+			name:      "wrong number of months",
+			isManager: true,
+			req:       dto.BudgetRequest{Category: models.WaterBill, Year: 2025, Monthly: monthly[:6]},
+			wantErr:   "twelve amounts",
+		},
+		{
+			name:      "annual amount doesn't match",
+			isManager: true,
+			req:       dto.BudgetRequest{Category: models.WaterBill, Year: 2025, AnnualAmount: 5000, Monthly: monthly},
+			wantErr:   "doesn't match",
+		},
+		{
+			name:      "no amount",
+			isManager: true,
+			req:       dto.BudgetRequest{Category: models.WaterBill, Year: 2025},
+			wantErr:   "needs an annual amount",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockBudgetRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(tt.isManager, nil)
+			mockRepo.On("SetBudgets", mock.Anything, mock.Anything).Return(nil)
+
+			service := NewBudgetService(mockRepo, nil, builtInCategories(), mockUserAptRepo)
+			budgets, err := service.SetBudget(context.Background(), 1, 7, tt.req)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "SetBudgets", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			tt.check(t, budgets)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func budgetAmounts(budgets []models.Budget) []money.Amount {
+	amounts := make([]money.Amount, len(budgets))
+	for i, budget := range budgets {
+		amounts[i] = budget.Amount
+	}
+	return amounts
+}
+
+func TestBudgetReport(t *testing.T) {
+	var budgets []models.Budget
+	for m := 1; m <= 12; m++ {
+		budgets = append(budgets, models.Budget{Category: models.WaterBill, Year: 2025, Month: m, Amount: 10000, Currency: money.IRR})
+	}
+	period := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	bills := []models.Bill{
+		{BillType: models.WaterBill, TotalAmount: 12000, Currency: money.IRR, DueDate: "2025-01-20", Status: models.BillSettled},
+		{BillType: models.WaterBill, TotalAmount: 9000, Currency: money.IRR, DueDate: "2025-02-20", Status: models.BillDivided},
+		{BillType: models.WaterBill, TotalAmount: 11000, Currency: money.IRR, DueDate: "2025-03-20", Status: models.BillDivided},
+		{BillType: models.WaterBill, TotalAmount: 13000, Currency: money.IRR, DueDate: "2025-04-20", Status: models.BillPublished},
+		// billed in may for its period, already above the trailing average
+		{BillType: models.WaterBill, TotalAmount: 20000, Currency: money.IRR, DueDate: "2025-06-05", PeriodStart: &period, Status: models.BillDivided},
+		{BillType: models.WaterBill, TotalAmount: 50000, Currency: money.IRR, DueDate: "2025-04-01", Status: models.BillCancelled},
+		{BillType: models.WaterBill, TotalAmount: 50000, Currency: money.IRR, DueDate: "2025-04-01", Status: models.BillDraft},
+		{BillType: models.WaterBill, TotalAmount: 500, Currency: money.USD, DueDate: "2025-04-01", Status: models.BillDivided},
+		{BillType: models.GasBill, TotalAmount: 6000, Currency: money.IRR, DueDate: "2024-12-10", Status: models.BillSettled},
+	}
+	now := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	report := budgetReport(7, 2025, money.IRR, budgets, bills, now)
+
+	assert.Len(t, report.Categories, 1, "gas was only billed last year, outside the feb-apr window")
+	water := report.Categories[0]
+
+	assert.Equal(t, models.WaterBill, water.Category)
+	assert.Equal(t, money.Amount(120000), water.Planned)
+	assert.Equal(t, money.Amount(65000), water.Actual)
+	assert.Equal(t, money.Amount(-2000), water.Months[0].Variance, "january went over budget")
+	assert.Equal(t, money.Amount(20000), water.Months[4].Actual, "bills count in the month their period starts")
+	// jan-apr as billed, may as billed since it's above the 11000 average of feb-apr, then 7 months of the average
+	assert.Equal(t, money.Amount(45000+20000+7*11000), water.Forecast)
+	assert.Equal(t, money.Amount(120000-142000), water.ForecastVariance)
+
+	assert.Equal(t, water.Forecast, report.Forecast)
+
+	last := budgetReport(7, 2024, money.IRR, nil, bills, now)
+	assert.Len(t, last.Categories, 1)
+	assert.Equal(t, money.Amount(6000), last.Forecast, "a year that is over forecasts what was billed")
+}
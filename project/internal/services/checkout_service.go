@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/payment"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// runs payments through the gateway. a checkout puts its payments in the processing status and
+// only a verified callback marks them paid
+type CheckoutService interface {
+	StartCheckout(ctx context.Context, userID int, currency money.Currency, items []models.TransactionItem, description, idempotencyKey string) (*models.PaymentTransaction, error)
+	StartTopUp(ctx context.Context, userID, walletID int, currency money.Currency, amount money.Amount, idempotencyKey string) (*models.PaymentTransaction, error)
+	HandleCallback(ctx context.Context, params url.Values) (*models.PaymentTransaction, error)
+	GetTransaction(ctx context.Context, userID, transactionID int) (*models.PaymentTransaction, error)
+	ExpireStaleCheckouts(ctx context.Context, now time.Time) (int, error)
+}
+
+type checkoutServiceImpl struct {
+	repo        repositories.PaymentTransactionRepository
+	gateway     payment.Gateway
+	callbackURL string
+}
+
+func NewCheckoutService(repo repositories.PaymentTransactionRepository, gateway payment.Gateway, callbackURL string) CheckoutService {
+	return &checkoutServiceImpl{
+		repo:        repo,
+		gateway:     gateway,
+		callbackURL: callbackURL,
+	}
+}
+
+// a retried request with the same idempotency key gets the checkout it already started
+func (s *checkoutServiceImpl) StartCheckout(ctx context.Context, userID int, currency money.Currency, items []models.TransactionItem, description, idempotencyKey string) (*models.PaymentTransaction, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("nothing to pay")
+	}
+	var amount money.Amount
+	for _, item := range items {
+		if item.Amount <= 0 {
+			return nil, fmt.Errorf("payment amounts must be positive")
+		}
+		amount += item.Amount
+	}
+
+	return s.start(ctx, models.PaymentTransaction{
+		UserID:         userID,
+		Amount:         amount,
+		Currency:       currency,
+		IdempotencyKey: idempotencyKey,
+		Items:          items,
+	}, description)
+}
+
+// a top-up is a checkout without payments, the verified callback credits the wallet instead
+func (s *checkoutServiceImpl) StartTopUp(ctx context.Context, userID, walletID int, currency money.Currency, amount money.Amount, idempotencyKey string) (*models.PaymentTransaction, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("top-up amount must be positive")
+	}
+
+	return s.start(ctx, models.PaymentTransaction{
+		UserID:         userID,
+		Amount:         amount,
+		Currency:       currency,
+		IdempotencyKey: idempotencyKey,
+		WalletID:       &walletID,
+	}, "Wallet top-up")
+}
+
+func (s *checkoutServiceImpl) start(ctx context.Context, transaction models.PaymentTransaction, description string) (*models.PaymentTransaction, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":         transaction.UserID,
+		"idempotency_key": transaction.IdempotencyKey,
+	})
+
+	if existing, err := s.repo.GetTransactionByIdempotencyKey(transaction.UserID, transaction.IdempotencyKey); err == nil {
+		logger.WithField("transaction_id", existing.ID).Info("Returning existing checkout for idempotency key")
+		return existing, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+
+	transaction.Gateway = s.gateway.Name()
+	transaction.Status = models.TransactionProcessing
+	id, err := s.repo.CreateTransaction(ctx, transaction)
+	if errors.Is(err, repositories.ErrDuplicateIdempotencyKey) {
+		// a concurrent request with the same key got there first
+		logger.Info("Returning checkout of a concurrent request with the same idempotency key")
+		return s.repo.GetTransactionByIdempotencyKey(transaction.UserID, transaction.IdempotencyKey)
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Failed to start checkout")
+		return nil, fmt.Errorf("failed to start checkout: %w", err)
+	}
+	transaction.ID = id
+
+	checkout, err := s.gateway.CreateCheckout(ctx, payment.CheckoutRequest{
+		Reference:   fmt.Sprintf("txn-%d", id),
+		Amount:      transaction.Amount,
+		Currency:    transaction.Currency,
+		Description: description,
+		CallbackURL: s.callbackURL,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Gateway refused the checkout")
+		if _, closeErr := s.repo.CloseTransaction(ctx, id, models.TransactionFailed); closeErr != nil {
+			logger.WithError(closeErr).Error("Failed to release payments of a refused checkout")
+		}
+		return nil, fmt.Errorf("payment gateway error: %w", err)
+	}
+
+	if err := s.repo.SetCheckout(ctx, id, checkout.SessionID, checkout.RedirectURL, checkout.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("failed to save checkout: %w", err)
+	}
+	transaction.SessionID = &checkout.SessionID
+	transaction.RedirectURL = checkout.RedirectURL
+	transaction.ExpiresAt = &checkout.ExpiresAt
+
+	logger.WithFields(logrus.Fields{
+		"transaction_id": id,
+		"amount":         transaction.Amount,
+	}).Info("Checkout started")
+	return &transaction, nil
+}
+
+// applies the outcome of a verified gateway callback. repeated callbacks for a settled
+// transaction are ignored and just report its current state
+func (s *checkoutServiceImpl) HandleCallback(ctx context.Context, params url.Values) (*models.PaymentTransaction, error) {
+	result, err := s.gateway.VerifyCallback(ctx, params)
+	if err != nil {
+		logrus.WithError(err).Warn("Rejected gateway callback")
+		return nil, fmt.Errorf("invalid callback: %w", err)
+	}
+
+	transaction, err := s.repo.GetTransactionBySession(result.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("transaction not found: %w", err)
+	}
+	logger := logrus.WithFields(logrus.Fields{
+		"transaction_id": transaction.ID,
+		"status":         result.Status,
+	})
+	if result.Amount != transaction.Amount {
+		logger.WithField("callback_amount", result.Amount).Error("Callback amount does not match the transaction")
+		return nil, fmt.Errorf("callback amount does not match the transaction")
+	}
+
+	var changed bool
+	switch result.Status {
+	case payment.CallbackSucceeded:
+		changed, err = s.repo.CompleteTransaction(ctx, transaction.ID, result.TransactionID)
+	default:
+		changed, err = s.repo.CloseTransaction(ctx, transaction.ID, models.TransactionFailed)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to apply gateway callback")
+		return nil, fmt.Errorf("failed to apply callback: %w", err)
+	}
+	if !changed {
+		logger.Info("Duplicate callback ignored")
+	} else {
+		logger.Info("Gateway callback applied")
+	}
+
+	return s.repo.GetTransactionByID(transaction.ID)
+}
+
+func (s *checkoutServiceImpl) GetTransaction(ctx context.Context, userID, transactionID int) (*models.PaymentTransaction, error) {
+	transaction, err := s.repo.GetTransactionByID(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("transaction not found: %w", err)
+	}
+	if transaction.UserID != userID {
+		return nil, fmt.Errorf("transaction does not belong to the user")
+	}
+	return transaction, nil
+}
+
+// releases the payments of checkouts the resident never finished
+func (s *checkoutServiceImpl) ExpireStaleCheckouts(ctx context.Context, now time.Time) (int, error) {
+	transactions, err := s.repo.GetExpiredTransactions(now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired checkouts: %w", err)
+	}
+
+	expired := 0
+	for _, transaction := range transactions {
+		closed, err := s.repo.CloseTransaction(ctx, transaction.ID, models.TransactionExpired)
+		if err != nil {
+			logrus.WithError(err).WithField("transaction_id", transaction.ID).Error("Failed to expire checkout")
+			continue
+		}
+		if closed {
+			expired++
+		}
+	}
+	return expired, nil
+}
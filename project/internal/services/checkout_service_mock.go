@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCheckoutService struct {
+	mock.Mock
+}
+
+func (m *MockCheckoutService) StartCheckout(ctx context.Context, userID int, currency money.Currency, items []models.TransactionItem, description, idempotencyKey string) (*models.PaymentTransaction, error) {
+	args := m.Called(ctx, userID, currency, items, description, idempotencyKey)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCheckoutService) StartTopUp(ctx context.Context, userID, walletID int, currency money.Currency, amount money.Amount, idempotencyKey string) (*models.PaymentTransaction, error) {
+	args := m.Called(ctx, userID, walletID, currency, amount, idempotencyKey)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCheckoutService) HandleCallback(ctx context.Context, params url.Values) (*models.PaymentTransaction, error) {
+	args := m.Called(ctx, params)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCheckoutService) GetTransaction(ctx context.Context, userID, transactionID int) (*models.PaymentTransaction, error) {
+	args := m.Called(ctx, userID, transactionID)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCheckoutService) ExpireStaleCheckouts(ctx context.Context, now time.Time) (int, error) {
+	args := m.Called(ctx, now)
+	return args.Int(0), args.Error(1)
+}
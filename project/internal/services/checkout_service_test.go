@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/payment"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func startSimulatedCheckout(t *testing.T, mockRepo *repositories.MockPaymentTransactionRepository, simulator *payment.Simulator) *models.PaymentTransaction {
+	items := []models.TransactionItem{{PaymentID: 5, Amount: 7000}}
+	mockRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(nil, sql.ErrNoRows).Once()
+	mockRepo.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(transaction models.PaymentTransaction) bool {
+		return transaction.Amount == 7000 && transaction.Gateway == payment.SimulatorName
+	})).Return(4, nil)
+	mockRepo.On("SetCheckout", mock.Anything, 4, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	service := NewCheckoutService(mockRepo, simulator, "http://localhost:8080/api/v1/payments/callback")
+	transaction, err := service.StartCheckout(context.Background(), 2, money.IRR, items, "Bill payment", "idemp123")
+	require.NoError(t, err)
+	require.NotNil(t, transaction.SessionID)
+	return transaction
+}
+
+func TestStartCheckout(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	simulator := payment.NewSimulator("http://localhost:8080", "secret", 15*time.Minute)
+
+	transaction := startSimulatedCheckout(t, mockRepo, simulator)
+
+	assert.Equal(t, 4, transaction.ID)
+	assert.Equal(t, models.TransactionProcessing, transaction.Status)
+	assert.Contains(t, transaction.RedirectURL, "/simulator/checkout/"+*transaction.SessionID)
+
+	// a retry with the same key gets the same checkout back instead of a second one
+	mockRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(transaction, nil)
+	service := NewCheckoutService(mockRepo, simulator, "")
+	again, err := service.StartCheckout(context.Background(), 2, money.IRR, transaction.Items, "Bill payment", "idemp123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, transaction, again)
+	mockRepo.AssertNumberOfCalls(t, "CreateTransaction", 1)
+}
+
+func TestStartCheckout_GatewayRefuses(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	mockGateway := new(payment.MockGateway)
+
+	mockRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(nil, sql.ErrNoRows)
+	mockRepo.On("CreateTransaction", mock.Anything, mock.Anything).Return(4, nil)
+	mockGateway.On("CreateCheckout", mock.Anything, mock.Anything).Return(nil, errors.New("gateway down"))
+	mockRepo.On("CloseTransaction", mock.Anything, 4, models.TransactionFailed).Return(true, nil)
+
+	service := NewCheckoutService(mockRepo, mockGateway, "")
+	_, err := service.StartCheckout(context.Background(), 2, money.IRR, []models.TransactionItem{{PaymentID: 5, Amount: 7000}}, "Bill payment", "idemp123")
+
+	assert.ErrorContains(t, err, "payment gateway error")
+	mockRepo.AssertExpectations(t)
+}
+
+// requests with the same key racing past the lookup: the one that loses the insert returns the winner's checkout
+func TestStartCheckout_ConcurrentSameKey(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	mockGateway := new(payment.MockGateway)
+	existing := &models.PaymentTransaction{BaseModel: models.BaseModel{ID: 4}, UserID: 2, Amount: 7000, IdempotencyKey: "idemp123"}
+
+	mockRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(nil, sql.ErrNoRows).Once()
+	mockRepo.On("CreateTransaction", mock.Anything, mock.Anything).Return(0, repositories.ErrDuplicateIdempotencyKey)
+	mockRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(existing, nil).Once()
+
+	service := NewCheckoutService(mockRepo, mockGateway, "")
+	transaction, err := service.StartCheckout(context.Background(), 2, money.IRR, []models.TransactionItem{{PaymentID: 5, Amount: 7000}}, "Bill payment", "idemp123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, existing, transaction)
+	mockGateway.AssertNotCalled(t, "CreateCheckout", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestStartTopUp(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	simulator := payment.NewSimulator("http://localhost:8080", "secret", 15*time.Minute)
+
+	mockRepo.On("GetTransactionByIdempotencyKey", 2, "topup1").Return(nil, sql.ErrNoRows)
+	mockRepo.On("CreateTransaction", mock.Anything, mock.MatchedBy(func(transaction models.PaymentTransaction) bool {
+		return transaction.WalletID != nil && *transaction.WalletID == 3 && len(transaction.Items) == 0
+	})).Return(8, nil)
+	mockRepo.On("SetCheckout", mock.Anything, 8, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	service := NewCheckoutService(mockRepo, simulator, "")
+	transaction, err := service.StartTopUp(context.Background(), 2, 3, money.IRR, 20000, "topup1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(20000), transaction.Amount)
+	mockRepo.AssertExpectations(t)
+
+	_, err = service.StartTopUp(context.Background(), 2, 3, money.IRR, 0, "topup2")
+	assert.ErrorContains(t, err, "must be positive")
+}
+
+func TestHandleCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome payment.Outcome
+		setup   func(*repositories.MockPaymentTransactionRepository)
+	}{
+		{
+			name:    "success settles the payments",
+			outcome: payment.OutcomeSuccess,
+			setup: func(mockRepo *repositories.MockPaymentTransactionRepository) {
+				mockRepo.On("CompleteTransaction", mock.Anything, 4, mock.AnythingOfType("string")).Return(true, nil).Once()
+			},
+		},
+		{
+			name:    "failure releases the payments",
+			outcome: payment.OutcomeFailure,
+			setup: func(mockRepo *repositories.MockPaymentTransactionRepository) {
+				mockRepo.On("CloseTransaction", mock.Anything, 4, models.TransactionFailed).Return(true, nil).Once()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockPaymentTransactionRepository)
+			simulator := payment.NewSimulator("http://localhost:8080", "secret", 15*time.Minute)
+			transaction := startSimulatedCheckout(t, mockRepo, simulator)
+
+			params, err := simulator.Complete(*transaction.SessionID, tt.outcome)
+			require.NoError(t, err)
+
+			mockRepo.On("GetTransactionBySession", *transaction.SessionID).Return(transaction, nil)
+			mockRepo.On("GetTransactionByID", 4).Return(transaction, nil)
+			tt.setup(mockRepo)
+
+			service := NewCheckoutService(mockRepo, simulator, "")
+			_, err = service.HandleCallback(context.Background(), params)
+			assert.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleCallback_Duplicate(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	simulator := payment.NewSimulator("http://localhost:8080", "secret", 15*time.Minute)
+	transaction := startSimulatedCheckout(t, mockRepo, simulator)
+
+	mockRepo.On("GetTransactionBySession", *transaction.SessionID).Return(transaction, nil)
+	mockRepo.On("GetTransactionByID", 4).Return(transaction, nil)
+	mockRepo.On("CompleteTransaction", mock.Anything, 4, mock.AnythingOfType("string")).Return(true, nil).Once()
+	mockRepo.On("CompleteTransaction", mock.Anything, 4, mock.AnythingOfType("string")).Return(false, nil).Once()
+
+	service := NewCheckoutService(mockRepo, simulator, "")
+	for i := 0; i < 2; i++ {
+		params, err := simulator.Complete(*transaction.SessionID, payment.OutcomeSuccess)
+		require.NoError(t, err)
+		_, err = service.HandleCallback(context.Background(), params)
+		assert.NoError(t, err)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHandleCallback_Tampered(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	simulator := payment.NewSimulator("http://localhost:8080", "secret", 15*time.Minute)
+	transaction := startSimulatedCheckout(t, mockRepo, simulator)
+
+	params, err := simulator.Complete(*transaction.SessionID, payment.OutcomeFailure)
+	require.NoError(t, err)
+	params.Set("status", string(payment.CallbackSucceeded))
+
+	service := NewCheckoutService(mockRepo, simulator, "")
+	_, err = service.HandleCallback(context.Background(), params)
+
+	assert.ErrorContains(t, err, "invalid callback")
+	mockRepo.AssertNotCalled(t, "CompleteTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExpireStaleCheckouts(t *testing.T) {
+	mockRepo := new(repositories.MockPaymentTransactionRepository)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetExpiredTransactions", now).Return([]models.PaymentTransaction{
+		{BaseModel: models.BaseModel{ID: 4}},
+		{BaseModel: models.BaseModel{ID: 6}},
+	}, nil)
+	mockRepo.On("CloseTransaction", mock.Anything, 4, models.TransactionExpired).Return(true, nil)
+	mockRepo.On("CloseTransaction", mock.Anything, 6, models.TransactionExpired).Return(false, nil)
+
+	service := NewCheckoutService(mockRepo, nil, "")
+	expired, err := service.ExpireStaleCheckouts(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired, "a checkout settled in the meantime is not counted")
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/payment"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// residents dispute what they paid, the apartment's manager refunds it or rejects the dispute
+type DisputeService interface {
+	OpenDispute(ctx context.Context, userID, paymentID int, req dto.OpenDisputeRequest) (*models.PaymentDispute, error)
+	GetMyDisputes(ctx context.Context, userID int) ([]models.PaymentDispute, error)
+	GetApartmentDisputes(ctx context.Context, managerID, apartmentID int) ([]models.PaymentDispute, error)
+	GetDispute(ctx context.Context, userID, disputeID int) (*models.PaymentDispute, error)
+	ApproveDispute(ctx context.Context, managerID, disputeID int, req dto.ResolveDisputeRequest) (*models.PaymentDispute, error)
+	RejectDispute(ctx context.Context, managerID, disputeID int, req dto.ResolveDisputeRequest) (*models.PaymentDispute, error)
+}
+
+type disputeServiceImpl struct {
+	repo                   repositories.DisputeRepository
+	paymentRepo            repositories.PaymentRepository
+	billRepo               repositories.BillRepository
+	paymentTransactionRepo repositories.PaymentTransactionRepository
+	walletRepo             repositories.WalletRepository
+	userApartmentRepo      repositories.UserApartmentRepository
+	gateway                payment.Gateway
+	notificationService    notification.Notification
+}
+
+func NewDisputeService(
+	repo repositories.DisputeRepository,
+	paymentRepo repositories.PaymentRepository,
+	billRepo repositories.BillRepository,
+	paymentTransactionRepo repositories.PaymentTransactionRepository,
+	walletRepo repositories.WalletRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	gateway payment.Gateway,
+	notificationService notification.Notification,
+) DisputeService {
+	return &disputeServiceImpl{
+		repo:                   repo,
+		paymentRepo:            paymentRepo,
+		billRepo:               billRepo,
+		paymentTransactionRepo: paymentTransactionRepo,
+		walletRepo:             walletRepo,
+		userApartmentRepo:      userApartmentRepo,
+		gateway:                gateway,
+		notificationService:    notificationService,
+	}
+}
+
+func (s *disputeServiceImpl) OpenDispute(ctx context.Context, userID, paymentID int, req dto.OpenDisputeRequest) (*models.PaymentDispute, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"payment_id": paymentID,
+	})
+
+	p, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil || p.UserID != userID {
+		return nil, fmt.Errorf("payment not found")
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, fmt.Errorf("a dispute needs a reason")
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = p.Refundable()
+	}
+	if amount <= 0 || amount > p.Refundable() {
+		return nil, fmt.Errorf("dispute amount must be positive and at most %s", p.Refundable())
+	}
+
+	dispute := models.PaymentDispute{
+		PaymentID: paymentID,
+		UserID:    userID,
+		Amount:    amount,
+		Currency:  p.Currency,
+		Reason:    req.Reason,
+		Status:    models.DisputeOpen,
+	}
+	id, err := s.repo.OpenDispute(ctx, dispute)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to open dispute")
+		return nil, fmt.Errorf("failed to open dispute: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"dispute_id": id,
+		"amount":     amount,
+	}).Info("Dispute opened")
+	return s.repo.GetDisputeByID(id)
+}
+
+func (s *disputeServiceImpl) GetMyDisputes(ctx context.Context, userID int) ([]models.PaymentDispute, error) {
+	disputes, err := s.repo.GetDisputesByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disputes: %w", err)
+	}
+	return disputes, nil
+}
+
+func (s *disputeServiceImpl) GetApartmentDisputes(ctx context.Context, managerID, apartmentID int) ([]models.PaymentDispute, error) {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can view disputes")
+	}
+	disputes, err := s.repo.GetDisputesByApartment(apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disputes: %w", err)
+	}
+	return disputes, nil
+}
+
+// the resident who opened it and the apartment's managers can follow a dispute together with the
+// status history of its payment
+func (s *disputeServiceImpl) GetDispute(ctx context.Context, userID, disputeID int) (*models.PaymentDispute, error) {
+	dispute, err := s.repo.GetDisputeByID(disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("dispute not found")
+	}
+	if dispute.UserID != userID {
+		if _, err := s.managedDisputeBill(ctx, userID, dispute); err != nil {
+			return nil, fmt.Errorf("dispute not found")
+		}
+	}
+
+	events, err := s.paymentRepo.GetPaymentEvents(dispute.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment history: %w", err)
+	}
+	dispute.Events = events
+	return dispute, nil
+}
+
+// refunds the disputed amount. the gateway refunds go out before anything is booked, a refund the
+// provider declines leaves the dispute open
+func (s *disputeServiceImpl) ApproveDispute(ctx context.Context, managerID, disputeID int, req dto.ResolveDisputeRequest) (*models.PaymentDispute, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":    managerID,
+		"dispute_id": disputeID,
+	})
+
+	dispute, err := s.repo.GetDisputeByID(disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("dispute not found")
+	}
+	bill, err := s.managedDisputeBill(ctx, managerID, dispute)
+	if err != nil {
+		logger.Warn("Non-manager user attempted to approve a dispute")
+		return nil, err
+	}
+	if dispute.Status != models.DisputeOpen {
+		return nil, repositories.ErrDisputeClosed
+	}
+
+	refunds, gatewayPaid, err := s.gatewayRefunds(dispute)
+	if err != nil {
+		return nil, err
+	}
+	method := req.RefundTo
+	if method == "" {
+		method = models.RefundToWallet
+		if gatewayPaid >= dispute.Amount {
+			method = models.RefundToGateway
+		}
+	}
+
+	dispute.RefundMethod = &method
+	dispute.ResolvedBy = &managerID
+	dispute.ResolutionNote = req.Note
+
+	var walletID *int
+	switch method {
+	case models.RefundToGateway:
+		if gatewayPaid < dispute.Amount {
+			return nil, fmt.Errorf("only %s was paid through the gateway, refund to the wallet instead", gatewayPaid)
+		}
+	case models.RefundToWallet:
+		wallet, err := s.walletRepo.GetOrCreateWallet(ctx, dispute.UserID, bill.ApartmentID, dispute.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get wallet: %w", err)
+		}
+		if wallet.Currency != dispute.Currency {
+			return nil, fmt.Errorf("wallet is in %s, the payment was in %s", wallet.Currency, dispute.Currency)
+		}
+		walletID = &wallet.ID
+	default:
+		return nil, fmt.Errorf("invalid refund method %q", method)
+	}
+
+	// claimed before any money moves, a second approval of the same dispute stops here
+	if err := s.repo.ClaimDispute(ctx, disputeID); err != nil {
+		return nil, err
+	}
+
+	if method == models.RefundToGateway {
+		var refundIDs []string
+		for _, refund := range refunds {
+			result, err := s.gateway.Refund(ctx, refund)
+			if err != nil {
+				// earlier refunds already left, the dispute stays claimed and they are logged so the
+				// books can be fixed by hand
+				logger.WithError(err).WithField("refund_ids", refundIDs).Error("Gateway refund failed")
+				if len(refundIDs) == 0 {
+					s.release(ctx, logger, disputeID)
+				}
+				return nil, fmt.Errorf("gateway refund failed: %w", err)
+			}
+			refundIDs = append(refundIDs, result.RefundID)
+		}
+		dispute.RefundRef = strings.Join(refundIDs, ",")
+	}
+
+	if err := s.repo.RefundDispute(ctx, *dispute, walletID); err != nil {
+		logger.WithError(err).WithField("refund_ref", dispute.RefundRef).Error("Failed to book refund")
+		// a wallet credit is booked with the refund, nothing left yet
+		if method == models.RefundToWallet {
+			s.release(ctx, logger, disputeID)
+		}
+		return nil, fmt.Errorf("failed to refund dispute: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"amount":        dispute.Amount,
+		"refund_method": method,
+	}).Info("Dispute refunded")
+	s.notify(ctx, dispute.UserID, fmt.Sprintf("Your dispute #%d was approved, %s %s is refunded to your %s.",
+		dispute.ID, dispute.Amount, dispute.Currency, method))
+	return s.repo.GetDisputeByID(disputeID)
+}
+
+func (s *disputeServiceImpl) RejectDispute(ctx context.Context, managerID, disputeID int, req dto.ResolveDisputeRequest) (*models.PaymentDispute, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":    managerID,
+		"dispute_id": disputeID,
+	})
+
+	dispute, err := s.repo.GetDisputeByID(disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("dispute not found")
+	}
+	if _, err := s.managedDisputeBill(ctx, managerID, dispute); err != nil {
+		logger.Warn("Non-manager user attempted to reject a dispute")
+		return nil, err
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		return nil, fmt.Errorf("rejecting a dispute needs a note")
+	}
+
+	dispute.ResolvedBy = &managerID
+	dispute.ResolutionNote = req.Note
+	if err := s.repo.RejectDispute(ctx, *dispute); err != nil {
+		logger.WithError(err).Error("Failed to reject dispute")
+		return nil, fmt.Errorf("failed to reject dispute: %w", err)
+	}
+
+	logger.Info("Dispute rejected")
+	s.notify(ctx, dispute.UserID, fmt.Sprintf("Your dispute #%d was rejected: %s", dispute.ID, req.Note))
+	return s.repo.GetDisputeByID(disputeID)
+}
+
+// the bill of the disputed payment, as long as the user manages its apartment
+func (s *disputeServiceImpl) managedDisputeBill(ctx context.Context, managerID int, dispute *models.PaymentDispute) (*models.Bill, error) {
+	p, err := s.paymentRepo.GetPaymentByID(dispute.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found: %w", err)
+	}
+	bill, err := s.billRepo.GetBillByID(p.BillID)
+	if err != nil {
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, bill.ApartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can resolve disputes")
+	}
+	return bill, nil
+}
+
+// splits the refund over the gateway checkouts that paid the payment, newest first, skipping what
+// earlier disputes already sent back through the gateway. also returns how much of the payment
+// can still go back through the gateway
+func (s *disputeServiceImpl) gatewayRefunds(dispute *models.PaymentDispute) ([]payment.RefundRequest, money.Amount, error) {
+	transactions, err := s.paymentTransactionRepo.GetSucceededTransactionsByPayment(dispute.PaymentID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get payment transactions: %w", err)
+	}
+	previous, err := s.repo.GetDisputesByUser(dispute.UserID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get disputes: %w", err)
+	}
+
+	var refunded money.Amount
+	for _, d := range previous {
+		if d.PaymentID == dispute.PaymentID && d.Status == models.DisputeRefunded &&
+			d.RefundMethod != nil && *d.RefundMethod == models.RefundToGateway {
+			refunded += d.Amount
+		}
+	}
+
+	var refunds []payment.RefundRequest
+	var available money.Amount
+	remaining := dispute.Amount
+	for _, transaction := range transactions {
+		if transaction.Gateway == models.WalletGateway || transaction.SessionID == nil {
+			continue
+		}
+		var paid money.Amount
+		for _, item := range transaction.Items {
+			paid += item.Amount
+		}
+		skipped := min(refunded, paid)
+		refunded -= skipped
+		paid -= skipped
+		available += paid
+
+		if amount := min(remaining, paid); amount > 0 {
+			refunds = append(refunds, payment.RefundRequest{
+				SessionID:     *transaction.SessionID,
+				TransactionID: transaction.GatewayRef,
+				Amount:        amount,
+				Reason:        dispute.Reason,
+			})
+			remaining -= amount
+		}
+	}
+	return refunds, available, nil
+}
+
+func (s *disputeServiceImpl) notify(ctx context.Context, userID int, message string) {
+	if err := s.notificationService.SendNotification(ctx, userID, message); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to send dispute notification")
+	}
+}
+
+// opens a claimed dispute again after its refund failed before any money left
+func (s *disputeServiceImpl) release(ctx context.Context, logger *logrus.Entry, disputeID int) {
+	if err := s.repo.ReleaseDispute(ctx, disputeID); err != nil {
+		logger.WithError(err).Error("Failed to reopen dispute after a failed refund")
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/payment"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOpenDispute(t *testing.T) {
+	paid := &models.Payment{BaseModel: models.BaseModel{ID: 4}, BillID: 11, UserID: 2, Amount: 5000, AmountPaid: 5000, AmountRefunded: 1000, Currency: money.IRR, PaymentStatus: models.Paid}
+
+	tests := []struct {
+		name           string
+		userID         int
+		req            dto.OpenDisputeRequest
+		expectedAmount money.Amount
+		expectedError  string
+	}{
+		{name: "defaults to the refundable amount", userID: 2, req: dto.OpenDisputeRequest{Reason: "charged twice"}, expectedAmount: 4000},
+		{name: "part of the payment", userID: 2, req: dto.OpenDisputeRequest{Amount: 1500, Reason: "wrong meter"}, expectedAmount: 1500},
+		{name: "more than refundable", userID: 2, req: dto.OpenDisputeRequest{Amount: 4500, Reason: "charged twice"}, expectedError: "at most"},
+		{name: "missing reason", userID: 2, req: dto.OpenDisputeRequest{Amount: 1000}, expectedError: "needs a reason"},
+		{name: "someone else's payment", userID: 3, req: dto.OpenDisputeRequest{Reason: "charged twice"}, expectedError: "payment not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockDisputeRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockPaymentRepo.On("GetPaymentByID", 4).Return(paid, nil)
+			mockRepo.On("OpenDispute", mock.Anything, mock.MatchedBy(func(d models.PaymentDispute) bool {
+				return d.Amount == tt.expectedAmount && d.UserID == 2 && d.Currency == money.IRR
+			})).Return(6, nil)
+			mockRepo.On("GetDisputeByID", 6).Return(&models.PaymentDispute{BaseModel: models.BaseModel{ID: 6}}, nil)
+
+			service := NewDisputeService(mockRepo, mockPaymentRepo, nil, nil, nil, nil, nil, nil)
+			dispute, err := service.OpenDispute(context.Background(), tt.userID, 4, tt.req)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "OpenDispute", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 6, dispute.ID)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApproveDispute(t *testing.T) {
+	sessionA, sessionB := "cs_a", "cs_b"
+	transactions := []models.PaymentTransaction{
+		{BaseModel: models.BaseModel{ID: 21}, Gateway: payment.SimulatorName, SessionID: &sessionB, GatewayRef: "tx_b",
+			Items: []models.TransactionItem{{PaymentID: 4, Amount: 2000}}},
+		{BaseModel: models.BaseModel{ID: 20}, Gateway: models.WalletGateway, Items: []models.TransactionItem{{PaymentID: 4, Amount: 1000}}},
+		{BaseModel: models.BaseModel{ID: 19}, Gateway: payment.SimulatorName, SessionID: &sessionA, GatewayRef: "tx_a",
+			Items: []models.TransactionItem{{PaymentID: 4, Amount: 2000}}},
+	}
+
+	tests := []struct {
+		name           string
+		amount         money.Amount
+		refundTo       models.RefundMethod
+		setupMocks     func(*payment.MockGateway, *repositories.MockWalletRepository)
+		claimErr       error
+		expectedMethod models.RefundMethod
+		expectedRef    string
+		expectedError  string
+		released       bool
+	}{
+		{
+			name:   "split over the gateway checkouts newest first",
+			amount: 3000,
+			setupMocks: func(gateway *payment.MockGateway, _ *repositories.MockWalletRepository) {
+				gateway.On("Refund", mock.Anything, payment.RefundRequest{SessionID: "cs_b", TransactionID: "tx_b", Amount: 2000, Reason: "charged twice"}).
+					Return(&payment.RefundResult{RefundID: "re_1", Amount: 2000}, nil).Once()
+				gateway.On("Refund", mock.Anything, payment.RefundRequest{SessionID: "cs_a", TransactionID: "tx_a", Amount: 1000, Reason: "charged twice"}).
+					Return(&payment.RefundResult{RefundID: "re_2", Amount: 1000}, nil).Once()
+			},
+			expectedMethod: models.RefundToGateway,
+			expectedRef:    "re_1,re_2",
+		},
+		{
+			name:   "more than the gateway took goes to the wallet",
+			amount: 5000,
+			setupMocks: func(_ *payment.MockGateway, walletRepo *repositories.MockWalletRepository) {
+				walletRepo.On("GetOrCreateWallet", mock.Anything, 2, 7, money.IRR).Return(&models.Wallet{BaseModel: models.BaseModel{ID: 9}, Currency: money.IRR}, nil)
+			},
+			expectedMethod: models.RefundToWallet,
+		},
+		{
+			name:          "gateway asked for more than it took",
+			amount:        5000,
+			refundTo:      models.RefundToGateway,
+			setupMocks:    func(*payment.MockGateway, *repositories.MockWalletRepository) {},
+			expectedError: "refund to the wallet instead",
+		},
+		{
+			name:          "approved by someone else meanwhile",
+			amount:        3000,
+			setupMocks:    func(*payment.MockGateway, *repositories.MockWalletRepository) {},
+			claimErr:      repositories.ErrDisputeClosed,
+			expectedError: "already resolved",
+		},
+		{
+			name:   "gateway refused, the dispute is open again",
+			amount: 2000,
+			setupMocks: func(gateway *payment.MockGateway, _ *repositories.MockWalletRepository) {
+				gateway.On("Refund", mock.Anything, mock.Anything).Return(nil, errors.New("provider down")).Once()
+			},
+			expectedError: "gateway refund failed",
+			released:      true,
+		},
+		{
+			name:   "refunds that already left keep the dispute claimed",
+			amount: 3000,
+			setupMocks: func(gateway *payment.MockGateway, _ *repositories.MockWalletRepository) {
+				gateway.On("Refund", mock.Anything, mock.MatchedBy(func(r payment.RefundRequest) bool { return r.SessionID == "cs_b" })).
+					Return(&payment.RefundResult{RefundID: "re_1", Amount: 2000}, nil).Once()
+				gateway.On("Refund", mock.Anything, mock.MatchedBy(func(r payment.RefundRequest) bool { return r.SessionID == "cs_a" })).
+					Return(nil, errors.New("provider down")).Once()
+			},
+			expectedError: "gateway refund failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispute := &models.PaymentDispute{BaseModel: models.BaseModel{ID: 6}, PaymentID: 4, UserID: 2, Amount: tt.amount, Currency: money.IRR, Reason: "charged twice", Status: models.DisputeOpen}
+
+			mockRepo := new(repositories.MockDisputeRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockTransactionRepo := new(repositories.MockPaymentTransactionRepository)
+			mockWalletRepo := new(repositories.MockWalletRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockGateway := new(payment.MockGateway)
+			mockNotificationService := new(notification.MockNotification)
+			mockNotificationService.ExpectAnyNotificationCall(nil)
+
+			mockRepo.On("GetDisputeByID", 6).Return(dispute, nil)
+			mockPaymentRepo.On("GetPaymentByID", 4).Return(&models.Payment{BaseModel: models.BaseModel{ID: 4}, BillID: 11, UserID: 2}, nil)
+			mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7}, nil)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockTransactionRepo.On("GetSucceededTransactionsByPayment", 4).Return(transactions, nil)
+			mockRepo.On("GetDisputesByUser", 2).Return([]models.PaymentDispute{}, nil)
+			mockRepo.On("RefundDispute", mock.Anything, mock.MatchedBy(func(d models.PaymentDispute) bool {
+				return *d.RefundMethod == tt.expectedMethod && d.RefundRef == tt.expectedRef && *d.ResolvedBy == 1
+			}), mock.Anything).Return(nil)
+			mockRepo.On("ClaimDispute", mock.Anything, 6).Return(tt.claimErr)
+			if tt.released {
+				mockRepo.On("ReleaseDispute", mock.Anything, 6).Return(nil).Once()
+			}
+			tt.setupMocks(mockGateway, mockWalletRepo)
+
+			service := NewDisputeService(mockRepo, mockPaymentRepo, mockBillRepo, mockTransactionRepo, mockWalletRepo, mockUserAptRepo, mockGateway, mockNotificationService)
+			_, err := service.ApproveDispute(context.Background(), 1, 6, dto.ResolveDisputeRequest{RefundTo: tt.refundTo})
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "RefundDispute", mock.Anything, mock.Anything, mock.Anything)
+				mockGateway.AssertExpectations(t)
+				if tt.released {
+					mockRepo.AssertCalled(t, "ReleaseDispute", mock.Anything, 6)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+			mockGateway.AssertExpectations(t)
+			mockWalletRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGatewayRefunds_SkipsEarlierRefunds(t *testing.T) {
+	session := "cs_a"
+	gateway := models.RefundToGateway
+	mockRepo := new(repositories.MockDisputeRepository)
+	mockTransactionRepo := new(repositories.MockPaymentTransactionRepository)
+
+	mockTransactionRepo.On("GetSucceededTransactionsByPayment", 4).Return([]models.PaymentTransaction{
+		{Gateway: payment.SimulatorName, SessionID: &session, GatewayRef: "tx_a", Items: []models.TransactionItem{{PaymentID: 4, Amount: 5000}}},
+	}, nil)
+	mockRepo.On("GetDisputesByUser", 2).Return([]models.PaymentDispute{
+		{PaymentID: 4, Amount: 2000, Status: models.DisputeRefunded, RefundMethod: &gateway},
+		{PaymentID: 4, Amount: 1000, Status: models.DisputeRejected},
+		{PaymentID: 8, Amount: 1000, Status: models.DisputeRefunded, RefundMethod: &gateway},
+	}, nil)
+
+	service := &disputeServiceImpl{repo: mockRepo, paymentTransactionRepo: mockTransactionRepo}
+	refunds, available, err := service.gatewayRefunds(&models.PaymentDispute{PaymentID: 4, UserID: 2, Amount: 1000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(3000), available)
+	assert.Equal(t, []payment.RefundRequest{{SessionID: "cs_a", TransactionID: "tx_a", Amount: 1000}}, refunds)
+}
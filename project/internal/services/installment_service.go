@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+type InstallmentService interface {
+	CreatePlan(ctx context.Context, userID, paymentID int, req dto.InstallmentPlanRequest) (*models.InstallmentPlan, error)
+	GetPlan(ctx context.Context, userID, paymentID int) (*models.InstallmentPlan, error)
+	GetUserPlans(ctx context.Context, userID int) ([]models.InstallmentPlan, error)
+	DeletePlan(ctx context.Context, userID, paymentID int) error
+	PayInstallment(ctx context.Context, userID, installmentID int, idempotentKey string) (*models.PaymentTransaction, error)
+}
+
+type installmentServiceImpl struct {
+	repo              repositories.InstallmentRepository
+	paymentRepo       repositories.PaymentRepository
+	billRepo          repositories.BillRepository
+	userApartmentRepo repositories.UserApartmentRepository
+	checkoutService   CheckoutService
+}
+
+func NewInstallmentService(
+	repo repositories.InstallmentRepository,
+	paymentRepo repositories.PaymentRepository,
+	billRepo repositories.BillRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	checkoutService CheckoutService,
+) InstallmentService {
+	return &installmentServiceImpl{
+		repo:              repo,
+		paymentRepo:       paymentRepo,
+		billRepo:          billRepo,
+		userApartmentRepo: userApartmentRepo,
+		checkoutService:   checkoutService,
+	}
+}
+
+// spreads what is left of a resident's share over the given due dates
+func (s *installmentServiceImpl) CreatePlan(ctx context.Context, userID, paymentID int, req dto.InstallmentPlanRequest) (*models.InstallmentPlan, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"payment_id": paymentID,
+	})
+
+	share, err := s.managedShare(ctx, userID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if share.Kind != models.ShareKind {
+		return nil, fmt.Errorf("installment plans are only available for bill shares")
+	}
+	if share.PaymentStatus != models.Pending && share.PaymentStatus != models.PartiallyPaid {
+		return nil, fmt.Errorf("payment is already settled")
+	}
+	if _, err := s.repo.GetPlanByPayment(paymentID); err == nil {
+		return nil, fmt.Errorf("payment already has an installment plan")
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check existing plan: %w", err)
+	}
+
+	installments, err := planInstallments(share.Outstanding(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := models.InstallmentPlan{
+		PaymentID:    paymentID,
+		UserID:       share.UserID,
+		CreatedBy:    userID,
+		Installments: installments,
+	}
+	id, err := s.repo.CreatePlan(ctx, plan)
+	if err != nil {
+		logger.WithError(err).Error("Failed to create installment plan")
+		return nil, fmt.Errorf("failed to create installment plan: %w", err)
+	}
+	plan.ID = id
+
+	logger.WithField("installments", len(installments)).Info("Installment plan created")
+	return &plan, nil
+}
+
+// visible to the manager of the apartment and to the resident the plan is for
+func (s *installmentServiceImpl) GetPlan(ctx context.Context, userID, paymentID int) (*models.InstallmentPlan, error) {
+	plan, err := s.repo.GetPlanByPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("installment plan not found: %w", err)
+	}
+	if plan.UserID == userID {
+		return plan, nil
+	}
+	if _, err := s.managedShare(ctx, userID, paymentID); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (s *installmentServiceImpl) GetUserPlans(ctx context.Context, userID int) ([]models.InstallmentPlan, error) {
+	plans, err := s.repo.GetPlansByUser(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to get installment plans")
+		return nil, fmt.Errorf("failed to get installment plans: %w", err)
+	}
+	return plans, nil
+}
+
+// only plans nothing was paid on yet can be removed, the share goes back to its bill deadline
+func (s *installmentServiceImpl) DeletePlan(ctx context.Context, userID, paymentID int) error {
+	if _, err := s.managedShare(ctx, userID, paymentID); err != nil {
+		return err
+	}
+
+	err := s.repo.DeletePlan(ctx, paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("installment plan not found: %w", err)
+	}
+	if errors.Is(err, repositories.ErrPlanPaidOn) {
+		return err
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("payment_id", paymentID).Error("Failed to delete installment plan")
+		return fmt.Errorf("failed to delete installment plan: %w", err)
+	}
+	return nil
+}
+
+func (s *installmentServiceImpl) PayInstallment(ctx context.Context, userID, installmentID int, idempotentKey string) (*models.PaymentTransaction, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":        userID,
+		"installment_id": installmentID,
+	})
+
+	installment, err := s.repo.GetInstallmentByID(installmentID)
+	if err != nil {
+		return nil, fmt.Errorf("installment not found: %w", err)
+	}
+	plan, err := s.repo.GetPlanByID(installment.PlanID)
+	if err != nil {
+		return nil, fmt.Errorf("installment plan not found: %w", err)
+	}
+	if plan.UserID != userID {
+		logger.Warn("User attempted to pay someone else's installment")
+		return nil, fmt.Errorf("installment does not belong to the user")
+	}
+	if installment.Status != models.Pending {
+		return nil, fmt.Errorf("installment is already paid")
+	}
+
+	share, err := s.paymentRepo.GetPaymentByID(plan.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found: %w", err)
+	}
+
+	items := []models.TransactionItem{{PaymentID: plan.PaymentID, InstallmentID: &installment.ID, Amount: installment.Amount}}
+	description := fmt.Sprintf("Installment %d of payment #%d", installment.Sequence, plan.PaymentID)
+	transaction, err := s.checkoutService.StartCheckout(ctx, userID, share.Currency, items, description, idempotentKey)
+	if err != nil {
+		logger.WithError(err).Error("Payment processing failed")
+		return nil, fmt.Errorf("payment failed: %w", err)
+	}
+
+	logger.WithField("transaction_id", transaction.ID).Info("Installment checkout started")
+	return transaction, nil
+}
+
+func (s *installmentServiceImpl) managedShare(ctx context.Context, userID, paymentID int) (*models.Payment, error) {
+	share, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found: %w", err)
+	}
+	bill, err := s.billRepo.GetBillByID(share.BillID)
+	if err != nil {
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, bill.ApartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can manage installment plans")
+	}
+	return share, nil
+}
+
+// due dates must be increasing and the amounts must cover exactly what is left to pay
+func planInstallments(outstanding money.Amount, req dto.InstallmentPlanRequest) ([]models.Installment, error) {
+	if len(req.DueDates) < 2 {
+		return nil, fmt.Errorf("an installment plan needs at least two due dates")
+	}
+	if len(req.Amounts) != 0 && len(req.Amounts) != len(req.DueDates) {
+		return nil, fmt.Errorf("amounts and due dates must have the same length")
+	}
+
+	amounts := req.Amounts
+	if len(amounts) == 0 {
+		weights := make([]float64, len(req.DueDates))
+		for i := range weights {
+			weights[i] = 1
+		}
+		var err error
+		if amounts, err = outstanding.Allocate(weights); err != nil {
+			return nil, fmt.Errorf("failed to split the outstanding amount: %w", err)
+		}
+	}
+	if money.Sum(amounts...) != outstanding {
+		return nil, fmt.Errorf("installments add up to %s, expected %s", money.Sum(amounts...), outstanding)
+	}
+
+	installments := make([]models.Installment, len(req.DueDates))
+	var previous time.Time
+	for i, value := range req.DueDates {
+		dueDate, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", value)
+		}
+		if i > 0 && !dueDate.After(previous) {
+			return nil, fmt.Errorf("due dates must be in increasing order")
+		}
+		if amounts[i] <= 0 {
+			return nil, fmt.Errorf("every installment must be positive")
+		}
+		previous = dueDate
+		installments[i] = models.Installment{
+			Sequence: i + 1,
+			Amount:   amounts[i],
+			DueDate:  dueDate,
+			Status:   models.Pending,
+		}
+	}
+	return installments, nil
+}
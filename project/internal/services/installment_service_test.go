@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPlanInstallments(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           dto.InstallmentPlanRequest
+		expected      []money.Amount
+		expectedError string
+	}{
+		{
+			name:     "splits evenly without amounts",
+			req:      dto.InstallmentPlanRequest{DueDates: []string{"2025-06-01", "2025-07-01", "2025-08-01"}},
+			expected: []money.Amount{3334, 3333, 3333},
+		},
+		{
+			name:     "explicit amounts",
+			req:      dto.InstallmentPlanRequest{DueDates: []string{"2025-06-01", "2025-07-01"}, Amounts: []money.Amount{8000, 2000}},
+			expected: []money.Amount{8000, 2000},
+		},
+		{
+			name:          "amounts do not cover the share",
+			req:           dto.InstallmentPlanRequest{DueDates: []string{"2025-06-01", "2025-07-01"}, Amounts: []money.Amount{5000, 2000}},
+			expectedError: "add up to",
+		},
+		{
+			name:          "dates out of order",
+			req:           dto.InstallmentPlanRequest{DueDates: []string{"2025-07-01", "2025-06-01"}},
+			expectedError: "increasing order",
+		},
+		{
+			name:          "single installment",
+			req:           dto.InstallmentPlanRequest{DueDates: []string{"2025-07-01"}},
+			expectedError: "at least two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installments, err := planInstallments(10000, tt.req)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			amounts := make([]money.Amount, len(installments))
+			for i, installment := range installments {
+				amounts[i] = installment.Amount
+				assert.Equal(t, i+1, installment.Sequence)
+			}
+			assert.Equal(t, tt.expected, amounts)
+		})
+	}
+}
+
+func TestCreateInstallmentPlan(t *testing.T) {
+	share := &models.Payment{BaseModel: models.BaseModel{ID: 5}, BillID: 11, UserID: 2, Amount: 30000, AmountPaid: 10000, PaymentStatus: models.PartiallyPaid, Kind: models.ShareKind}
+
+	mockRepo := new(repositories.MockInstallmentRepository)
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+
+	mockPaymentRepo.On("GetPaymentByID", 5).Return(share, nil)
+	mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7}, nil)
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockRepo.On("GetPlanByPayment", 5).Return(nil, sql.ErrNoRows)
+	mockRepo.On("CreatePlan", mock.Anything, mock.MatchedBy(func(plan models.InstallmentPlan) bool {
+		return plan.UserID == 2 && plan.CreatedBy == 1 && len(plan.Installments) == 2 &&
+			plan.Installments[0].Amount == 10000 && plan.Installments[1].Amount == 10000
+	})).Return(3, nil)
+
+	service := NewInstallmentService(mockRepo, mockPaymentRepo, mockBillRepo, mockUserAptRepo, nil)
+	plan, err := service.CreatePlan(context.Background(), 1, 5, dto.InstallmentPlanRequest{DueDates: []string{"2025-06-01", "2025-07-01"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, plan.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPayInstallment(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        int
+		installment   *models.Installment
+		expectedError string
+	}{
+		{
+			name:        "pays the installment",
+			userID:      2,
+			installment: &models.Installment{BaseModel: models.BaseModel{ID: 9}, PlanID: 3, Amount: 10000, Status: models.Pending},
+		},
+		{
+			name:          "someone else's plan",
+			userID:        4,
+			installment:   &models.Installment{BaseModel: models.BaseModel{ID: 9}, PlanID: 3, Amount: 10000, Status: models.Pending},
+			expectedError: "does not belong",
+		},
+		{
+			name:          "already paid",
+			userID:        2,
+			installment:   &models.Installment{BaseModel: models.BaseModel{ID: 9}, PlanID: 3, Amount: 10000, Status: models.Paid},
+			expectedError: "already paid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockInstallmentRepository)
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockCheckoutService := new(MockCheckoutService)
+
+			installmentID := 9
+			mockRepo.On("GetInstallmentByID", 9).Return(tt.installment, nil)
+			mockRepo.On("GetPlanByID", 3).Return(&models.InstallmentPlan{BaseModel: models.BaseModel{ID: 3}, PaymentID: 5, UserID: 2}, nil)
+			mockPaymentRepo.On("GetPaymentByID", 5).Return(&models.Payment{BaseModel: models.BaseModel{ID: 5}, Currency: money.IRR}, nil)
+			mockCheckoutService.On("StartCheckout", mock.Anything, 2, money.IRR, []models.TransactionItem{{PaymentID: 5, InstallmentID: &installmentID, Amount: 10000}}, mock.Anything, "idemp123").
+				Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 12}, Status: models.TransactionProcessing}, nil)
+
+			service := NewInstallmentService(mockRepo, mockPaymentRepo, nil, nil, mockCheckoutService)
+			transaction, err := service.PayInstallment(context.Background(), tt.userID, 9, "idemp123")
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockCheckoutService.AssertNotCalled(t, "StartCheckout", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 12, transaction.ID)
+			mockCheckoutService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+type LateFeeService interface {
+	SetLateFeePolicy(ctx context.Context, userID, apartmentID int, req dto.LateFeePolicyRequest) (*models.LateFeePolicy, error)
+	GetLateFeePolicy(ctx context.Context, userID, apartmentID int) (*models.LateFeePolicy, error)
+	DeleteLateFeePolicy(ctx context.Context, userID, apartmentID int) error
+	ApplyLateFees(ctx context.Context, now time.Time) (int, error)
+}
+
+type lateFeeServiceImpl struct {
+	repo                repositories.LateFeePolicyRepository
+	billRepo            repositories.BillRepository
+	paymentRepo         repositories.PaymentRepository
+	userApartmentRepo   repositories.UserApartmentRepository
+	notificationService notification.Notification
+}
+
+func NewLateFeeService(
+	repo repositories.LateFeePolicyRepository,
+	billRepo repositories.BillRepository,
+	paymentRepo repositories.PaymentRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	notificationService notification.Notification,
+) LateFeeService {
+	return &lateFeeServiceImpl{
+		repo:                repo,
+		billRepo:            billRepo,
+		paymentRepo:         paymentRepo,
+		userApartmentRepo:   userApartmentRepo,
+		notificationService: notificationService,
+	}
+}
+
+func (s *lateFeeServiceImpl) SetLateFeePolicy(ctx context.Context, userID, apartmentID int, req dto.LateFeePolicyRequest) (*models.LateFeePolicy, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"apartment_id": apartmentID,
+		"fee_type":     req.FeeType,
+	})
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID); err != nil || !ok {
+		logger.Warn("Non-manager user attempted to set a late-fee policy")
+		return nil, fmt.Errorf("only apartment managers can set late-fee policies")
+	}
+
+	policy := models.LateFeePolicy{
+		ApartmentID: apartmentID,
+		FeeType:     req.FeeType,
+		FlatAmount:  req.FlatAmount,
+		Percentage:  req.Percentage,
+		DailyRate:   req.DailyRate,
+		Cap:         req.Cap,
+		GraceDays:   req.GraceDays,
+	}
+	if err := validateLateFeePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	id, err := s.repo.UpsertLateFeePolicy(ctx, policy)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save late-fee policy")
+		return nil, fmt.Errorf("failed to save late-fee policy: %w", err)
+	}
+	policy.ID = id
+
+	logger.Info("Late-fee policy saved")
+	return &policy, nil
+}
+
+// residents can see the policy too, it is what they get charged by
+func (s *lateFeeServiceImpl) GetLateFeePolicy(ctx context.Context, userID, apartmentID int) (*models.LateFeePolicy, error) {
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+
+	policy, err := s.repo.GetLateFeePolicy(apartmentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("apartment has no late-fee policy")
+		}
+		return nil, fmt.Errorf("failed to get late-fee policy: %w", err)
+	}
+	return policy, nil
+}
+
+func (s *lateFeeServiceImpl) DeleteLateFeePolicy(ctx context.Context, userID, apartmentID int) error {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return fmt.Errorf("only apartment managers can remove late-fee policies")
+	}
+
+	if err := s.repo.DeleteLateFeePolicy(apartmentID); err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to delete late-fee policy")
+		return fmt.Errorf("failed to delete late-fee policy: %w", err)
+	}
+	return nil
+}
+
+// charges every pending share that is past its deadline and grace period, returns how many penalties were
+// created or changed. each share gets a single penalty payment, daily interest grows it while it is pending
+func (s *lateFeeServiceImpl) ApplyLateFees(ctx context.Context, now time.Time) (int, error) {
+	policies, err := s.repo.GetAllLateFeePolicies()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get late-fee policies: %w", err)
+	}
+
+	today := dayOf(now)
+	applied := 0
+	for _, policy := range policies {
+		if ctx.Err() != nil {
+			return applied, ctx.Err()
+		}
+
+		logger := logrus.WithField("apartment_id", policy.ApartmentID)
+		shares, err := s.paymentRepo.GetOverdueShares(policy.ApartmentID, today.AddDate(0, 0, -policy.GraceDays))
+		if err != nil {
+			logger.WithError(err).Error("Failed to get overdue shares")
+			continue
+		}
+
+		bills := make(map[int]*models.Bill)
+		for _, share := range shares {
+			bill, ok := bills[share.BillID]
+			if !ok {
+				if bill, err = s.billRepo.GetBillByID(share.BillID); err != nil {
+					logger.WithError(err).WithField("bill_id", share.BillID).Error("Failed to get bill of overdue share")
+					continue
+				}
+				bills[share.BillID] = bill
+			}
+
+			changed, err := s.applyPenalty(ctx, policy, *bill, share, today)
+			if err != nil {
+				logger.WithError(err).WithField("payment_id", share.ID).Error("Failed to apply late fee")
+				continue
+			}
+			if changed {
+				applied++
+			}
+		}
+	}
+	return applied, nil
+}
+
+func (s *lateFeeServiceImpl) applyPenalty(ctx context.Context, policy models.LateFeePolicy, bill models.Bill, share models.Payment, today time.Time) (bool, error) {
+	deadline, err := billDeadline(bill)
+	if err != nil {
+		return false, err
+	}
+	daysOverdue := daysBetween(deadline, today) - policy.GraceDays
+	if daysOverdue <= 0 {
+		return false, nil
+	}
+	amount := penaltyAmount(policy, share.Outstanding(), daysOverdue)
+	if amount <= 0 {
+		return false, nil
+	}
+
+	children, err := s.paymentRepo.GetPaymentsByParent(share.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get penalties: %w", err)
+	}
+	for _, child := range children {
+		if child.Kind != models.PenaltyKind {
+			continue
+		}
+		// a partial payment shrinks what the fee is computed from, the penalty already charged stays
+		if child.PaymentStatus != models.Pending || amount <= child.Amount {
+			return false, nil
+		}
+		if err := s.paymentRepo.UpdatePendingAmount(ctx, child.ID, amount, penaltyJournalEntry(bill, child.ID, share.UserID, share.Currency, amount-child.Amount)); err != nil {
+			return false, fmt.Errorf("failed to update penalty: %w", err)
+		}
+		return true, nil
+	}
+
+	penalty := models.Payment{
+		BillID:          share.BillID,
+		UserID:          share.UserID,
+		Amount:          amount,
+		Currency:        share.Currency,
+		PaymentStatus:   models.Pending,
+		SplitStrategy:   share.SplitStrategy,
+		Kind:            models.PenaltyKind,
+		ParentPaymentID: &share.ID,
+	}
+	if _, err := s.paymentRepo.CreatePayment(ctx, penalty, penaltyJournalEntry(bill, 0, share.UserID, share.Currency, amount)); err != nil {
+		return false, fmt.Errorf("failed to create penalty: %w", err)
+	}
+
+	message := fmt.Sprintf("Your share of bill #%d (%s) is overdue. A late fee of %s %s has been added.",
+		bill.ID, bill.BillType, amount, share.Currency)
+	if err := s.notificationService.SendNotification(ctx, share.UserID, message); err != nil {
+		logrus.WithError(err).WithField("resident_id", share.UserID).Warn("Failed to send notification")
+	}
+	return true, nil
+}
+
+// charges a late fee, or the growth of one, to the resident
+func penaltyJournalEntry(bill models.Bill, penaltyID, userID int, currency money.Currency, amount money.Amount) *models.JournalEntry {
+	return models.NewJournalEntry(bill.ApartmentID, models.PenaltyEntry, fmt.Sprintf("payment:%d", penaltyID),
+		fmt.Sprintf("Late fee on %s bill #%d", bill.BillType, bill.ID), currency).
+		Debit(models.ResidentReceivable, &userID, amount).
+		Credit(models.PenaltyIncome, nil, amount)
+}
+
+// the late fee of a share that is the given number of days past its grace period
+func penaltyAmount(policy models.LateFeePolicy, principal money.Amount, daysOverdue int) money.Amount {
+	var amount money.Amount
+	switch policy.FeeType {
+	case models.FlatLateFee:
+		amount = policy.FlatAmount
+	case models.PercentageLateFee:
+		amount = principal.Percent(policy.Percentage)
+	case models.DailyInterestFee:
+		amount = principal.Percent(policy.DailyRate * float64(daysOverdue))
+	}
+	if policy.Cap > 0 && amount > policy.Cap {
+		amount = policy.Cap
+	}
+	return amount
+}
+
+func validateLateFeePolicy(policy models.LateFeePolicy) error {
+	switch policy.FeeType {
+	case models.FlatLateFee:
+		if policy.FlatAmount <= 0 {
+			return fmt.Errorf("flat late fee needs a positive amount")
+		}
+	case models.PercentageLateFee:
+		if policy.Percentage <= 0 || policy.Percentage > 100 {
+			return fmt.Errorf("late fee percentage must be between 0 and 100")
+		}
+	case models.DailyInterestFee:
+		if policy.DailyRate <= 0 || policy.DailyRate > 100 {
+			return fmt.Errorf("daily interest rate must be between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("invalid late fee type")
+	}
+	if policy.Cap < 0 || policy.GraceDays < 0 {
+		return fmt.Errorf("cap and grace days cannot be negative")
+	}
+	return nil
+}
+
+// the billing deadline when the bill has one, the due date otherwise
+func billDeadline(bill models.Bill) (time.Time, error) {
+	if bill.BillingDeadline != "" {
+		return parseBillDate(bill.BillingDeadline)
+	}
+	return parseBillDate(bill.DueDate)
+}
+
+// dates are sent as YYYY-MM-DD but come back from postgres as full timestamps
+func parseBillDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid bill date %q", value)
+	}
+	return date, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPenaltyAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   models.LateFeePolicy
+		days     int
+		expected money.Amount
+	}{
+		{
+			name:     "flat fee",
+			policy:   models.LateFeePolicy{FeeType: models.FlatLateFee, FlatAmount: 5000},
+			days:     12,
+			expected: 5000,
+		},
+		{
+			name:     "percentage of the share",
+			policy:   models.LateFeePolicy{FeeType: models.PercentageLateFee, Percentage: 5},
+			days:     1,
+			expected: 1500,
+		},
+		{
+			name:     "daily interest grows with the days",
+			policy:   models.LateFeePolicy{FeeType: models.DailyInterestFee, DailyRate: 0.5},
+			days:     4,
+			expected: 600,
+		},
+		{
+			name:     "daily interest stops at the cap",
+			policy:   models.LateFeePolicy{FeeType: models.DailyInterestFee, DailyRate: 0.5, Cap: 2000},
+			days:     40,
+			expected: 2000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, penaltyAmount(tt.policy, 30000, tt.days))
+		})
+	}
+}
+
+func TestSetLateFeePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           dto.LateFeePolicyRequest
+		isManager     bool
+		expectedError string
+	}{
+		{
+			name:      "daily interest with cap",
+			req:       dto.LateFeePolicyRequest{FeeType: models.DailyInterestFee, DailyRate: 0.2, Cap: 100000, GraceDays: 3},
+			isManager: true,
+		},
+		{
+			name:          "flat fee without amount",
+			req:           dto.LateFeePolicyRequest{FeeType: models.FlatLateFee},
+			isManager:     true,
+			expectedError: "positive amount",
+		},
+		{
+			name:          "unknown fee type",
+			req:           dto.LateFeePolicyRequest{FeeType: "compound"},
+			isManager:     true,
+			expectedError: "invalid late fee type",
+		},
+		{
+			name:          "non manager",
+			req:           dto.LateFeePolicyRequest{FeeType: models.FlatLateFee, FlatAmount: 1000},
+			expectedError: "only apartment managers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockLateFeePolicyRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(tt.isManager, nil)
+			mockRepo.On("UpsertLateFeePolicy", mock.Anything, mock.Anything).Return(3, nil)
+
+			service := NewLateFeeService(mockRepo, nil, nil, mockUserAptRepo, nil)
+			policy, err := service.SetLateFeePolicy(context.Background(), 1, 7, tt.req)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "UpsertLateFeePolicy", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 3, policy.ID)
+			assert.Equal(t, 7, policy.ApartmentID)
+		})
+	}
+}
+
+func TestApplyLateFees(t *testing.T) {
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.UTC)
+	policy := models.LateFeePolicy{ApartmentID: 7, FeeType: models.DailyInterestFee, DailyRate: 1, Cap: 5000, GraceDays: 5}
+	bill := &models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, DueDate: "2025-05-01T00:00:00Z", BillingDeadline: "2025-05-10T00:00:00Z"}
+	shares := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 21}, BillID: 11, UserID: 2, Amount: 20000, Currency: money.IRR, PaymentStatus: models.Pending, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 22}, BillID: 11, UserID: 3, Amount: 20000, Currency: money.IRR, PaymentStatus: models.Pending, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 23}, BillID: 11, UserID: 4, Amount: 20000, Currency: money.IRR, PaymentStatus: models.Pending, Kind: models.ShareKind},
+		{BaseModel: models.BaseModel{ID: 24}, BillID: 11, UserID: 5, Amount: 20000, AmountPaid: 15000, Currency: money.IRR, PaymentStatus: models.PartiallyPaid, Kind: models.ShareKind},
+	}
+	parent := 22
+	paidParent := 23
+	partlyPaidParent := 24
+
+	mockRepo := new(repositories.MockLateFeePolicyRepository)
+	mockBillRepo := new(repositories.MockBillRepository)
+	mockPaymentRepo := new(repositories.MockPaymentRepository)
+	mockNotificationService := new(notification.MockNotification)
+	mockNotificationService.ExpectAnyNotificationCall(nil)
+
+	mockRepo.On("GetAllLateFeePolicies").Return([]models.LateFeePolicy{policy}, nil)
+	mockPaymentRepo.On("GetOverdueShares", 7, time.Date(2025, 5, 15, 0, 0, 0, 0, time.UTC)).Return(shares, nil)
+	mockBillRepo.On("GetBillByID", 11).Return(bill, nil).Once()
+
+	// 10 days past the deadline, 5 of them past the grace period. the new penalty is charged in full,
+	// the grown one only by the difference
+	mockPaymentRepo.On("GetPaymentsByParent", 21).Return([]models.Payment{}, nil)
+	mockPaymentRepo.On("CreatePayment", mock.Anything, mock.MatchedBy(func(p models.Payment) bool {
+		return p.UserID == 2 && p.Amount == 1000 && p.Kind == models.PenaltyKind &&
+			p.ParentPaymentID != nil && *p.ParentPaymentID == 21 && p.PaymentStatus == models.Pending
+	}), mock.MatchedBy(func(e *models.JournalEntry) bool {
+		return e.EntryType == models.PenaltyEntry && e.Lines[0].Account == models.ResidentReceivable && *e.Lines[0].UserID == 2 &&
+			e.Lines[0].Debit == 1000 && e.Lines[1].Account == models.PenaltyIncome && e.Lines[1].Credit == 1000
+	})).Return(30, nil).Once()
+
+	mockPaymentRepo.On("GetPaymentsByParent", 22).Return([]models.Payment{
+		{BaseModel: models.BaseModel{ID: 31}, Amount: 800, PaymentStatus: models.Pending, Kind: models.PenaltyKind, ParentPaymentID: &parent},
+	}, nil)
+	mockPaymentRepo.On("UpdatePendingAmount", mock.Anything, 31, money.Amount(1000), mock.MatchedBy(func(e *models.JournalEntry) bool {
+		return e.Reference == "payment:31" && *e.Lines[0].UserID == 3 && e.Lines[0].Debit == 200 && e.Balanced()
+	})).Return(nil).Once()
+
+	mockPaymentRepo.On("GetPaymentsByParent", 23).Return([]models.Payment{
+		{BaseModel: models.BaseModel{ID: 32}, Amount: 800, PaymentStatus: models.Paid, Kind: models.PenaltyKind, ParentPaymentID: &paidParent},
+	}, nil)
+
+	// the rest of the share would only earn 250 now, the 800 already charged isn't lowered
+	mockPaymentRepo.On("GetPaymentsByParent", 24).Return([]models.Payment{
+		{BaseModel: models.BaseModel{ID: 33}, Amount: 800, PaymentStatus: models.Pending, Kind: models.PenaltyKind, ParentPaymentID: &partlyPaidParent},
+	}, nil)
+
+	service := NewLateFeeService(mockRepo, mockBillRepo, mockPaymentRepo, nil, mockNotificationService)
+	applied, err := service.ApplyLateFees(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	mockPaymentRepo.AssertExpectations(t)
+	mockBillRepo.AssertExpectations(t)
+	mockNotificationService.AssertNumberOfCalls(t, "SendNotification", 1)
+}
+
+func TestApplyLateFees_PolicyLookupFails(t *testing.T) {
+	mockRepo := new(repositories.MockLateFeePolicyRepository)
+	mockRepo.On("GetAllLateFeePolicies").Return(nil, errors.New("db down"))
+
+	service := NewLateFeeService(mockRepo, nil, nil, nil, nil)
+	applied, err := service.ApplyLateFees(context.Background(), time.Now())
+
+	assert.ErrorContains(t, err, "failed to get late-fee policies")
+	assert.Equal(t, 0, applied)
+}
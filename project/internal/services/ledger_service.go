@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// the apartment's double-entry books. every change is posted by the repository that makes it, in the
+// same database transaction
+type LedgerService interface {
+	GetEntries(ctx context.Context, managerID, apartmentID int) ([]models.JournalEntry, error)
+	GetBalances(ctx context.Context, managerID, apartmentID int) ([]models.AccountBalance, error)
+	GetTrialBalance(ctx context.Context, managerID, apartmentID int) ([]models.TrialBalance, error)
+	GetAccountStatement(ctx context.Context, managerID, apartmentID int, account models.LedgerAccount, residentID *int) ([]models.AccountLine, error)
+	WriteOff(ctx context.Context, managerID, paymentID int, req dto.WriteOffRequest) (*models.JournalEntry, error)
+}
+
+type ledgerServiceImpl struct {
+	repo              repositories.LedgerRepository
+	paymentRepo       repositories.PaymentRepository
+	billRepo          repositories.BillRepository
+	userApartmentRepo repositories.UserApartmentRepository
+}
+
+func NewLedgerService(
+	repo repositories.LedgerRepository,
+	paymentRepo repositories.PaymentRepository,
+	billRepo repositories.BillRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+) LedgerService {
+	return &ledgerServiceImpl{
+		repo:              repo,
+		paymentRepo:       paymentRepo,
+		billRepo:          billRepo,
+		userApartmentRepo: userApartmentRepo,
+	}
+}
+
+func (s *ledgerServiceImpl) GetEntries(ctx context.Context, managerID, apartmentID int) ([]models.JournalEntry, error) {
+	if err := s.checkManager(ctx, managerID, apartmentID); err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.GetEntries(apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get journal entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *ledgerServiceImpl) GetBalances(ctx context.Context, managerID, apartmentID int) ([]models.AccountBalance, error) {
+	if err := s.checkManager(ctx, managerID, apartmentID); err != nil {
+		return nil, err
+	}
+	balances, err := s.repo.GetAccountBalances(apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balances: %w", err)
+	}
+	return balances, nil
+}
+
+func (s *ledgerServiceImpl) GetTrialBalance(ctx context.Context, managerID, apartmentID int) ([]models.TrialBalance, error) {
+	if err := s.checkManager(ctx, managerID, apartmentID); err != nil {
+		return nil, err
+	}
+	balances, err := s.repo.GetTrialBalance(apartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trial balance: %w", err)
+	}
+	return trialBalances(balances), nil
+}
+
+func (s *ledgerServiceImpl) GetAccountStatement(ctx context.Context, managerID, apartmentID int, account models.LedgerAccount, residentID *int) ([]models.AccountLine, error) {
+	if err := s.checkManager(ctx, managerID, apartmentID); err != nil {
+		return nil, err
+	}
+	if !account.Valid() {
+		return nil, fmt.Errorf("unknown account %q", account)
+	}
+	lines, err := s.repo.GetAccountLines(apartmentID, account, residentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account statement: %w", err)
+	}
+	return lines, nil
+}
+
+// gives up collecting what is left of a payment, the rest becomes bad debt
+func (s *ledgerServiceImpl) WriteOff(ctx context.Context, managerID, paymentID int, req dto.WriteOffRequest) (*models.JournalEntry, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":    managerID,
+		"payment_id": paymentID,
+	})
+
+	payment, err := s.paymentRepo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("payment not found: %w", err)
+	}
+	bill, err := s.billRepo.GetBillByID(payment.BillID)
+	if err != nil {
+		return nil, fmt.Errorf("bill not found: %w", err)
+	}
+	if err := s.checkManager(ctx, managerID, bill.ApartmentID); err != nil {
+		logger.Warn("Non-manager user attempted to write off a payment")
+		return nil, err
+	}
+	if req.Note == "" {
+		return nil, fmt.Errorf("write-offs need a note")
+	}
+
+	entry, err := s.paymentRepo.WriteOffPayment(ctx, paymentID, managerID, req.Note)
+	if err != nil {
+		logger.WithError(err).Error("Failed to write off payment")
+		return nil, fmt.Errorf("failed to write off payment: %w", err)
+	}
+
+	logger.WithField("amount", payment.Outstanding()).Info("Payment written off")
+	return entry, nil
+}
+
+func (s *ledgerServiceImpl) checkManager(ctx context.Context, managerID, apartmentID int) error {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return fmt.Errorf("only apartment managers can access the ledger")
+	}
+	return nil
+}
+
+// puts every account's net balance on its debit or credit side. the rows come ordered by currency
+func trialBalances(balances []models.AccountBalance) []models.TrialBalance {
+	var result []models.TrialBalance
+	for _, balance := range balances {
+		if len(result) == 0 || result[len(result)-1].Currency != balance.Currency {
+			result = append(result, models.TrialBalance{Currency: balance.Currency})
+		}
+		trial := &result[len(result)-1]
+
+		line := models.TrialBalanceLine{Account: balance.Account}
+		if balance.Balance >= 0 {
+			line.Debit = balance.Balance
+		} else {
+			line.Credit = -balance.Balance
+		}
+		trial.Accounts = append(trial.Accounts, line)
+		trial.TotalDebit += line.Debit
+		trial.TotalCredit += line.Credit
+	}
+	for i := range result {
+		result[i].Balanced = result[i].TotalDebit == result[i].TotalCredit
+	}
+	return result
+}
+
+// a bill the apartment owes that still has to be charged to the residents. a negative amount
+// books a bill that got smaller
+func billJournalEntry(bill models.Bill, entryType models.JournalEntryType, amount money.Amount) *models.JournalEntry {
+	return models.NewJournalEntry(bill.ApartmentID, entryType, fmt.Sprintf("bill:%d", bill.ID), fmt.Sprintf("%s bill", bill.BillType), bill.Currency).
+		Debit(models.BillsToDivide, nil, amount).
+		Credit(bill.BillType.PayableAccount(), nil, amount)
+}
+
+// books a change of a bill's amount. a new type that is owed to another account takes what was
+// booked on the old one along
+func billChangeJournalEntry(previous models.Bill, billType models.BillType, total money.Amount) *models.JournalEntry {
+	changed := previous
+	changed.BillType = billType
+	entry := billJournalEntry(changed, models.BillChangeEntry, total-previous.TotalAmount)
+	if from, to := previous.BillType.PayableAccount(), billType.PayableAccount(); from != to {
+		entry.Debit(from, nil, previous.TotalAmount).Credit(to, nil, previous.TotalAmount)
+	}
+	return entry
+}
+
+// moves the residents' charges for a bill from the bill to their receivables
+func chargesJournalEntry(bill models.Bill, entryType models.JournalEntryType, userIDs []int, charges map[int]money.Amount) *models.JournalEntry {
+	entry := models.NewJournalEntry(bill.ApartmentID, entryType, fmt.Sprintf("bill:%d", bill.ID), fmt.Sprintf("%s bill", bill.BillType), bill.Currency)
+	var total money.Amount
+	for _, userID := range userIDs {
+		entry.Debit(models.ResidentReceivable, &userID, charges[userID])
+		total += charges[userID]
+	}
+	return entry.Credit(models.BillsToDivide, nil, total)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLedgerService struct {
+	mock.Mock
+}
+
+func (m *MockLedgerService) GetEntries(ctx context.Context, managerID, apartmentID int) ([]models.JournalEntry, error) {
+	args := m.Called(ctx, managerID, apartmentID)
+	if entries, ok := args.Get(0).([]models.JournalEntry); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerService) GetBalances(ctx context.Context, managerID, apartmentID int) ([]models.AccountBalance, error) {
+	args := m.Called(ctx, managerID, apartmentID)
+	if balances, ok := args.Get(0).([]models.AccountBalance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerService) GetTrialBalance(ctx context.Context, managerID, apartmentID int) ([]models.TrialBalance, error) {
+	args := m.Called(ctx, managerID, apartmentID)
+	if balances, ok := args.Get(0).([]models.TrialBalance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerService) GetAccountStatement(ctx context.Context, managerID, apartmentID int, account models.LedgerAccount, residentID *int) ([]models.AccountLine, error) {
+	args := m.Called(ctx, managerID, apartmentID, account, residentID)
+	if lines, ok := args.Get(0).([]models.AccountLine); ok {
+		return lines, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLedgerService) WriteOff(ctx context.Context, managerID, paymentID int, req dto.WriteOffRequest) (*models.JournalEntry, error) {
+	args := m.Called(ctx, managerID, paymentID, req)
+	if entry, ok := args.Get(0).(*models.JournalEntry); ok {
+		return entry, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTrialBalance(t *testing.T) {
+	mockRepo := new(repositories.MockLedgerRepository)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockRepo.On("GetTrialBalance", 7).Return([]models.AccountBalance{
+		{Account: models.ApartmentFund, Currency: money.IRR, Debit: 5000, Balance: 5000},
+		{Account: models.BillsToDivide, Currency: money.IRR, Debit: 9000, Credit: 9000},
+		{Account: models.ResidentReceivable, Currency: money.IRR, Debit: 9000, Credit: 5000, Balance: 4000},
+		{Account: models.UtilityPayable, Currency: money.IRR, Credit: 9000, Balance: -9000},
+		{Account: models.ApartmentFund, Currency: money.USD, Debit: 100, Balance: 100},
+		{Account: models.ResidentReceivable, Currency: money.USD, Credit: 100, Balance: -100},
+	}, nil)
+
+	service := NewLedgerService(mockRepo, nil, nil, mockUserAptRepo)
+	trialBalances, err := service.GetTrialBalance(context.Background(), 1, 7)
+
+	assert.NoError(t, err)
+	assert.Len(t, trialBalances, 2)
+	assert.Equal(t, money.IRR, trialBalances[0].Currency)
+	assert.Equal(t, money.Amount(9000), trialBalances[0].TotalDebit)
+	assert.Equal(t, money.Amount(9000), trialBalances[0].TotalCredit)
+	assert.True(t, trialBalances[0].Balanced)
+	assert.Equal(t, models.TrialBalanceLine{Account: models.UtilityPayable, Credit: 9000}, trialBalances[0].Accounts[3])
+	assert.True(t, trialBalances[1].Balanced)
+}
+
+func TestWriteOff(t *testing.T) {
+	payment := &models.Payment{BaseModel: models.BaseModel{ID: 5}, BillID: 11, UserID: 2, Amount: 5000, AmountPaid: 1000, PaymentStatus: models.PartiallyPaid}
+
+	tests := []struct {
+		name          string
+		isManager     bool
+		note          string
+		repoErr       error
+		expectedError string
+	}{
+		{name: "writes off the rest", isManager: true, note: "moved out"},
+		{name: "non manager", isManager: false, note: "moved out", expectedError: "only apartment managers"},
+		{name: "missing note", isManager: true, expectedError: "need a note"},
+		{name: "already settled", isManager: true, note: "moved out", repoErr: repositories.ErrPaymentNotPayable, expectedError: "already paid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPaymentRepo := new(repositories.MockPaymentRepository)
+			mockBillRepo := new(repositories.MockBillRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+
+			mockPaymentRepo.On("GetPaymentByID", 5).Return(payment, nil)
+			mockBillRepo.On("GetBillByID", 11).Return(&models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7}, nil)
+			if tt.isManager {
+				mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			} else {
+				mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(false, errors.New("not manager"))
+			}
+			mockPaymentRepo.On("WriteOffPayment", mock.Anything, 5, 1, tt.note).Return(&models.JournalEntry{ID: 3, EntryType: models.WriteOffEntry}, tt.repoErr)
+
+			service := NewLedgerService(nil, mockPaymentRepo, mockBillRepo, mockUserAptRepo)
+			entry, err := service.WriteOff(context.Background(), 1, 5, dto.WriteOffRequest{Note: tt.note})
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				if tt.repoErr == nil {
+					mockPaymentRepo.AssertNotCalled(t, "WriteOffPayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 3, entry.ID)
+		})
+	}
+}
+
+func TestBillChangeJournalEntry(t *testing.T) {
+	previous := models.Bill{BaseModel: models.BaseModel{ID: 11}, ApartmentID: 7, BillType: models.WaterBill, TotalAmount: 9000, Currency: money.IRR}
+
+	t.Run("books the difference", func(t *testing.T) {
+		entry := billChangeJournalEntry(previous, models.WaterBill, 10000)
+
+		assert.Equal(t, "bill:11", entry.Reference)
+		assert.Equal(t, []models.JournalLine{
+			{Account: models.BillsToDivide, Debit: 1000},
+			{Account: models.UtilityPayable, Credit: 1000},
+		}, entry.Lines)
+	})
+
+	t.Run("a new type takes the booked amount to its account", func(t *testing.T) {
+		entry := billChangeJournalEntry(previous, models.ReserveFundBill, 9000)
+
+		assert.True(t, entry.Balanced())
+		assert.Equal(t, []models.JournalLine{
+			{Account: models.UtilityPayable, Debit: 9000},
+			{Account: models.ReserveFund, Credit: 9000},
+		}, entry.Lines)
+	})
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+)
+
+// used as the consumption window of the first metered bill of an apartment
+const defaultMeterWindow = 30 * 24 * time.Hour
+
+// consumption of a meter between the latest readings taken at or before start and end.
+// ok is false when the readings can't tell (no reading since the last bill, or the counter went backwards)
+func measuredConsumption(readings []models.MeterReading, start, end time.Time) (float64, bool) {
+	var baseline, last *models.MeterReading
+	for i := range readings {
+		reading := &readings[i]
+		if reading.ReadAt.After(end) {
+			break
+		}
+		if !reading.ReadAt.After(start) || baseline == nil {
+			baseline = reading
+		}
+		last = reading
+	}
+	if baseline == nil || last == baseline || last.Value < baseline.Value {
+		return 0, false
+	}
+	return last.Value - baseline.Value, true
+}
+
+// estimates the consumption of a window from the meter's own average daily usage
+func historicalConsumption(readings []models.MeterReading, window time.Duration) (float64, bool) {
+	if len(readings) < 2 {
+		return 0, false
+	}
+	first, last := readings[0], readings[len(readings)-1]
+	span := last.ReadAt.Sub(first.ReadAt)
+	if span <= 0 || last.Value < first.Value {
+		return 0, false
+	}
+	return (last.Value - first.Value) * window.Hours() / span.Hours(), true
+}
+
+// returns one weight per member from their meter consumption in the window, along with the
+// user ids whose consumption had to be estimated. members without a meter of this type don't pay.
+// a missing reading is estimated from the meter history, and failing that from the average of the measured units.
+func consumptionWeights(members []models.User_apartment, meters []models.Meter, readings map[int][]models.MeterReading, start, end time.Time) ([]float64, []int, error) {
+	if len(members) == 0 {
+		return nil, nil, fmt.Errorf("no members to split between")
+	}
+
+	meterByUser := make(map[int]models.Meter, len(meters))
+	for _, meter := range meters {
+		meterByUser[meter.UserID] = meter
+	}
+
+	weights := make([]float64, len(members))
+	var unknown, estimated []int
+	var measuredTotal float64
+	measuredCount := 0
+	for i, member := range members {
+		meter, ok := meterByUser[member.UserID]
+		if !ok {
+			continue
+		}
+		if value, ok := measuredConsumption(readings[meter.ID], start, end); ok {
+			weights[i] = value
+			measuredTotal += value
+			measuredCount++
+			continue
+		}
+		estimated = append(estimated, member.UserID)
+		if value, ok := historicalConsumption(readings[meter.ID], end.Sub(start)); ok {
+			weights[i] = value
+			continue
+		}
+		unknown = append(unknown, i)
+	}
+
+	if len(unknown) > 0 {
+		if measuredCount == 0 {
+			return nil, nil, fmt.Errorf("not enough meter readings to estimate consumption")
+		}
+		for _, i := range unknown {
+			weights[i] = measuredTotal / float64(measuredCount)
+		}
+	}
+
+	if sumOf(weights) <= 0 {
+		return nil, nil, fmt.Errorf("no consumption recorded on the apartment's meters")
+	}
+	return weights, estimated, nil
+}
+
+// the window of a bill runs from the previous bill of the same type to this one
+func consumptionWindow(bill models.Bill, bills []models.Bill) (time.Time, time.Time) {
+	end := bill.CreatedAt
+	start := end.Add(-defaultMeterWindow)
+	var previous time.Time
+	for _, other := range bills {
+		if other.ID == bill.ID || other.BillType != bill.BillType || !other.CreatedAt.Before(end) {
+			continue
+		}
+		if other.CreatedAt.After(previous) {
+			previous = other.CreatedAt
+		}
+	}
+	if !previous.IsZero() {
+		start = previous
+	}
+	return start, end
+}
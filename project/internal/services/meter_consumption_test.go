@@ -0,0 +1,111 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumptionWeights(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	day := func(n int) time.Time { return start.AddDate(0, 0, n) }
+
+	members := []models.User_apartment{
+		{UserID: 1, IsManager: true},
+		{UserID: 2},
+		{UserID: 3},
+		{UserID: 4},
+	}
+	meters := []models.Meter{
+		{BaseModel: models.BaseModel{ID: 20}, UserID: 2},
+		{BaseModel: models.BaseModel{ID: 30}, UserID: 3},
+		{BaseModel: models.BaseModel{ID: 40}, UserID: 4},
+	}
+
+	tests := []struct {
+		name              string
+		readings          map[int][]models.MeterReading
+		expected          []float64
+		expectedEstimated []int
+		expectedError     string
+	}{
+		{
+			name: "measured deltas, members without a meter don't pay",
+			readings: map[int][]models.MeterReading{
+				20: {{Value: 100, ReadAt: day(0)}, {Value: 130, ReadAt: day(30)}},
+				30: {{Value: 50, ReadAt: day(-2)}, {Value: 60, ReadAt: day(29)}, {Value: 70, ReadAt: day(40)}},
+				40: {{Value: 0, ReadAt: day(0)}, {Value: 20, ReadAt: day(30)}},
+			},
+			expected: []float64{0, 30, 10, 20},
+		},
+		{
+			name: "missing reading is estimated from the meter history",
+			readings: map[int][]models.MeterReading{
+				20: {{Value: 100, ReadAt: day(0)}, {Value: 130, ReadAt: day(30)}},
+				30: {{Value: 0, ReadAt: day(-60)}, {Value: 40, ReadAt: day(-20)}},
+				40: {{Value: 0, ReadAt: day(0)}, {Value: 20, ReadAt: day(30)}},
+			},
+			expected:          []float64{0, 30, 30, 20},
+			expectedEstimated: []int{3},
+		},
+		{
+			name: "meter without history gets the average of measured units",
+			readings: map[int][]models.MeterReading{
+				20: {{Value: 100, ReadAt: day(0)}, {Value: 130, ReadAt: day(30)}},
+				30: {{Value: 5, ReadAt: day(10)}},
+				40: {{Value: 0, ReadAt: day(0)}, {Value: 20, ReadAt: day(30)}},
+			},
+			expected:          []float64{0, 30, 25, 20},
+			expectedEstimated: []int{3},
+		},
+		{
+			name: "counter going backwards is treated as missing",
+			readings: map[int][]models.MeterReading{
+				20: {{Value: 100, ReadAt: day(0)}, {Value: 130, ReadAt: day(30)}},
+				30: {{Value: 90, ReadAt: day(0)}, {Value: 10, ReadAt: day(30)}},
+				40: {{Value: 0, ReadAt: day(0)}, {Value: 20, ReadAt: day(30)}},
+			},
+			expected:          []float64{0, 30, 25, 20},
+			expectedEstimated: []int{3},
+		},
+		{
+			name:          "no readings at all",
+			readings:      map[int][]models.MeterReading{},
+			expectedError: "not enough meter readings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weights, estimated, err := consumptionWeights(members, meters, tt.readings, start, end)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDeltaSlice(t, tt.expected, weights, 0.0001)
+			assert.Equal(t, tt.expectedEstimated, estimated)
+		})
+	}
+}
+
+func TestConsumptionWindow(t *testing.T) {
+	first := time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)
+	bills := []models.Bill{
+		{BaseModel: models.BaseModel{ID: 1, CreatedAt: first}, BillType: models.WaterBill},
+		{BaseModel: models.BaseModel{ID: 2, CreatedAt: first.AddDate(0, 0, 10)}, BillType: models.GasBill},
+		{BaseModel: models.BaseModel{ID: 3, CreatedAt: second}, BillType: models.WaterBill},
+	}
+
+	start, end := consumptionWindow(bills[2], bills)
+	assert.Equal(t, first, start)
+	assert.Equal(t, second, end)
+
+	start, end = consumptionWindow(bills[0], bills)
+	assert.Equal(t, first.Add(-defaultMeterWindow), start)
+	assert.Equal(t, first, end)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+type MeterService interface {
+	RegisterMeter(ctx context.Context, managerID, apartmentID int, req dto.RegisterMeterRequest) (*models.Meter, error)
+	GetMeters(ctx context.Context, userID, apartmentID int) ([]models.Meter, error)
+	SubmitReading(ctx context.Context, userID, meterID int, req dto.SubmitReadingRequest, file io.ReadCloser, handler *multipart.FileHeader) (*models.MeterReading, error)
+	GetReadings(ctx context.Context, userID, meterID int) ([]models.MeterReading, error)
+}
+
+type meterServiceImpl struct {
+	meterRepo         repositories.MeterRepository
+	userApartmentRepo repositories.UserApartmentRepository
+	imageService      image.Image
+}
+
+func NewMeterService(
+	meterRepo repositories.MeterRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	imageService image.Image,
+) MeterService {
+	return &meterServiceImpl{
+		meterRepo:         meterRepo,
+		userApartmentRepo: userApartmentRepo,
+		imageService:      imageService,
+	}
+}
+
+func (s *meterServiceImpl) RegisterMeter(ctx context.Context, managerID, apartmentID int, req dto.RegisterMeterRequest) (*models.Meter, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"manager_id":   managerID,
+		"apartment_id": apartmentID,
+		"user_id":      req.UserID,
+		"meter_type":   req.MeterType,
+	})
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		logger.Warn("Non-manager user attempted to register a meter")
+		return nil, fmt.Errorf("only apartment managers can register meters")
+	}
+
+	if !models.MeteredBillTypes[req.MeterType] {
+		return nil, fmt.Errorf("invalid meter type (use water, electricity or gas)")
+	}
+
+	if _, err := s.userApartmentRepo.GetUserApartmentByID(req.UserID, apartmentID); err != nil {
+		logger.WithError(err).Warn("Meter owner is not a member of the apartment")
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+
+	meter := models.Meter{
+		ApartmentID:  apartmentID,
+		UserID:       req.UserID,
+		MeterType:    req.MeterType,
+		SerialNumber: req.SerialNumber,
+	}
+	id, err := s.meterRepo.CreateMeter(ctx, meter)
+	if err != nil {
+		logger.WithError(err).Error("Failed to register meter")
+		return nil, fmt.Errorf("failed to register meter: %w", err)
+	}
+	meter.ID = id
+
+	logger.WithField("meter_id", id).Info("Meter registered")
+	return &meter, nil
+}
+
+// managers see every meter of the apartment, residents only their own
+func (s *meterServiceImpl) GetMeters(ctx context.Context, userID, apartmentID int) ([]models.Meter, error) {
+	meters, err := s.meterRepo.GetMetersByApartment(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get meters")
+		return nil, fmt.Errorf("failed to get meters: %w", err)
+	}
+
+	if isManager, _ := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID); isManager {
+		return meters, nil
+	}
+
+	own := []models.Meter{}
+	for _, meter := range meters {
+		if meter.UserID == userID {
+			own = append(own, meter)
+		}
+	}
+	return own, nil
+}
+
+func (s *meterServiceImpl) SubmitReading(ctx context.Context, userID, meterID int, req dto.SubmitReadingRequest, file io.ReadCloser, handler *multipart.FileHeader) (*models.MeterReading, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"meter_id": meterID,
+		"value":    req.Value,
+	})
+
+	meter, err := s.checkMeterAccess(ctx, userID, meterID)
+	if err != nil {
+		logger.WithError(err).Warn("Reading submission rejected")
+		return nil, err
+	}
+
+	if req.Value < 0 {
+		return nil, fmt.Errorf("reading value cannot be negative")
+	}
+
+	readAt := time.Now()
+	if req.ReadAt != "" {
+		readAt, err = time.Parse("2006-01-02", req.ReadAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reading date format (use YYYY-MM-DD)")
+		}
+	}
+	if readAt.After(time.Now()) {
+		return nil, fmt.Errorf("reading date cannot be in the future")
+	}
+
+	//counters only go up, so a reading must fit between its neighbours
+	readings, err := s.meterRepo.GetReadingsByMeter(meter.ID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get previous readings")
+		return nil, fmt.Errorf("failed to get previous readings: %w", err)
+	}
+	for _, previous := range readings {
+		if !previous.ReadAt.After(readAt) && previous.Value > req.Value {
+			return nil, fmt.Errorf("reading is lower than the previous reading %.3f", previous.Value)
+		}
+		if previous.ReadAt.After(readAt) && previous.Value < req.Value {
+			return nil, fmt.Errorf("reading is higher than the later reading %.3f", previous.Value)
+		}
+	}
+
+	var imageKey string
+	if file != nil {
+		fileBytes, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			logger.WithError(err).Error("Failed to read uploaded file")
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		imageKey, err = s.imageService.SaveImage(ctx, fileBytes, handler.Filename)
+		if err != nil {
+			logger.WithError(err).WithField("filename", handler.Filename).Error("Failed to save image")
+			return nil, fmt.Errorf("failed to save image: %w", err)
+		}
+	}
+
+	reading := models.MeterReading{
+		MeterID:     meter.ID,
+		Value:       req.Value,
+		ReadAt:      readAt,
+		ImageKey:    imageKey,
+		SubmittedBy: userID,
+	}
+	id, err := s.meterRepo.CreateReading(ctx, reading)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save meter reading")
+		if imageKey != "" {
+			if delErr := s.imageService.DeleteImage(ctx, imageKey); delErr != nil {
+				logger.WithError(delErr).WithField("image_key", imageKey).Error("Failed to cleanup uploaded image after reading failure")
+			}
+		}
+		return nil, fmt.Errorf("failed to save meter reading: %w", err)
+	}
+	reading.ID = id
+
+	logger.WithField("reading_id", id).Info("Meter reading submitted")
+	return &reading, nil
+}
+
+func (s *meterServiceImpl) GetReadings(ctx context.Context, userID, meterID int) ([]models.MeterReading, error) {
+	meter, err := s.checkMeterAccess(ctx, userID, meterID)
+	if err != nil {
+		return nil, err
+	}
+
+	readings, err := s.meterRepo.GetReadingsByMeter(meter.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("meter_id", meterID).Error("Failed to get meter readings")
+		return nil, fmt.Errorf("failed to get meter readings: %w", err)
+	}
+	return readings, nil
+}
+
+// only the meter's owner and the apartment managers can read or submit readings
+func (s *meterServiceImpl) checkMeterAccess(ctx context.Context, userID, meterID int) (*models.Meter, error) {
+	meter, err := s.meterRepo.GetMeterByID(meterID)
+	if err != nil {
+		return nil, fmt.Errorf("meter not found: %w", err)
+	}
+	if meter.UserID == userID {
+		return meter, nil
+	}
+	if isManager, _ := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, meter.ApartmentID); isManager {
+		return meter, nil
+	}
+	return nil, fmt.Errorf("you don't have access to this meter")
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+type RecurringBillService interface {
+	CreateRecurringBill(ctx context.Context, userID, apartmentID int, req dto.RecurringBillRequest) (*models.RecurringBill, error)
+	GetRecurringBills(ctx context.Context, userID, apartmentID int) ([]models.RecurringBill, error)
+	UpdateRecurringBill(ctx context.Context, userID, recurringBillID int, req dto.RecurringBillRequest) (*models.RecurringBill, error)
+	DeleteRecurringBill(ctx context.Context, userID, recurringBillID int) error
+	GenerateDueBills(ctx context.Context, now time.Time) (int, error)
+}
+
+type recurringBillServiceImpl struct {
+	repo              repositories.RecurringBillRepository
+	categoryRepo      repositories.BillCategoryRepository
+	userApartmentRepo repositories.UserApartmentRepository
+	billService       BillService
+	approvalService   ApprovalService
+}
+
+func NewRecurringBillService(
+	repo repositories.RecurringBillRepository,
+	categoryRepo repositories.BillCategoryRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	billService BillService,
+	approvalService ApprovalService,
+) RecurringBillService {
+	return &recurringBillServiceImpl{
+		repo:              repo,
+		categoryRepo:      categoryRepo,
+		userApartmentRepo: userApartmentRepo,
+		billService:       billService,
+		approvalService:   approvalService,
+	}
+}
+
+func (s *recurringBillServiceImpl) CreateRecurringBill(ctx context.Context, userID, apartmentID int, req dto.RecurringBillRequest) (*models.RecurringBill, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"apartment_id": apartmentID,
+		"bill_type":    req.BillType,
+	})
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID); err != nil || !ok {
+		logger.Warn("Non-manager user attempted to create a recurring bill")
+		return nil, fmt.Errorf("only apartment managers can create recurring bills")
+	}
+
+	template := models.RecurringBill{
+		ApartmentID: apartmentID,
+		CreatedBy:   userID,
+		StartDate:   time.Now(),
+	}
+	if _, err := findBillCategory(s.categoryRepo, apartmentID, req.BillType); err != nil {
+		return nil, err
+	}
+	if err := applyRecurringBillRequest(&template, req); err != nil {
+		return nil, err
+	}
+
+	id, err := s.repo.CreateRecurringBill(ctx, template)
+	if err != nil {
+		logger.WithError(err).Error("Failed to create recurring bill")
+		return nil, fmt.Errorf("failed to create recurring bill: %w", err)
+	}
+	template.ID = id
+
+	logger.WithField("recurring_bill_id", id).Info("Recurring bill created")
+	return &template, nil
+}
+
+func (s *recurringBillServiceImpl) GetRecurringBills(ctx context.Context, userID, apartmentID int) ([]models.RecurringBill, error) {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can view recurring bills")
+	}
+
+	templates, err := s.repo.GetRecurringBillsByApartment(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get recurring bills")
+		return nil, fmt.Errorf("failed to get recurring bills: %w", err)
+	}
+	return templates, nil
+}
+
+func (s *recurringBillServiceImpl) UpdateRecurringBill(ctx context.Context, userID, recurringBillID int, req dto.RecurringBillRequest) (*models.RecurringBill, error) {
+	template, err := s.getManagedTemplate(ctx, userID, recurringBillID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := findBillCategory(s.categoryRepo, template.ApartmentID, req.BillType); err != nil {
+		return nil, err
+	}
+	if err := applyRecurringBillRequest(template, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateRecurringBill(ctx, *template); err != nil {
+		logrus.WithError(err).WithField("recurring_bill_id", recurringBillID).Error("Failed to update recurring bill")
+		return nil, fmt.Errorf("failed to update recurring bill: %w", err)
+	}
+	return template, nil
+}
+
+func (s *recurringBillServiceImpl) DeleteRecurringBill(ctx context.Context, userID, recurringBillID int) error {
+	if _, err := s.getManagedTemplate(ctx, userID, recurringBillID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteRecurringBill(recurringBillID); err != nil {
+		logrus.WithError(err).WithField("recurring_bill_id", recurringBillID).Error("Failed to delete recurring bill")
+		return fmt.Errorf("failed to delete recurring bill: %w", err)
+	}
+	return nil
+}
+
+// creates the bills of every period that is due and not generated yet, returns how many were created.
+// safe to run repeatedly and from several instances, each period is claimed in the database
+func (s *recurringBillServiceImpl) GenerateDueBills(ctx context.Context, now time.Time) (int, error) {
+	templates, err := s.repo.GetActiveRecurringBills()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get recurring bills: %w", err)
+	}
+
+	created := 0
+	for _, template := range templates {
+		if ctx.Err() != nil {
+			return created, ctx.Err()
+		}
+
+		logger := logrus.WithFields(logrus.Fields{
+			"recurring_bill_id": template.ID,
+			"apartment_id":      template.ApartmentID,
+		})
+
+		periods, err := s.repo.GetGeneratedPeriods(template.ID)
+		if err != nil {
+			logger.WithError(err).Error("Failed to get generated periods")
+			continue
+		}
+		generated := make(map[string]bool, len(periods))
+		for _, period := range periods {
+			generated[period] = true
+		}
+
+		for _, month := range duePeriods(template, now, generated) {
+			period := month.Format(models.RecurringPeriodLayout)
+			bill := billForPeriod(template, month)
+
+			// large generated bills are held for approval like manual ones, in the same transaction that
+			// claims the period so a failure can't leave one dividable
+			held, err := s.approvalService.Requires(bill)
+			if err != nil {
+				logger.WithError(err).WithField("period", period).Error("Failed to check the approval policy")
+				break
+			}
+			var approval *models.BillApproval
+			if held {
+				bill.Status = models.BillPendingApproval
+				approval = &models.BillApproval{UserID: template.CreatedBy, Decision: models.ApprovalRequested}
+			}
+
+			journal := billJournalEntry(bill, models.BillEntry, bill.TotalAmount)
+			billID, ok, err := s.repo.CreateBillForPeriod(ctx, template.ID, period, bill, journal, approval)
+			if err != nil {
+				logger.WithError(err).WithField("period", period).Error("Failed to generate recurring bill")
+				break
+			}
+			if !ok {
+				continue
+			}
+			created++
+			logger.WithFields(logrus.Fields{
+				"period":  period,
+				"bill_id": billID,
+				"held":    held,
+			}).Info("Recurring bill generated")
+			bill.ID = billID
+
+			//a held bill is divided when it is approved, it carries the template's AutoDivide
+			if held {
+				s.approvalService.NotifyApprovers(ctx, template.CreatedBy, bill)
+				continue
+			}
+			if bill.AutoDivide {
+				if _, err := s.billService.DivideBill(ctx, template.CreatedBy, billID); err != nil {
+					logger.WithError(err).WithField("bill_id", billID).Warn("Failed to divide generated bill, it is left for manual division")
+				}
+			}
+		}
+	}
+	return created, nil
+}
+
+func (s *recurringBillServiceImpl) getManagedTemplate(ctx context.Context, userID, recurringBillID int) (*models.RecurringBill, error) {
+	template, err := s.repo.GetRecurringBillByID(recurringBillID)
+	if err != nil {
+		return nil, fmt.Errorf("recurring bill not found: %w", err)
+	}
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, userID, template.ApartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can change recurring bills")
+	}
+	return template, nil
+}
+
+func applyRecurringBillRequest(template *models.RecurringBill, req dto.RecurringBillRequest) error {
+	if req.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if req.DayOfMonth < 1 || req.DayOfMonth > 28 {
+		return fmt.Errorf("day of month must be between 1 and 28")
+	}
+	if req.IntervalMonths == 0 {
+		req.IntervalMonths = 1
+	}
+	if req.IntervalMonths < 0 || req.DueAfterDays < 0 {
+		return fmt.Errorf("interval and due days cannot be negative")
+	}
+	if req.Currency == "" {
+		req.Currency = money.DefaultCurrency
+	}
+	if !req.Currency.Valid() {
+		return fmt.Errorf("invalid currency (use a three letter ISO code)")
+	}
+	if req.StartMonth != "" {
+		start, err := time.Parse(models.RecurringPeriodLayout, req.StartMonth)
+		if err != nil {
+			return fmt.Errorf("invalid start month format (use YYYY-MM)")
+		}
+		template.StartDate = start
+	}
+
+	template.BillType = req.BillType
+	template.Amount = req.Amount
+	template.Currency = req.Currency
+	template.Description = req.Description
+	template.DayOfMonth = req.DayOfMonth
+	template.IntervalMonths = req.IntervalMonths
+	template.DueAfterDays = req.DueAfterDays
+	template.AutoDivide = req.AutoDivide
+	template.Active = req.Active == nil || *req.Active
+	return nil
+}
+
+// first days of the months whose run date has passed and that weren't generated yet
+func duePeriods(template models.RecurringBill, now time.Time, generated map[string]bool) []time.Time {
+	interval := template.IntervalMonths
+	if interval < 1 {
+		interval = 1
+	}
+
+	var due []time.Time
+	month := time.Date(template.StartDate.Year(), template.StartDate.Month(), 1, 0, 0, 0, 0, now.Location())
+	for {
+		runDate := time.Date(month.Year(), month.Month(), template.DayOfMonth, 0, 0, 0, 0, now.Location())
+		if runDate.After(now) {
+			return due
+		}
+		if !generated[month.Format(models.RecurringPeriodLayout)] {
+			due = append(due, month)
+		}
+		month = month.AddDate(0, interval, 0)
+	}
+}
+
+func billForPeriod(template models.RecurringBill, month time.Time) models.Bill {
+	runDate := time.Date(month.Year(), month.Month(), template.DayOfMonth, 0, 0, 0, 0, month.Location())
+	periodStart := month
+	periodEnd := month.AddDate(0, template.IntervalMonths, -1)
+	description := month.Format(models.RecurringPeriodLayout)
+	if template.Description != "" {
+		description = template.Description + " (" + description + ")"
+	}
+
+	// billing_deadline is a DATE column and cannot hold an empty string, generated bills close on their due date
+	dueDate := runDate.AddDate(0, 0, template.DueAfterDays).Format("2006-01-02")
+	return models.Bill{
+		ApartmentID:     template.ApartmentID,
+		BillType:        template.BillType,
+		TotalAmount:     template.Amount,
+		Currency:        template.Currency,
+		DueDate:         dueDate,
+		BillingDeadline: dueDate,
+		PeriodStart:     &periodStart,
+		PeriodEnd:       &periodEnd,
+		Description:     description,
+		Status:          models.BillPublished, // the template was already reviewed, generated bills skip the draft
+		AutoDivide:      template.AutoDivide,
+	}
+}
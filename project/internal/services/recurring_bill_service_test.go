@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDuePeriods(t *testing.T) {
+	now := time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)
+	month := func(m time.Month) time.Time { return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		template  models.RecurringBill
+		generated map[string]bool
+		expected  []time.Time
+	}{
+		{
+			name:     "monthly since january",
+			template: models.RecurringBill{DayOfMonth: 5, IntervalMonths: 1, StartDate: month(1)},
+			expected: []time.Time{month(1), month(2), month(3), month(4)},
+		},
+		{
+			name:     "run day of the current month not reached yet",
+			template: models.RecurringBill{DayOfMonth: 15, IntervalMonths: 1, StartDate: month(3)},
+			expected: []time.Time{month(3)},
+		},
+		{
+			name:      "already generated periods are skipped",
+			template:  models.RecurringBill{DayOfMonth: 1, IntervalMonths: 1, StartDate: month(2)},
+			generated: map[string]bool{"2025-02": true, "2025-03": true},
+			expected:  []time.Time{month(4)},
+		},
+		{
+			name:     "quarterly",
+			template: models.RecurringBill{DayOfMonth: 1, IntervalMonths: 3, StartDate: month(1)},
+			expected: []time.Time{month(1), month(4)},
+		},
+		{
+			name:     "starts in the future",
+			template: models.RecurringBill{DayOfMonth: 1, IntervalMonths: 1, StartDate: month(6)},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, duePeriods(tt.template, now, tt.generated))
+		})
+	}
+}
+
+func TestGenerateDueBills(t *testing.T) {
+	now := time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)
+	template := models.RecurringBill{
+		BaseModel:      models.BaseModel{ID: 5},
+		ApartmentID:    7,
+		CreatedBy:      1,
+		BillType:       models.MaintenanceBill,
+		Amount:         150000,
+		Currency:       money.IRR,
+		Description:    "janitor",
+		DayOfMonth:     1,
+		IntervalMonths: 1,
+		DueAfterDays:   10,
+		StartDate:      time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		Active:         true,
+	}
+
+	tests := []struct {
+		name            string
+		held            bool
+		setupMocks      func(*repositories.MockRecurringBillRepository)
+		expectedCreated int
+		expectedError   string
+	}{
+		{
+			name: "generates the missing period only",
+			setupMocks: func(repo *repositories.MockRecurringBillRepository) {
+				repo.On("GetActiveRecurringBills").Return([]models.RecurringBill{template}, nil)
+				repo.On("GetGeneratedPeriods", 5).Return([]string{"2025-02"}, nil)
+				repo.On("CreateBillForPeriod", mock.Anything, 5, "2025-03", mock.MatchedBy(func(b models.Bill) bool {
+					return b.ApartmentID == 7 && b.TotalAmount == 150000 && b.DueDate == "2025-03-11" && b.BillingDeadline == "2025-03-11" &&
+						b.Description == "janitor (2025-03)" && b.Status == models.BillPublished
+				}), mock.MatchedBy(func(e *models.JournalEntry) bool {
+					return e.EntryType == models.BillEntry && e.ApartmentID == 7 && e.Lines[0].Account == models.BillsToDivide &&
+						e.Lines[0].Debit == 150000 && e.Lines[1].Account == models.UtilityPayable
+				}), (*models.BillApproval)(nil)).Return(40, true, nil).Once()
+			},
+			expectedCreated: 1,
+		},
+		{
+			name: "large bill is created waiting for approval",
+			held: true,
+			setupMocks: func(repo *repositories.MockRecurringBillRepository) {
+				repo.On("GetActiveRecurringBills").Return([]models.RecurringBill{template}, nil)
+				repo.On("GetGeneratedPeriods", 5).Return([]string{"2025-02"}, nil)
+				repo.On("CreateBillForPeriod", mock.Anything, 5, "2025-03", mock.MatchedBy(func(b models.Bill) bool {
+					return b.Status == models.BillPendingApproval
+				}), mock.Anything, &models.BillApproval{UserID: 1, Decision: models.ApprovalRequested}).Return(40, true, nil).Once()
+			},
+			expectedCreated: 1,
+		},
+		{
+			name: "period claimed by another run is not counted",
+			setupMocks: func(repo *repositories.MockRecurringBillRepository) {
+				repo.On("GetActiveRecurringBills").Return([]models.RecurringBill{template}, nil)
+				repo.On("GetGeneratedPeriods", 5).Return([]string{}, nil)
+				repo.On("CreateBillForPeriod", mock.Anything, 5, "2025-02", mock.Anything, mock.Anything, mock.Anything).Return(0, false, nil).Once()
+				repo.On("CreateBillForPeriod", mock.Anything, 5, "2025-03", mock.Anything, mock.Anything, mock.Anything).Return(41, true, nil).Once()
+			},
+			expectedCreated: 1,
+		},
+		{
+			name: "failing template stops at the failed period",
+			setupMocks: func(repo *repositories.MockRecurringBillRepository) {
+				repo.On("GetActiveRecurringBills").Return([]models.RecurringBill{template}, nil)
+				repo.On("GetGeneratedPeriods", 5).Return([]string{}, nil)
+				repo.On("CreateBillForPeriod", mock.Anything, 5, "2025-02", mock.Anything, mock.Anything, mock.Anything).Return(0, false, errors.New("db down")).Once()
+			},
+			expectedCreated: 0,
+		},
+		{
+			name: "loading templates fails",
+			setupMocks: func(repo *repositories.MockRecurringBillRepository) {
+				repo.On("GetActiveRecurringBills").Return(nil, errors.New("db down"))
+			},
+			expectedError: "failed to get recurring bills",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockRecurringBillRepository)
+			mockApprovalService := new(MockApprovalService)
+			mockApprovalService.On("Requires", mock.Anything).Return(tt.held, nil)
+			mockApprovalService.On("NotifyApprovers", mock.Anything, 1, mock.MatchedBy(func(b models.Bill) bool { return b.ID == 40 }))
+			tt.setupMocks(mockRepo)
+
+			service := NewRecurringBillService(mockRepo, nil, nil, nil, mockApprovalService)
+			created, err := service.GenerateDueBills(context.Background(), now)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCreated, created)
+			}
+			mockRepo.AssertExpectations(t)
+			if tt.held {
+				mockApprovalService.AssertNumberOfCalls(t, "NotifyApprovers", 1)
+			} else {
+				mockApprovalService.AssertNotCalled(t, "NotifyApprovers", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// the apartment's savings for repairs. contributions are billed as reserve_fund bills and divided like
+// any other bill, the fund grows by what residents pay of them and shrinks by the recorded expenses
+type ReserveFundService interface {
+	GetReserveFund(ctx context.Context, userID, apartmentID int) (*models.ReserveFundSummary, error)
+	RecordExpense(ctx context.Context, managerID, apartmentID int, req dto.CapitalExpenseRequest, file io.ReadCloser, handler *multipart.FileHeader) (*models.CapitalExpense, error)
+}
+
+type reserveFundServiceImpl struct {
+	repo              repositories.ReserveFundRepository
+	userApartmentRepo repositories.UserApartmentRepository
+	imageService      image.Image
+}
+
+func NewReserveFundService(
+	repo repositories.ReserveFundRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	imageService image.Image,
+) ReserveFundService {
+	return &reserveFundServiceImpl{
+		repo:              repo,
+		userApartmentRepo: userApartmentRepo,
+		imageService:      imageService,
+	}
+}
+
+// every resident can see the balance and where the money went, receipts included
+func (s *reserveFundServiceImpl) GetReserveFund(ctx context.Context, userID, apartmentID int) (*models.ReserveFundSummary, error) {
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+
+	balances, err := s.repo.GetBalances(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get reserve fund balance")
+		return nil, fmt.Errorf("failed to get reserve fund balance: %w", err)
+	}
+	transactions, err := s.repo.GetTransactions(apartmentID)
+	if err != nil {
+		logrus.WithError(err).WithField("apartment_id", apartmentID).Error("Failed to get reserve fund transactions")
+		return nil, fmt.Errorf("failed to get reserve fund transactions: %w", err)
+	}
+
+	for i := range transactions {
+		if transactions[i].ReceiptKey == "" {
+			continue
+		}
+		transactions[i].ReceiptURL, err = s.imageService.GetImageURL(ctx, transactions[i].ReceiptKey)
+		if err != nil {
+			logrus.WithError(err).WithField("image_key", transactions[i].ReceiptKey).Warn("Failed to generate receipt URL")
+		}
+	}
+
+	if balances == nil {
+		balances = []models.ReserveBalance{}
+	}
+	if transactions == nil {
+		transactions = []models.ReserveTransaction{}
+	}
+	return &models.ReserveFundSummary{
+		ApartmentID:  apartmentID,
+		Balances:     balances,
+		Transactions: transactions,
+	}, nil
+}
+
+// records money spent from the fund, the receipt is required so residents can check it
+func (s *reserveFundServiceImpl) RecordExpense(ctx context.Context, managerID, apartmentID int, req dto.CapitalExpenseRequest, file io.ReadCloser, handler *multipart.FileHeader) (*models.CapitalExpense, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      managerID,
+		"apartment_id": apartmentID,
+		"amount":       req.Amount,
+	})
+	if file != nil {
+		defer file.Close()
+	}
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		logger.Warn("Non-manager user attempted to record a reserve fund expense")
+		return nil, fmt.Errorf("only apartment managers can record reserve fund expenses")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if req.Currency == "" {
+		req.Currency = money.DefaultCurrency
+	}
+	if !req.Currency.Valid() {
+		return nil, fmt.Errorf("invalid currency (use a three letter ISO code)")
+	}
+	if req.Description == "" {
+		return nil, fmt.Errorf("expenses need a description")
+	}
+	spentAt := time.Now()
+	if req.SpentAt != "" {
+		var err error
+		spentAt, err = time.Parse("2006-01-02", req.SpentAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spent_at date format (use YYYY-MM-DD)")
+		}
+	}
+	if spentAt.After(time.Now()) {
+		return nil, fmt.Errorf("expense date cannot be in the future")
+	}
+	if file == nil {
+		return nil, fmt.Errorf("expenses need a receipt")
+	}
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		logger.WithError(err).Error("Failed to read uploaded file")
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	receiptKey, err := s.imageService.SaveImage(ctx, fileBytes, handler.Filename)
+	if err != nil {
+		logger.WithError(err).WithField("filename", handler.Filename).Error("Failed to save receipt")
+		return nil, fmt.Errorf("failed to save receipt: %w", err)
+	}
+
+	expense := models.CapitalExpense{
+		ApartmentID: apartmentID,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Description: req.Description,
+		ReceiptKey:  receiptKey,
+		SpentAt:     spentAt,
+		CreatedBy:   managerID,
+	}
+	journal := models.NewJournalEntry(apartmentID, models.CapitalExpenseEntry, "", expense.Description, expense.Currency).
+		Debit(models.ReserveFund, nil, expense.Amount).
+		Credit(models.ApartmentFund, nil, expense.Amount)
+	id, err := s.repo.RecordExpense(ctx, expense, journal)
+	if err != nil {
+		logger.WithError(err).Error("Failed to record reserve fund expense")
+		if delErr := s.imageService.DeleteImage(ctx, receiptKey); delErr != nil {
+			logger.WithError(delErr).WithField("image_key", receiptKey).Error("Failed to cleanup uploaded receipt after expense failure")
+		}
+		return nil, fmt.Errorf("failed to record expense: %w", err)
+	}
+	expense.ID = id
+
+	if expense.ReceiptURL, err = s.imageService.GetImageURL(ctx, receiptKey); err != nil {
+		logger.WithError(err).WithField("image_key", receiptKey).Warn("Failed to generate receipt URL")
+	}
+
+	logger.WithField("expense_id", id).Info("Reserve fund expense recorded")
+	return &expense, nil
+}
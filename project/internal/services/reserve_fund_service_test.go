@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetReserveFund(t *testing.T) {
+	expenseID, billID, resident := 3, 11, 2
+	mockRepo := new(repositories.MockReserveFundRepository)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockImage := new(image.MockImage)
+	mockUserAptRepo.On("IsUserInApartment", mock.Anything, 2, 7).Return(true, nil)
+	mockUserAptRepo.On("IsUserInApartment", mock.Anything, 9, 7).Return(false, nil)
+	mockRepo.On("GetBalances", 7).Return([]models.ReserveBalance{
+		{Currency: money.IRR, Contributions: 500000, Expenses: 200000, Balance: 300000},
+	}, nil)
+	mockRepo.On("GetTransactions", 7).Return([]models.ReserveTransaction{
+		{Type: models.ReserveExpense, Amount: 200000, Currency: money.IRR, ExpenseID: &expenseID, Description: "roof repair", ReceiptKey: "bills/1_roof.pdf"},
+		{Type: models.ReserveContribution, Amount: 500000, Currency: money.IRR, BillID: &billID, UserID: &resident},
+	}, nil)
+	mockImage.On("GetImageURL", mock.Anything, "bills/1_roof.pdf").Return("https://minio/roof.pdf", nil).Once()
+
+	service := NewReserveFundService(mockRepo, mockUserAptRepo, mockImage)
+
+	fund, err := service.GetReserveFund(context.Background(), 2, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(300000), fund.Balances[0].Balance)
+	assert.Equal(t, "https://minio/roof.pdf", fund.Transactions[0].ReceiptURL)
+	assert.Empty(t, fund.Transactions[1].ReceiptURL)
+	mockImage.AssertExpectations(t)
+
+	_, err = service.GetReserveFund(context.Background(), 9, 7)
+	assert.ErrorContains(t, err, "not a member")
+}
+
+func TestRecordCapitalExpense(t *testing.T) {
+	receipt := &multipart.FileHeader{Filename: "roof.pdf"}
+
+	tests := []struct {
+		name          string
+		req           dto.CapitalExpenseRequest
+		withReceipt   bool
+		repoErr       error
+		expectedError string
+	}{
+		{name: "recorded with its receipt", req: dto.CapitalExpenseRequest{Amount: 200000, Description: "roof repair", SpentAt: "2025-03-02"}, withReceipt: true},
+		{name: "missing receipt", req: dto.CapitalExpenseRequest{Amount: 200000, Description: "roof repair"}, expectedError: "need a receipt"},
+		{name: "missing description", req: dto.CapitalExpenseRequest{Amount: 200000}, withReceipt: true, expectedError: "need a description"},
+		{name: "future date", req: dto.CapitalExpenseRequest{Amount: 200000, Description: "roof repair", SpentAt: "2999-01-01"}, withReceipt: true, expectedError: "future"},
+		{name: "more than the fund holds", req: dto.CapitalExpenseRequest{Amount: 200000, Description: "roof repair"}, withReceipt: true, repoErr: repositories.ErrInsufficientReserve, expectedError: "enough"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockReserveFundRepository)
+			mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+			mockImage := new(image.MockImage)
+			mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+			mockImage.On("SaveImage", mock.Anything, []byte("receipt"), "roof.pdf").Return("bills/1_roof.pdf", nil)
+			mockImage.On("GetImageURL", mock.Anything, "bills/1_roof.pdf").Return("https://minio/roof.pdf", nil)
+			mockImage.On("DeleteImage", mock.Anything, "bills/1_roof.pdf").Return(nil)
+			mockRepo.On("RecordExpense", mock.Anything, mock.MatchedBy(func(e models.CapitalExpense) bool {
+				return e.ApartmentID == 7 && e.Amount == 200000 && e.Currency == money.IRR && e.ReceiptKey == "bills/1_roof.pdf" && e.CreatedBy == 1
+			}), mock.MatchedBy(func(e *models.JournalEntry) bool {
+				return e.EntryType == models.CapitalExpenseEntry &&
+					e.Lines[0].Account == models.ReserveFund && e.Lines[0].Debit == 200000 &&
+					e.Lines[1].Account == models.ApartmentFund && e.Lines[1].Credit == 200000
+			})).Return(3, tt.repoErr)
+
+			var file io.ReadCloser
+			if tt.withReceipt {
+				file = io.NopCloser(strings.NewReader("receipt"))
+			}
+
+			service := NewReserveFundService(mockRepo, mockUserAptRepo, mockImage)
+			expense, err := service.RecordExpense(context.Background(), 1, 7, tt.req, file, receipt)
+
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				if tt.repoErr != nil {
+					mockImage.AssertCalled(t, "DeleteImage", mock.Anything, "bills/1_roof.pdf")
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 3, expense.ID)
+			assert.Equal(t, "https://minio/roof.pdf", expense.ReceiptURL)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
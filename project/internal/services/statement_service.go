@@ -0,0 +1,334 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/pdf"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// account statements of residents, read from their receivable account in the ledger. a statement
+// can be exported as CSV, or generated as a PDF that is kept in storage to be downloaded again
+type StatementService interface {
+	GetStatement(ctx context.Context, userID, apartmentID int, period dto.StatementPeriod) (*models.Statement, error)
+	ExportCSV(ctx context.Context, userID, apartmentID int, period dto.StatementPeriod) ([]byte, error)
+	GeneratePDF(ctx context.Context, userID, apartmentID int, period dto.StatementPeriod) (*models.StatementDocument, error)
+	GetStatementDocuments(ctx context.Context, userID int) ([]models.StatementDocument, error)
+	GetStatementDocument(ctx context.Context, userID, documentID int) (*models.StatementDocument, error)
+	SendStatementDocument(ctx context.Context, userID, documentID int) error
+}
+
+type statementServiceImpl struct {
+	repo                repositories.StatementRepository
+	ledgerRepo          repositories.LedgerRepository
+	apartmentRepo       repositories.ApartmentRepository
+	userRepo            repositories.UserRepository
+	userApartmentRepo   repositories.UserApartmentRepository
+	imageService        image.Image
+	notificationService notification.Notification
+}
+
+func NewStatementService(
+	repo repositories.StatementRepository,
+	ledgerRepo repositories.LedgerRepository,
+	apartmentRepo repositories.ApartmentRepository,
+	userRepo repositories.UserRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	imageService image.Image,
+	notificationService notification.Notification,
+) StatementService {
+	return &statementServiceImpl{
+		repo:                repo,
+		ledgerRepo:          ledgerRepo,
+		apartmentRepo:       apartmentRepo,
+		userRepo:            userRepo,
+		userApartmentRepo:   userApartmentRepo,
+		imageService:        imageService,
+		notificationService: notificationService,
+	}
+}
+
+// the opening balance is everything posted before the period, then every charge and payment in it
+func (s *statementServiceImpl) GetStatement(ctx context.Context, userID, apartmentID int, period dto.StatementPeriod) (*models.Statement, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"apartment_id": apartmentID,
+	})
+
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+	from, to, err := statementPeriod(period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	apartment, err := s.apartmentRepo.GetApartmentByID(apartmentID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get apartment")
+		return nil, fmt.Errorf("failed to get apartment: %w", err)
+	}
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get user")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	opening, err := s.ledgerRepo.GetAccountBalancesAt(apartmentID, models.ResidentReceivable, &userID, from)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get opening balance")
+		return nil, fmt.Errorf("failed to get opening balance: %w", err)
+	}
+	lines, err := s.ledgerRepo.GetAccountLinesBetween(apartmentID, models.ResidentReceivable, &userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		logger.WithError(err).Error("Failed to get statement lines")
+		return nil, fmt.Errorf("failed to get statement lines: %w", err)
+	}
+
+	statement := &models.Statement{
+		ApartmentID:   apartmentID,
+		ApartmentName: apartment.ApartmentName,
+		UserID:        userID,
+		ResidentName:  user.Username,
+		From:          from,
+		To:            to,
+		Sections:      statementSections(opening, lines),
+	}
+	if user.FullName != "" {
+		statement.ResidentName = user.FullName
+	}
+	return statement, nil
+}
+
+func (s *statementServiceImpl) ExportCSV(ctx context.Context, userID, apartmentID int, period dto.StatementPeriod) ([]byte, error) {
+	statement, err := s.GetStatement(ctx, userID, apartmentID, period)
+	if err != nil {
+		return nil, err
+	}
+	return statementCSV(statement)
+}
+
+func (s *statementServiceImpl) GeneratePDF(ctx context.Context, userID, apartmentID int, period dto.StatementPeriod) (*models.StatementDocument, error) {
+	statement, err := s.GetStatement(ctx, userID, apartmentID, period)
+	if err != nil {
+		return nil, err
+	}
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"apartment_id": apartmentID,
+	})
+
+	filename := fmt.Sprintf("statement_%d_%d_%s_%s.pdf", apartmentID, userID, statement.From.Format("20060102"), statement.To.Format("20060102"))
+	documentKey, err := s.imageService.SaveImage(ctx, statementPDF(statement), filename)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save statement")
+		return nil, fmt.Errorf("failed to save statement: %w", err)
+	}
+
+	document := models.StatementDocument{
+		ApartmentID: apartmentID,
+		UserID:      userID,
+		PeriodFrom:  statement.From,
+		PeriodTo:    statement.To,
+		DocumentKey: documentKey,
+	}
+	id, err := s.repo.CreateStatementDocument(ctx, document)
+	if err != nil {
+		logger.WithError(err).Error("Failed to store statement document")
+		if delErr := s.imageService.DeleteImage(ctx, documentKey); delErr != nil {
+			logger.WithError(delErr).WithField("image_key", documentKey).Error("Failed to cleanup statement after store failure")
+		}
+		return nil, fmt.Errorf("failed to store statement: %w", err)
+	}
+	document.ID = id
+	document.CreatedAt = time.Now()
+	s.presign(ctx, &document)
+
+	logger.WithField("statement_id", id).Info("Statement generated")
+	return &document, nil
+}
+
+func (s *statementServiceImpl) GetStatementDocuments(ctx context.Context, userID int) ([]models.StatementDocument, error) {
+	documents, err := s.repo.GetStatementDocumentsByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statements: %w", err)
+	}
+	for i := range documents {
+		s.presign(ctx, &documents[i])
+	}
+	if documents == nil {
+		documents = []models.StatementDocument{}
+	}
+	return documents, nil
+}
+
+// residents only get their own statements
+func (s *statementServiceImpl) GetStatementDocument(ctx context.Context, userID, documentID int) (*models.StatementDocument, error) {
+	document, err := s.repo.GetStatementDocument(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("statement not found: %w", err)
+	}
+	if document.UserID != userID {
+		return nil, fmt.Errorf("statement not found")
+	}
+	s.presign(ctx, document)
+	return document, nil
+}
+
+// sends a download link over telegram, it stays valid as long as presigned urls do
+func (s *statementServiceImpl) SendStatementDocument(ctx context.Context, userID, documentID int) error {
+	document, err := s.GetStatementDocument(ctx, userID, documentID)
+	if err != nil {
+		return err
+	}
+	if document.DocumentURL == "" {
+		return fmt.Errorf("failed to generate a download link for the statement")
+	}
+
+	message := fmt.Sprintf("📄 *Account statement*\n\n%s to %s\n\n[Download the PDF](%s)",
+		document.PeriodFrom.Format("2006-01-02"), document.PeriodTo.Format("2006-01-02"), document.DocumentURL)
+	if err := s.notificationService.SendNotification(ctx, userID, message); err != nil {
+		return fmt.Errorf("failed to send statement: %w", err)
+	}
+	return nil
+}
+
+func (s *statementServiceImpl) presign(ctx context.Context, document *models.StatementDocument) {
+	var err error
+	if document.DocumentURL, err = s.imageService.GetImageURL(ctx, document.DocumentKey); err != nil {
+		logrus.WithError(err).WithField("image_key", document.DocumentKey).Warn("Failed to generate statement URL")
+	}
+}
+
+func statementPeriod(period dto.StatementPeriod, now time.Time) (from, to time.Time, err error) {
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if period.To != "" {
+		if to, err = time.Parse("2006-01-02", period.To); err != nil {
+			return from, to, fmt.Errorf("invalid to date format (use YYYY-MM-DD)")
+		}
+	}
+	from = time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if period.From != "" {
+		if from, err = time.Parse("2006-01-02", period.From); err != nil {
+			return from, to, fmt.Errorf("invalid from date format (use YYYY-MM-DD)")
+		}
+	}
+	if from.After(to) {
+		return from, to, fmt.Errorf("from date must not be after to date")
+	}
+	return from, to, nil
+}
+
+// one section per currency the resident has a balance or lines in
+func statementSections(opening []models.AccountBalance, lines []models.AccountLine) []models.StatementSection {
+	byCurrency := make(map[money.Currency]*models.StatementSection)
+	section := func(currency money.Currency) *models.StatementSection {
+		if byCurrency[currency] == nil {
+			byCurrency[currency] = &models.StatementSection{Currency: currency, Lines: []models.StatementLine{}}
+		}
+		return byCurrency[currency]
+	}
+
+	for _, balance := range opening {
+		section(balance.Currency).OpeningBalance += balance.Balance
+	}
+	for _, line := range lines {
+		current := section(line.Currency)
+		current.Lines = append(current.Lines, models.StatementLine{
+			Date:        line.CreatedAt,
+			EntryType:   line.EntryType,
+			Reference:   line.Reference,
+			Description: line.Description,
+			Charge:      line.Debit,
+			Payment:     line.Credit,
+			Balance:     line.RunningBalance,
+		})
+		current.Charges += line.Debit
+		current.Payments += line.Credit
+	}
+
+	sections := make([]models.StatementSection, 0, len(byCurrency))
+	for _, current := range byCurrency {
+		current.ClosingBalance = current.OpeningBalance + current.Charges - current.Payments
+		sections = append(sections, *current)
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Currency < sections[j].Currency })
+	return sections
+}
+
+func statementCSV(statement *models.Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"currency", "date", "type", "reference", "description", "charge", "payment", "balance"})
+	for _, section := range statement.Sections {
+		currency := string(section.Currency)
+		w.Write([]string{currency, statement.From.Format("2006-01-02"), "opening_balance", "", "", "", "", section.OpeningBalance.String()})
+		for _, line := range section.Lines {
+			w.Write([]string{currency, line.Date.Format("2006-01-02"), string(line.EntryType), line.Reference, line.Description,
+				line.Charge.String(), line.Payment.String(), line.Balance.String()})
+		}
+		w.Write([]string{currency, statement.To.Format("2006-01-02"), "closing_balance", "", "",
+			section.Charges.String(), section.Payments.String(), section.ClosingBalance.String()})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write statement: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func statementPDF(statement *models.Statement) []byte {
+	const row = "%-10s  %-30s  %14s  %14s  %14s"
+
+	doc := pdf.New()
+	doc.Line("ACCOUNT STATEMENT")
+	doc.Line("")
+	doc.Line("Apartment: %s", statement.ApartmentName)
+	doc.Line("Resident:  %s", statement.ResidentName)
+	doc.Line("Period:    %s to %s", statement.From.Format("2006-01-02"), statement.To.Format("2006-01-02"))
+	if len(statement.Sections) == 0 {
+		doc.Line("")
+		doc.Line("Nothing was charged or paid in this period.")
+	}
+	for _, section := range statement.Sections {
+		doc.Line("")
+		doc.Line("Currency: %s", section.Currency)
+		doc.Line(row, "Date", "Description", "Charge", "Payment", "Balance")
+		doc.Line(row, statement.From.Format("2006-01-02"), "Opening balance", "", "", section.OpeningBalance)
+		for _, line := range section.Lines {
+			description := line.Description
+			if description == "" {
+				description = string(line.EntryType)
+			}
+			doc.Line(row, line.Date.Format("2006-01-02"), truncate(description, 30), blankIfZero(line.Charge), blankIfZero(line.Payment), line.Balance)
+		}
+		doc.Line(row, statement.To.Format("2006-01-02"), "Closing balance", section.Charges, section.Payments, section.ClosingBalance)
+	}
+	return doc.Bytes()
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+func blankIfZero(amount money.Amount) string {
+	if amount == 0 {
+		return ""
+	}
+	return amount.String()
+}
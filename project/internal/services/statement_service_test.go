@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/image"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func statementMocks() (*repositories.MockStatementRepository, *repositories.MockLedgerRepository, *image.MockImage, StatementService) {
+	resident := 2
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(repositories.MockStatementRepository)
+	mockLedgerRepo := new(repositories.MockLedgerRepository)
+	mockApartmentRepo := new(repositories.MockApartmentRepo)
+	mockUserRepo := new(repositories.MockUserRepository)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockImage := new(image.MockImage)
+
+	mockUserAptRepo.On("IsUserInApartment", mock.Anything, 2, 7).Return(true, nil)
+	mockUserAptRepo.On("IsUserInApartment", mock.Anything, 9, 7).Return(false, nil)
+	mockApartmentRepo.On("GetApartmentByID", 7).Return(&models.Apartment{BaseModel: models.BaseModel{ID: 7}, ApartmentName: "Sunrise"}, nil)
+	mockUserRepo.On("GetUserByID", 2).Return(&models.User{Username: "sara", FullName: "Sara Ahmadi"}, nil)
+	mockLedgerRepo.On("GetAccountBalancesAt", 7, models.ResidentReceivable, &resident, from).Return([]models.AccountBalance{
+		{Account: models.ResidentReceivable, Currency: money.IRR, Balance: 3000},
+		{Account: models.ResidentReceivable, Currency: money.USD, Balance: 500},
+	}, nil)
+	mockLedgerRepo.On("GetAccountLinesBetween", 7, models.ResidentReceivable, &resident, from, to).Return([]models.AccountLine{
+		{EntryID: 5, EntryType: models.DivisionEntry, Reference: "bill:11", Description: "water bill", Currency: money.IRR, Debit: 20000, RunningBalance: 23000, CreatedAt: from.AddDate(0, 0, 2)},
+		{EntryID: 6, EntryType: models.PaymentEntry, Reference: "transaction:4", Description: "Paid through simulator", Currency: money.IRR, Credit: 18000, RunningBalance: 5000, CreatedAt: from.AddDate(0, 0, 9)},
+	}, nil)
+
+	service := NewStatementService(mockRepo, mockLedgerRepo, mockApartmentRepo, mockUserRepo, mockUserAptRepo, mockImage, nil)
+	return mockRepo, mockLedgerRepo, mockImage, service
+}
+
+func TestGetStatement(t *testing.T) {
+	_, _, _, service := statementMocks()
+	period := dto.StatementPeriod{From: "2025-03-01", To: "2025-03-31"}
+
+	statement, err := service.GetStatement(context.Background(), 2, 7, period)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Sara Ahmadi", statement.ResidentName)
+	assert.Len(t, statement.Sections, 2)
+	irr := statement.Sections[0]
+	assert.Equal(t, money.IRR, irr.Currency)
+	assert.Equal(t, money.Amount(3000), irr.OpeningBalance)
+	assert.Equal(t, money.Amount(20000), irr.Charges)
+	assert.Equal(t, money.Amount(18000), irr.Payments)
+	assert.Equal(t, money.Amount(5000), irr.ClosingBalance)
+	assert.Equal(t, irr.Lines[1].Balance, irr.ClosingBalance)
+	usd := statement.Sections[1]
+	assert.Empty(t, usd.Lines, "a currency with only an opening balance is still listed")
+	assert.Equal(t, money.Amount(500), usd.ClosingBalance)
+
+	_, err = service.GetStatement(context.Background(), 9, 7, period)
+	assert.ErrorContains(t, err, "not a member")
+	_, err = service.GetStatement(context.Background(), 2, 7, dto.StatementPeriod{From: "2025-04-01", To: "2025-03-31"})
+	assert.ErrorContains(t, err, "must not be after")
+}
+
+func TestStatementPeriod(t *testing.T) {
+	now := time.Date(2025, 3, 17, 15, 4, 0, 0, time.UTC)
+
+	from, to, err := statementPeriod(dto.StatementPeriod{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC), to)
+
+	_, _, err = statementPeriod(dto.StatementPeriod{From: "03/01/2025"}, now)
+	assert.ErrorContains(t, err, "invalid from date")
+}
+
+func TestExportStatementCSV(t *testing.T) {
+	_, _, _, service := statementMocks()
+
+	out, err := service.ExportCSV(context.Background(), 2, 7, dto.StatementPeriod{From: "2025-03-01", To: "2025-03-31"})
+
+	assert.NoError(t, err)
+	rows := strings.Split(strings.TrimSpace(string(out)), "\n")
+	assert.Equal(t, "currency,date,type,reference,description,charge,payment,balance", rows[0])
+	assert.Equal(t, "IRR,2025-03-01,opening_balance,,,,,30.00", rows[1])
+	assert.Equal(t, "IRR,2025-03-03,division,bill:11,water bill,200.00,0.00,230.00", rows[2])
+	assert.Equal(t, "IRR,2025-03-31,closing_balance,,,200.00,180.00,50.00", rows[4])
+	assert.Len(t, rows, 7)
+}
+
+func TestGenerateStatementPDF(t *testing.T) {
+	period := dto.StatementPeriod{From: "2025-03-01", To: "2025-03-31"}
+
+	t.Run("stored and presigned", func(t *testing.T) {
+		mockRepo, _, mockImage, service := statementMocks()
+		mockImage.On("SaveImage", mock.Anything, mock.MatchedBy(func(data []byte) bool {
+			return bytes.HasPrefix(data, []byte("%PDF")) && bytes.Contains(data, []byte("Sara Ahmadi"))
+		}), "statement_7_2_20250301_20250331.pdf").Return("bills/1_statement.pdf", nil)
+		mockRepo.On("CreateStatementDocument", mock.Anything, mock.MatchedBy(func(d models.StatementDocument) bool {
+			return d.UserID == 2 && d.DocumentKey == "bills/1_statement.pdf"
+		})).Return(4, nil)
+		mockImage.On("GetImageURL", mock.Anything, "bills/1_statement.pdf").Return("https://minio/statement.pdf", nil)
+
+		document, err := service.GeneratePDF(context.Background(), 2, 7, period)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, document.ID)
+		assert.Equal(t, "https://minio/statement.pdf", document.DocumentURL)
+	})
+
+	t.Run("upload removed when it can't be stored", func(t *testing.T) {
+		mockRepo, _, mockImage, service := statementMocks()
+		mockImage.On("SaveImage", mock.Anything, mock.Anything, mock.Anything).Return("bills/1_statement.pdf", nil)
+		mockRepo.On("CreateStatementDocument", mock.Anything, mock.Anything).Return(0, sql.ErrConnDone)
+		mockImage.On("DeleteImage", mock.Anything, "bills/1_statement.pdf").Return(nil).Once()
+
+		_, err := service.GeneratePDF(context.Background(), 2, 7, period)
+
+		assert.Error(t, err)
+		mockImage.AssertExpectations(t)
+	})
+}
+
+func TestSendStatementDocument(t *testing.T) {
+	mockRepo := new(repositories.MockStatementRepository)
+	mockImage := new(image.MockImage)
+	mockNotification := new(notification.MockNotification)
+	mockRepo.On("GetStatementDocument", 4).Return(&models.StatementDocument{
+		BaseModel: models.BaseModel{ID: 4}, UserID: 2, DocumentKey: "bills/1_statement.pdf",
+		PeriodFrom: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), PeriodTo: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+	}, nil)
+	mockImage.On("GetImageURL", mock.Anything, "bills/1_statement.pdf").Return("https://minio/statement.pdf", nil)
+	mockNotification.On("SendNotification", mock.Anything, 2, mock.MatchedBy(func(message string) bool {
+		return strings.Contains(message, "https://minio/statement.pdf") && strings.Contains(message, "2025-03-01 to 2025-03-31")
+	})).Return(nil).Once()
+
+	service := NewStatementService(mockRepo, nil, nil, nil, nil, mockImage, mockNotification)
+
+	assert.NoError(t, service.SendStatementDocument(context.Background(), 2, 4))
+	assert.ErrorContains(t, service.SendStatementDocument(context.Background(), 3, 4), "not found", "residents only get their own statements")
+	mockNotification.AssertExpectations(t)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStatsMonths = 12
+	maxStatsMonths     = 60
+	topDebtorsLimit    = 5
+)
+
+// labels of the buckets the stats repository counts days to pay in
+var daysToPayBuckets = []string{"0-7", "8-14", "15-30", "31-60", "60+"}
+
+type StatsService interface {
+	GetApartmentStats(ctx context.Context, managerID, apartmentID, months int) (*models.ApartmentStats, error)
+}
+
+type statsServiceImpl struct {
+	repo              repositories.StatsRepository
+	userApartmentRepo repositories.UserApartmentRepository
+}
+
+func NewStatsService(repo repositories.StatsRepository, userApartmentRepo repositories.UserApartmentRepository) StatsService {
+	return &statsServiceImpl{
+		repo:              repo,
+		userApartmentRepo: userApartmentRepo,
+	}
+}
+
+// covers the current month and the ones before it, 12 when months is 0
+func (s *statsServiceImpl) GetApartmentStats(ctx context.Context, managerID, apartmentID, months int) (*models.ApartmentStats, error) {
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("only apartment managers can view apartment stats")
+	}
+	if months == 0 {
+		months = defaultStatsMonths
+	}
+	if months < 1 || months > maxStatsMonths {
+		return nil, fmt.Errorf("months must be between 1 and %d", maxStatsMonths)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.UTC)
+	logger := logrus.WithField("apartment_id", apartmentID)
+
+	collection, err := s.repo.GetCollectionSummary(apartmentID, from, today)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get collection summary")
+		return nil, fmt.Errorf("failed to get collection summary: %w", err)
+	}
+	series, err := s.repo.GetBilledVsCollected(apartmentID, from)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get billed vs collected")
+		return nil, fmt.Errorf("failed to get billed vs collected: %w", err)
+	}
+	buckets, err := s.repo.GetDaysToPay(apartmentID, from)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get days to pay")
+		return nil, fmt.Errorf("failed to get days to pay: %w", err)
+	}
+	categories, err := s.repo.GetCategoryTotals(apartmentID, from)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get category totals")
+		return nil, fmt.Errorf("failed to get category totals: %w", err)
+	}
+	debtors, err := s.repo.GetTopDebtors(apartmentID, today, topDebtorsLimit)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get top debtors")
+		return nil, fmt.Errorf("failed to get top debtors: %w", err)
+	}
+
+	for i := range collection {
+		if collection[i].Charged > 0 {
+			rate := float64(collection[i].Collected) / float64(collection[i].Charged) * 100
+			collection[i].CollectionRate = math.Round(rate*10) / 10
+		}
+	}
+	if collection == nil {
+		collection = []models.CollectionSummary{}
+	}
+	if series == nil {
+		series = []models.MonthlyTotals{}
+	}
+	if categories == nil {
+		categories = []models.CategoryTotals{}
+	}
+	if debtors == nil {
+		debtors = []models.Debtor{}
+	}
+
+	return &models.ApartmentStats{
+		ApartmentID:       apartmentID,
+		From:              from,
+		Collection:        collection,
+		BilledVsCollected: series,
+		DaysToPay:         daysToPay(buckets),
+		Categories:        categories,
+		TopDebtors:        debtors,
+	}, nil
+}
+
+// every bucket is listed, the empty ones with zero payments
+func daysToPay(counted []models.DaysToPayBucket) models.DaysToPay {
+	stats := models.DaysToPay{Distribution: make([]models.DaysToPayBucket, len(daysToPayBuckets))}
+	for i, label := range daysToPayBuckets {
+		stats.Distribution[i] = models.DaysToPayBucket{Bucket: i + 1, Label: label}
+	}
+
+	totalDays := 0
+	for _, bucket := range counted {
+		if bucket.Bucket < 1 || bucket.Bucket > len(daysToPayBuckets) {
+			continue
+		}
+		bucket.Label = daysToPayBuckets[bucket.Bucket-1]
+		stats.Distribution[bucket.Bucket-1] = bucket
+		stats.Payments += bucket.Payments
+		stats.PaidLate += bucket.PaidLate
+		totalDays += bucket.TotalDays
+	}
+	if stats.Payments > 0 {
+		stats.AverageDays = math.Round(float64(totalDays)/float64(stats.Payments)*10) / 10
+	}
+	return stats
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetApartmentStats(t *testing.T) {
+	mockRepo := new(repositories.MockStatsRepository)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 2, 7).Return(false, nil)
+
+	mockRepo.On("GetCollectionSummary", 7, mock.Anything, mock.Anything).Return([]models.CollectionSummary{
+		{Currency: money.IRR, Charged: 300000, Collected: 200000, Outstanding: 100000, Overdue: 40000, OverduePayments: 2},
+	}, nil)
+	mockRepo.On("GetBilledVsCollected", 7, mock.Anything).Return(nil, nil)
+	mockRepo.On("GetDaysToPay", 7, mock.Anything).Return([]models.DaysToPayBucket{
+		{Bucket: 1, Payments: 3, TotalDays: 9},
+		{Bucket: 4, Payments: 1, TotalDays: 45, PaidLate: 1},
+	}, nil)
+	mockRepo.On("GetCategoryTotals", 7, mock.Anything).Return([]models.CategoryTotals{
+		{Category: models.WaterBill, Currency: money.IRR, Bills: 2, Billed: 300000, Collected: 200000},
+	}, nil)
+	mockRepo.On("GetTopDebtors", 7, mock.Anything, 5).Return([]models.Debtor{
+		{UserID: 3, Username: "reza", Currency: money.IRR, Outstanding: 100000, Overdue: 40000, OpenPayments: 2},
+	}, nil)
+
+	service := NewStatsService(mockRepo, mockUserAptRepo)
+
+	stats, err := service.GetApartmentStats(context.Background(), 1, 7, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 66.7, stats.Collection[0].CollectionRate)
+	assert.NotNil(t, stats.BilledVsCollected)
+	assert.Equal(t, 4, stats.DaysToPay.Payments)
+	assert.Equal(t, 13.5, stats.DaysToPay.AverageDays)
+	assert.Equal(t, 1, stats.DaysToPay.PaidLate)
+	assert.Len(t, stats.DaysToPay.Distribution, 5, "empty buckets are listed too")
+	assert.Equal(t, "31-60", stats.DaysToPay.Distribution[3].Label)
+	assert.Equal(t, 0, stats.DaysToPay.Distribution[1].Payments)
+	assert.Equal(t, 1, stats.From.Day())
+
+	_, err = service.GetApartmentStats(context.Background(), 2, 7, 0)
+	assert.ErrorContains(t, err, "only apartment managers")
+	_, err = service.GetApartmentStats(context.Background(), 1, 7, 61)
+	assert.ErrorContains(t, err, "months must be between")
+}
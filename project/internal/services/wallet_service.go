@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// prepaid credit per resident and apartment. top-ups go through the gateway, bills can then be
+// paid from the balance without a checkout
+type WalletService interface {
+	GetWallet(ctx context.Context, userID, apartmentID int) (*models.Wallet, error)
+	GetWallets(ctx context.Context, userID int) ([]models.Wallet, error)
+	GetTransactions(ctx context.Context, userID, apartmentID int) ([]models.WalletTransaction, error)
+	TopUp(ctx context.Context, userID, apartmentID int, amount money.Amount, idempotencyKey string) (*models.PaymentTransaction, error)
+	SetAutoPay(ctx context.Context, userID, apartmentID int, enabled bool) (*models.Wallet, error)
+	AdjustBalance(ctx context.Context, managerID, apartmentID, residentID int, req dto.WalletAdjustmentRequest) (*models.WalletTransaction, error)
+	PayFromWallet(ctx context.Context, userID, apartmentID int, currency money.Currency, items []models.TransactionItem, idempotencyKey string) (*models.PaymentTransaction, error)
+	AutoPayShares(ctx context.Context, apartmentID int, shares []models.Payment) int
+}
+
+type walletServiceImpl struct {
+	repo                   repositories.WalletRepository
+	paymentTransactionRepo repositories.PaymentTransactionRepository
+	userApartmentRepo      repositories.UserApartmentRepository
+	checkoutService        CheckoutService
+	notificationService    notification.Notification
+}
+
+func NewWalletService(
+	repo repositories.WalletRepository,
+	paymentTransactionRepo repositories.PaymentTransactionRepository,
+	userApartmentRepo repositories.UserApartmentRepository,
+	checkoutService CheckoutService,
+	notificationService notification.Notification,
+) WalletService {
+	return &walletServiceImpl{
+		repo:                   repo,
+		paymentTransactionRepo: paymentTransactionRepo,
+		userApartmentRepo:      userApartmentRepo,
+		checkoutService:        checkoutService,
+		notificationService:    notificationService,
+	}
+}
+
+// members get an empty wallet the first time they look at it
+func (s *walletServiceImpl) GetWallet(ctx context.Context, userID, apartmentID int) (*models.Wallet, error) {
+	if ok, err := s.userApartmentRepo.IsUserInApartment(ctx, userID, apartmentID); err != nil || !ok {
+		return nil, fmt.Errorf("user is not a member of this apartment")
+	}
+
+	wallet, err := s.repo.GetOrCreateWallet(ctx, userID, apartmentID, money.DefaultCurrency)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":      userID,
+			"apartment_id": apartmentID,
+		}).Error("Failed to get wallet")
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	return wallet, nil
+}
+
+func (s *walletServiceImpl) GetWallets(ctx context.Context, userID int) ([]models.Wallet, error) {
+	wallets, err := s.repo.GetWalletsByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+	return wallets, nil
+}
+
+func (s *walletServiceImpl) GetTransactions(ctx context.Context, userID, apartmentID int) ([]models.WalletTransaction, error) {
+	wallet, err := s.GetWallet(ctx, userID, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.GetTransactions(wallet.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet transactions: %w", err)
+	}
+	return entries, nil
+}
+
+// starts a gateway checkout, the balance is credited once the gateway confirms the payment
+func (s *walletServiceImpl) TopUp(ctx context.Context, userID, apartmentID int, amount money.Amount, idempotencyKey string) (*models.PaymentTransaction, error) {
+	wallet, err := s.GetWallet(ctx, userID, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.checkoutService.StartTopUp(ctx, userID, wallet.ID, wallet.Currency, amount, idempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("top-up failed: %w", err)
+	}
+	return transaction, nil
+}
+
+func (s *walletServiceImpl) SetAutoPay(ctx context.Context, userID, apartmentID int, enabled bool) (*models.Wallet, error) {
+	wallet, err := s.GetWallet(ctx, userID, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetAutoPay(ctx, wallet.ID, enabled); err != nil {
+		return nil, fmt.Errorf("failed to update auto-pay: %w", err)
+	}
+	wallet.AutoPay = enabled
+	return wallet, nil
+}
+
+func (s *walletServiceImpl) AdjustBalance(ctx context.Context, managerID, apartmentID, residentID int, req dto.WalletAdjustmentRequest) (*models.WalletTransaction, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":      managerID,
+		"apartment_id": apartmentID,
+		"resident_id":  residentID,
+		"amount":       req.Amount,
+	})
+
+	if ok, err := s.userApartmentRepo.IsUserManagerOfApartment(ctx, managerID, apartmentID); err != nil || !ok {
+		logger.Warn("Non-manager user attempted to adjust a wallet")
+		return nil, fmt.Errorf("only apartment managers can adjust wallets")
+	}
+	if req.Amount == 0 {
+		return nil, fmt.Errorf("adjustment amount cannot be zero")
+	}
+	if req.Note == "" {
+		return nil, fmt.Errorf("adjustments need a note")
+	}
+
+	wallet, err := s.GetWallet(ctx, residentID, apartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := s.repo.AddTransaction(ctx, models.WalletTransaction{
+		WalletID: wallet.ID,
+		Type:     models.WalletAdjustment,
+		Amount:   req.Amount,
+		Note:     req.Note,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to adjust wallet")
+		return nil, fmt.Errorf("failed to adjust wallet: %w", err)
+	}
+
+	logger.WithField("balance", entry.BalanceAfter).Info("Wallet adjusted")
+	return entry, nil
+}
+
+// settles the items from the resident's wallet in the apartment, all or nothing
+func (s *walletServiceImpl) PayFromWallet(ctx context.Context, userID, apartmentID int, currency money.Currency, items []models.TransactionItem, idempotencyKey string) (*models.PaymentTransaction, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"apartment_id":    apartmentID,
+		"idempotency_key": idempotencyKey,
+	})
+
+	if existing, err := s.paymentTransactionRepo.GetTransactionByIdempotencyKey(userID, idempotencyKey); err == nil {
+		logger.WithField("transaction_id", existing.ID).Info("Returning existing payment for idempotency key")
+		return existing, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+
+	wallet, err := s.repo.GetWallet(userID, apartmentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.ErrInsufficientFunds
+		}
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	if wallet.Currency != currency {
+		return nil, fmt.Errorf("wallet is in %s, payments are in %s", wallet.Currency, currency)
+	}
+
+	var amount money.Amount
+	for _, item := range items {
+		if item.Amount <= 0 {
+			return nil, fmt.Errorf("payment amounts must be positive")
+		}
+		amount += item.Amount
+	}
+	if amount > wallet.Balance {
+		return nil, repositories.ErrInsufficientFunds
+	}
+
+	id, err := s.repo.PayFromWallet(ctx, wallet.ID, models.PaymentTransaction{
+		UserID:         userID,
+		Amount:         amount,
+		Currency:       currency,
+		IdempotencyKey: idempotencyKey,
+		Items:          items,
+	})
+	if errors.Is(err, repositories.ErrDuplicateIdempotencyKey) {
+		// a concurrent request with the same key got there first
+		logger.Info("Returning payment of a concurrent request with the same idempotency key")
+		return s.paymentTransactionRepo.GetTransactionByIdempotencyKey(userID, idempotencyKey)
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Wallet payment failed")
+		return nil, fmt.Errorf("wallet payment failed: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"transaction_id": id,
+		"amount":         amount,
+	}).Info("Paid from wallet")
+	return s.paymentTransactionRepo.GetTransactionByID(id)
+}
+
+// pays freshly created shares for residents who turned auto-pay on and have enough balance,
+// returns how many were paid. shares that can't be covered stay pending
+func (s *walletServiceImpl) AutoPayShares(ctx context.Context, apartmentID int, shares []models.Payment) int {
+	wallets := make(map[int]*models.Wallet)
+	paid := 0
+	for _, share := range shares {
+		logger := logrus.WithFields(logrus.Fields{
+			"apartment_id": apartmentID,
+			"resident_id":  share.UserID,
+			"payment_id":   share.ID,
+		})
+
+		wallet, ok := wallets[share.UserID]
+		if !ok {
+			wallet, _ = s.repo.GetWallet(share.UserID, apartmentID)
+			wallets[share.UserID] = wallet
+		}
+		if wallet == nil || !wallet.AutoPay || wallet.Currency != share.Currency {
+			continue
+		}
+		if wallet.Balance < share.Outstanding() {
+			logger.Info("Wallet balance too low for auto-pay")
+			continue
+		}
+
+		items := []models.TransactionItem{{PaymentID: share.ID, Amount: share.Outstanding()}}
+		if _, err := s.PayFromWallet(ctx, share.UserID, apartmentID, share.Currency, items, fmt.Sprintf("auto-pay-%d", share.ID)); err != nil {
+			logger.WithError(err).Warn("Auto-pay failed")
+			continue
+		}
+		wallet.Balance -= share.Outstanding()
+		paid++
+
+		message := fmt.Sprintf("Your share of %s %s was paid automatically from your wallet.", share.Outstanding(), share.Currency)
+		if err := s.notificationService.SendNotification(ctx, share.UserID, message); err != nil {
+			logger.WithError(err).Warn("Failed to send notification")
+		}
+	}
+	return paid
+}
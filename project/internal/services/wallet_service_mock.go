@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWalletService struct {
+	mock.Mock
+}
+
+func (m *MockWalletService) GetWallet(ctx context.Context, userID, apartmentID int) (*models.Wallet, error) {
+	args := m.Called(ctx, userID, apartmentID)
+	if wallet, ok := args.Get(0).(*models.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) GetWallets(ctx context.Context, userID int) ([]models.Wallet, error) {
+	args := m.Called(ctx, userID)
+	if wallets, ok := args.Get(0).([]models.Wallet); ok {
+		return wallets, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) GetTransactions(ctx context.Context, userID, apartmentID int) ([]models.WalletTransaction, error) {
+	args := m.Called(ctx, userID, apartmentID)
+	if entries, ok := args.Get(0).([]models.WalletTransaction); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) TopUp(ctx context.Context, userID, apartmentID int, amount money.Amount, idempotencyKey string) (*models.PaymentTransaction, error) {
+	args := m.Called(ctx, userID, apartmentID, amount, idempotencyKey)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) SetAutoPay(ctx context.Context, userID, apartmentID int, enabled bool) (*models.Wallet, error) {
+	args := m.Called(ctx, userID, apartmentID, enabled)
+	if wallet, ok := args.Get(0).(*models.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) AdjustBalance(ctx context.Context, managerID, apartmentID, residentID int, req dto.WalletAdjustmentRequest) (*models.WalletTransaction, error) {
+	args := m.Called(ctx, managerID, apartmentID, residentID, req)
+	if entry, ok := args.Get(0).(*models.WalletTransaction); ok {
+		return entry, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) PayFromWallet(ctx context.Context, userID, apartmentID int, currency money.Currency, items []models.TransactionItem, idempotencyKey string) (*models.PaymentTransaction, error) {
+	args := m.Called(ctx, userID, apartmentID, currency, items, idempotencyKey)
+	if transaction, ok := args.Get(0).(*models.PaymentTransaction); ok {
+		return transaction, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletService) AutoPayShares(ctx context.Context, apartmentID int, shares []models.Payment) int {
+	args := m.Called(ctx, apartmentID, shares)
+	return args.Int(0)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/dto"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/models"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/money"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/notification"
+	"github.com/nedaZarei/arcaptcha-internship-2025/neda-arcaptcha-internship-2025/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPayFromWallet(t *testing.T) {
+	items := []models.TransactionItem{{PaymentID: 5, Amount: 3000}}
+
+	tests := []struct {
+		name          string
+		wallet        *models.Wallet
+		existing      *models.PaymentTransaction
+		expectedError error
+	}{
+		{
+			name:   "pays from the balance",
+			wallet: &models.Wallet{BaseModel: models.BaseModel{ID: 3}, Currency: money.IRR, Balance: 5000},
+		},
+		{
+			name:          "balance too low",
+			wallet:        &models.Wallet{BaseModel: models.BaseModel{ID: 3}, Currency: money.IRR, Balance: 2000},
+			expectedError: repositories.ErrInsufficientFunds,
+		},
+		{
+			name:     "retried request",
+			existing: &models.PaymentTransaction{BaseModel: models.BaseModel{ID: 7}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(repositories.MockWalletRepository)
+			mockTxRepo := new(repositories.MockPaymentTransactionRepository)
+
+			if tt.existing != nil {
+				mockTxRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(tt.existing, nil)
+			} else {
+				mockTxRepo.On("GetTransactionByIdempotencyKey", 2, "idemp123").Return(nil, sql.ErrNoRows)
+				mockRepo.On("GetWallet", 2, 7).Return(tt.wallet, nil)
+			}
+			mockRepo.On("PayFromWallet", mock.Anything, 3, mock.MatchedBy(func(transaction models.PaymentTransaction) bool {
+				return transaction.Amount == 3000 && transaction.IdempotencyKey == "idemp123"
+			})).Return(7, nil)
+			mockTxRepo.On("GetTransactionByID", 7).Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 7}}, nil)
+
+			service := NewWalletService(mockRepo, mockTxRepo, nil, nil, nil)
+			transaction, err := service.PayFromWallet(context.Background(), 2, 7, money.IRR, items, "idemp123")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "PayFromWallet", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 7, transaction.ID)
+			if tt.existing != nil {
+				mockRepo.AssertNotCalled(t, "PayFromWallet", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAutoPayShares(t *testing.T) {
+	mockRepo := new(repositories.MockWalletRepository)
+	mockTxRepo := new(repositories.MockPaymentTransactionRepository)
+	mockNotificationService := new(notification.MockNotification)
+	mockNotificationService.ExpectAnyNotificationCall(nil)
+
+	shares := []models.Payment{
+		{BaseModel: models.BaseModel{ID: 21}, UserID: 2, Amount: 3000, Currency: money.IRR},
+		{BaseModel: models.BaseModel{ID: 22}, UserID: 2, Amount: 3000, Currency: money.IRR},
+		{BaseModel: models.BaseModel{ID: 23}, UserID: 3, Amount: 3000, Currency: money.IRR},
+		{BaseModel: models.BaseModel{ID: 24}, UserID: 4, Amount: 3000, Currency: money.IRR},
+	}
+	mockRepo.On("GetWallet", 2, 7).Return(&models.Wallet{BaseModel: models.BaseModel{ID: 1}, UserID: 2, Currency: money.IRR, Balance: 4000, AutoPay: true}, nil)
+	mockRepo.On("GetWallet", 3, 7).Return(&models.Wallet{BaseModel: models.BaseModel{ID: 2}, UserID: 3, Currency: money.IRR, Balance: 9000}, nil)
+	mockRepo.On("GetWallet", 4, 7).Return(nil, sql.ErrNoRows)
+	mockTxRepo.On("GetTransactionByIdempotencyKey", 2, "auto-pay-21").Return(nil, sql.ErrNoRows)
+	mockRepo.On("PayFromWallet", mock.Anything, 1, mock.Anything).Return(30, nil).Once()
+	mockTxRepo.On("GetTransactionByID", 30).Return(&models.PaymentTransaction{BaseModel: models.BaseModel{ID: 30}}, nil)
+
+	service := NewWalletService(mockRepo, mockTxRepo, nil, nil, mockNotificationService)
+	paid := service.AutoPayShares(context.Background(), 7, shares)
+
+	assert.Equal(t, 1, paid, "the second share no longer fits, auto-pay off and no wallet are skipped")
+	mockRepo.AssertExpectations(t)
+	mockTxRepo.AssertExpectations(t)
+}
+
+func TestAdjustBalance(t *testing.T) {
+	mockRepo := new(repositories.MockWalletRepository)
+	mockUserAptRepo := new(repositories.MockUserApartmentRepository)
+
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 1, 7).Return(true, nil)
+	mockUserAptRepo.On("IsUserManagerOfApartment", mock.Anything, 2, 7).Return(false, nil)
+	mockUserAptRepo.On("IsUserInApartment", mock.Anything, 2, 7).Return(true, nil)
+	mockRepo.On("GetOrCreateWallet", mock.Anything, 2, 7, money.DefaultCurrency).Return(&models.Wallet{BaseModel: models.BaseModel{ID: 3}}, nil)
+	mockRepo.On("AddTransaction", mock.Anything, models.WalletTransaction{WalletID: 3, Type: models.WalletAdjustment, Amount: -500, Note: "duplicate top-up"}).
+		Return(&models.WalletTransaction{ID: 4, BalanceAfter: 1500}, nil)
+
+	service := NewWalletService(mockRepo, nil, mockUserAptRepo, nil, nil)
+
+	entry, err := service.AdjustBalance(context.Background(), 1, 7, 2, dto.WalletAdjustmentRequest{Amount: -500, Note: "duplicate top-up"})
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(1500), entry.BalanceAfter)
+
+	_, err = service.AdjustBalance(context.Background(), 1, 7, 2, dto.WalletAdjustmentRequest{Amount: 500})
+	assert.ErrorContains(t, err, "need a note")
+
+	_, err = service.AdjustBalance(context.Background(), 2, 7, 2, dto.WalletAdjustmentRequest{Amount: 500, Note: "self"})
+	assert.ErrorContains(t, err, "only apartment managers")
+}